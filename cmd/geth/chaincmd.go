@@ -89,6 +89,7 @@ The dumpgenesis command dumps the genesis block configuration in JSON format to
 			utils.SyncModeFlag,
 			utils.GCModeFlag,
 			utils.SnapshotFlag,
+			utils.SnapshotZktrieFlag,
 			utils.CacheDatabaseFlag,
 			utils.CacheGCFlag,
 			utils.MetricsEnabledFlag,
@@ -521,7 +521,14 @@ func dbDumpTrie(ctx *cli.Context) error {
 			return err
 		}
 	}
-	theTrie, err := trie.New(stRoot, trie.NewDatabase(db))
+	var theTrie interface {
+		NodeIterator(start []byte) trie.NodeIterator
+	}
+	if triedb := newStateTrieDatabase(db); triedb.Zktrie {
+		theTrie, err = trie.NewZkTrie(stRoot, trie.NewZktrieDatabaseFromTriedb(triedb))
+	} else {
+		theTrie, err = trie.New(stRoot, triedb)
+	}
 	if err != nil {
 		return err
 	}
@@ -95,6 +95,7 @@ var (
 		utils.ExitWhenSyncedFlag,
 		utils.GCModeFlag,
 		utils.SnapshotFlag,
+		utils.SnapshotZktrieFlag,
 		utils.TxLookupLimitFlag,
 		utils.LightServeFlag,
 		utils.LightIngressFlag,
@@ -132,6 +133,13 @@ var (
 		utils.MinerNoVerifyFlag,
 		utils.MinerStoreSkippedTxTracesFlag,
 		utils.MinerMaxAccountsNumFlag,
+		utils.MinerCCCMaxOverflowRetriesFlag,
+		utils.MinerMaxReorgDepthFlag,
+		utils.MinerL1MessageMaxAgeFlag,
+		utils.MinerL1MessageForcedPerBlockFlag,
+		utils.MinerOrderingFlag,
+		utils.MinerOrderingMaxTxsPerSenderFlag,
+		utils.MinerOrderingPriorityAddressesFlag,
 		utils.NATFlag,
 		utils.NoDiscoverFlag,
 		utils.DiscoveryV5Flag,
@@ -165,11 +173,20 @@ var (
 		configFileFlag,
 		utils.CatalystFlag,
 		utils.L1EndpointFlag,
+		utils.L1QuorumFlag,
 		utils.L1ConfirmationsFlag,
 		utils.L1DeploymentBlockFlag,
+		utils.L1FetchBlockRangeFlag,
+		utils.L1MaxFetchBlockRangeFlag,
+		utils.L1FetchConcurrencyFlag,
 		utils.CircuitCapacityCheckEnabledFlag,
 		utils.CircuitCapacityCheckWorkersFlag,
 		utils.RollupVerifyEnabledFlag,
+		utils.RollupVerifyFailurePolicyFlag,
+		utils.RollupDeriveEnabledFlag,
+		utils.RollupBlobDirFlag,
+		utils.RollupBlobBeaconNodeFlag,
+		utils.RollupBlobArchiveFlag,
 		utils.ShadowforkPeersFlag,
 	}
 
@@ -254,6 +271,8 @@ func init() {
 		utils.ShowDeprecated,
 		// See snapshot.go
 		snapshotCommand,
+		// See scrollcmd.go
+		scrollCommand,
 	}
 	sort.Sort(cli.CommandsByName(app.Commands))
 
@@ -0,0 +1,259 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	cli "gopkg.in/urfave/cli.v1"
+
+	"github.com/scroll-tech/go-ethereum/cmd/utils"
+	"github.com/scroll-tech/go-ethereum/common"
+	"github.com/scroll-tech/go-ethereum/core"
+	"github.com/scroll-tech/go-ethereum/core/rawdb"
+	"github.com/scroll-tech/go-ethereum/core/types"
+	"github.com/scroll-tech/go-ethereum/log"
+	"github.com/scroll-tech/go-ethereum/miner"
+)
+
+var (
+	replayCCCDelayFlag = cli.IntFlag{
+		Name:  "replay.cccdelay",
+		Usage: "Number of blocks built before the circuit capacity check of a block completes",
+		Value: 1,
+	}
+
+	scrollCommand = cli.Command{
+		Name:        "scroll",
+		Usage:       "A set of commands for debugging Scroll sequencers",
+		Category:    "MISCELLANEOUS COMMANDS",
+		Description: "",
+		Subcommands: []cli.Command{
+			{
+				Name:      "replay-seal",
+				Usage:     "Deterministically rebuild sequencer blocks from a recording",
+				ArgsUsage: "<recording> [<parent block number or hash>]",
+				Action:    utils.MigrateFlags(replaySeal),
+				Category:  "MISCELLANEOUS COMMANDS",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.AncientFlag,
+					utils.ScrollAlphaFlag,
+					utils.ScrollSepoliaFlag,
+					utils.ScrollFlag,
+					utils.MinerEtherbaseFlag,
+					utils.MinerGasLimitFlag,
+					utils.MinerExtraDataFlag,
+					utils.MinerMaxAccountsNumFlag,
+					utils.MinerCCCMaxOverflowRetriesFlag,
+					utils.MinerMaxReorgDepthFlag,
+					utils.MinerL1MessageMaxAgeFlag,
+					utils.MinerL1MessageForcedPerBlockFlag,
+					utils.MinerOrderingFlag,
+					utils.MinerOrderingMaxTxsPerSenderFlag,
+					utils.MinerOrderingPriorityAddressesFlag,
+					utils.MinerStoreSkippedTxTracesFlag,
+					replayCCCDelayFlag,
+				},
+				Description: `
+geth scroll replay-seal <recording> [<parent block number or hash>]
+rebuilds sequencer blocks on top of the given parent block, which defaults to
+the head block, by feeding the worker the transactions and L1 messages of the
+recording in order. The recording is a JSON array of steps:
+
+  [{"time": 1700000000, "txs": ["0x..."], "l1Messages": ["0x..."]},
+   {"time": 1700000003, "seal": true}]
+
+Each step advances the clock to its unix time, adds the binary encoded
+transactions to the txpool and the binary encoded L1 messages to the database,
+and seals the pending block if "seal" is set. The circuit capacity check of a
+block completes after --replay.cccdelay more blocks were built. Builds with the
+circuit_capacity_checker or ccc_go tags use the real checker, other builds use
+the mock checker.
+
+The produced blocks, skipped transactions, row consumption and reorgs are
+printed as JSON.
+
+The database is opened read-only. Rewinding the chain to the parent block and
+the replayed blocks are kept in memory and discarded on exit, the parent block
+must not be in the ancient store. The command cannot run while another instance
+uses the data directory.
+`,
+			},
+		},
+	}
+)
+
+// replayBlock is the JSON representation of a block built by a replay.
+type replayBlock struct {
+	Number         uint64                `json:"number"`
+	Hash           common.Hash           `json:"hash"`
+	Time           uint64                `json:"time"`
+	GasUsed        uint64                `json:"gasUsed"`
+	Transactions   []common.Hash         `json:"transactions"`
+	RowConsumption *types.RowConsumption `json:"rowConsumption"`
+}
+
+// replaySkippedTx is the JSON representation of a transaction skipped during a replay.
+type replaySkippedTx struct {
+	Hash           common.Hash          `json:"hash"`
+	Reason         string               `json:"reason"`
+	BlockNumber    uint64               `json:"blockNumber"`
+	RowConsumption types.RowConsumption `json:"rowConsumption,omitempty"`
+}
+
+// replayReorg is the JSON representation of a reorg caused by a block failing the circuit
+// capacity check during a replay.
+type replayReorg struct {
+	FailedBlock common.Hash `json:"failedBlock"`
+	Number      uint64      `json:"number"`
+	Depth       uint64      `json:"depth"`
+	NewHead     common.Hash `json:"newHead"`
+	Reason      string      `json:"reason"`
+}
+
+func replaySeal(ctx *cli.Context) error {
+	if ctx.NArg() < 1 || ctx.NArg() > 2 {
+		utils.Fatalf("This command requires a recording and an optional parent block.")
+	}
+	stack, cfg := makeConfigNode(ctx)
+	defer stack.Close()
+
+	if cfg.Eth.Miner.Etherbase == (common.Address{}) {
+		utils.Fatalf("The etherbase of the replayed blocks must be set with --%s", utils.MinerEtherbaseFlag.Name)
+	}
+	steps, err := readReplaySteps(ctx.Args().First())
+	if err != nil {
+		utils.Fatalf("Failed to read recording: %v", err)
+	}
+
+	// all changes of the replay are kept in memory, the sequencer's database is never modified
+	db := utils.MakeChainDatabase(ctx, stack, true)
+	defer db.Close()
+	chain := utils.MakeChainWithDatabase(ctx, stack, rawdb.NewOverlayDatabase(db))
+	defer chain.Stop()
+
+	if ctx.NArg() == 2 {
+		parent, err := replayParent(chain, ctx.Args().Get(1))
+		if err != nil {
+			utils.Fatalf("Failed to resolve parent block: %v", err)
+		}
+		if frozen, _ := db.Ancients(); parent.NumberU64()+1 < frozen {
+			utils.Fatalf("Cannot replay on block %d, it is in the ancient store (%d blocks)", parent.NumberU64(), frozen)
+		}
+		if parent.Hash() != chain.CurrentBlock().Hash() {
+			log.Warn("Rewinding chain to replay parent", "number", parent.NumberU64(), "hash", parent.Hash())
+			if err := chain.SetHead(parent.NumberU64()); err != nil {
+				utils.Fatalf("Failed to rewind chain: %v", err)
+			}
+		}
+	}
+	log.Info("Replaying sequencer recording", "steps", len(steps), "parent", chain.CurrentBlock().NumberU64())
+
+	result, err := miner.Replay(chain, &cfg.Eth.Miner, steps, ctx.Int(replayCCCDelayFlag.Name))
+	if err != nil {
+		utils.Fatalf("Replay failed: %v", err)
+	}
+	return printReplayResult(result)
+}
+
+// readReplaySteps reads a JSON encoded recording from file.
+func readReplaySteps(file string) ([]miner.ReplayStep, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var steps []miner.ReplayStep
+	if err := json.Unmarshal(data, &steps); err != nil {
+		return nil, err
+	}
+	return steps, nil
+}
+
+// replayParent resolves the block number or hash of the block to replay on.
+func replayParent(chain *core.BlockChain, arg string) (*types.Block, error) {
+	var block *types.Block
+	if hashish(arg) {
+		block = chain.GetBlockByHash(common.HexToHash(arg))
+	} else {
+		number, err := strconv.ParseUint(arg, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		block = chain.GetBlockByNumber(number)
+	}
+	if block == nil {
+		return nil, fmt.Errorf("block %s not found", arg)
+	}
+	if chain.GetCanonicalHash(block.NumberU64()) != block.Hash() {
+		return nil, errors.New("block is not canonical")
+	}
+	return block, nil
+}
+
+func printReplayResult(result *miner.ReplayResult) error {
+	out := struct {
+		Blocks  []replayBlock     `json:"blocks"`
+		Skipped []replaySkippedTx `json:"skipped"`
+		Reorgs  []replayReorg     `json:"reorgs"`
+	}{
+		Blocks:  make([]replayBlock, 0, len(result.Blocks)),
+		Skipped: make([]replaySkippedTx, 0, len(result.Skipped)),
+		Reorgs:  make([]replayReorg, 0, len(result.Reorgs)),
+	}
+	for i, block := range result.Blocks {
+		txs := make([]common.Hash, 0, len(block.Transactions()))
+		for _, tx := range block.Transactions() {
+			txs = append(txs, tx.Hash())
+		}
+		out.Blocks = append(out.Blocks, replayBlock{
+			Number:         block.NumberU64(),
+			Hash:           block.Hash(),
+			Time:           block.Time(),
+			GasUsed:        block.GasUsed(),
+			Transactions:   txs,
+			RowConsumption: result.RowConsumption[i],
+		})
+	}
+	for _, stx := range result.Skipped {
+		out.Skipped = append(out.Skipped, replaySkippedTx{
+			Hash:           stx.Tx.Hash(),
+			Reason:         stx.Reason,
+			BlockNumber:    stx.BlockNumber,
+			RowConsumption: stx.RowConsumption,
+		})
+	}
+	for _, ev := range result.Reorgs {
+		reorg := replayReorg{
+			FailedBlock: ev.FailedBlock.Hash(),
+			Number:      ev.FailedBlock.NumberU64(),
+			Depth:       ev.Depth,
+			NewHead:     ev.NewHead.Hash(),
+		}
+		if ev.Reason != nil {
+			reorg.Reason = ev.Reason.Error()
+		}
+		out.Reorgs = append(out.Reorgs, reorg)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
@@ -33,6 +33,7 @@ import (
 	"github.com/scroll-tech/go-ethereum/core/state/snapshot"
 	"github.com/scroll-tech/go-ethereum/core/types"
 	"github.com/scroll-tech/go-ethereum/crypto/codehash"
+	"github.com/scroll-tech/go-ethereum/ethdb"
 	"github.com/scroll-tech/go-ethereum/log"
 	"github.com/scroll-tech/go-ethereum/rlp"
 	"github.com/scroll-tech/go-ethereum/trie"
@@ -47,6 +48,12 @@ var (
 )
 
 var (
+	pruneZktrieRetainFlag = cli.Uint64Flag{
+		Name:  "prune.retain",
+		Usage: "Number of most recent blocks whose state is kept by the zktrie pruning",
+		Value: 128,
+	}
+
 	snapshotCommand = cli.Command{
 		Name:        "snapshot",
 		Usage:       "A set of commands based on the snapshot",
@@ -81,6 +88,40 @@ two version states are available: genesis and the specific one.
 
 The default pruning target is the HEAD-127 state.
 
+WARNING: It's necessary to delete the trie clean cache after the pruning.
+If you specify another directory for the trie clean cache via "--cache.trie.journal"
+during the use of Geth, please also specify it here for correct deletion. Otherwise
+the trie clean cache with default directory will be deleted.
+`,
+			},
+			{
+				Name:      "prune-zktrie-state",
+				Usage:     "Prune stale zktrie state data based on the recent state tries",
+				ArgsUsage: "",
+				Action:    utils.MigrateFlags(pruneZktrieState),
+				Category:  "MISCELLANEOUS COMMANDS",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.AncientFlag,
+					utils.ScrollAlphaFlag,
+					utils.ScrollSepoliaFlag,
+					utils.ScrollFlag,
+					utils.CacheTrieJournalFlag,
+					utils.BloomFilterSizeFlag,
+					pruneZktrieRetainFlag,
+				},
+				Description: `
+geth snapshot prune-zktrie-state
+will prune historical state data of a zktrie database. All trie nodes and
+contract codes that do not belong to the states of the most recent blocks
+(--prune.retain), the state of the snapshot disk layer or the genesis state
+will be deleted from the database. Only the states that are present in the
+database can be kept, so in non-archive mode the number of available states
+is lower than the number of retained blocks.
+
+If the pruning is interrupted after the live state has been marked, it is
+resumed by running the command again or when Geth is started.
+
 WARNING: It's necessary to delete the trie clean cache after the pruning.
 If you specify another directory for the trie clean cache via "--cache.trie.journal"
 during the use of Geth, please also specify it here for correct deletion. Otherwise
@@ -109,6 +150,11 @@ geth snapshot verify-state <state-root>
 will traverse the whole accounts and storages set based on the specified
 snapshot and recalculate the root hash of state for verification.
 In other words, this command does the snapshot to trie conversion.
+
+For zktrie states, the leaves of the snapshot are not ordered like the trie,
+so the account trie and the storage tries are rebuilt in memory. Verifying a
+large state needs memory proportional to the size of the account trie plus
+the largest storage tries being rebuilt concurrently.
 `,
 			},
 			{
@@ -202,6 +248,10 @@ func pruneState(ctx *cli.Context) error {
 	defer stack.Close()
 
 	chaindb := utils.MakeChainDatabase(ctx, stack, false)
+	if newStateTrieDatabase(chaindb).Zktrie {
+		log.Error("Snapshot based pruning is not supported for zktrie, use prune-zktrie-state")
+		return errors.New("zktrie database")
+	}
 	pruner, err := pruner.NewPruner(chaindb, stack.ResolvePath(""), stack.ResolvePath(config.Eth.TrieCleanCacheJournal), ctx.GlobalUint64(utils.BloomFilterSizeFlag.Name))
 	if err != nil {
 		log.Error("Failed to open snapshot tree", "err", err)
@@ -226,6 +276,31 @@ func pruneState(ctx *cli.Context) error {
 	return nil
 }
 
+func pruneZktrieState(ctx *cli.Context) error {
+	stack, config := makeConfigNode(ctx)
+	defer stack.Close()
+
+	chaindb := utils.MakeChainDatabase(ctx, stack, false)
+	if !newStateTrieDatabase(chaindb).Zktrie {
+		log.Error("Zktrie pruning is not supported for MPT, use prune-state")
+		return errors.New("not a zktrie database")
+	}
+	if ctx.NArg() > 0 {
+		log.Error("Too many arguments given")
+		return errors.New("too many arguments")
+	}
+	pruner, err := pruner.NewZktriePruner(chaindb, stack.ResolvePath(""), stack.ResolvePath(config.Eth.TrieCleanCacheJournal), ctx.GlobalUint64(utils.BloomFilterSizeFlag.Name), ctx.Uint64(pruneZktrieRetainFlag.Name))
+	if err != nil {
+		log.Error("Failed to create zktrie pruner", "err", err)
+		return err
+	}
+	if err = pruner.Prune(); err != nil {
+		log.Error("Failed to prune state", "err", err)
+		return err
+	}
+	return nil
+}
+
 func verifyState(ctx *cli.Context) error {
 	stack, _ := makeConfigNode(ctx)
 	defer stack.Close()
@@ -236,7 +311,7 @@ func verifyState(ctx *cli.Context) error {
 		log.Error("Failed to load head block")
 		return errors.New("no head block")
 	}
-	snaptree, err := snapshot.New(chaindb, trie.NewDatabase(chaindb), 256, headBlock.Root(), false, false, false)
+	snaptree, err := snapshot.New(chaindb, newStateTrieDatabase(chaindb), 256, headBlock.Root(), false, false, false)
 	if err != nil {
 		log.Error("Failed to open snapshot tree", "err", err)
 		return err
@@ -472,6 +547,13 @@ func traverseRawState(ctx *cli.Context) error {
 	return nil
 }
 
+// newStateTrieDatabase creates the trie database of the state, which is a zktrie
+// database if the stored chain config enables the zktrie.
+func newStateTrieDatabase(db ethdb.Database) *trie.Database {
+	config := rawdb.ReadChainConfig(db, rawdb.ReadCanonicalHash(db, 0))
+	return trie.NewDatabaseWithConfig(db, &trie.Config{Zktrie: config != nil && config.Scroll.ZktrieEnabled()})
+}
+
 func parseRoot(input string) (common.Hash, error) {
 	var h common.Hash
 	if err := h.UnmarshalText([]byte(input)); err != nil {
@@ -488,7 +570,7 @@ func dumpState(ctx *cli.Context) error {
 	if err != nil {
 		return err
 	}
-	snaptree, err := snapshot.New(db, trie.NewDatabase(db), 256, root, false, false, false)
+	snaptree, err := snapshot.New(db, newStateTrieDatabase(db), 256, root, false, false, false)
 	if err != nil {
 		return err
 	}
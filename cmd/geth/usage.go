@@ -195,6 +195,13 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			utils.MinerNoVerifyFlag,
 			utils.MinerStoreSkippedTxTracesFlag,
 			utils.MinerMaxAccountsNumFlag,
+			utils.MinerCCCMaxOverflowRetriesFlag,
+			utils.MinerMaxReorgDepthFlag,
+			utils.MinerL1MessageMaxAgeFlag,
+			utils.MinerL1MessageForcedPerBlockFlag,
+			utils.MinerOrderingFlag,
+			utils.MinerOrderingMaxTxsPerSenderFlag,
+			utils.MinerOrderingPriorityAddressesFlag,
 		},
 	},
 	{
@@ -234,6 +241,7 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 		Name: "MISC",
 		Flags: []cli.Flag{
 			utils.SnapshotFlag,
+			utils.SnapshotZktrieFlag,
 			utils.BloomFilterSizeFlag,
 			cli.HelpFlag,
 			utils.CatalystFlag,
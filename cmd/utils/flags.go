@@ -74,6 +74,8 @@ import (
 	"github.com/scroll-tech/go-ethereum/p2p/nat"
 	"github.com/scroll-tech/go-ethereum/p2p/netutil"
 	"github.com/scroll-tech/go-ethereum/params"
+	"github.com/scroll-tech/go-ethereum/rollup/rollup_sync_service"
+	"github.com/scroll-tech/go-ethereum/rollup/sync_service"
 	"github.com/scroll-tech/go-ethereum/rollup/tracing"
 	"github.com/scroll-tech/go-ethereum/rpc"
 )
@@ -250,6 +252,10 @@ var (
 		Name:  "snapshot",
 		Usage: `Enables snapshot-database mode (default = enable)`,
 	}
+	SnapshotZktrieFlag = cli.BoolFlag{
+		Name:  "snapshot.zktrie",
+		Usage: "Enables snapshot-database mode for zktrie states, the snapshot of the whole state is generated in the background first (default = disable)",
+	}
 	TxLookupLimitFlag = cli.Uint64Flag{
 		Name:  "txlookuplimit",
 		Usage: "Number of recent blocks to maintain transactions index for (default = about one year, 0 = entire chain)",
@@ -506,6 +512,40 @@ var (
 		Usage: "Maximum number of accounts that miner will fetch the pending transactions of when building a new block",
 		Value: math.MaxInt,
 	}
+	MinerCCCMaxOverflowRetriesFlag = cli.IntFlag{
+		Name:  "miner.cccmaxoverflowretries",
+		Usage: "Maximum number of transactions of other senders to try after a transaction overflows the circuit capacity of a block (0 = seal immediately)",
+		Value: ethconfig.Defaults.Miner.CCCMaxOverflowRetries,
+	}
+	MinerMaxReorgDepthFlag = cli.Uint64Flag{
+		Name:  "miner.maxreorgdepth",
+		Usage: "Maximum number of blocks the sequencer may replace after a block fails the circuit capacity check, sealing waits for the check of older blocks (0 = number of circuit capacity check workers + 1)",
+		Value: ethconfig.Defaults.Miner.MaxReorgDepth,
+	}
+	MinerL1MessageMaxAgeFlag = cli.DurationFlag{
+		Name:  "miner.l1messagemaxage",
+		Usage: "Age after which pending L1 messages are included with a raised per-block quota (0 = disabled)",
+		Value: ethconfig.Defaults.Miner.L1MessageMaxAge,
+	}
+	MinerL1MessageForcedPerBlockFlag = cli.Uint64Flag{
+		Name:  "miner.l1messageforcedperblock",
+		Usage: "Per-block L1 message quota while pending L1 messages are older than miner.l1messagemaxage (0 = twice the chain's default)",
+		Value: ethconfig.Defaults.Miner.L1MessageForcedPerBlock,
+	}
+	MinerOrderingFlag = cli.StringFlag{
+		Name:  "miner.ordering",
+		Usage: "Transaction ordering policy (\"price\", \"fifo\", \"fair\" or \"priority\")",
+		Value: miner.OrderingPrice,
+	}
+	MinerOrderingMaxTxsPerSenderFlag = cli.IntFlag{
+		Name:  "miner.ordering.maxtxspersender",
+		Usage: "Maximum number of transactions per sender and block of the \"fair\" ordering policy",
+		Value: miner.DefaultMaxTxsPerSender,
+	}
+	MinerOrderingPriorityAddressesFlag = cli.StringFlag{
+		Name:  "miner.ordering.priorityaddresses",
+		Usage: "Comma separated list of contract addresses whose callers are processed first by the \"priority\" ordering policy",
+	}
 	// Account settings
 	UnlockedAccountFlag = cli.StringFlag{
 		Name:  "unlock",
@@ -831,7 +871,11 @@ var (
 	// L1Settings
 	L1EndpointFlag = cli.StringFlag{
 		Name:  "l1.endpoint",
-		Usage: "Endpoint of L1 HTTP-RPC server",
+		Usage: "Endpoint of L1 HTTP-RPC server, or a comma-separated list of endpoints used with failover",
+	}
+	L1QuorumFlag = cli.IntFlag{
+		Name:  "l1.quorum",
+		Usage: "Number of L1 endpoints that must return identical logs and block hashes before they are accepted (0 = disabled)",
 	}
 	L1ConfirmationsFlag = cli.StringFlag{
 		Name:  "l1.confirmations",
@@ -841,6 +885,21 @@ var (
 		Name:  "l1.sync.startblock",
 		Usage: "L1 block height to start syncing from. Should be set to the L1 message queue deployment block number.",
 	}
+	L1FetchBlockRangeFlag = cli.Uint64Flag{
+		Name:  "l1.sync.fetchblockrange",
+		Usage: "Initial number of L1 blocks per eth_getLogs query, shrinks automatically if the L1 provider rejects the query",
+		Value: sync_service.DefaultFetchBlockRange,
+	}
+	L1MaxFetchBlockRangeFlag = cli.Uint64Flag{
+		Name:  "l1.sync.maxfetchblockrange",
+		Usage: "Maximum number of L1 blocks per eth_getLogs query",
+		Value: sync_service.DefaultMaxFetchBlockRange,
+	}
+	L1FetchConcurrencyFlag = cli.IntFlag{
+		Name:  "l1.sync.concurrency",
+		Usage: "Maximum number of parallel eth_getLogs queries when syncing from L1",
+		Value: sync_service.DefaultFetchConcurrency,
+	}
 
 	// Circuit capacity check settings
 	CircuitCapacityCheckEnabledFlag = cli.BoolFlag{
@@ -859,6 +918,27 @@ var (
 		Name:  "rollup.verify",
 		Usage: "Enable verification of batch consistency between L1 and L2 in rollup",
 	}
+	RollupVerifyFailurePolicyFlag = cli.StringFlag{
+		Name:  "rollup.verify.onfailure",
+		Usage: "Action taken when a batch fails verification: \"halt\" (shut down), \"diverge\" (keep running, stop finalizing) or \"rollback\" (rewind to the last valid batch)",
+		Value: string(rollup_sync_service.HaltOnFailure),
+	}
+	RollupDeriveEnabledFlag = cli.BoolFlag{
+		Name:  "rollup.derive",
+		Usage: "Rebuild L2 blocks from the batch data committed on L1 instead of syncing them from peers (implies --rollup.verify). Batches committed with blobs (codecv1+) require a --rollup.blob.* source",
+	}
+	RollupBlobDirFlag = cli.StringFlag{
+		Name:  "rollup.blob.dir",
+		Usage: "Local directory with blobs stored as files named by versioned hash, used by --rollup.verify and --rollup.derive",
+	}
+	RollupBlobBeaconNodeFlag = cli.StringFlag{
+		Name:  "rollup.blob.beaconnode",
+		Usage: "Beacon node API endpoint used to fetch blobs for --rollup.verify and --rollup.derive",
+	}
+	RollupBlobArchiveFlag = cli.StringFlag{
+		Name:  "rollup.blob.archive",
+		Usage: "HTTP blob archive endpoint serving blobs by versioned hash, used by --rollup.verify and --rollup.derive",
+	}
 
 	// Max block range for `eth_getLogs` method
 	MaxBlockRangeFlag = cli.Int64Flag{
@@ -1373,6 +1453,18 @@ func setL1(ctx *cli.Context, cfg *node.Config) {
 	if ctx.GlobalIsSet(L1DeploymentBlockFlag.Name) {
 		cfg.L1DeploymentBlock = ctx.GlobalUint64(L1DeploymentBlockFlag.Name)
 	}
+	if ctx.GlobalIsSet(L1FetchBlockRangeFlag.Name) {
+		cfg.L1FetchBlockRange = ctx.GlobalUint64(L1FetchBlockRangeFlag.Name)
+	}
+	if ctx.GlobalIsSet(L1MaxFetchBlockRangeFlag.Name) {
+		cfg.L1MaxFetchBlockRange = ctx.GlobalUint64(L1MaxFetchBlockRangeFlag.Name)
+	}
+	if ctx.GlobalIsSet(L1FetchConcurrencyFlag.Name) {
+		cfg.L1FetchConcurrency = ctx.GlobalInt(L1FetchConcurrencyFlag.Name)
+	}
+	if ctx.GlobalIsSet(L1QuorumFlag.Name) {
+		cfg.L1Quorum = ctx.GlobalInt(L1QuorumFlag.Name)
+	}
 }
 
 func setSmartCard(ctx *cli.Context, cfg *node.Config) {
@@ -1548,6 +1640,36 @@ func setMiner(ctx *cli.Context, cfg *miner.Config) {
 	if ctx.GlobalIsSet(MinerMaxAccountsNumFlag.Name) {
 		cfg.MaxAccountsNum = ctx.GlobalInt(MinerMaxAccountsNumFlag.Name)
 	}
+	if ctx.GlobalIsSet(MinerCCCMaxOverflowRetriesFlag.Name) {
+		cfg.CCCMaxOverflowRetries = ctx.GlobalInt(MinerCCCMaxOverflowRetriesFlag.Name)
+	}
+	if ctx.GlobalIsSet(MinerMaxReorgDepthFlag.Name) {
+		cfg.MaxReorgDepth = ctx.GlobalUint64(MinerMaxReorgDepthFlag.Name)
+	}
+	if ctx.GlobalIsSet(MinerL1MessageMaxAgeFlag.Name) {
+		cfg.L1MessageMaxAge = ctx.GlobalDuration(MinerL1MessageMaxAgeFlag.Name)
+	}
+	if ctx.GlobalIsSet(MinerL1MessageForcedPerBlockFlag.Name) {
+		cfg.L1MessageForcedPerBlock = ctx.GlobalUint64(MinerL1MessageForcedPerBlockFlag.Name)
+	}
+	if ctx.GlobalIsSet(MinerOrderingFlag.Name) {
+		cfg.Ordering = ctx.GlobalString(MinerOrderingFlag.Name)
+	}
+	if ctx.GlobalIsSet(MinerOrderingMaxTxsPerSenderFlag.Name) {
+		cfg.OrderingMaxTxsPerSender = ctx.GlobalInt(MinerOrderingMaxTxsPerSenderFlag.Name)
+	}
+	if ctx.GlobalIsSet(MinerOrderingPriorityAddressesFlag.Name) {
+		cfg.OrderingPriorityAddresses = nil
+		for _, addr := range SplitAndTrim(ctx.GlobalString(MinerOrderingPriorityAddressesFlag.Name)) {
+			if !common.IsHexAddress(addr) {
+				Fatalf("Invalid address in --%s: %s", MinerOrderingPriorityAddressesFlag.Name, addr)
+			}
+			cfg.OrderingPriorityAddresses = append(cfg.OrderingPriorityAddresses, common.HexToAddress(addr))
+		}
+	}
+	if _, err := miner.NewOrderingPolicy(cfg); err != nil {
+		Fatalf("Invalid transaction ordering policy: %v", err)
+	}
 	if ctx.GlobalIsSet(LegacyMinerGasTargetFlag.Name) {
 		log.Warn("The generic --miner.gastarget flag is deprecated and will be removed in the future!")
 	}
@@ -1595,6 +1717,28 @@ func setEnableRollupVerify(ctx *cli.Context, cfg *ethconfig.Config) {
 	if ctx.GlobalIsSet(RollupVerifyEnabledFlag.Name) {
 		cfg.EnableRollupVerify = ctx.GlobalBool(RollupVerifyEnabledFlag.Name)
 	}
+	if ctx.GlobalIsSet(RollupVerifyFailurePolicyFlag.Name) {
+		policy, err := rollup_sync_service.ParseValidationFailurePolicy(ctx.GlobalString(RollupVerifyFailurePolicyFlag.Name))
+		if err != nil {
+			Fatalf("Invalid value for flag %s: %v", RollupVerifyFailurePolicyFlag.Name, err)
+		}
+		cfg.RollupVerifyFailurePolicy = string(policy)
+	}
+	if ctx.GlobalIsSet(RollupDeriveEnabledFlag.Name) {
+		cfg.EnableRollupDerivation = ctx.GlobalBool(RollupDeriveEnabledFlag.Name)
+		if cfg.EnableRollupDerivation {
+			cfg.EnableRollupVerify = true
+		}
+	}
+	if ctx.GlobalIsSet(RollupBlobDirFlag.Name) {
+		cfg.RollupBlobDir = ctx.GlobalString(RollupBlobDirFlag.Name)
+	}
+	if ctx.GlobalIsSet(RollupBlobBeaconNodeFlag.Name) {
+		cfg.RollupBlobBeaconNode = ctx.GlobalString(RollupBlobBeaconNodeFlag.Name)
+	}
+	if ctx.GlobalIsSet(RollupBlobArchiveFlag.Name) {
+		cfg.RollupBlobArchive = ctx.GlobalString(RollupBlobArchiveFlag.Name)
+	}
 }
 
 func setMaxBlockRange(ctx *cli.Context, cfg *ethconfig.Config) {
@@ -1652,6 +1796,8 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	CheckExclusive(ctx, MainnetFlag, DeveloperFlag, RopstenFlag, RinkebyFlag, GoerliFlag, SepoliaFlag, ScrollAlphaFlag, ScrollSepoliaFlag, ScrollFlag)
 	CheckExclusive(ctx, LightServeFlag, SyncModeFlag, "light")
 	CheckExclusive(ctx, DeveloperFlag, ExternalSignerFlag) // Can't use both ephemeral unlocked and external signer
+	// Blocks are either produced by the local miner or derived from L1, not both
+	CheckExclusive(ctx, MiningEnabledFlag, RollupDeriveEnabledFlag)
 	if ctx.GlobalString(GCModeFlag.Name) == GCModeArchive && ctx.GlobalUint64(TxLookupLimitFlag.Name) != 0 {
 		ctx.GlobalSet(TxLookupLimitFlag.Name, "0")
 		log.Warn("Disable transaction unindexing for archive node")
@@ -1745,6 +1891,9 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheSnapshotFlag.Name) {
 		cfg.SnapshotCache = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheSnapshotFlag.Name) / 100
 	}
+	if ctx.GlobalIsSet(SnapshotZktrieFlag.Name) {
+		cfg.ZktrieSnapshot = ctx.GlobalBool(SnapshotZktrieFlag.Name)
+	}
 	if !ctx.GlobalBool(SnapshotFlag.Name) {
 		// If snap-sync is requested, this flag is also required
 		if cfg.SyncMode == downloader.SnapSync {
@@ -1948,17 +2097,9 @@ func RegisterEthService(stack *node.Node, cfg *ethconfig.Config) (ethapi.Backend
 
 	// initialize L1 client for sync service
 	// note: we need to do this here to avoid circular dependency
-	l1EndpointUrl := stack.Config().L1Endpoint
-	var l1Client *ethclient.Client
-
-	if l1EndpointUrl != "" {
-		var err error
-		l1Client, err = ethclient.Dial(l1EndpointUrl)
-		if err != nil {
-			Fatalf("Unable to connect to L1 endpoint at %v: %v", l1EndpointUrl, err)
-		}
-
-		log.Info("Initialized L1 client", "endpoint", l1EndpointUrl)
+	var l1Client sync_service.EthClient
+	if l1Endpoints := SplitAndTrim(stack.Config().L1Endpoint); len(l1Endpoints) > 0 {
+		l1Client = newL1Client(stack, l1Endpoints)
 	}
 
 	backend, err := eth.New(stack, cfg, l1Client)
@@ -1976,6 +2117,40 @@ func RegisterEthService(stack *node.Node, cfg *ethconfig.Config) (ethapi.Backend
 	return backend.APIBackend, backend
 }
 
+// newL1Client connects to the given L1 endpoints. Multiple endpoints are
+// combined into a MultiClient that fails over between them. Endpoints that
+// cannot be dialed are skipped, as long as at least one of them connects.
+func newL1Client(stack *node.Node, endpoints []string) sync_service.EthClient {
+	names := make([]string, 0, len(endpoints))
+	clients := make([]sync_service.EthClient, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		client, err := ethclient.Dial(endpoint)
+		if err != nil {
+			log.Error("Unable to connect to L1 endpoint, skipping it", "endpoint", endpoint, "err", err)
+			continue
+		}
+		names = append(names, endpoint)
+		clients = append(clients, client)
+	}
+	if len(clients) == 0 {
+		Fatalf("Unable to connect to any of the %d L1 endpoints", len(endpoints))
+	}
+
+	quorum := stack.Config().L1Quorum
+	if len(clients) == 1 && quorum <= 1 {
+		log.Info("Initialized L1 client", "endpoint", names[0])
+		return clients[0]
+	}
+
+	client, err := sync_service.NewMultiClient(names, clients, sync_service.MultiClientConfig{Quorum: quorum})
+	if err != nil {
+		Fatalf("Failed to initialize L1 client: %v", err)
+	}
+	stack.RegisterLifecycle(client)
+	log.Info("Initialized L1 client", "endpoints", len(clients), "configured", len(endpoints), "quorum", quorum)
+	return client
+}
+
 // RegisterEthStatsService configures the Ethereum Stats daemon and adds it to
 // the given node.
 func RegisterEthStatsService(stack *node.Node, backend ethapi.Backend, url string) {
@@ -2116,8 +2291,13 @@ func MakeGenesis(ctx *cli.Context) *core.Genesis {
 
 // MakeChain creates a chain manager from set command line flags.
 func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chainDb ethdb.Database) {
-	var err error
 	chainDb = MakeChainDatabase(ctx, stack, false) // TODO(rjl493456442) support read-only database
+	return MakeChainWithDatabase(ctx, stack, chainDb), chainDb
+}
+
+// MakeChainWithDatabase creates a chain manager from set command line flags on top of
+// the given database.
+func MakeChainWithDatabase(ctx *cli.Context, stack *node.Node, chainDb ethdb.Database) (chain *core.BlockChain) {
 	config, _, err := core.SetupGenesisBlock(chainDb, MakeGenesis(ctx))
 	if err != nil {
 		Fatalf("%v", err)
@@ -2150,6 +2330,7 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chai
 		TrieDirtyDisabled:   ctx.GlobalString(GCModeFlag.Name) == GCModeArchive,
 		TrieTimeLimit:       ethconfig.Defaults.TrieTimeout,
 		SnapshotLimit:       ethconfig.Defaults.SnapshotCache,
+		ZktrieSnapshot:      ctx.GlobalBool(SnapshotZktrieFlag.Name),
 		Preimages:           ctx.GlobalBool(CachePreimagesFlag.Name),
 	}
 	if cache.TrieDirtyDisabled && !cache.Preimages {
@@ -2173,7 +2354,7 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chai
 	if err != nil {
 		Fatalf("Can't create BlockChain: %v", err)
 	}
-	return chain, chainDb
+	return chain
 }
 
 // MakeConsolePreloads retrieves the absolute paths for the console JavaScript
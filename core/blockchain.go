@@ -133,6 +133,7 @@ type CacheConfig struct {
 	TrieDirtyDisabled   bool          // Whether to disable trie write caching and GC altogether (archive node)
 	TrieTimeLimit       time.Duration // Time limit after which to flush the current in-memory trie to disk
 	SnapshotLimit       int           // Memory allowance (MB) to use for caching snapshot entries in memory
+	ZktrieSnapshot      bool          // Whether to maintain snapshots of zktrie states, which are generated from the whole state first
 	Preimages           bool          // Whether to store preimage of trie key to the disk
 
 	SnapshotWait bool // Wait for snapshot construction on startup. TODO(karalabe): This is a dirty hack for testing, nuke it
@@ -230,9 +231,9 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 	blockCache, _ := lru.New(blockCacheLimit)
 	txLookupCache, _ := lru.New(txLookupCacheLimit)
 	futureBlocks, _ := lru.New(maxFutureBlocks)
-	// override snapshot setting
-	if chainConfig.Scroll.ZktrieEnabled() && cacheConfig.SnapshotLimit > 0 {
-		log.Warn("Snapshot has been disabled by zktrie")
+	// snapshots of zktrie states are opt-in, enabling them generates the snapshot of the whole state
+	if chainConfig.Scroll.ZktrieEnabled() && cacheConfig.SnapshotLimit > 0 && !cacheConfig.ZktrieSnapshot {
+		log.Info("Snapshot is disabled for zktrie, enable it with --snapshot.zktrie")
 		cacheConfig.SnapshotLimit = 0
 	}
 
@@ -1239,6 +1240,94 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.
 	return bc.writeBlockWithState(block, receipts, logs, state, emitHeadEvent)
 }
 
+// WriteSideBlockWithState writes the block and all associated state to the database
+// without considering it for the canonical chain. A chain of such blocks can be made
+// canonical at once with SetCanonical.
+func (bc *BlockChain) WriteSideBlockWithState(block *types.Block, receipts []*types.Receipt, state *state.StateDB) error {
+	if !bc.chainmu.TryLock() {
+		return errInsertionInterrupted
+	}
+	defer bc.chainmu.Unlock()
+
+	if bc.insertStopped() {
+		return errInsertionInterrupted
+	}
+	ptd := bc.GetTd(block.ParentHash(), block.NumberU64()-1)
+	if ptd == nil {
+		return consensus.ErrUnknownAncestor
+	}
+	return bc.writeBlockAndState(block, receipts, state, new(big.Int).Add(block.Difficulty(), ptd))
+}
+
+// SetCanonical makes the given block the new head of the chain. All canonical blocks
+// after the common ancestor of the current head and the given block are replaced in a
+// single reorg. The block and its ancestors must have been written with their state.
+func (bc *BlockChain) SetCanonical(head *types.Block) error {
+	if !bc.chainmu.TryLock() {
+		return errInsertionInterrupted
+	}
+	defer bc.chainmu.Unlock()
+
+	if !bc.HasBlockAndState(head.Hash(), head.NumberU64()) {
+		return fmt.Errorf("block %d (%s) or its state is missing", head.NumberU64(), head.Hash().Hex())
+	}
+	if err := bc.writeKnownBlock(head); err != nil {
+		return err
+	}
+
+	// reorg announces the logs of all new canonical blocks except for the head
+	var logs []*types.Log
+	for _, receipt := range rawdb.ReadReceipts(bc.db, head.Hash(), head.NumberU64(), bc.chainConfig) {
+		logs = append(logs, receipt.Logs...)
+	}
+	bc.chainFeed.Send(ChainEvent{Block: head, Hash: head.Hash(), Logs: logs})
+	if len(logs) > 0 {
+		bc.logsFeed.Send(logs)
+	}
+	bc.chainHeadFeed.Send(ChainHeadEvent{Block: head})
+	return nil
+}
+
+// BuildAndWriteBlock executes the given transactions on top of the parent block, fills in the
+// execution results (state root, gas used, receipts) of the header and writes the resulting
+// block to the database. It is used to import blocks that were reconstructed from L1 data,
+// which therefore carry no sealer signature and skip header verification.
+func (bc *BlockChain) BuildAndWriteBlock(parentBlock *types.Block, header *types.Header, txs types.Transactions) (*types.Block, WriteStatus, error) {
+	if !bc.chainmu.TryLock() {
+		return nil, NonStatTy, errInsertionInterrupted
+	}
+	defer bc.chainmu.Unlock()
+
+	statedb, err := state.New(parentBlock.Root(), bc.stateCache, bc.snaps)
+	if err != nil {
+		return nil, NonStatTy, err
+	}
+
+	receipts, logs, gasUsed, err := bc.processor.Process(types.NewBlockWithHeader(header).WithBody(txs, nil), statedb, bc.vmConfig)
+	if err != nil {
+		return nil, NonStatTy, fmt.Errorf("failed to process block %d: %w", header.Number, err)
+	}
+
+	header.GasUsed = gasUsed
+	header.Root = statedb.IntermediateRoot(bc.chainConfig.IsEIP158(header.Number))
+	block := types.NewBlock(header, txs, nil, receipts, trie.NewStackTrie(nil))
+
+	// receipts and logs were created before the final block hash was known
+	blockHash := block.Hash()
+	for _, receipt := range receipts {
+		receipt.BlockHash = blockHash
+		for _, l := range receipt.Logs {
+			l.BlockHash = blockHash
+		}
+	}
+
+	status, err := bc.writeBlockWithState(block, receipts, logs, statedb, true)
+	if err != nil {
+		return nil, NonStatTy, err
+	}
+	return block, status, nil
+}
+
 // writeBlockWithState writes the block and all associated state to the database,
 // but is expects the chain mutex to be held.
 func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.Receipt, logs []*types.Log, state *state.StateDB, emitHeadEvent bool) (status WriteStatus, err error) {
@@ -1269,12 +1358,72 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 	localTd := bc.GetTd(currentBlock.Hash(), currentBlock.NumberU64())
 	externTd := new(big.Int).Add(block.Difficulty(), ptd)
 
+	if err := bc.writeBlockAndState(block, receipts, state, externTd); err != nil {
+		return NonStatTy, err
+	}
+	// If the total difficulty is higher than our known, add it to the canonical chain
+	// Second clause in the if statement reduces the vulnerability to selfish mining.
+	// Please refer to http://www.cs.cornell.edu/~ie53/publications/btcProcFC.pdf
+	reorg := externTd.Cmp(localTd) > 0
+	currentBlock = bc.CurrentBlock()
+	if !reorg && externTd.Cmp(localTd) == 0 {
+		// Split same-difficulty blocks by number, then preferentially select
+		// the block generated by the local miner as the canonical block.
+		if block.NumberU64() < currentBlock.NumberU64() {
+			reorg = true
+		} else if block.NumberU64() == currentBlock.NumberU64() {
+			var currentPreserve, blockPreserve bool
+			if bc.shouldPreserve != nil {
+				currentPreserve, blockPreserve = bc.shouldPreserve(currentBlock), bc.shouldPreserve(block)
+			}
+			reorg = !currentPreserve && (blockPreserve || mrand.Float64() < 0.5)
+		}
+	}
+	if reorg {
+		// Reorganise the chain if the parent is not the head block
+		if block.ParentHash() != currentBlock.Hash() {
+			if err := bc.reorg(currentBlock, block); err != nil {
+				return NonStatTy, err
+			}
+		}
+		status = CanonStatTy
+	} else {
+		status = SideStatTy
+	}
+	// Set new head.
+	if status == CanonStatTy {
+		bc.writeHeadBlock(block)
+	}
+	bc.futureBlocks.Remove(block.Hash())
+
+	if status == CanonStatTy {
+		bc.chainFeed.Send(ChainEvent{Block: block, Hash: block.Hash(), Logs: logs})
+		if len(logs) > 0 {
+			bc.logsFeed.Send(logs)
+		}
+		// In theory we should fire a ChainHeadEvent when we inject
+		// a canonical block, but sometimes we can insert a batch of
+		// canonicial blocks. Avoid firing too much ChainHeadEvents,
+		// we will fire an accumulated ChainHeadEvent and disable fire
+		// event here.
+		if emitHeadEvent {
+			bc.chainHeadFeed.Send(ChainHeadEvent{Block: block})
+		}
+	} else {
+		bc.chainSideFeed.Send(ChainSideEvent{Block: block})
+	}
+	return status, nil
+}
+
+// writeBlockAndState writes the block, its receipts and its state to the database without
+// changing the canonical chain. It expects the chain mutex to be held.
+func (bc *BlockChain) writeBlockAndState(block *types.Block, receipts []*types.Receipt, state *state.StateDB, td *big.Int) error {
 	// Irrelevant of the canonical status, write the block itself to the database.
 	//
 	// Note all the components of block(td, hash->number map, header, body, receipts)
 	// should be written atomically. BlockBatch is used for containing all components.
 	blockBatch := bc.db.NewBatch()
-	rawdb.WriteTd(blockBatch, block.Hash(), block.NumberU64(), externTd)
+	rawdb.WriteTd(blockBatch, block.Hash(), block.NumberU64(), td)
 	rawdb.WriteBlock(blockBatch, block)
 	rawdb.WriteReceipts(blockBatch, block.Hash(), block.NumberU64(), receipts)
 	rawdb.WritePreimages(blockBatch, state.Preimages())
@@ -1315,14 +1464,14 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 	// Commit all cached state changes into underlying memory database.
 	root, err := state.Commit(bc.chainConfig.IsEIP158(block.Number()))
 	if err != nil {
-		return NonStatTy, err
+		return err
 	}
 	triedb := bc.stateCache.TrieDB()
 
 	// If we're running an archive node, always flush
 	if bc.cacheConfig.TrieDirtyDisabled {
 		if err := triedb.Commit(root, false, nil); err != nil {
-			return NonStatTy, err
+			return err
 		}
 	} else {
 		// Full but not archive node, do proper garbage collection
@@ -1371,58 +1520,7 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 			}
 		}
 	}
-	// If the total difficulty is higher than our known, add it to the canonical chain
-	// Second clause in the if statement reduces the vulnerability to selfish mining.
-	// Please refer to http://www.cs.cornell.edu/~ie53/publications/btcProcFC.pdf
-	reorg := externTd.Cmp(localTd) > 0
-	currentBlock = bc.CurrentBlock()
-	if !reorg && externTd.Cmp(localTd) == 0 {
-		// Split same-difficulty blocks by number, then preferentially select
-		// the block generated by the local miner as the canonical block.
-		if block.NumberU64() < currentBlock.NumberU64() {
-			reorg = true
-		} else if block.NumberU64() == currentBlock.NumberU64() {
-			var currentPreserve, blockPreserve bool
-			if bc.shouldPreserve != nil {
-				currentPreserve, blockPreserve = bc.shouldPreserve(currentBlock), bc.shouldPreserve(block)
-			}
-			reorg = !currentPreserve && (blockPreserve || mrand.Float64() < 0.5)
-		}
-	}
-	if reorg {
-		// Reorganise the chain if the parent is not the head block
-		if block.ParentHash() != currentBlock.Hash() {
-			if err := bc.reorg(currentBlock, block); err != nil {
-				return NonStatTy, err
-			}
-		}
-		status = CanonStatTy
-	} else {
-		status = SideStatTy
-	}
-	// Set new head.
-	if status == CanonStatTy {
-		bc.writeHeadBlock(block)
-	}
-	bc.futureBlocks.Remove(block.Hash())
-
-	if status == CanonStatTy {
-		bc.chainFeed.Send(ChainEvent{Block: block, Hash: block.Hash(), Logs: logs})
-		if len(logs) > 0 {
-			bc.logsFeed.Send(logs)
-		}
-		// In theory we should fire a ChainHeadEvent when we inject
-		// a canonical block, but sometimes we can insert a batch of
-		// canonicial blocks. Avoid firing too much ChainHeadEvents,
-		// we will fire an accumulated ChainHeadEvent and disable fire
-		// event here.
-		if emitHeadEvent {
-			bc.chainHeadFeed.Send(ChainHeadEvent{Block: block})
-		}
-	} else {
-		bc.chainSideFeed.Send(ChainSideEvent{Block: block})
-	}
-	return status, nil
+	return nil
 }
 
 // addFutureBlock checks if the block is within the max allowed window to get
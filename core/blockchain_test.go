@@ -3790,3 +3790,101 @@ func TestCurieTransition(t *testing.T) {
 		}
 	}
 }
+
+// Tests that a side chain written with WriteSideBlockWithState stays non-canonical
+// until it is swapped in with SetCanonical.
+func TestSetCanonicalSideChain(t *testing.T) {
+	db, chain, err := newCanonical(ethash.NewFaker(), 0, true)
+	if err != nil {
+		t.Fatalf("failed to create pristine chain: %v", err)
+	}
+	defer chain.Stop()
+
+	canonical, _ := GenerateChain(chain.Config(), chain.Genesis(), ethash.NewFaker(), db, 5, func(i int, b *BlockGen) {
+		b.SetCoinbase(common.Address{1})
+	})
+	if _, err := chain.InsertChain(canonical); err != nil {
+		t.Fatalf("failed to insert canonical chain: %v", err)
+	}
+	side, _ := GenerateChain(chain.Config(), canonical[1], ethash.NewFaker(), db, 2, func(i int, b *BlockGen) {
+		b.SetCoinbase(common.Address{2})
+	})
+	for _, block := range side {
+		parent := chain.GetBlockByHash(block.ParentHash())
+		statedb, err := chain.StateAt(parent.Root())
+		if err != nil {
+			t.Fatalf("failed to get parent state: %v", err)
+		}
+		receipts, _, _, err := chain.Processor().Process(block, statedb, vm.Config{})
+		if err != nil {
+			t.Fatalf("failed to process side block: %v", err)
+		}
+		if err := chain.WriteSideBlockWithState(block, receipts, statedb); err != nil {
+			t.Fatalf("failed to write side block: %v", err)
+		}
+	}
+	if head := chain.CurrentBlock(); head.Hash() != canonical[4].Hash() {
+		t.Fatalf("head changed by side chain: have %d (%x), want %d (%x)", head.NumberU64(), head.Hash(), canonical[4].NumberU64(), canonical[4].Hash())
+	}
+
+	headCh := make(chan ChainHeadEvent, 1)
+	sub := chain.SubscribeChainHeadEvent(headCh)
+	defer sub.Unsubscribe()
+
+	if err := chain.SetCanonical(side[1]); err != nil {
+		t.Fatalf("failed to set canonical head: %v", err)
+	}
+	if head := chain.CurrentBlock(); head.Hash() != side[1].Hash() {
+		t.Fatalf("head mismatch: have %d (%x), want %d (%x)", head.NumberU64(), head.Hash(), side[1].NumberU64(), side[1].Hash())
+	}
+	for _, block := range append(canonical[:2:2], side...) {
+		if hash := rawdb.ReadCanonicalHash(db, block.NumberU64()); hash != block.Hash() {
+			t.Errorf("canonical hash mismatch at %d: have %x, want %x", block.NumberU64(), hash, block.Hash())
+		}
+	}
+	if block := chain.GetBlockByNumber(canonical[4].NumberU64()); block != nil {
+		t.Errorf("replaced block %d still canonical", block.NumberU64())
+	}
+	select {
+	case ev := <-headCh:
+		if ev.Block.Hash() != side[1].Hash() {
+			t.Errorf("chain head event mismatch: have %x, want %x", ev.Block.Hash(), side[1].Hash())
+		}
+	default:
+		t.Errorf("no chain head event")
+	}
+
+	// a block without state cannot become the head
+	orphan, _ := GenerateChain(chain.Config(), side[1], ethash.NewFaker(), db, 1, nil)
+	if err := chain.SetCanonical(orphan[0]); err == nil {
+		t.Fatalf("set unknown block as canonical head")
+	}
+}
+
+// Tests that snapshots of zktrie states are only maintained if explicitly enabled.
+func TestZktrieSnapshotOptIn(t *testing.T) {
+	config := *params.TestChainConfig
+	config.Scroll.UseZktrie = true
+
+	for _, enabled := range []bool{false, true} {
+		db := rawdb.NewMemoryDatabase()
+		(&Genesis{Config: &config, BaseFee: big.NewInt(params.InitialBaseFee)}).MustCommit(db)
+
+		cacheConfig := &CacheConfig{
+			TrieCleanLimit: 256,
+			TrieDirtyLimit: 256,
+			TrieTimeLimit:  5 * time.Minute,
+			SnapshotLimit:  256,
+			ZktrieSnapshot: enabled,
+			SnapshotWait:   true,
+		}
+		chain, err := NewBlockChain(db, cacheConfig, &config, ethash.NewFaker(), vm.Config{}, nil, nil)
+		if err != nil {
+			t.Fatalf("failed to create chain: %v", err)
+		}
+		if have := chain.Snapshots() != nil; have != enabled {
+			t.Errorf("snapshot mismatch with zktrie snapshots enabled=%v: have %v", enabled, have)
+		}
+		chain.Stop()
+	}
+}
@@ -44,3 +44,31 @@ type ChainHeadEvent struct{ Block *types.Block }
 
 // NewL1MsgsEvent is posted when we receive some new messages from L1.
 type NewL1MsgsEvent struct{ Count int }
+
+// L1ReorgEvent is posted when L1 messages are rolled back due to an L1 reorg.
+type L1ReorgEvent struct {
+	CommonAncestor         uint64 // number of the latest L1 block that is still canonical
+	FirstRemovedQueueIndex uint64 // queue index of the first L1 message removed from the database
+	NumRemovedMessages     int
+}
+
+// SequencerReorgEvent is posted when the sequencer replaces the tail of the canonical
+// chain because one of its blocks failed the circuit capacity check.
+type SequencerReorgEvent struct {
+	FailedBlock *types.Block // canonical block that failed the circuit capacity check
+	OldHead     *types.Block // head of the canonical chain before the reorg
+	NewHead     *types.Block // head of the replacement chain
+	Depth       uint64       // number of canonical blocks that were replaced
+	Reason      error        // circuit capacity check error of the failed block
+}
+
+// PreconfirmationEvent is posted when the sequencer adds a transaction to the block it is
+// building, and again with Retracted set if that block is discarded or replaced.
+type PreconfirmationEvent struct {
+	TxHash      common.Hash
+	BlockNumber uint64         // number of the block the transaction is included in
+	Index       uint           // index of the transaction in the block
+	Receipt     *types.Receipt // receipt of the transaction, nil for retractions
+	Retracted   bool           // whether an earlier preconfirmation is no longer valid
+	Reason      error          // why the preconfirmation was retracted
+}
@@ -85,6 +85,14 @@ func ReadHighestSyncedQueueIndex(db ethdb.Reader) uint64 {
 	return number.Uint64()
 }
 
+// DeleteL1Message removes an L1 message from the database.
+// Note: This does not update the highest synced queue index.
+func DeleteL1Message(db ethdb.KeyValueWriter, queueIndex uint64) {
+	if err := db.Delete(L1MessageKey(queueIndex)); err != nil {
+		log.Crit("Failed to delete L1 message", "queueIndex", queueIndex, "err", err)
+	}
+}
+
 // WriteL1Message writes an L1 message to the database.
 // We assume that L1 messages are written to DB following their queue index order.
 func WriteL1Message(db ethdb.KeyValueWriter, l1Msg types.L1MessageTx) {
@@ -107,6 +115,58 @@ func WriteL1Messages(db ethdb.KeyValueWriter, l1Msgs []types.L1MessageTx) {
 	}
 }
 
+// WriteL1MessageSyncTime writes the unix time at which an L1 message was synced to the database.
+func WriteL1MessageSyncTime(db ethdb.KeyValueWriter, queueIndex uint64, syncTime uint64) {
+	if err := db.Put(L1MessageSyncTimeKey(queueIndex), encodeBigEndian(syncTime)); err != nil {
+		log.Crit("Failed to store L1 message sync time", "queueIndex", queueIndex, "err", err)
+	}
+}
+
+// ReadL1MessageSyncTime retrieves the unix time at which an L1 message was synced.
+// It returns nil for messages synced before sync times were recorded.
+func ReadL1MessageSyncTime(db ethdb.Reader, queueIndex uint64) *uint64 {
+	data, err := db.Get(L1MessageSyncTimeKey(queueIndex))
+	if err != nil && isNotFoundErr(err) {
+		return nil
+	}
+	if err != nil {
+		log.Crit("Failed to read L1 message sync time from database", "queueIndex", queueIndex, "err", err)
+	}
+	if len(data) != 8 {
+		return nil
+	}
+	syncTime := binary.BigEndian.Uint64(data)
+	return &syncTime
+}
+
+// DeleteL1MessageSyncTime removes the sync time of an L1 message from the database.
+func DeleteL1MessageSyncTime(db ethdb.KeyValueWriter, queueIndex uint64) {
+	if err := db.Delete(L1MessageSyncTimeKey(queueIndex)); err != nil {
+		log.Crit("Failed to delete L1 message sync time", "queueIndex", queueIndex, "err", err)
+	}
+}
+
+// DeleteL1MessageSyncTimesBelow removes the sync times of all L1 messages with a queue index below queueIndex.
+func DeleteL1MessageSyncTimesBelow(db ethdb.Iteratee, batch ethdb.KeyValueWriter, queueIndex uint64) {
+	it := db.NewIterator(l1MessageSyncTimePrefix, nil)
+	defer it.Release()
+
+	for it.Next() {
+		if len(it.Key()) != len(l1MessageSyncTimePrefix)+8 {
+			continue
+		}
+		if binary.BigEndian.Uint64(it.Key()[len(l1MessageSyncTimePrefix):]) >= queueIndex {
+			break
+		}
+		if err := batch.Delete(it.Key()); err != nil {
+			log.Crit("Failed to delete L1 message sync time", "key", it.Key(), "err", err)
+		}
+	}
+	if err := it.Error(); err != nil {
+		log.Crit("Failed to read L1 message sync times", "err", err)
+	}
+}
+
 // ReadL1MessageRLP retrieves an L1 message in its raw RLP database encoding.
 func ReadL1MessageRLP(db ethdb.Reader, queueIndex uint64) rlp.RawValue {
 	data, err := db.Get(L1MessageKey(queueIndex))
@@ -275,3 +335,93 @@ func ReadFirstQueueIndexNotInL2Block(db ethdb.Reader, l2BlockHash common.Hash) *
 	queueIndex := binary.BigEndian.Uint64(data)
 	return &queueIndex
 }
+
+// L1BlockCheckpoint records an L1 block processed by the L1 message sync service.
+// It is used to detect L1 reorgs and to roll back L1 messages synced from reorged blocks.
+type L1BlockCheckpoint struct {
+	Number         uint64
+	Hash           common.Hash
+	NextQueueIndex uint64 // queue index of the first L1 message NOT emitted in or before this block
+}
+
+// WriteL1BlockCheckpoint writes an L1 block checkpoint to the database.
+func WriteL1BlockCheckpoint(db ethdb.KeyValueWriter, checkpoint L1BlockCheckpoint) {
+	bytes, err := rlp.EncodeToBytes(checkpoint)
+	if err != nil {
+		log.Crit("Failed to RLP encode L1 block checkpoint", "number", checkpoint.Number, "err", err)
+	}
+	if err := db.Put(L1BlockCheckpointKey(checkpoint.Number), bytes); err != nil {
+		log.Crit("Failed to store L1 block checkpoint", "number", checkpoint.Number, "err", err)
+	}
+}
+
+// ReadL1BlockCheckpoint retrieves the checkpoint of the provided L1 block.
+func ReadL1BlockCheckpoint(db ethdb.Reader, l1BlockNumber uint64) *L1BlockCheckpoint {
+	data, err := db.Get(L1BlockCheckpointKey(l1BlockNumber))
+	if err != nil && isNotFoundErr(err) {
+		return nil
+	}
+	if err != nil {
+		log.Crit("Failed to read L1 block checkpoint from database", "number", l1BlockNumber, "err", err)
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	checkpoint := new(L1BlockCheckpoint)
+	if err := rlp.DecodeBytes(data, checkpoint); err != nil {
+		log.Crit("Invalid L1 block checkpoint RLP", "number", l1BlockNumber, "data", data, "err", err)
+	}
+	return checkpoint
+}
+
+// DeleteL1BlockCheckpoint removes the checkpoint of the provided L1 block from the database.
+func DeleteL1BlockCheckpoint(db ethdb.KeyValueWriter, l1BlockNumber uint64) {
+	if err := db.Delete(L1BlockCheckpointKey(l1BlockNumber)); err != nil {
+		log.Crit("Failed to delete L1 block checkpoint", "number", l1BlockNumber, "err", err)
+	}
+}
+
+// ReadL1BlockCheckpointsFrom retrieves all L1 block checkpoints starting at
+// the provided L1 block number, in ascending order of block number.
+func ReadL1BlockCheckpointsFrom(db ethdb.Iteratee, fromL1BlockNumber uint64) []L1BlockCheckpoint {
+	it := db.NewIterator(l1BlockCheckpointPrefix, encodeBigEndian(fromL1BlockNumber))
+	defer it.Release()
+
+	var checkpoints []L1BlockCheckpoint
+	for it.Next() {
+		if len(it.Key()) != len(l1BlockCheckpointPrefix)+8 {
+			continue
+		}
+		var checkpoint L1BlockCheckpoint
+		if err := rlp.DecodeBytes(it.Value(), &checkpoint); err != nil {
+			log.Crit("Invalid L1 block checkpoint RLP", "key", it.Key(), "data", it.Value(), "err", err)
+		}
+		checkpoints = append(checkpoints, checkpoint)
+	}
+	if err := it.Error(); err != nil {
+		log.Crit("Failed to read L1 block checkpoints", "err", err)
+	}
+	return checkpoints
+}
+
+// DeleteL1BlockCheckpointsBelow removes all L1 block checkpoints below the provided L1 block
+// number. The deletions are written to batch, only the deleted checkpoints are iterated over.
+func DeleteL1BlockCheckpointsBelow(db ethdb.Iteratee, batch ethdb.KeyValueWriter, l1BlockNumber uint64) {
+	it := db.NewIterator(l1BlockCheckpointPrefix, nil)
+	defer it.Release()
+
+	for it.Next() {
+		if len(it.Key()) != len(l1BlockCheckpointPrefix)+8 {
+			continue
+		}
+		if binary.BigEndian.Uint64(it.Key()[len(l1BlockCheckpointPrefix):]) >= l1BlockNumber {
+			break
+		}
+		if err := batch.Delete(it.Key()); err != nil {
+			log.Crit("Failed to delete L1 block checkpoint", "key", it.Key(), "err", err)
+		}
+	}
+	if err := it.Error(); err != nil {
+		log.Crit("Failed to read L1 block checkpoints", "err", err)
+	}
+}
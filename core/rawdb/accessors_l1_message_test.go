@@ -157,3 +157,93 @@ func TestIterationStopsAtMaxQueueIndex(t *testing.T) {
 		t.Fatal("Invalid length", "expected", 3, "got", len(got))
 	}
 }
+
+func TestDeleteL1Message(t *testing.T) {
+	db := NewMemoryDatabase()
+	WriteL1Messages(db, []types.L1MessageTx{newL1MessageTx(0), newL1MessageTx(1)})
+
+	DeleteL1Message(db, 1)
+	if got := ReadL1Message(db, 1); got != nil {
+		t.Fatal("L1 message not deleted", "got", got)
+	}
+	if got := ReadL1Message(db, 0); got == nil {
+		t.Fatal("Unrelated L1 message deleted")
+	}
+}
+
+func TestReadWriteL1BlockCheckpoint(t *testing.T) {
+	db := NewMemoryDatabase()
+	checkpoints := []L1BlockCheckpoint{
+		{Number: 10, Hash: common.Hash{1}, NextQueueIndex: 0},
+		{Number: 12, Hash: common.Hash{2}, NextQueueIndex: 3},
+		{Number: 1 << 32, Hash: common.Hash{3}, NextQueueIndex: 5},
+	}
+	for _, cp := range checkpoints {
+		WriteL1BlockCheckpoint(db, cp)
+	}
+
+	for _, cp := range checkpoints {
+		got := ReadL1BlockCheckpoint(db, cp.Number)
+		if got == nil || *got != cp {
+			t.Fatal("L1 block checkpoint mismatch", "expected", cp, "got", got)
+		}
+	}
+	if got := ReadL1BlockCheckpoint(db, 11); got != nil {
+		t.Fatal("Unexpected L1 block checkpoint", "got", got)
+	}
+
+	got := ReadL1BlockCheckpointsFrom(db, 11)
+	if len(got) != 2 || got[0] != checkpoints[1] || got[1] != checkpoints[2] {
+		t.Fatal("L1 block checkpoints mismatch", "expected", checkpoints[1:], "got", got)
+	}
+
+	DeleteL1BlockCheckpoint(db, 12)
+	if got := ReadL1BlockCheckpointsFrom(db, 0); len(got) != 2 {
+		t.Fatal("Invalid number of L1 block checkpoints", "expected", 2, "got", len(got))
+	}
+
+	DeleteL1BlockCheckpointsBelow(db, db, 1<<32)
+	if got := ReadL1BlockCheckpointsFrom(db, 0); len(got) != 1 || got[0] != checkpoints[2] {
+		t.Fatal("L1 block checkpoints mismatch", "expected", checkpoints[2:], "got", got)
+	}
+}
+
+func TestReadWriteL1MessageSyncTime(t *testing.T) {
+	db := NewMemoryDatabase()
+	WriteL1MessageSyncTime(db, 1, 1700000000)
+	WriteL1MessageSyncTime(db, 2, 1700000012)
+
+	if got := ReadL1MessageSyncTime(db, 1); got == nil || *got != 1700000000 {
+		t.Fatal("L1 message sync time mismatch", "expected", 1700000000, "got", got)
+	}
+	if got := ReadL1MessageSyncTime(db, 3); got != nil {
+		t.Fatal("Unexpected L1 message sync time", "got", *got)
+	}
+
+	DeleteL1MessageSyncTime(db, 1)
+	if got := ReadL1MessageSyncTime(db, 1); got != nil {
+		t.Fatal("L1 message sync time not deleted", "got", *got)
+	}
+	if got := ReadL1MessageSyncTime(db, 2); got == nil || *got != 1700000012 {
+		t.Fatal("Unrelated L1 message sync time deleted", "got", got)
+	}
+}
+
+func TestDeleteL1MessageSyncTimesBelow(t *testing.T) {
+	db := NewMemoryDatabase()
+	for _, queueIndex := range []uint64{0, 1, 2, 1 << 32} {
+		WriteL1MessageSyncTime(db, queueIndex, 1700000000+queueIndex)
+	}
+
+	DeleteL1MessageSyncTimesBelow(db, db, 2)
+	for _, queueIndex := range []uint64{0, 1} {
+		if got := ReadL1MessageSyncTime(db, queueIndex); got != nil {
+			t.Fatal("L1 message sync time not deleted", "queueIndex", queueIndex, "got", *got)
+		}
+	}
+	for _, queueIndex := range []uint64{2, 1 << 32} {
+		if got := ReadL1MessageSyncTime(db, queueIndex); got == nil {
+			t.Fatal("L1 message sync time deleted", "queueIndex", queueIndex)
+		}
+	}
+}
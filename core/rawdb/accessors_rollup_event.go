@@ -21,6 +21,10 @@ type CommittedBatchMeta struct {
 	Version             uint8
 	BlobVersionedHashes []common.Hash
 	ChunkBlockRanges    []*ChunkBlockRange
+
+	// BatchHash is the hash of the batch as emitted in the CommitBatch event.
+	// It is empty for batches committed before this field was introduced.
+	BatchHash common.Hash `rlp:"optional"`
 }
 
 // FinalizedBatchMeta holds metadata for finalized batches.
@@ -31,6 +35,28 @@ type FinalizedBatchMeta struct {
 	WithdrawRoot         common.Hash
 }
 
+// l2BlockBatchIndexEntry is the value of the L2 block to batch index mapping,
+// stored under the last L2 block number of the batch.
+type l2BlockBatchIndexEntry struct {
+	BatchIndex       uint64
+	StartBlockNumber uint64
+}
+
+// BatchValidationFailure records a batch whose locally computed data does not match the L1 FinalizeBatch event.
+type BatchValidationFailure struct {
+	BatchIndex        uint64
+	CodecVersion      uint8
+	ChunkBlockRanges  []*ChunkBlockRange
+	Reason            string
+	L1StateRoot       common.Hash
+	LocalStateRoot    common.Hash
+	L1WithdrawRoot    common.Hash
+	LocalWithdrawRoot common.Hash
+	L1BatchHash       common.Hash
+	LocalBatchHash    common.Hash
+	DetectedAt        uint64 // unix timestamp of the detection
+}
+
 // WriteRollupEventSyncedL1BlockNumber stores the latest synced L1 block number related to rollup events in the database.
 func WriteRollupEventSyncedL1BlockNumber(db ethdb.KeyValueWriter, l1BlockNumber uint64) {
 	value := big.NewInt(0).SetUint64(l1BlockNumber).Bytes()
@@ -154,6 +180,40 @@ func ReadFinalizedL2BlockNumber(db ethdb.Reader) *uint64 {
 	return &finalizedL2BlockNumber
 }
 
+// WriteCommittedL2BlockNumber stores the highest L2 block number included in a batch that is committed and not reverted.
+func WriteCommittedL2BlockNumber(db ethdb.KeyValueWriter, l2BlockNumber uint64) {
+	value := big.NewInt(0).SetUint64(l2BlockNumber).Bytes()
+	if err := db.Put(committedL2BlockNumberKey, value); err != nil {
+		log.Crit("failed to store committed L2 block number for rollup event", "L2 block number", l2BlockNumber, "value", value, "err", err)
+	}
+}
+
+// DeleteCommittedL2BlockNumber removes the highest committed L2 block number from the database.
+func DeleteCommittedL2BlockNumber(db ethdb.KeyValueWriter) {
+	if err := db.Delete(committedL2BlockNumberKey); err != nil {
+		log.Crit("failed to delete committed L2 block number", "err", err)
+	}
+}
+
+// ReadCommittedL2BlockNumber fetches the highest L2 block number included in a batch that is committed and not reverted.
+func ReadCommittedL2BlockNumber(db ethdb.Reader) *uint64 {
+	data, err := db.Get(committedL2BlockNumberKey)
+	if err != nil && isNotFoundErr(err) {
+		return nil
+	}
+	if err != nil {
+		log.Crit("failed to read committed L2 block number from database", "key", committedL2BlockNumberKey, "err", err)
+	}
+
+	number := new(big.Int).SetBytes(data)
+	if !number.IsUint64() {
+		log.Crit("unexpected committed L2 block number in database", "data", data, "number", number)
+	}
+
+	committedL2BlockNumber := number.Uint64()
+	return &committedL2BlockNumber
+}
+
 // WriteLastFinalizedBatchIndex stores the last finalized batch index in the database.
 func WriteLastFinalizedBatchIndex(db ethdb.KeyValueWriter, lastFinalizedBatchIndex uint64) {
 	value := big.NewInt(0).SetUint64(lastFinalizedBatchIndex).Bytes()
@@ -216,3 +276,172 @@ func DeleteCommittedBatchMeta(db ethdb.KeyValueWriter, batchIndex uint64) {
 		log.Crit("failed to delete committed batch metadata", "batch index", batchIndex, "err", err)
 	}
 }
+
+// WriteBatchValidationFailure stores a batch validation failure in the database.
+func WriteBatchValidationFailure(db ethdb.KeyValueWriter, failure *BatchValidationFailure) {
+	value, err := rlp.EncodeToBytes(failure)
+	if err != nil {
+		log.Crit("failed to RLP encode batch validation failure", "batch index", failure.BatchIndex, "err", err)
+	}
+	if err := db.Put(batchValidationFailureKey(failure.BatchIndex), value); err != nil {
+		log.Crit("failed to store batch validation failure", "batch index", failure.BatchIndex, "value", value, "err", err)
+	}
+}
+
+// ReadBatchValidationFailure fetches the validation failure of a specific batch from the database.
+func ReadBatchValidationFailure(db ethdb.Reader, batchIndex uint64) *BatchValidationFailure {
+	data, err := db.Get(batchValidationFailureKey(batchIndex))
+	if err != nil && isNotFoundErr(err) {
+		return nil
+	}
+	if err != nil {
+		log.Crit("failed to read batch validation failure from database", "batch index", batchIndex, "err", err)
+	}
+
+	failure := new(BatchValidationFailure)
+	if err := rlp.Decode(bytes.NewReader(data), failure); err != nil {
+		log.Crit("Invalid BatchValidationFailure RLP", "batch index", batchIndex, "data", data, "err", err)
+	}
+	return failure
+}
+
+// ReadBatchValidationFailures fetches all batch validation failures starting at the provided batch index,
+// in ascending order of batch index.
+func ReadBatchValidationFailures(db ethdb.Iteratee, fromBatchIndex uint64) []*BatchValidationFailure {
+	it := db.NewIterator(batchValidationFailurePrefix, encodeBigEndian(fromBatchIndex))
+	defer it.Release()
+
+	var failures []*BatchValidationFailure
+	for it.Next() {
+		if len(it.Key()) != len(batchValidationFailurePrefix)+8 {
+			continue
+		}
+		failure := new(BatchValidationFailure)
+		if err := rlp.DecodeBytes(it.Value(), failure); err != nil {
+			log.Crit("Invalid BatchValidationFailure RLP", "key", it.Key(), "data", it.Value(), "err", err)
+		}
+		failures = append(failures, failure)
+	}
+	if err := it.Error(); err != nil {
+		log.Crit("failed to read batch validation failures", "err", err)
+	}
+	return failures
+}
+
+// WriteDivergedBatchIndex stores the index of the batch whose validation failure made the local chain diverge from L1.
+func WriteDivergedBatchIndex(db ethdb.KeyValueWriter, batchIndex uint64) {
+	writeBatchIndex(db, divergedBatchIndexKey, batchIndex)
+}
+
+// DeleteDivergedBatchIndex removes the diverged batch index, once the chain is resynced from L1.
+func DeleteDivergedBatchIndex(db ethdb.KeyValueWriter) {
+	if err := db.Delete(divergedBatchIndexKey); err != nil {
+		log.Crit("failed to delete diverged batch index", "err", err)
+	}
+}
+
+// ReadDivergedBatchIndex fetches the index of the batch whose validation failure made the local chain diverge
+// from L1, or nil if the chain did not diverge.
+func ReadDivergedBatchIndex(db ethdb.Reader) *uint64 {
+	return readBatchIndex(db, divergedBatchIndexKey)
+}
+
+// WriteRolledBackBatchIndex stores the index of the batch whose validation failure triggered the last rollback.
+func WriteRolledBackBatchIndex(db ethdb.KeyValueWriter, batchIndex uint64) {
+	writeBatchIndex(db, rolledBackBatchIndexKey, batchIndex)
+}
+
+// DeleteRolledBackBatchIndex removes the rolled back batch index.
+func DeleteRolledBackBatchIndex(db ethdb.KeyValueWriter) {
+	if err := db.Delete(rolledBackBatchIndexKey); err != nil {
+		log.Crit("failed to delete rolled back batch index", "err", err)
+	}
+}
+
+// ReadRolledBackBatchIndex fetches the index of the batch whose validation failure triggered the last rollback,
+// or nil if there was none.
+func ReadRolledBackBatchIndex(db ethdb.Reader) *uint64 {
+	return readBatchIndex(db, rolledBackBatchIndexKey)
+}
+
+func writeBatchIndex(db ethdb.KeyValueWriter, key []byte, batchIndex uint64) {
+	value := big.NewInt(0).SetUint64(batchIndex).Bytes()
+	if err := db.Put(key, value); err != nil {
+		log.Crit("failed to store batch index", "key", string(key), "batch index", batchIndex, "err", err)
+	}
+}
+
+func readBatchIndex(db ethdb.Reader, key []byte) *uint64 {
+	data, err := db.Get(key)
+	if err != nil && isNotFoundErr(err) {
+		return nil
+	}
+	if err != nil {
+		log.Crit("failed to read batch index from database", "key", string(key), "err", err)
+	}
+
+	number := new(big.Int).SetBytes(data)
+	if !number.IsUint64() {
+		log.Crit("unexpected batch index in database", "key", string(key), "data", data, "number", number)
+	}
+
+	batchIndex := number.Uint64()
+	return &batchIndex
+}
+
+// WriteL2BlockBatchIndex stores the mapping from the L2 blocks of a committed batch to its batch index.
+// A single entry is written per batch, keyed by the last L2 block number of the batch.
+func WriteL2BlockBatchIndex(db ethdb.KeyValueWriter, batchIndex uint64, chunkBlockRanges []*ChunkBlockRange) {
+	if len(chunkBlockRanges) == 0 {
+		return
+	}
+	entry := l2BlockBatchIndexEntry{
+		BatchIndex:       batchIndex,
+		StartBlockNumber: chunkBlockRanges[0].StartBlockNumber,
+	}
+	endBlockNumber := chunkBlockRanges[len(chunkBlockRanges)-1].EndBlockNumber
+	value, err := rlp.EncodeToBytes(&entry)
+	if err != nil {
+		log.Crit("failed to RLP encode L2 block batch index", "batch index", batchIndex, "err", err)
+	}
+	if err := db.Put(l2BlockBatchIndexKey(endBlockNumber), value); err != nil {
+		log.Crit("failed to store L2 block batch index", "batch index", batchIndex, "end block number", endBlockNumber, "err", err)
+	}
+}
+
+// DeleteL2BlockBatchIndex removes the mapping from the L2 blocks of a reverted batch to its batch index.
+func DeleteL2BlockBatchIndex(db ethdb.KeyValueWriter, chunkBlockRanges []*ChunkBlockRange) {
+	if len(chunkBlockRanges) == 0 {
+		return
+	}
+	endBlockNumber := chunkBlockRanges[len(chunkBlockRanges)-1].EndBlockNumber
+	if err := db.Delete(l2BlockBatchIndexKey(endBlockNumber)); err != nil {
+		log.Crit("failed to delete L2 block batch index", "end block number", endBlockNumber, "err", err)
+	}
+}
+
+// ReadBatchIndexByL2BlockNumber retrieves the index of the committed batch that contains the given L2 block.
+// It returns nil if the block is not part of any indexed batch.
+func ReadBatchIndexByL2BlockNumber(db ethdb.Iteratee, l2BlockNumber uint64) *uint64 {
+	// the first batch ending at or after the block is the only candidate
+	it := db.NewIterator(l2BlockBatchIndexPrefix, encodeBigEndian(l2BlockNumber))
+	defer it.Release()
+
+	for it.Next() {
+		if len(it.Key()) != len(l2BlockBatchIndexPrefix)+8 {
+			continue
+		}
+		var entry l2BlockBatchIndexEntry
+		if err := rlp.DecodeBytes(it.Value(), &entry); err != nil {
+			log.Crit("Invalid L2 block batch index RLP", "key", it.Key(), "data", it.Value(), "err", err)
+		}
+		if entry.StartBlockNumber > l2BlockNumber {
+			return nil
+		}
+		return &entry.BatchIndex
+	}
+	if err := it.Error(); err != nil {
+		log.Crit("failed to read L2 block batch index", "l2 block number", l2BlockNumber, "err", err)
+	}
+	return nil
+}
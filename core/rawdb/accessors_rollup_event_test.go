@@ -1,9 +1,11 @@
 package rawdb
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/scroll-tech/go-ethereum/common"
+	"github.com/scroll-tech/go-ethereum/rlp"
 )
 
 func TestWriteRollupEventSyncedL1BlockNumber(t *testing.T) {
@@ -58,6 +60,37 @@ func TestFinalizedL2BlockNumber(t *testing.T) {
 	}
 }
 
+func TestCommittedL2BlockNumber(t *testing.T) {
+	blockNumbers := []uint64{
+		1,
+		1 << 2,
+		1 << 8,
+		1 << 16,
+		1 << 32,
+	}
+
+	db := NewMemoryDatabase()
+
+	// read non-existing value
+	if got := ReadCommittedL2BlockNumber(db); got != nil {
+		t.Fatal("Expected nil for non-existing value", "got", *got)
+	}
+
+	for _, num := range blockNumbers {
+		WriteCommittedL2BlockNumber(db, num)
+		got := ReadCommittedL2BlockNumber(db)
+
+		if *got != num {
+			t.Fatal("Block number mismatch", "expected", num, "got", got)
+		}
+	}
+
+	DeleteCommittedL2BlockNumber(db)
+	if got := ReadCommittedL2BlockNumber(db); got != nil {
+		t.Fatal("Expected nil for deleted value", "got", *got)
+	}
+}
+
 func TestLastFinalizedBatchIndex(t *testing.T) {
 	batchIndxes := []uint64{
 		1,
@@ -240,6 +273,7 @@ func TestWriteReadDeleteCommittedBatchMeta(t *testing.T) {
 				Version:             255,
 				BlobVersionedHashes: []common.Hash{common.HexToHash("0xabcd"), common.HexToHash("0xef01")},
 				ChunkBlockRanges:    []*ChunkBlockRange{{StartBlockNumber: 0, EndBlockNumber: 10}, {StartBlockNumber: 11, EndBlockNumber: 20}},
+				BatchHash:           common.HexToHash("0x5678"),
 			},
 		},
 	}
@@ -336,5 +370,148 @@ func compareCommittedBatchMeta(a, b *CommittedBatchMeta) bool {
 			return false
 		}
 	}
-	return true
+	return a.BatchHash == b.BatchHash
+}
+
+func TestReadLegacyCommittedBatchMeta(t *testing.T) {
+	db := NewMemoryDatabase()
+
+	// committed batch meta written before the batch hash was stored
+	legacy := struct {
+		Version             uint8
+		BlobVersionedHashes []common.Hash
+		ChunkBlockRanges    []*ChunkBlockRange
+	}{
+		Version:             3,
+		BlobVersionedHashes: []common.Hash{common.HexToHash("0x1234")},
+		ChunkBlockRanges:    []*ChunkBlockRange{{StartBlockNumber: 1, EndBlockNumber: 10}},
+	}
+	value, err := rlp.EncodeToBytes(legacy)
+	if err != nil {
+		t.Fatal("failed to encode legacy committed batch meta", err)
+	}
+	if err := db.Put(committedBatchMetaKey(1), value); err != nil {
+		t.Fatal("failed to store legacy committed batch meta", err)
+	}
+
+	got := ReadCommittedBatchMeta(db, 1)
+	expected := &CommittedBatchMeta{
+		Version:             legacy.Version,
+		BlobVersionedHashes: legacy.BlobVersionedHashes,
+		ChunkBlockRanges:    legacy.ChunkBlockRanges,
+	}
+	if got == nil || !compareCommittedBatchMeta(expected, got) {
+		t.Fatalf("Legacy CommittedBatchMeta mismatch, expected %+v, got %+v", expected, got)
+	}
+}
+
+func TestBatchValidationFailure(t *testing.T) {
+	db := NewMemoryDatabase()
+
+	failures := []*BatchValidationFailure{
+		{
+			BatchIndex:       3,
+			CodecVersion:     1,
+			ChunkBlockRanges: []*ChunkBlockRange{{StartBlockNumber: 10, EndBlockNumber: 20}},
+			Reason:           "state root mismatch",
+			L1StateRoot:      common.HexToHash("0x01"),
+			LocalStateRoot:   common.HexToHash("0x02"),
+			DetectedAt:       1700000000,
+		},
+		{
+			BatchIndex:       1 << 32,
+			CodecVersion:     4,
+			ChunkBlockRanges: []*ChunkBlockRange{{StartBlockNumber: 21, EndBlockNumber: 30}, {StartBlockNumber: 31, EndBlockNumber: 35}},
+			Reason:           "batch hash mismatch",
+			L1BatchHash:      common.HexToHash("0x03"),
+			LocalBatchHash:   common.HexToHash("0x04"),
+		},
+	}
+
+	for _, failure := range failures {
+		WriteBatchValidationFailure(db, failure)
+		got := ReadBatchValidationFailure(db, failure.BatchIndex)
+		if !reflect.DeepEqual(failure, got) {
+			t.Fatalf("BatchValidationFailure mismatch, expected %+v, got %+v", failure, got)
+		}
+	}
+
+	if got := ReadBatchValidationFailure(db, 4); got != nil {
+		t.Fatalf("Expected nil for non-existing value, got %+v", got)
+	}
+
+	if got := ReadBatchValidationFailures(db, 0); !reflect.DeepEqual(failures, got) {
+		t.Fatalf("BatchValidationFailures mismatch, expected %+v, got %+v", failures, got)
+	}
+	if got := ReadBatchValidationFailures(db, 4); len(got) != 1 || got[0].BatchIndex != 1<<32 {
+		t.Fatalf("Unexpected batch validation failures, got %+v", got)
+	}
+}
+
+func TestDivergedAndRolledBackBatchIndex(t *testing.T) {
+	db := NewMemoryDatabase()
+
+	if got := ReadDivergedBatchIndex(db); got != nil {
+		t.Fatalf("Expected nil diverged batch index, got %d", *got)
+	}
+	if got := ReadRolledBackBatchIndex(db); got != nil {
+		t.Fatalf("Expected nil rolled back batch index, got %d", *got)
+	}
+
+	WriteDivergedBatchIndex(db, 0)
+	WriteRolledBackBatchIndex(db, 1<<40)
+	if got := ReadDivergedBatchIndex(db); got == nil || *got != 0 {
+		t.Fatalf("Diverged batch index mismatch, expected 0, got %v", got)
+	}
+	if got := ReadRolledBackBatchIndex(db); got == nil || *got != 1<<40 {
+		t.Fatalf("Rolled back batch index mismatch, expected %d, got %v", uint64(1<<40), got)
+	}
+
+	DeleteDivergedBatchIndex(db)
+	DeleteRolledBackBatchIndex(db)
+	if got := ReadDivergedBatchIndex(db); got != nil {
+		t.Fatalf("Expected nil diverged batch index after deletion, got %d", *got)
+	}
+	if got := ReadRolledBackBatchIndex(db); got != nil {
+		t.Fatalf("Expected nil rolled back batch index after deletion, got %d", *got)
+	}
+}
+
+func TestL2BlockBatchIndex(t *testing.T) {
+	db := NewMemoryDatabase()
+
+	batches := map[uint64][]*ChunkBlockRange{
+		1: {{StartBlockNumber: 1, EndBlockNumber: 5}, {StartBlockNumber: 6, EndBlockNumber: 10}},
+		2: {{StartBlockNumber: 11, EndBlockNumber: 11}},
+		3: {{StartBlockNumber: 12, EndBlockNumber: 300}},
+	}
+	for batchIndex, ranges := range batches {
+		WriteL2BlockBatchIndex(db, batchIndex, ranges)
+	}
+
+	expected := map[uint64]uint64{1: 1, 7: 1, 10: 1, 11: 2, 12: 3, 256: 3, 300: 3}
+	for number, batchIndex := range expected {
+		got := ReadBatchIndexByL2BlockNumber(db, number)
+		if got == nil || *got != batchIndex {
+			t.Fatalf("Batch index mismatch for block %d, expected %d, got %v", number, batchIndex, got)
+		}
+	}
+	for _, number := range []uint64{0, 301} {
+		if got := ReadBatchIndexByL2BlockNumber(db, number); got != nil {
+			t.Fatalf("Expected nil for block %d, got %d", number, *got)
+		}
+	}
+
+	// revert batch 3 and commit a shorter batch in its place
+	DeleteL2BlockBatchIndex(db, batches[3])
+	if got := ReadBatchIndexByL2BlockNumber(db, 12); got != nil {
+		t.Fatalf("Expected nil for reverted block, got %d", *got)
+	}
+	WriteL2BlockBatchIndex(db, 3, []*ChunkBlockRange{{StartBlockNumber: 12, EndBlockNumber: 20}})
+	if got := ReadBatchIndexByL2BlockNumber(db, 20); got == nil || *got != 3 {
+		t.Fatalf("Batch index mismatch for recommitted block, got %v", got)
+	}
+	if got := ReadBatchIndexByL2BlockNumber(db, 21); got != nil {
+		t.Fatalf("Expected nil for block after recommitted batch, got %d", *got)
+	}
 }
@@ -82,27 +82,41 @@ type SkippedTransactionV2 struct {
 
 	// BlockHash is the hash of the block in which this transaction was skipped or nil.
 	BlockHash *common.Hash
+
+	// RowConsumption is the per-subcircuit row consumption reported by the circuit capacity checker
+	// when the transaction was skipped. It is empty for transactions skipped for other reasons and
+	// for transactions skipped before this field was introduced.
+	RowConsumption types.RowConsumption `rlp:"optional"`
+
+	// Readmitted is set when the transaction was readmitted into the transaction pool
+	// after it had been skipped, e.g. after a circuit upgrade raised the capacity limits.
+	Readmitted bool `rlp:"optional"`
 }
 
 // writeSkippedTransaction writes a skipped transaction to the database.
-func writeSkippedTransaction(db ethdb.KeyValueWriter, tx *types.Transaction, traces *types.BlockTrace, reason string, blockNumber uint64, blockHash *common.Hash) {
+func writeSkippedTransaction(db ethdb.KeyValueWriter, tx *types.Transaction, traces *types.BlockTrace, rc types.RowConsumption, reason string, blockNumber uint64, blockHash *common.Hash) {
 	var err error
 	// workaround: RLP decoding fails if this is nil
 	if blockHash == nil {
 		blockHash = &common.Hash{}
 	}
-	stx := SkippedTransactionV2{Tx: tx, Reason: reason, BlockNumber: blockNumber, BlockHash: blockHash}
+	stx := SkippedTransactionV2{Tx: tx, Reason: reason, BlockNumber: blockNumber, BlockHash: blockHash, RowConsumption: rc}
 	if traces != nil {
 		if stx.TracesBytes, err = json.Marshal(traces); err != nil {
 			log.Crit("Failed to json marshal skipped transaction", "hash", tx.Hash().String(), "err", err)
 		}
 	}
+	writeSkippedTransactionV2(db, &stx)
+}
+
+// writeSkippedTransactionV2 writes an already assembled skipped transaction to the database.
+func writeSkippedTransactionV2(db ethdb.KeyValueWriter, stx *SkippedTransactionV2) {
 	bytes, err := rlp.EncodeToBytes(stx)
 	if err != nil {
-		log.Crit("Failed to RLP encode skipped transaction", "hash", tx.Hash().String(), "err", err)
+		log.Crit("Failed to RLP encode skipped transaction", "hash", stx.Tx.Hash().String(), "err", err)
 	}
-	if err := db.Put(SkippedTransactionKey(tx.Hash()), bytes); err != nil {
-		log.Crit("Failed to store skipped transaction", "hash", tx.Hash().String(), "err", err)
+	if err := db.Put(SkippedTransactionKey(stx.Tx.Hash()), bytes); err != nil {
+		log.Crit("Failed to store skipped transaction", "hash", stx.Tx.Hash().String(), "err", err)
 	}
 }
 
@@ -140,6 +154,57 @@ func ReadSkippedTransaction(db ethdb.Reader, txHash common.Hash) *SkippedTransac
 	if len(data) == 0 {
 		return nil
 	}
+	return decodeSkippedTransaction(txHash, data)
+}
+
+// ReadSkippedTransactionWithoutTraces retrieves a skipped transaction by its hash like
+// ReadSkippedTransaction, but does not decode the stored traces.
+func ReadSkippedTransactionWithoutTraces(db ethdb.Reader, txHash common.Hash) *SkippedTransactionV2 {
+	data := readSkippedTransactionRLP(db, txHash)
+	if len(data) == 0 {
+		return nil
+	}
+	if stripped, err := stripSkippedTransactionTraces(data); err == nil {
+		data = stripped
+	}
+	return decodeSkippedTransaction(txHash, data)
+}
+
+// stripSkippedTransactionTraces replaces the traces of an RLP encoded SkippedTransactionV2 with an
+// empty string. Encodings of the older SkippedTransaction, which has no traces, are returned as is.
+func stripSkippedTransactionTraces(data []byte) ([]byte, error) {
+	content, _, err := rlp.SplitList(data)
+	if err != nil {
+		return nil, err
+	}
+	// SkippedTransaction has 4 fields, SkippedTransactionV2 has at least 5
+	if n, err := rlp.CountValues(content); err != nil || n < 5 {
+		return data, err
+	}
+	_, _, afterTx, err := rlp.Split(content)
+	if err != nil {
+		return nil, err
+	}
+	_, _, afterTraces, err := rlp.Split(afterTx)
+	if err != nil {
+		return nil, err
+	}
+	fields := make([]rlp.RawValue, 0, 3)
+	fields = append(fields, content[:len(content)-len(afterTx)], rlp.EmptyString)
+	for len(afterTraces) > 0 {
+		_, _, rest, err := rlp.Split(afterTraces)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, afterTraces[:len(afterTraces)-len(rest)])
+		afterTraces = rest
+	}
+	return rlp.EncodeToBytes(fields)
+}
+
+// decodeSkippedTransaction decodes a skipped transaction stored in either the SkippedTransactionV2
+// or the older SkippedTransaction encoding.
+func decodeSkippedTransaction(txHash common.Hash, data []byte) *SkippedTransactionV2 {
 	var stxV2 SkippedTransactionV2
 	var stx SkippedTransaction
 	if err := rlp.Decode(bytes.NewReader(data), &stxV2); err != nil {
@@ -158,14 +223,39 @@ func ReadSkippedTransaction(db ethdb.Reader, txHash common.Hash) *SkippedTransac
 	return &stxV2
 }
 
-// IsSkippedTransaction checks if a transaction exists as a skipped transaction in the database.
+// IsSkippedTransaction checks if a transaction exists as a skipped transaction in the database
+// and has not been readmitted since.
 func IsSkippedTransaction(db ethdb.Reader, txHash common.Hash) bool {
 	exists, err := db.Has(SkippedTransactionKey(txHash))
 	if err != nil {
 		log.Error("Failed to check skipped transaction", "hash", txHash.String(), "err", err)
 		return false
 	}
-	return exists
+	if !exists {
+		return false
+	}
+	stx := ReadSkippedTransaction(db, txHash)
+	return stx != nil && !stx.Readmitted
+}
+
+// SetSkippedTransactionReadmitted updates the readmitted flag of a skipped transaction.
+// Transactions that are marked as readmitted are accepted by the transaction pool again.
+// It returns false if the transaction is not a skipped transaction.
+func SetSkippedTransactionReadmitted(db ethdb.Database, txHash common.Hash, readmitted bool) bool {
+	mu.Lock()
+	defer mu.Unlock()
+
+	stx := ReadSkippedTransaction(db, txHash)
+	if stx == nil {
+		return false
+	}
+	// workaround: RLP decoding fails if this is nil
+	if stx.BlockHash == nil {
+		stx.BlockHash = &common.Hash{}
+	}
+	stx.Readmitted = readmitted
+	writeSkippedTransactionV2(db, stx)
+	return true
 }
 
 // writeSkippedTransactionHash writes the hash of a skipped transaction to the database.
@@ -175,6 +265,74 @@ func writeSkippedTransactionHash(db ethdb.KeyValueWriter, index uint64, txHash c
 	}
 }
 
+// writeSkippedTransactionBlock indexes a skipped transaction by the number of the block it was skipped in.
+func writeSkippedTransactionBlock(db ethdb.KeyValueWriter, blockNumber uint64, txHash common.Hash) {
+	if err := db.Put(SkippedTransactionBlockKey(blockNumber, txHash), nil); err != nil {
+		log.Crit("Failed to store skipped transaction block index", "number", blockNumber, "hash", txHash.String(), "err", err)
+	}
+}
+
+// ReadSkippedTransactionHashesInBlockRange retrieves the hashes of the transactions skipped in blocks
+// between from and to (inclusive), ordered by block number. At most limit hashes are returned.
+// Transactions skipped more than once are returned for every block they were skipped in.
+func ReadSkippedTransactionHashesInBlockRange(db ethdb.Iteratee, from, to uint64, limit int) []common.Hash {
+	it := db.NewIterator(skippedTransactionBlockPrefix, encodeBigEndian(from))
+	defer it.Release()
+
+	var hashes []common.Hash
+	keyLength := len(skippedTransactionBlockPrefix) + 8 + common.HashLength
+	for len(hashes) < limit && it.Next() {
+		key := it.Key()
+		if len(key) != keyLength {
+			continue
+		}
+		if binary.BigEndian.Uint64(key[len(skippedTransactionBlockPrefix):]) > to {
+			break
+		}
+		hashes = append(hashes, common.BytesToHash(key[len(skippedTransactionBlockPrefix)+8:]))
+	}
+	return hashes
+}
+
+// IndexSkippedTransactionBlocks indexes the transactions skipped before the block number index of
+// skipped transactions was introduced. It does nothing once the index is complete.
+func IndexSkippedTransactionBlocks(db ethdb.Database) {
+	if has, _ := db.Has(skippedTransactionBlockIndexedKey); has {
+		return
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	it := IterateSkippedTransactionsFrom(db, 0)
+	defer it.Release()
+
+	batch := db.NewBatch()
+	indexed := 0
+	for it.Next() {
+		hash := it.TransactionHash()
+		if stx := ReadSkippedTransactionWithoutTraces(db, hash); stx != nil {
+			writeSkippedTransactionBlock(batch, stx.BlockNumber, hash)
+			indexed++
+		}
+		if batch.ValueSize() > ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				log.Crit("Failed to store skipped transaction block index", "err", err)
+			}
+			batch.Reset()
+		}
+	}
+	if err := batch.Put(skippedTransactionBlockIndexedKey, []byte{1}); err != nil {
+		log.Crit("Failed to store skipped transaction block index marker", "err", err)
+	}
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to store skipped transaction block index", "err", err)
+	}
+	if indexed > 0 {
+		log.Info("Indexed skipped transactions by block number", "count", indexed)
+	}
+}
+
 // ReadSkippedTransactionHash retrieves the hash of a skipped transaction by its index.
 func ReadSkippedTransactionHash(db ethdb.Reader, index uint64) *common.Hash {
 	data, err := db.Get(SkippedTransactionHashKey(index))
@@ -190,7 +348,7 @@ func ReadSkippedTransactionHash(db ethdb.Reader, index uint64) *common.Hash {
 
 // WriteSkippedTransaction writes a skipped transaction to the database and also updates the count and lookup index.
 // Note: The lookup index and count will include duplicates if there are chain reorgs.
-func WriteSkippedTransaction(db ethdb.Database, tx *types.Transaction, traces *types.BlockTrace, reason string, blockNumber uint64, blockHash *common.Hash) {
+func WriteSkippedTransaction(db ethdb.Database, tx *types.Transaction, traces *types.BlockTrace, rc types.RowConsumption, reason string, blockNumber uint64, blockHash *common.Hash) {
 	// this method is not accessed concurrently, but just to be sure...
 	mu.Lock()
 	defer mu.Unlock()
@@ -199,7 +357,8 @@ func WriteSkippedTransaction(db ethdb.Database, tx *types.Transaction, traces *t
 
 	// update in a batch
 	batch := db.NewBatch()
-	writeSkippedTransaction(batch, tx, traces, reason, blockNumber, blockHash)
+	writeSkippedTransaction(batch, tx, traces, rc, reason, blockNumber, blockHash)
+	writeSkippedTransactionBlock(batch, blockNumber, tx.Hash())
 	writeSkippedTransactionHash(batch, index, tx.Hash())
 	writeNumSkippedTransactions(batch, index+1)
 
@@ -263,3 +422,94 @@ func (it *SkippedTransactionIterator) TransactionHash() common.Hash {
 func (it *SkippedTransactionIterator) Release() {
 	it.inner.Release()
 }
+
+// SkippedTransactionReadmission is the audit record of an attempt to readmit a skipped transaction.
+type SkippedTransactionReadmission struct {
+	// TxHash is the hash of the skipped transaction.
+	TxHash common.Hash
+
+	// SkippedIndex is the index of the skipped transaction, see ReadSkippedTransactionHash.
+	SkippedIndex uint64
+
+	// BlockNumber is the number of the chain head at the time of the attempt.
+	BlockNumber uint64
+
+	// Time is the unix timestamp of the attempt.
+	Time uint64
+
+	// Readmitted is true if the transaction was added to the transaction pool.
+	Readmitted bool
+
+	// Error is the reason why the transaction was not readmitted.
+	Error string
+}
+
+// writeNumSkippedTransactionReadmissions writes the number of readmission audit records to the database.
+func writeNumSkippedTransactionReadmissions(db ethdb.KeyValueWriter, num uint64) {
+	value := big.NewInt(0).SetUint64(num).Bytes()
+
+	if err := db.Put(numSkippedTransactionReadmissionsKey, value); err != nil {
+		log.Crit("Failed to update the number of skipped transaction readmissions", "err", err)
+	}
+}
+
+// ReadNumSkippedTransactionReadmissions retrieves the number of readmission audit records.
+func ReadNumSkippedTransactionReadmissions(db ethdb.Reader) uint64 {
+	data, err := db.Get(numSkippedTransactionReadmissionsKey)
+	if err != nil && isNotFoundErr(err) {
+		return 0
+	}
+	if err != nil {
+		log.Crit("Failed to read number of skipped transaction readmissions from database", "err", err)
+	}
+	if len(data) == 0 {
+		return 0
+	}
+
+	number := new(big.Int).SetBytes(data)
+	if !number.IsUint64() {
+		log.Crit("Unexpected number of skipped transaction readmissions in database", "number", number)
+	}
+	return number.Uint64()
+}
+
+// WriteSkippedTransactionReadmission appends a readmission audit record to the database
+// and returns its index.
+func WriteSkippedTransactionReadmission(db ethdb.Database, readmission *SkippedTransactionReadmission) uint64 {
+	mu.Lock()
+	defer mu.Unlock()
+
+	index := ReadNumSkippedTransactionReadmissions(db)
+
+	bytes, err := rlp.EncodeToBytes(readmission)
+	if err != nil {
+		log.Crit("Failed to RLP encode skipped transaction readmission", "hash", readmission.TxHash.String(), "err", err)
+	}
+
+	batch := db.NewBatch()
+	if err := batch.Put(SkippedTransactionReadmissionKey(index), bytes); err != nil {
+		log.Crit("Failed to store skipped transaction readmission", "hash", readmission.TxHash.String(), "err", err)
+	}
+	writeNumSkippedTransactionReadmissions(batch, index+1)
+
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to store skipped transaction readmission", "hash", readmission.TxHash.String(), "err", err)
+	}
+	return index
+}
+
+// ReadSkippedTransactionReadmission retrieves a readmission audit record by its index.
+func ReadSkippedTransactionReadmission(db ethdb.Reader, index uint64) *SkippedTransactionReadmission {
+	data, err := db.Get(SkippedTransactionReadmissionKey(index))
+	if err != nil && isNotFoundErr(err) {
+		return nil
+	}
+	if err != nil {
+		log.Crit("Failed to load skipped transaction readmission", "index", index, "err", err)
+	}
+	var readmission SkippedTransactionReadmission
+	if err := rlp.Decode(bytes.NewReader(data), &readmission); err != nil {
+		log.Crit("Invalid skipped transaction readmission RLP", "index", index, "data", data, "err", err)
+	}
+	return &readmission
+}
@@ -2,6 +2,7 @@ package rawdb
 
 import (
 	"math/big"
+	"reflect"
 	"sync"
 	"testing"
 
@@ -44,7 +45,7 @@ func newTestTransaction(queueIndex uint64) *types.Transaction {
 func TestReadWriteSkippedTransactionNoIndex(t *testing.T) {
 	tx := newTestTransaction(123)
 	db := NewMemoryDatabase()
-	writeSkippedTransaction(db, tx, nil, "random reason", 1, &common.Hash{1})
+	writeSkippedTransaction(db, tx, nil, nil, "random reason", 1, &common.Hash{1})
 	got := ReadSkippedTransaction(db, tx.Hash())
 	if got == nil || got.Tx.Hash() != tx.Hash() || got.Reason != "random reason" || got.BlockNumber != 1 || got.BlockHash == nil || *got.BlockHash != (common.Hash{1}) {
 		t.Fatal("Skipped transaction mismatch", "got", got)
@@ -61,10 +62,24 @@ func TestReadSkippedTransactionV1AsV2(t *testing.T) {
 	}
 }
 
+func TestReadWriteSkippedTransactionRowConsumption(t *testing.T) {
+	tx := newTestTransaction(123)
+	db := NewMemoryDatabase()
+	rc := types.RowConsumption{{Name: "evm", RowNumber: 10}, {Name: "keccak", RowNumber: types.RowConsumptionLimit + 1}}
+	writeSkippedTransaction(db, tx, nil, rc, "random reason", 1, &common.Hash{1})
+	got := ReadSkippedTransaction(db, tx.Hash())
+	if got == nil || got.Tx.Hash() != tx.Hash() || got.Reason != "random reason" {
+		t.Fatal("Skipped transaction mismatch", "got", got)
+	}
+	if !reflect.DeepEqual(got.RowConsumption, rc) {
+		t.Fatal("Skipped transaction row consumption mismatch", "expected", rc, "got", got.RowConsumption)
+	}
+}
+
 func TestReadWriteSkippedTransaction(t *testing.T) {
 	tx := newTestTransaction(123)
 	db := NewMemoryDatabase()
-	WriteSkippedTransaction(db, tx, nil, "random reason", 1, &common.Hash{1})
+	WriteSkippedTransaction(db, tx, nil, nil, "random reason", 1, &common.Hash{1})
 	got := ReadSkippedTransaction(db, tx.Hash())
 	if got == nil || got.Tx.Hash() != tx.Hash() || got.Reason != "random reason" || got.BlockNumber != 1 || got.BlockHash == nil || *got.BlockHash != (common.Hash{1}) {
 		t.Fatal("Skipped transaction mismatch", "got", got)
@@ -88,7 +103,7 @@ func TestSkippedTransactionConcurrentUpdate(t *testing.T) {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			WriteSkippedTransaction(db, tx, nil, "random reason", 1, &common.Hash{1})
+			WriteSkippedTransaction(db, tx, nil, nil, "random reason", 1, &common.Hash{1})
 		}()
 	}
 	wg.Wait()
@@ -110,12 +125,12 @@ func TestIterateSkippedTransactions(t *testing.T) {
 	}
 
 	for _, tx := range txs {
-		WriteSkippedTransaction(db, tx, nil, "random reason", 1, &common.Hash{1})
+		WriteSkippedTransaction(db, tx, nil, nil, "random reason", 1, &common.Hash{1})
 	}
 
 	// simulate skipped L2 tx that's not included in the index
 	l2tx := newTestTransaction(6)
-	writeSkippedTransaction(db, l2tx, nil, "random reason", 1, &common.Hash{1})
+	writeSkippedTransaction(db, l2tx, nil, nil, "random reason", 1, &common.Hash{1})
 
 	it := IterateSkippedTransactionsFrom(db, 2)
 	defer it.Release()
@@ -142,3 +157,162 @@ func TestIterateSkippedTransactions(t *testing.T) {
 		t.Fatal("Iterator did not terminate")
 	}
 }
+
+func TestSetSkippedTransactionReadmitted(t *testing.T) {
+	tx := newTestTransaction(123)
+	db := NewMemoryDatabase()
+
+	if SetSkippedTransactionReadmitted(db, tx.Hash(), true) {
+		t.Fatal("Marked unknown transaction as readmitted")
+	}
+
+	WriteSkippedTransaction(db, tx, nil, nil, "random reason", 1, nil)
+	if !IsSkippedTransaction(db, tx.Hash()) {
+		t.Fatal("Transaction not skipped")
+	}
+
+	if !SetSkippedTransactionReadmitted(db, tx.Hash(), true) {
+		t.Fatal("Failed to mark transaction as readmitted")
+	}
+	if IsSkippedTransaction(db, tx.Hash()) {
+		t.Fatal("Readmitted transaction is still skipped")
+	}
+	got := ReadSkippedTransaction(db, tx.Hash())
+	if got == nil || !got.Readmitted || got.Reason != "random reason" || got.BlockNumber != 1 || got.BlockHash != nil {
+		t.Fatal("Skipped transaction mismatch", "got", got)
+	}
+
+	// skipping the transaction again resets the flag
+	WriteSkippedTransaction(db, tx, nil, nil, "another reason", 2, nil)
+	if !IsSkippedTransaction(db, tx.Hash()) {
+		t.Fatal("Transaction not skipped")
+	}
+}
+
+func TestReadWriteSkippedTransactionReadmission(t *testing.T) {
+	db := NewMemoryDatabase()
+
+	if got := ReadSkippedTransactionReadmission(db, 0); got != nil {
+		t.Fatal("Unexpected readmission", "got", got)
+	}
+
+	readmissions := []*SkippedTransactionReadmission{
+		{TxHash: common.Hash{1}, SkippedIndex: 0, BlockNumber: 10, Time: 100, Readmitted: true},
+		{TxHash: common.Hash{2}, SkippedIndex: 1, BlockNumber: 10, Time: 100, Error: "nonce too low"},
+	}
+	for ii, readmission := range readmissions {
+		if index := WriteSkippedTransactionReadmission(db, readmission); index != uint64(ii) {
+			t.Fatal("Readmission index mismatch", "expected", ii, "got", index)
+		}
+	}
+
+	if count := ReadNumSkippedTransactionReadmissions(db); count != uint64(len(readmissions)) {
+		t.Fatal("Readmission count mismatch", "expected", len(readmissions), "got", count)
+	}
+	for ii, readmission := range readmissions {
+		got := ReadSkippedTransactionReadmission(db, uint64(ii))
+		if !reflect.DeepEqual(got, readmission) {
+			t.Fatal("Readmission mismatch", "expected", readmission, "got", got)
+		}
+	}
+}
+
+func TestReadSkippedTransactionWithoutTraces(t *testing.T) {
+	db := NewMemoryDatabase()
+	rc := types.RowConsumption{{Name: "evm", RowNumber: types.RowConsumptionLimit + 1}}
+
+	tx := newTestTransaction(123)
+	writeSkippedTransaction(db, tx, &types.BlockTrace{ChainID: 1}, rc, "random reason", 1, &common.Hash{1})
+	SetSkippedTransactionReadmitted(db, tx.Hash(), true)
+	if full := ReadSkippedTransaction(db, tx.Hash()); full == nil || len(full.TracesBytes) == 0 {
+		t.Fatal("Skipped transaction traces missing", "got", full)
+	}
+	got := ReadSkippedTransactionWithoutTraces(db, tx.Hash())
+	if got == nil || got.Tx.Hash() != tx.Hash() || got.Reason != "random reason" || got.BlockNumber != 1 || got.BlockHash == nil || *got.BlockHash != (common.Hash{1}) || !got.Readmitted {
+		t.Fatal("Skipped transaction mismatch", "got", got)
+	}
+	if len(got.TracesBytes) != 0 {
+		t.Fatal("Skipped transaction traces decoded", "got", len(got.TracesBytes))
+	}
+	if !reflect.DeepEqual(got.RowConsumption, rc) {
+		t.Fatal("Skipped transaction row consumption mismatch", "expected", rc, "got", got.RowConsumption)
+	}
+
+	txV1 := newTestTransaction(124)
+	writeSkippedTransactionV1(db, txV1, "random reason", 2, &common.Hash{2})
+	got = ReadSkippedTransactionWithoutTraces(db, txV1.Hash())
+	if got == nil || got.Tx.Hash() != txV1.Hash() || got.Reason != "random reason" || got.BlockNumber != 2 || got.BlockHash == nil || *got.BlockHash != (common.Hash{2}) {
+		t.Fatal("Skipped transaction mismatch", "got", got)
+	}
+
+	if got := ReadSkippedTransactionWithoutTraces(db, common.Hash{3}); got != nil {
+		t.Fatal("Unexpected skipped transaction", "got", got)
+	}
+}
+
+func TestReadSkippedTransactionHashesInBlockRange(t *testing.T) {
+	db := NewMemoryDatabase()
+
+	// skipped in blocks 1, 2, 2, 3, 4, written out of block order as after a reorg
+	txs := []*types.Transaction{
+		newTestTransaction(1),
+		newTestTransaction(2),
+		newTestTransaction(3),
+		newTestTransaction(4),
+		newTestTransaction(5),
+	}
+	blocks := []uint64{1, 3, 2, 4, 2}
+	for i, tx := range txs {
+		WriteSkippedTransaction(db, tx, nil, nil, "random reason", blocks[i], &common.Hash{1})
+	}
+
+	hashes := ReadSkippedTransactionHashesInBlockRange(db, 2, 3, 10)
+	if len(hashes) != 3 {
+		t.Fatal("Skipped transaction count mismatch", "expected", 3, "got", len(hashes))
+	}
+	// block 2 first, then block 3
+	if hashes[2] != txs[1].Hash() {
+		t.Fatal("Skipped transaction hash mismatch", "expected", txs[1].Hash(), "got", hashes[2])
+	}
+	for _, hash := range hashes[:2] {
+		if hash != txs[2].Hash() && hash != txs[4].Hash() {
+			t.Fatal("Unexpected skipped transaction hash", "got", hash)
+		}
+	}
+
+	if hashes := ReadSkippedTransactionHashesInBlockRange(db, 0, 10, 2); len(hashes) != 2 {
+		t.Fatal("Skipped transaction limit not applied", "got", len(hashes))
+	}
+	if hashes := ReadSkippedTransactionHashesInBlockRange(db, 5, 10, 10); len(hashes) != 0 {
+		t.Fatal("Unexpected skipped transactions", "got", len(hashes))
+	}
+}
+
+func TestIndexSkippedTransactionBlocks(t *testing.T) {
+	db := NewMemoryDatabase()
+
+	// simulate transactions skipped before the block number index was introduced
+	txs := []*types.Transaction{newTestTransaction(1), newTestTransaction(2)}
+	for i, tx := range txs {
+		writeSkippedTransaction(db, tx, nil, nil, "random reason", uint64(i+1), &common.Hash{1})
+		writeSkippedTransactionHash(db, uint64(i), tx.Hash())
+	}
+	writeNumSkippedTransactions(db, uint64(len(txs)))
+	if hashes := ReadSkippedTransactionHashesInBlockRange(db, 0, 10, 10); len(hashes) != 0 {
+		t.Fatal("Unexpected skipped transactions", "got", len(hashes))
+	}
+
+	IndexSkippedTransactionBlocks(db)
+	hashes := ReadSkippedTransactionHashesInBlockRange(db, 0, 10, 10)
+	if len(hashes) != 2 || hashes[0] != txs[0].Hash() || hashes[1] != txs[1].Hash() {
+		t.Fatal("Skipped transaction hashes mismatch", "got", hashes)
+	}
+
+	// the index is only built once
+	writeSkippedTransaction(db, newTestTransaction(3), nil, nil, "random reason", 3, &common.Hash{1})
+	writeSkippedTransactionHash(db, 2, newTestTransaction(3).Hash())
+	IndexSkippedTransactionBlocks(db)
+	if hashes := ReadSkippedTransactionHashesInBlockRange(db, 0, 10, 10); len(hashes) != 2 {
+		t.Fatal("Skipped transactions indexed twice", "got", len(hashes))
+	}
+}
@@ -0,0 +1,321 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+
+	"github.com/scroll-tech/go-ethereum/common"
+	"github.com/scroll-tech/go-ethereum/ethdb"
+	"github.com/scroll-tech/go-ethereum/ethdb/memorydb"
+)
+
+// errOverlayNotFound is returned when a key is deleted in the overlay.
+var errOverlayNotFound = errors.New("not found")
+
+// overlay is a wrapper around a database that keeps all key-value modifications in
+// memory, leaving the underlying database untouched.
+type overlay struct {
+	db ethdb.Database
+
+	lock    sync.RWMutex
+	mem     *memorydb.Database  // keys written to the overlay
+	deleted map[string]struct{} // keys deleted in the overlay
+}
+
+// NewOverlayDatabase returns a database object that reads through to db, but keeps all
+// writes and deletions in memory. The ancient store of db is read-only through the overlay.
+func NewOverlayDatabase(db ethdb.Database) ethdb.Database {
+	return &overlay{
+		db:      db,
+		mem:     memorydb.New(),
+		deleted: make(map[string]struct{}),
+	}
+}
+
+// Close discards the in-memory modifications, the underlying database is not closed.
+func (o *overlay) Close() error {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	o.mem = memorydb.New()
+	o.deleted = make(map[string]struct{})
+	return nil
+}
+
+// Has retrieves if a key is present in the overlay or in the underlying database.
+func (o *overlay) Has(key []byte) (bool, error) {
+	o.lock.RLock()
+	defer o.lock.RUnlock()
+
+	if _, ok := o.deleted[string(key)]; ok {
+		return false, nil
+	}
+	if ok, _ := o.mem.Has(key); ok {
+		return true, nil
+	}
+	return o.db.Has(key)
+}
+
+// Get retrieves the given key from the overlay, or from the underlying database if it
+// was not modified.
+func (o *overlay) Get(key []byte) ([]byte, error) {
+	o.lock.RLock()
+	defer o.lock.RUnlock()
+
+	if _, ok := o.deleted[string(key)]; ok {
+		return nil, errOverlayNotFound
+	}
+	if value, err := o.mem.Get(key); err == nil {
+		return value, nil
+	}
+	return o.db.Get(key)
+}
+
+// HasAncient is a noop passthrough that just forwards the request to the underlying
+// database.
+func (o *overlay) HasAncient(kind string, number uint64) (bool, error) {
+	return o.db.HasAncient(kind, number)
+}
+
+// Ancient is a noop passthrough that just forwards the request to the underlying
+// database.
+func (o *overlay) Ancient(kind string, number uint64) ([]byte, error) {
+	return o.db.Ancient(kind, number)
+}
+
+// AncientRange is a noop passthrough that just forwards the request to the underlying
+// database.
+func (o *overlay) AncientRange(kind string, start, count, maxBytes uint64) ([][]byte, error) {
+	return o.db.AncientRange(kind, start, count, maxBytes)
+}
+
+// Ancients is a noop passthrough that just forwards the request to the underlying
+// database.
+func (o *overlay) Ancients() (uint64, error) {
+	return o.db.Ancients()
+}
+
+// AncientSize is a noop passthrough that just forwards the request to the underlying
+// database.
+func (o *overlay) AncientSize(kind string) (uint64, error) {
+	return o.db.AncientSize(kind)
+}
+
+// ReadAncients is a noop passthrough that just forwards the request to the underlying
+// database.
+func (o *overlay) ReadAncients(fn func(reader ethdb.AncientReader) error) (err error) {
+	return o.db.ReadAncients(fn)
+}
+
+// ModifyAncients is not supported, the ancient store is read-only.
+func (o *overlay) ModifyAncients(fn func(ethdb.AncientWriteOp) error) (int64, error) {
+	return 0, errReadOnly
+}
+
+// TruncateAncients is not supported, the ancient store is read-only.
+func (o *overlay) TruncateAncients(items uint64) error {
+	return errReadOnly
+}
+
+// Sync is a noop, there is no ancient data to flush.
+func (o *overlay) Sync() error {
+	return nil
+}
+
+// Put inserts the given value into the overlay.
+func (o *overlay) Put(key []byte, value []byte) error {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	delete(o.deleted, string(key))
+	return o.mem.Put(key, value)
+}
+
+// Delete removes the key from the overlay and hides it in the underlying database.
+func (o *overlay) Delete(key []byte) error {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	o.deleted[string(key)] = struct{}{}
+	return o.mem.Delete(key)
+}
+
+// isDeleted returns whether the key is deleted in the overlay.
+func (o *overlay) isDeleted(key []byte) bool {
+	o.lock.RLock()
+	defer o.lock.RUnlock()
+
+	_, ok := o.deleted[string(key)]
+	return ok
+}
+
+// NewIterator creates a binary-alphabetical iterator over a subset of the merged
+// content of the overlay and the underlying database with a particular key prefix,
+// starting at a particular initial key (or after, if it does not exist).
+func (o *overlay) NewIterator(prefix []byte, start []byte) ethdb.Iterator {
+	o.lock.RLock()
+	mem := o.mem.NewIterator(prefix, start)
+	o.lock.RUnlock()
+
+	it := &overlayIterator{
+		db:   o,
+		mem:  mem,
+		base: o.db.NewIterator(prefix, start),
+	}
+	it.memOk = it.mem.Next()
+	it.baseOk = it.base.Next()
+	return it
+}
+
+// Stat returns a particular internal stat of the underlying database.
+func (o *overlay) Stat(property string) (string, error) {
+	return o.db.Stat(property)
+}
+
+// Compact is a noop, the underlying database is not modified.
+func (o *overlay) Compact(start []byte, limit []byte) error {
+	return nil
+}
+
+// NewBatch creates a write-only database that buffers changes to the overlay
+// until a final write is called.
+func (o *overlay) NewBatch() ethdb.Batch {
+	return &overlayBatch{db: o}
+}
+
+// overlayIterator merges the iterators of the overlay and the underlying database,
+// preferring the overlay for keys present in both.
+type overlayIterator struct {
+	db *overlay
+
+	mem, base     ethdb.Iterator
+	memOk, baseOk bool // whether the iterators are positioned on an entry not yet consumed
+
+	key, value []byte
+}
+
+// Next moves the iterator to the next key/value pair. It returns whether the
+// iterator is exhausted.
+func (it *overlayIterator) Next() bool {
+	for it.baseOk && it.db.isDeleted(it.base.Key()) {
+		it.baseOk = it.base.Next()
+	}
+	switch {
+	case !it.memOk && !it.baseOk:
+		it.key, it.value = nil, nil
+		return false
+
+	case !it.baseOk || (it.memOk && bytes.Compare(it.mem.Key(), it.base.Key()) <= 0):
+		if it.baseOk && bytes.Equal(it.mem.Key(), it.base.Key()) {
+			it.baseOk = it.base.Next()
+		}
+		it.key, it.value = common.CopyBytes(it.mem.Key()), common.CopyBytes(it.mem.Value())
+		it.memOk = it.mem.Next()
+
+	default:
+		it.key, it.value = common.CopyBytes(it.base.Key()), common.CopyBytes(it.base.Value())
+		it.baseOk = it.base.Next()
+	}
+	return true
+}
+
+// Error returns any accumulated error of the merged iterators.
+func (it *overlayIterator) Error() error {
+	if err := it.mem.Error(); err != nil {
+		return err
+	}
+	return it.base.Error()
+}
+
+// Key returns the key of the current key/value pair, or nil if done.
+func (it *overlayIterator) Key() []byte {
+	return it.key
+}
+
+// Value returns the value of the current key/value pair, or nil if done.
+func (it *overlayIterator) Value() []byte {
+	return it.value
+}
+
+// Release releases associated resources.
+func (it *overlayIterator) Release() {
+	it.mem.Release()
+	it.base.Release()
+}
+
+// overlayWrite is a single write or deletion queued in an overlay batch.
+type overlayWrite struct {
+	key    []byte
+	value  []byte
+	delete bool
+}
+
+// overlayBatch is a batch that writes to the overlay.
+type overlayBatch struct {
+	db     *overlay
+	writes []overlayWrite
+	size   int
+}
+
+// Put inserts the given value into the batch for later committing.
+func (b *overlayBatch) Put(key, value []byte) error {
+	b.writes = append(b.writes, overlayWrite{common.CopyBytes(key), common.CopyBytes(value), false})
+	b.size += len(key) + len(value)
+	return nil
+}
+
+// Delete inserts the a key removal into the batch for later committing.
+func (b *overlayBatch) Delete(key []byte) error {
+	b.writes = append(b.writes, overlayWrite{common.CopyBytes(key), nil, true})
+	b.size += len(key)
+	return nil
+}
+
+// ValueSize retrieves the amount of data queued up for writing.
+func (b *overlayBatch) ValueSize() int {
+	return b.size
+}
+
+// Write flushes any accumulated data to the overlay.
+func (b *overlayBatch) Write() error {
+	return b.Replay(b.db)
+}
+
+// Reset resets the batch for reuse.
+func (b *overlayBatch) Reset() {
+	b.writes = b.writes[:0]
+	b.size = 0
+}
+
+// Replay replays the batch contents.
+func (b *overlayBatch) Replay(w ethdb.KeyValueWriter) error {
+	for _, write := range b.writes {
+		if write.delete {
+			if err := w.Delete(write.key); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := w.Put(write.key, write.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
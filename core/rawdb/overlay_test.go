@@ -0,0 +1,99 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestOverlayDatabase(t *testing.T) {
+	base := NewMemoryDatabase()
+	base.Put([]byte("a1"), []byte("base-a1"))
+	base.Put([]byte("a2"), []byte("base-a2"))
+	base.Put([]byte("a4"), []byte("base-a4"))
+	base.Put([]byte("b1"), []byte("base-b1"))
+
+	db := NewOverlayDatabase(base)
+	db.Put([]byte("a2"), []byte("overlay-a2"))
+	db.Put([]byte("a3"), []byte("overlay-a3"))
+	db.Delete([]byte("a4"))
+
+	batch := db.NewBatch()
+	batch.Put([]byte("a5"), []byte("overlay-a5"))
+	batch.Delete([]byte("a1"))
+	if err := batch.Write(); err != nil {
+		t.Fatalf("Failed to write batch: %v", err)
+	}
+
+	// reads see the modifications
+	want := map[string]string{"a2": "overlay-a2", "a3": "overlay-a3", "a5": "overlay-a5", "b1": "base-b1"}
+	for key, value := range want {
+		got, err := db.Get([]byte(key))
+		if err != nil {
+			t.Fatalf("Failed to get %s: %v", key, err)
+		}
+		if string(got) != value {
+			t.Fatalf("Value mismatch for %s: want=%s, got=%s", key, value, got)
+		}
+	}
+	for _, key := range []string{"a1", "a4"} {
+		if ok, _ := db.Has([]byte(key)); ok {
+			t.Fatalf("Deleted key %s still present", key)
+		}
+		if _, err := db.Get([]byte(key)); err == nil {
+			t.Fatalf("Deleted key %s still readable", key)
+		}
+	}
+
+	// iteration merges the overlay and the underlying database
+	it := db.NewIterator([]byte("a"), nil)
+	var keys, values []string
+	for it.Next() {
+		keys = append(keys, string(it.Key()))
+		values = append(values, string(it.Value()))
+	}
+	it.Release()
+	if err := it.Error(); err != nil {
+		t.Fatalf("Iterator failed: %v", err)
+	}
+	wantKeys := []string{"a2", "a3", "a5"}
+	if len(keys) != len(wantKeys) {
+		t.Fatalf("Iterated keys mismatch: want=%v, got=%v", wantKeys, keys)
+	}
+	for i, key := range wantKeys {
+		if keys[i] != key || values[i] != want[key] {
+			t.Fatalf("Iterated entry %d mismatch: want=%s:%s, got=%s:%s", i, key, want[key], keys[i], values[i])
+		}
+	}
+
+	// the underlying database is untouched
+	for key, value := range map[string]string{"a1": "base-a1", "a2": "base-a2", "a4": "base-a4"} {
+		got, err := base.Get([]byte(key))
+		if err != nil || !bytes.Equal(got, []byte(value)) {
+			t.Fatalf("Underlying database modified at %s: got=%s, err=%v", key, got, err)
+		}
+	}
+	for _, key := range []string{"a3", "a5"} {
+		if ok, _ := base.Has([]byte(key)); ok {
+			t.Fatalf("Overlay write %s leaked into the underlying database", key)
+		}
+	}
+	if err := db.TruncateAncients(0); err == nil {
+		t.Fatal("Truncating the ancient store through the overlay succeeded")
+	}
+}
@@ -109,22 +109,33 @@ var (
 	l1MessagePrefix                   = []byte("L1") // l1MessagePrefix + queueIndex (uint64 big endian) -> L1MessageTx
 	firstQueueIndexNotInL2BlockPrefix = []byte("q")  // firstQueueIndexNotInL2BlockPrefix + L2 block hash -> enqueue index
 	highestSyncedQueueIndexKey        = []byte("HighestSyncedQueueIndex")
+	l1BlockCheckpointPrefix           = []byte("Q-l1b") // l1BlockCheckpointPrefix + L1 block number (uint64 big endian) -> L1BlockCheckpoint
+	l1MessageSyncTimePrefix           = []byte("Q-st")  // l1MessageSyncTimePrefix + queueIndex (uint64 big endian) -> unix time the L1 message was synced
 
 	// Scroll rollup event store
 	rollupEventSyncedL1BlockNumberKey = []byte("R-LastRollupEventSyncedL1BlockNumber")
 	batchChunkRangesPrefix            = []byte("R-bcr")
 	batchMetaPrefix                   = []byte("R-bm")
 	finalizedL2BlockNumberKey         = []byte("R-finalized")
+	committedL2BlockNumberKey         = []byte("R-committed")
 	lastFinalizedBatchIndexKey        = []byte("R-finalizedBatchIndex")
 	committedBatchMetaPrefix          = []byte("R-cbm")
+	batchValidationFailurePrefix      = []byte("R-bvf")
+	divergedBatchIndexKey             = []byte("R-divergedBatchIndex")
+	rolledBackBatchIndexKey           = []byte("R-rolledBackBatchIndex")
+	l2BlockBatchIndexPrefix           = []byte("R-l2b")
 
 	// Row consumption
 	rowConsumptionPrefix = []byte("rc") // rowConsumptionPrefix + hash -> row consumption by block
 
 	// Skipped transactions
-	numSkippedTransactionsKey    = []byte("NumberOfSkippedTransactions")
-	skippedTransactionPrefix     = []byte("skip") // skippedTransactionPrefix + tx hash -> skipped transaction
-	skippedTransactionHashPrefix = []byte("sh")   // skippedTransactionHashPrefix + index -> tx hash
+	numSkippedTransactionsKey            = []byte("NumberOfSkippedTransactions")
+	skippedTransactionPrefix             = []byte("skip") // skippedTransactionPrefix + tx hash -> skipped transaction
+	skippedTransactionHashPrefix         = []byte("sh")   // skippedTransactionHashPrefix + index -> tx hash
+	skippedTransactionBlockPrefix        = []byte("sb")   // skippedTransactionBlockPrefix + block number + tx hash -> empty
+	skippedTransactionBlockIndexedKey    = []byte("SkippedTransactionBlockIndexed")
+	numSkippedTransactionReadmissionsKey = []byte("NumberOfSkippedTransactionReadmissions")
+	skippedTransactionReadmissionPrefix  = []byte("sr") // skippedTransactionReadmissionPrefix + index -> readmission audit record
 )
 
 // Use the updated "L1" prefix on all new networks
@@ -282,6 +293,16 @@ func FirstQueueIndexNotInL2BlockKey(l2BlockHash common.Hash) []byte {
 	return append(firstQueueIndexNotInL2BlockPrefix, l2BlockHash.Bytes()...)
 }
 
+// L1BlockCheckpointKey = l1BlockCheckpointPrefix + L1 block number (uint64 big endian)
+func L1BlockCheckpointKey(l1BlockNumber uint64) []byte {
+	return append(l1BlockCheckpointPrefix, encodeBigEndian(l1BlockNumber)...)
+}
+
+// L1MessageSyncTimeKey = l1MessageSyncTimePrefix + queueIndex (uint64 big endian)
+func L1MessageSyncTimeKey(queueIndex uint64) []byte {
+	return append(l1MessageSyncTimePrefix, encodeBigEndian(queueIndex)...)
+}
+
 // rowConsumptionKey = rowConsumptionPrefix + hash
 func rowConsumptionKey(hash common.Hash) []byte {
 	return append(rowConsumptionPrefix, hash.Bytes()...)
@@ -301,6 +322,16 @@ func SkippedTransactionHashKey(index uint64) []byte {
 	return append(skippedTransactionHashPrefix, encodeBigEndian(index)...)
 }
 
+// SkippedTransactionBlockKey = skippedTransactionBlockPrefix + block number (uint64 big endian) + tx hash
+func SkippedTransactionBlockKey(blockNumber uint64, txHash common.Hash) []byte {
+	return append(append(skippedTransactionBlockPrefix, encodeBigEndian(blockNumber)...), txHash.Bytes()...)
+}
+
+// SkippedTransactionReadmissionKey = skippedTransactionReadmissionPrefix + index (uint64 big endian)
+func SkippedTransactionReadmissionKey(index uint64) []byte {
+	return append(skippedTransactionReadmissionPrefix, encodeBigEndian(index)...)
+}
+
 // batchChunkRangesKey = batchChunkRangesPrefix + batch index (uint64 big endian)
 func batchChunkRangesKey(batchIndex uint64) []byte {
 	return append(batchChunkRangesPrefix, encodeBigEndian(batchIndex)...)
@@ -315,3 +346,13 @@ func batchMetaKey(batchIndex uint64) []byte {
 func committedBatchMetaKey(batchIndex uint64) []byte {
 	return append(committedBatchMetaPrefix, encodeBigEndian(batchIndex)...)
 }
+
+// batchValidationFailureKey = batchValidationFailurePrefix + batch index (uint64 big endian)
+func batchValidationFailureKey(batchIndex uint64) []byte {
+	return append(batchValidationFailurePrefix, encodeBigEndian(batchIndex)...)
+}
+
+// l2BlockBatchIndexKey = l2BlockBatchIndexPrefix + last L2 block number of the batch (uint64 big endian)
+func l2BlockBatchIndexKey(endBlockNumber uint64) []byte {
+	return append(l2BlockBatchIndexPrefix, encodeBigEndian(endBlockNumber)...)
+}
@@ -145,7 +145,13 @@ func (s *StateDB) DumpToCollector(c DumpCollector, conf *DumpConfig) (nextKey []
 	it := trie.NewIterator(s.trie.NodeIterator(conf.Start))
 	for it.Next() {
 		var data types.StateAccount
-		if err := rlp.DecodeBytes(it.Value, &data); err != nil {
+		if s.IsZktrie() {
+			account, err := types.UnmarshalStateAccount(it.Value)
+			if err != nil {
+				panic(err)
+			}
+			data = *account
+		} else if err := rlp.DecodeBytes(it.Value, &data); err != nil {
 			panic(err)
 		}
 		account := DumpAccount{
@@ -175,10 +181,13 @@ func (s *StateDB) DumpToCollector(c DumpCollector, conf *DumpConfig) (nextKey []
 			account.Storage = make(map[common.Hash]string)
 			storageIt := trie.NewIterator(obj.getTrie(s.db).NodeIterator(nil))
 			for storageIt.Next() {
-				_, content, _, err := rlp.Split(storageIt.Value)
-				if err != nil {
-					log.Error("Failed to decode the value returned by iterator", "error", err)
-					continue
+				content := storageIt.Value
+				if !s.IsZktrie() {
+					var err error
+					if _, content, _, err = rlp.Split(storageIt.Value); err != nil {
+						log.Error("Failed to decode the value returned by iterator", "error", err)
+						continue
+					}
 				}
 				account.Storage[common.BytesToHash(s.trie.GetKey(storageIt.Key))] = common.Bytes2Hex(content)
 			}
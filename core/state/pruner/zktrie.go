@@ -0,0 +1,304 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package pruner
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/scroll-tech/go-ethereum/common"
+	"github.com/scroll-tech/go-ethereum/core/rawdb"
+	"github.com/scroll-tech/go-ethereum/core/types"
+	"github.com/scroll-tech/go-ethereum/ethdb"
+	"github.com/scroll-tech/go-ethereum/log"
+	"github.com/scroll-tech/go-ethereum/trie"
+)
+
+// zktrieBloomFilePrefix is the filename prefix of the zktrie state bloom filter.
+// It differs from the one of the snapshot based pruner, so that the recovery of
+// either pruner never picks up the bloom filter of the other.
+const zktrieBloomFilePrefix = "zktriebloom"
+
+// ZktriePruner is an offline tool to prune the stale state of a zktrie database.
+// Unlike Pruner, it doesn't depend on the snapshot but walks the tries instead:
+//
+//   - iterate the tries of the most recent states, the state of the snapshot disk
+//     layer and the genesis state, marking all their nodes and codes in the bloom
+//   - iterate the database, delete all other state entries
+//
+// As the zktrie nodes are persisted under their hashes just like the MPT nodes,
+// the deletion is shared with Pruner.
+type ZktriePruner struct {
+	db            ethdb.Database
+	stateBloom    *stateBloom
+	datadir       string
+	trieCachePath string
+	headHeader    *types.Header
+	retain        uint64
+}
+
+// NewZktriePruner creates the zktrie pruner instance, which keeps the states of
+// the given number of most recent blocks.
+func NewZktriePruner(db ethdb.Database, datadir, trieCachePath string, bloomSize uint64, retain uint64) (*ZktriePruner, error) {
+	headBlock := rawdb.ReadHeadBlock(db)
+	if headBlock == nil {
+		return nil, errors.New("Failed to load head block")
+	}
+	if retain == 0 {
+		return nil, errors.New("no state to retain")
+	}
+	// Sanitize the bloom filter size if it's too small.
+	if bloomSize < 256 {
+		log.Warn("Sanitizing bloomfilter size", "provided(MB)", bloomSize, "updated(MB)", 256)
+		bloomSize = 256
+	}
+	stateBloom, err := newStateBloomWithSize(bloomSize)
+	if err != nil {
+		return nil, err
+	}
+	return &ZktriePruner{
+		db:            db,
+		stateBloom:    stateBloom,
+		datadir:       datadir,
+		trieCachePath: trieCachePath,
+		headHeader:    headBlock.Header(),
+		retain:        retain,
+	}, nil
+}
+
+// Prune deletes all state nodes and codes except the ones belonging to the
+// states of the most recent blocks, the snapshot disk layer and the genesis.
+func (p *ZktriePruner) Prune() error {
+	// If the state bloom filter is already committed previously,
+	// reuse it for pruning instead of generating a new one. It's
+	// mandatory because a part of state may already be deleted,
+	// the recovery procedure is necessary.
+	_, stateBloomRoot, err := findBloomFilter(p.datadir, zktrieBloomFilePrefix)
+	if err != nil {
+		return err
+	}
+	if stateBloomRoot != (common.Hash{}) {
+		return RecoverZktriePruning(p.datadir, p.db, p.trieCachePath)
+	}
+	roots, err := p.retainedRoots()
+	if err != nil {
+		return err
+	}
+	// Before start the pruning, delete the clean trie cache first.
+	// It's necessary otherwise in the next restart we will hit the
+	// deleted state root in the "clean cache" so that the incomplete
+	// state is picked for usage.
+	deleteCleanTrieCache(p.trieCachePath)
+
+	// Traverse the retained states and commit all their nodes and codes to
+	// the bloom filter.
+	start := time.Now()
+	if err := markZktrieStates(p.db, roots, p.stateBloom); err != nil {
+		return err
+	}
+	filterName := bloomFilterName(p.datadir, zktrieBloomFilePrefix, p.headHeader.Root)
+
+	log.Info("Writing state bloom to disk", "name", filterName)
+	if err := p.stateBloom.Commit(filterName, filterName+stateBloomFileTempSuffix); err != nil {
+		return err
+	}
+	log.Info("State bloom filter committed", "name", filterName)
+	return pruneZktrie(p.db, p.stateBloom, filterName, start)
+}
+
+// retainedRoots collects the state roots to keep: the ones of the most recent
+// blocks which are present in the database, the root of the snapshot disk layer
+// and the genesis root.
+func (p *ZktriePruner) retainedRoots() ([]common.Hash, error) {
+	var (
+		roots []common.Hash
+		seen  = make(map[common.Hash]struct{})
+	)
+	add := func(root common.Hash) {
+		if _, ok := seen[root]; ok || root == (common.Hash{}) {
+			return
+		}
+		seen[root] = struct{}{}
+		roots = append(roots, root)
+	}
+	if !hasZktrieNode(p.db, p.headHeader.Root) {
+		return nil, fmt.Errorf("associated state[%x] is not present", p.headHeader.Root)
+	}
+	head := p.headHeader.Number.Uint64()
+	for number := head; number+p.retain > head; number-- {
+		header := rawdb.ReadHeader(p.db, rawdb.ReadCanonicalHash(p.db, number), number)
+		if header == nil {
+			return nil, fmt.Errorf("missing header %d", number)
+		}
+		// States which are not persisted can't be retained, only the state of
+		// every few blocks is flushed to disk in non-archive mode.
+		if hasZktrieNode(p.db, header.Root) {
+			add(header.Root)
+		} else {
+			log.Debug("Skipping unavailable state", "number", number, "root", header.Root)
+		}
+		if number == 0 {
+			break
+		}
+	}
+	log.Info("Selected recent states as the pruning target", "states", len(roots), "blocks", p.retain)
+
+	// The generation of the snapshot needs the trie of its disk layer, keep it
+	// as long as it is there.
+	if root := rawdb.ReadSnapshotRoot(p.db); hasZktrieNode(p.db, root) {
+		add(root)
+	}
+	genesis := rawdb.ReadHeader(p.db, rawdb.ReadCanonicalHash(p.db, 0), 0)
+	if genesis == nil {
+		return nil, errors.New("missing genesis block")
+	}
+	if hasZktrieNode(p.db, genesis.Root) {
+		add(genesis.Root)
+	}
+	return roots, nil
+}
+
+// RecoverZktriePruning resumes an interrupted zktrie pruning during the system
+// restart. Once the bloom filter is committed, a part of the state may already
+// be deleted and the pruning has to be resumed, like in RecoverPruning.
+func RecoverZktriePruning(datadir string, db ethdb.Database, trieCachePath string) error {
+	stateBloomPath, _, err := findBloomFilter(datadir, zktrieBloomFilePrefix)
+	if err != nil {
+		return err
+	}
+	if stateBloomPath == "" {
+		return nil // nothing to recover
+	}
+	stateBloom, err := NewStateBloomFromDisk(stateBloomPath)
+	if err != nil {
+		return err
+	}
+	log.Info("Loaded state bloom filter", "path", stateBloomPath)
+
+	// Before start the pruning, delete the clean trie cache first.
+	// It's necessary otherwise in the next restart we will hit the
+	// deleted state root in the "clean cache" so that the incomplete
+	// state is picked for usage.
+	deleteCleanTrieCache(trieCachePath)
+
+	return pruneZktrie(db, stateBloom, stateBloomPath, time.Now())
+}
+
+func pruneZktrie(maindb ethdb.Database, stateBloom *stateBloom, bloomPath string, start time.Time) error {
+	count, size, err := deleteStaleState(maindb, stateBloom, nil)
+	if err != nil {
+		return err
+	}
+	// Delete the state bloom, it marks the entire pruning procedure is
+	// finished. If any crashes or manual exit happens before this,
+	// `RecoverZktriePruning` will pick it up in the next restarts to redo
+	// the deletion.
+	os.RemoveAll(bloomPath)
+
+	// Start compactions, will remove the deleted data from the disk immediately.
+	// Note for small pruning, the compaction is skipped.
+	if count >= rangeCompactionThreshold {
+		if err := compactDatabase(maindb); err != nil {
+			return err
+		}
+	}
+	log.Info("State pruning successful", "pruned", size, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// hasZktrieNode reports whether the zktrie node with the given hash is present
+// in the database.
+func hasZktrieNode(db ethdb.KeyValueReader, hash common.Hash) bool {
+	if hash == (common.Hash{}) {
+		return false
+	}
+	ok, _ := db.Has(trie.ZktrieNodeKey(hash))
+	return ok
+}
+
+// markZktrieStates walks the account and storage tries of the given states and
+// commits all their nodes and codes into the given bloom filter. The account trie
+// of each state is only walked where it differs from the previous state, and
+// storage tries are mostly shared between the states, so each of them is only
+// walked once.
+func markZktrieStates(db ethdb.Database, roots []common.Hash, stateBloom *stateBloom) error {
+	var (
+		zkdb    = trie.NewZktrieDatabaseFromTriedb(trie.NewDatabaseWithConfig(db, &trie.Config{Zktrie: true}))
+		visited = make(map[common.Hash]struct{})
+		nodes   int
+		start   = time.Now()
+		logged  = time.Now()
+	)
+	markNodes := func(it trie.NodeIterator, onLeaf func(blob []byte) error) error {
+		for it.Next(true) {
+			stateBloom.Put(trie.ZktrieNodeKey(it.Hash()), nil)
+			nodes++
+
+			if it.Leaf() && onLeaf != nil {
+				if err := onLeaf(it.LeafBlob()); err != nil {
+					return err
+				}
+			}
+			if time.Since(logged) > 8*time.Second {
+				log.Info("Marking state data", "nodes", nodes, "elapsed", common.PrettyDuration(time.Since(start)))
+				logged = time.Now()
+			}
+		}
+		return it.Error()
+	}
+	markAccount := func(blob []byte) error {
+		acc, err := types.UnmarshalStateAccount(blob)
+		if err != nil {
+			return err
+		}
+		if _, ok := visited[acc.Root]; !ok && acc.Root != (common.Hash{}) {
+			t, err := trie.NewZkTrie(acc.Root, zkdb)
+			if err != nil {
+				return err
+			}
+			if err := markNodes(t.NodeIterator(nil), nil); err != nil {
+				return err
+			}
+			visited[acc.Root] = struct{}{}
+		}
+		if !bytes.Equal(acc.KeccakCodeHash, emptyKeccakCodeHash) {
+			stateBloom.Put(acc.KeccakCodeHash, nil)
+		}
+		return nil
+	}
+	var prev *trie.ZkTrie
+	for _, root := range roots {
+		t, err := trie.NewZkTrie(root, zkdb)
+		if err != nil {
+			return err
+		}
+		it := t.NodeIterator(nil)
+		if prev != nil {
+			// the nodes shared with the previous state are marked already
+			it, _ = trie.NewDifferenceIterator(prev.NodeIterator(nil), it)
+		}
+		if err := markNodes(it, markAccount); err != nil {
+			return err
+		}
+		prev = t
+		log.Info("Marked state", "root", root, "nodes", nodes, "elapsed", common.PrettyDuration(time.Since(start)))
+	}
+	return nil
+}
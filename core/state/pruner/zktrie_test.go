@@ -0,0 +1,371 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package pruner
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/scroll-tech/go-ethereum/common"
+	"github.com/scroll-tech/go-ethereum/core/rawdb"
+	"github.com/scroll-tech/go-ethereum/core/state"
+	"github.com/scroll-tech/go-ethereum/core/types"
+	"github.com/scroll-tech/go-ethereum/ethdb"
+	"github.com/scroll-tech/go-ethereum/trie"
+)
+
+var testZktrieContract = common.Address{0xcc}
+
+// makeZktrieChain writes a chain of the given number of blocks on top of a genesis
+// block, persisting the state of every block. Every block funds a new account and
+// changes the storage of a shared contract, so that the tries of older states go
+// stale. It returns the state roots of all blocks.
+func makeZktrieChain(t *testing.T, db ethdb.Database, blocks int) []common.Hash {
+	t.Helper()
+
+	var (
+		sdb    = state.NewDatabaseWithConfig(db, &trie.Config{Zktrie: true})
+		roots  []common.Hash
+		root   common.Hash
+		parent common.Hash
+	)
+	for number := int64(0); number <= int64(blocks); number++ {
+		statedb, err := state.New(root, sdb, nil)
+		if err != nil {
+			t.Fatalf("failed to open state %d: %v", number, err)
+		}
+		if number == 0 {
+			statedb.SetCode(testZktrieContract, []byte{0x60, 0x01, 0x00})
+		}
+		statedb.AddBalance(common.BigToAddress(big.NewInt(number+1)), big.NewInt(number+1))
+		statedb.SetState(testZktrieContract, common.Hash{}, common.BigToHash(big.NewInt(number+1)))
+		statedb.SetState(testZktrieContract, common.BigToHash(big.NewInt(number+1)), common.BigToHash(big.NewInt(number+1)))
+
+		if root, err = statedb.Commit(false); err != nil {
+			t.Fatalf("failed to commit state %d: %v", number, err)
+		}
+		if err := sdb.TrieDB().Commit(root, false, nil); err != nil {
+			t.Fatalf("failed to flush state %d: %v", number, err)
+		}
+		block := types.NewBlockWithHeader(&types.Header{
+			ParentHash: parent,
+			Number:     big.NewInt(number),
+			Root:       root,
+			Difficulty: common.Big0,
+		})
+		rawdb.WriteBlock(db, block)
+		rawdb.WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+		rawdb.WriteHeadBlockHash(db, block.Hash())
+
+		roots = append(roots, root)
+		parent = block.Hash()
+	}
+	return roots
+}
+
+// newTestZktriePruner creates a zktrie pruner with a small bloom filter.
+func newTestZktriePruner(t *testing.T, db ethdb.Database, datadir string, retain uint64) *ZktriePruner {
+	t.Helper()
+
+	stateBloom, err := newStateBloomWithSize(1)
+	if err != nil {
+		t.Fatalf("failed to create state bloom: %v", err)
+	}
+	return &ZktriePruner{
+		db:         db,
+		stateBloom: stateBloom,
+		datadir:    datadir,
+		headHeader: rawdb.ReadHeadBlock(db).Header(),
+		retain:     retain,
+	}
+}
+
+// dumpZktrieState reads the entire state of the given root, including the storage
+// and code of every account, and returns it along with the database keys of all
+// its trie nodes and codes.
+func dumpZktrieState(t *testing.T, db ethdb.Database, root common.Hash) (map[string]string, map[string]struct{}) {
+	t.Helper()
+
+	var (
+		zkdb  = trie.NewZktrieDatabase(db)
+		dump  = make(map[string]string)
+		keys  = make(map[string]struct{})
+		visit func(root common.Hash, prefix string, onLeaf func(key, value []byte))
+	)
+	visit = func(root common.Hash, prefix string, onLeaf func(key, value []byte)) {
+		tr, err := trie.NewZkTrie(root, zkdb)
+		if err != nil {
+			t.Fatalf("failed to open trie %x: %v", root, err)
+		}
+		it := tr.NodeIterator(nil)
+		for it.Next(true) {
+			keys[string(trie.ZktrieNodeKey(it.Hash()))] = struct{}{}
+			if it.Leaf() {
+				dump[prefix+string(it.LeafKey())] = string(it.LeafBlob())
+				if onLeaf != nil {
+					onLeaf(it.LeafKey(), it.LeafBlob())
+				}
+			}
+		}
+		if err := it.Error(); err != nil {
+			t.Fatalf("failed to iterate trie %x: %v", root, err)
+		}
+	}
+	visit(root, "", func(key, value []byte) {
+		account, err := types.UnmarshalStateAccount(value)
+		if err != nil {
+			t.Fatalf("invalid account %x: %v", key, err)
+		}
+		if account.Root != (common.Hash{}) {
+			visit(account.Root, fmt.Sprintf("%x/", key), nil)
+		}
+		if !bytes.Equal(account.KeccakCodeHash, emptyKeccakCodeHash) {
+			code := rawdb.ReadCode(db, common.BytesToHash(account.KeccakCodeHash))
+			if len(code) == 0 {
+				t.Fatalf("missing code of account %x", key)
+			}
+			dump[fmt.Sprintf("%x/code", key)] = string(code)
+			keys[string(append(common.CopyBytes(rawdb.CodePrefix), account.KeccakCodeHash...))] = struct{}{}
+		}
+	})
+	return dump, keys
+}
+
+// stateKeys returns the keys of all trie nodes and codes in the database.
+func stateKeys(db ethdb.Database) map[string]struct{} {
+	keys := make(map[string]struct{})
+	it := db.NewIterator(nil, nil)
+	defer it.Release()
+	for it.Next() {
+		if isCode, _ := rawdb.IsCodeKey(it.Key()); isCode || len(it.Key()) == common.HashLength {
+			keys[string(it.Key())] = struct{}{}
+		}
+	}
+	return keys
+}
+
+// checkPrunedState checks that the database contains exactly the trie nodes and codes
+// of the retained states, and that these states are unchanged.
+func checkPrunedState(t *testing.T, db ethdb.Database, dumps map[common.Hash]map[string]string, retained map[string]struct{}) {
+	t.Helper()
+
+	for root, want := range dumps {
+		have, _ := dumpZktrieState(t, db, root)
+		if !reflect.DeepEqual(have, want) {
+			t.Fatalf("retained state %x changed by pruning", root)
+		}
+	}
+	for key := range stateKeys(db) {
+		if _, ok := retained[key]; !ok {
+			t.Fatalf("stale state entry %x not deleted", key)
+		}
+	}
+}
+
+func TestZktrieRetainedRoots(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	roots := makeZktrieChain(t, db, 8)
+
+	// the most recent states and the genesis state are retained
+	p := newTestZktriePruner(t, db, t.TempDir(), 3)
+	have, err := p.retainedRoots()
+	if err != nil {
+		t.Fatalf("failed to select retained roots: %v", err)
+	}
+	if want := []common.Hash{roots[8], roots[7], roots[6], roots[0]}; !reflect.DeepEqual(have, want) {
+		t.Fatalf("retained roots mismatch: have %x, want %x", have, want)
+	}
+
+	// the snapshot disk layer is retained as well, unavailable states are skipped
+	rawdb.WriteSnapshotRoot(db, roots[2])
+	db.Delete(trie.ZktrieNodeKey(roots[7]))
+	have, err = p.retainedRoots()
+	if err != nil {
+		t.Fatalf("failed to select retained roots: %v", err)
+	}
+	if want := []common.Hash{roots[8], roots[6], roots[2], roots[0]}; !reflect.DeepEqual(have, want) {
+		t.Fatalf("retained roots mismatch: have %x, want %x", have, want)
+	}
+
+	// retaining more blocks than the chain has stops at the genesis
+	p = newTestZktriePruner(t, db, t.TempDir(), 100)
+	if have, err = p.retainedRoots(); err != nil {
+		t.Fatalf("failed to select retained roots: %v", err)
+	}
+	if len(have) != len(roots)-1 {
+		t.Fatalf("retained roots mismatch: have %d, want %d", len(have), len(roots)-1)
+	}
+
+	// the head state must be available
+	db.Delete(trie.ZktrieNodeKey(roots[8]))
+	if _, err := p.retainedRoots(); err == nil {
+		t.Fatal("selected retained roots without head state")
+	}
+}
+
+func TestZktriePrune(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	roots := makeZktrieChain(t, db, 8)
+
+	var (
+		dumps    = make(map[common.Hash]map[string]string)
+		retained = make(map[string]struct{})
+	)
+	for _, root := range []common.Hash{roots[8], roots[7], roots[6], roots[0]} {
+		dump, keys := dumpZktrieState(t, db, root)
+		dumps[root] = dump
+		for key := range keys {
+			retained[key] = struct{}{}
+		}
+	}
+	if len(stateKeys(db)) <= len(retained) {
+		t.Fatal("test chain has no stale state")
+	}
+
+	datadir := t.TempDir()
+	if err := newTestZktriePruner(t, db, datadir, 3).Prune(); err != nil {
+		t.Fatalf("failed to prune: %v", err)
+	}
+	checkPrunedState(t, db, dumps, retained)
+
+	// the stale states are gone and the pruning is complete
+	for _, root := range roots[1:6] {
+		if hasZktrieNode(db, root) {
+			t.Fatalf("stale state root %x not deleted", root)
+		}
+	}
+	if path, _, _ := findBloomFilter(datadir, zktrieBloomFilePrefix); path != "" {
+		t.Fatalf("state bloom %s left after pruning", path)
+	}
+}
+
+func TestZktriePruneResume(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	roots := makeZktrieChain(t, db, 8)
+
+	var (
+		dumps    = make(map[common.Hash]map[string]string)
+		retained = make(map[string]struct{})
+	)
+	for _, root := range []common.Hash{roots[8], roots[7], roots[6], roots[0]} {
+		dump, keys := dumpZktrieState(t, db, root)
+		dumps[root] = dump
+		for key := range keys {
+			retained[key] = struct{}{}
+		}
+	}
+
+	// nothing to recover without a committed bloom filter
+	datadir := t.TempDir()
+	before := len(stateKeys(db))
+	if err := RecoverZktriePruning(datadir, db, ""); err != nil {
+		t.Fatalf("failed to recover: %v", err)
+	}
+	if after := len(stateKeys(db)); after != before {
+		t.Fatalf("recovery without bloom filter deleted state: have %d entries, want %d", after, before)
+	}
+
+	// interrupt a pruning after the bloom filter is committed and a part of the
+	// stale state is deleted
+	p := newTestZktriePruner(t, db, datadir, 3)
+	targets, err := p.retainedRoots()
+	if err != nil {
+		t.Fatalf("failed to select retained roots: %v", err)
+	}
+	if err := markZktrieStates(db, targets, p.stateBloom); err != nil {
+		t.Fatalf("failed to mark states: %v", err)
+	}
+	filterName := bloomFilterName(datadir, zktrieBloomFilePrefix, roots[8])
+	if err := p.stateBloom.Commit(filterName, filterName+stateBloomFileTempSuffix); err != nil {
+		t.Fatalf("failed to commit state bloom: %v", err)
+	}
+	var stale int
+	for key := range stateKeys(db) {
+		if _, ok := retained[key]; ok {
+			continue
+		}
+		if stale%2 == 0 {
+			db.Delete([]byte(key))
+		}
+		stale++
+	}
+	if stale < 2 {
+		t.Fatal("test chain has too little stale state")
+	}
+
+	// restarting the pruning picks up the committed bloom filter and finishes the deletion
+	if err := newTestZktriePruner(t, db, datadir, 3).Prune(); err != nil {
+		t.Fatalf("failed to resume pruning: %v", err)
+	}
+	checkPrunedState(t, db, dumps, retained)
+	if _, err := os.Stat(filterName); !os.IsNotExist(err) {
+		t.Fatalf("state bloom left after resumed pruning: %v", err)
+	}
+}
+
+// nodeReadCounter counts the trie node reads from the underlying database.
+type nodeReadCounter struct {
+	ethdb.Database
+	reads int
+}
+
+func (db *nodeReadCounter) Get(key []byte) ([]byte, error) {
+	db.reads++
+	return db.Database.Get(key)
+}
+
+func TestZktrieMarkSharedStates(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	roots := makeZktrieChain(t, db, 32)
+	head := roots[len(roots)-1]
+
+	markedReads := func(targets []common.Hash) int {
+		t.Helper()
+		stateBloom, err := newStateBloomWithSize(1)
+		if err != nil {
+			t.Fatalf("failed to create state bloom: %v", err)
+		}
+		counter := &nodeReadCounter{Database: db}
+		if err := markZktrieStates(counter, targets, stateBloom); err != nil {
+			t.Fatalf("failed to mark states: %v", err)
+		}
+		for _, root := range targets {
+			_, keys := dumpZktrieState(t, db, root)
+			for key := range keys {
+				checkKey := []byte(key)
+				if isCode, codeKey := rawdb.IsCodeKey(checkKey); isCode {
+					checkKey = codeKey
+				}
+				if ok, _ := stateBloom.Contain(checkKey); !ok {
+					t.Fatalf("state entry %x of root %x not marked", key, root)
+				}
+			}
+		}
+		return counter.reads
+	}
+	// the account trie of a state is not walked again where it is shared with the previous state
+	once := markedReads([]common.Hash{head})
+	twice := markedReads([]common.Hash{head, head})
+	if twice-once > 10 {
+		t.Fatalf("shared state walked again: %d node reads for one state, %d for the same state twice", once, twice)
+	}
+	markedReads([]common.Hash{head, roots[len(roots)-2], roots[0]})
+}
@@ -42,8 +42,9 @@ type trieKV struct {
 
 type (
 	// trieGeneratorFn is the interface of trie generation which can
-	// be implemented by different trie algorithm.
-	trieGeneratorFn func(db ethdb.KeyValueWriter, in chan (trieKV), out chan (common.Hash))
+	// be implemented by different trie algorithm. The generator must
+	// consume all leaves and deliver a root even if it fails.
+	trieGeneratorFn func(db ethdb.KeyValueWriter, in chan (trieKV), out chan (common.Hash)) error
 
 	// leafCallbackFn is the callback invoked at the leaves of the trie,
 	// returns the subtrie root with the specified subtrie identifier.
@@ -71,7 +72,8 @@ func GenerateTrie(snaptree *Tree, root common.Hash, src ethdb.Database, dst ethd
 	}
 	defer acctIt.Release()
 
-	got, err := generateTrieRoot(dst, acctIt, common.Hash{}, stackTrieGenerate, func(dst ethdb.KeyValueWriter, accountHash, codeHash common.Hash, stat *generateStats) (common.Hash, error) {
+	accountGen, storageGen := snaptree.trieGenerators()
+	got, err := generateTrieRoot(dst, acctIt, common.Hash{}, accountGen, func(dst ethdb.KeyValueWriter, accountHash, codeHash common.Hash, stat *generateStats) (common.Hash, error) {
 		// Migrate the code first, commit the contract code into the tmp db.
 		if codeHash != emptyKeccakCode {
 			code := rawdb.ReadCode(src, codeHash)
@@ -87,7 +89,7 @@ func GenerateTrie(snaptree *Tree, root common.Hash, src ethdb.Database, dst ethd
 		}
 		defer storageIt.Release()
 
-		hash, err := generateTrieRoot(dst, storageIt, accountHash, stackTrieGenerate, nil, stat, false)
+		hash, err := generateTrieRoot(dst, storageIt, accountHash, storageGen, nil, stat, false)
 		if err != nil {
 			return common.Hash{}, err
 		}
@@ -246,6 +248,7 @@ func generateTrieRoot(db ethdb.KeyValueWriter, it Iterator, account common.Hash,
 	var (
 		in      = make(chan trieKV)         // chan to pass leaves
 		out     = make(chan common.Hash, 1) // chan to collect result
+		genErr  = make(chan error, 1)       // chan to collect generation error
 		stoplog = make(chan bool, 1)        // 1-size buffer, works when logging is not enabled
 		wg      sync.WaitGroup
 	)
@@ -253,7 +256,7 @@ func generateTrieRoot(db ethdb.KeyValueWriter, it Iterator, account common.Hash,
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		generatorFn(db, in, out)
+		genErr <- generatorFn(db, in, out)
 	}()
 	// Spin up a go-routine for progress logging
 	if report && stats != nil {
@@ -276,6 +279,9 @@ func generateTrieRoot(db ethdb.KeyValueWriter, it Iterator, account common.Hash,
 	stop := func(fail error) (common.Hash, error) {
 		close(in)
 		result := <-out
+		if err := <-genErr; err != nil && fail == nil {
+			fail = err
+		}
 		for i := 0; i < threads; i++ {
 			if err := <-results; err != nil && fail == nil {
 				fail = err
@@ -360,7 +366,7 @@ func generateTrieRoot(db ethdb.KeyValueWriter, it Iterator, account common.Hash,
 	return stop(nil)
 }
 
-func stackTrieGenerate(db ethdb.KeyValueWriter, in chan trieKV, out chan common.Hash) {
+func stackTrieGenerate(db ethdb.KeyValueWriter, in chan trieKV, out chan common.Hash) error {
 	t := trie.NewStackTrie(db)
 	for leaf := range in {
 		t.TryUpdate(leaf.key[:], leaf.value)
@@ -372,4 +378,5 @@ func stackTrieGenerate(db ethdb.KeyValueWriter, in chan trieKV, out chan common.
 		root, _ = t.Commit()
 	}
 	out <- root
+	return nil
 }
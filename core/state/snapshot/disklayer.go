@@ -45,6 +45,15 @@ type diskLayer struct {
 	lock sync.RWMutex
 }
 
+// compareKeys compares two snapshot keys in the order the generator covers them,
+// which is the iteration order of the trie.
+func (dl *diskLayer) compareKeys(a, b []byte) int {
+	if dl.triedb != nil && dl.triedb.Zktrie {
+		return compareZkKeys(a, b)
+	}
+	return bytes.Compare(a, b)
+}
+
 // Root returns  root hash for which this snapshot was made.
 func (dl *diskLayer) Root() common.Hash {
 	return dl.root
@@ -94,7 +103,7 @@ func (dl *diskLayer) AccountRLP(hash common.Hash) ([]byte, error) {
 	}
 	// If the layer is being generated, ensure the requested hash has already been
 	// covered by the generator.
-	if dl.genMarker != nil && bytes.Compare(hash[:], dl.genMarker) > 0 {
+	if dl.genMarker != nil && dl.compareKeys(hash[:], dl.genMarker) > 0 {
 		return nil, ErrNotCoveredYet
 	}
 	// If we're in the disk layer, all diff layers missed
@@ -134,7 +143,7 @@ func (dl *diskLayer) Storage(accountHash, storageHash common.Hash) ([]byte, erro
 
 	// If the layer is being generated, ensure the requested hash has already been
 	// covered by the generator.
-	if dl.genMarker != nil && bytes.Compare(key, dl.genMarker) > 0 {
+	if dl.genMarker != nil && dl.compareKeys(key, dl.genMarker) > 0 {
 		return nil, ErrNotCoveredYet
 	}
 	// If we're in the disk layer, all diff layers missed
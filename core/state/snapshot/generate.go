@@ -539,6 +539,10 @@ func (dl *diskLayer) generateRange(root common.Hash, prefix []byte, kind string,
 // gathering and logging, since the method surfs the blocks as they arrive, often
 // being restarted.
 func (dl *diskLayer) generate(stats *generatorStats) {
+	if dl.triedb.Zktrie {
+		dl.generateZktrie(stats)
+		return
+	}
 	var (
 		accMarker    []byte
 		accountRange = accountCheckRange
@@ -185,9 +185,6 @@ type Tree struct {
 //   a background thread.
 func New(diskdb ethdb.KeyValueStore, triedb *trie.Database, cache int, root common.Hash, async bool, rebuild bool, recovery bool) (*Tree, error) {
 	// Create a new, empty snapshot tree
-	if triedb.Zktrie {
-		panic("zktrie does not support snapshot yet")
-	}
 	snap := &Tree{
 		diskdb: diskdb,
 		triedb: triedb,
@@ -540,7 +537,7 @@ func diffToDisk(bottom *diffLayer) *diskLayer {
 	// Destroy all the destructed accounts from the database
 	for hash := range bottom.destructSet {
 		// Skip any account not covered yet by the snapshot
-		if base.genMarker != nil && bytes.Compare(hash[:], base.genMarker) > 0 {
+		if base.genMarker != nil && base.compareKeys(hash[:], base.genMarker) > 0 {
 			continue
 		}
 		// Remove all storage slots
@@ -570,7 +567,7 @@ func diffToDisk(bottom *diffLayer) *diskLayer {
 	// Push all updated accounts into the database
 	for hash, data := range bottom.accountData {
 		// Skip any account not covered yet by the snapshot
-		if base.genMarker != nil && bytes.Compare(hash[:], base.genMarker) > 0 {
+		if base.genMarker != nil && base.compareKeys(hash[:], base.genMarker) > 0 {
 			continue
 		}
 		// Push the account to disk
@@ -594,7 +591,7 @@ func diffToDisk(bottom *diffLayer) *diskLayer {
 	// Push all the storage slots into the database
 	for accountHash, storage := range bottom.storageData {
 		// Skip any account not covered yet by the snapshot
-		if base.genMarker != nil && bytes.Compare(accountHash[:], base.genMarker) > 0 {
+		if base.genMarker != nil && base.compareKeys(accountHash[:], base.genMarker) > 0 {
 			continue
 		}
 		// Generation might be mid-account, track that case too
@@ -602,7 +599,7 @@ func diffToDisk(bottom *diffLayer) *diskLayer {
 
 		for storageHash, data := range storage {
 			// Skip any slot not covered yet by the snapshot
-			if midAccount && bytes.Compare(storageHash[:], base.genMarker[common.HashLength:]) > 0 {
+			if midAccount && base.compareKeys(storageHash[:], base.genMarker[common.HashLength:]) > 0 {
 				continue
 			}
 			if len(data) > 0 {
@@ -770,14 +767,15 @@ func (t *Tree) Verify(root common.Hash) error {
 	}
 	defer acctIt.Release()
 
-	got, err := generateTrieRoot(nil, acctIt, common.Hash{}, stackTrieGenerate, func(db ethdb.KeyValueWriter, accountHash, codeHash common.Hash, stat *generateStats) (common.Hash, error) {
+	accountGen, storageGen := t.trieGenerators()
+	got, err := generateTrieRoot(nil, acctIt, common.Hash{}, accountGen, func(db ethdb.KeyValueWriter, accountHash, codeHash common.Hash, stat *generateStats) (common.Hash, error) {
 		storageIt, err := t.StorageIterator(root, accountHash, common.Hash{})
 		if err != nil {
 			return common.Hash{}, err
 		}
 		defer storageIt.Release()
 
-		hash, err := generateTrieRoot(nil, storageIt, accountHash, stackTrieGenerate, nil, stat, false)
+		hash, err := generateTrieRoot(nil, storageIt, accountHash, storageGen, nil, stat, false)
 		if err != nil {
 			return common.Hash{}, err
 		}
@@ -0,0 +1,294 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/bits"
+	"time"
+
+	zkt "github.com/scroll-tech/zktrie/types"
+
+	"github.com/scroll-tech/go-ethereum/common"
+	"github.com/scroll-tech/go-ethereum/core/rawdb"
+	"github.com/scroll-tech/go-ethereum/core/types"
+	"github.com/scroll-tech/go-ethereum/ethdb"
+	"github.com/scroll-tech/go-ethereum/ethdb/memorydb"
+	"github.com/scroll-tech/go-ethereum/log"
+	"github.com/scroll-tech/go-ethereum/rlp"
+	"github.com/scroll-tech/go-ethereum/trie"
+)
+
+// The snapshot of a zktrie state is keyed by the Poseidon secure keys of the
+// accounts and storage slots, which are the node keys of the trie leaves. The
+// leaves of a zktrie are iterated in the order of their bit-reversed node keys
+// though, so the generator tracks its progress in that order.
+
+// compareZkKeys compares two snapshot keys, made up of one or more hashes, in the
+// iteration order of the zktrie.
+func compareZkKeys(a, b []byte) int {
+	for len(a) >= common.HashLength && len(b) >= common.HashLength {
+		// The path of a leaf starts with the least significant bit of its node key
+		for i := common.HashLength - 1; i >= 0; i-- {
+			if x, y := bits.Reverse8(a[i]), bits.Reverse8(b[i]); x != y {
+				if x < y {
+					return -1
+				}
+				return 1
+			}
+		}
+		a, b = a[common.HashLength:], b[common.HashLength:]
+	}
+	return bytes.Compare(a, b)
+}
+
+// zkAccountFields converts a full RLP encoded account into the fields of its leaf
+// in the zktrie.
+func zkAccountFields(blob []byte) (uint32, []zkt.Byte32, error) {
+	var account types.StateAccount
+	if err := rlp.DecodeBytes(blob, &account); err != nil {
+		return 0, nil, err
+	}
+	fields, flag := account.MarshalFields()
+	return flag, fields, nil
+}
+
+// zkStorageFields converts a storage slot into the fields of its leaf in the zktrie.
+func zkStorageFields(blob []byte) (uint32, []zkt.Byte32, error) {
+	return 1, []zkt.Byte32{*zkt.NewByte32FromBytes(blob)}, nil
+}
+
+// zktrieGenerate returns a trie generator which inserts the leaves into a zktrie
+// by their node keys, converting the values with the given function. Unlike the
+// stack trie, the trie is built in memory, as the leaves are not delivered in the
+// iteration order of the zktrie, so generating a trie needs memory proportional
+// to its size.
+func zktrieGenerate(leafFields func([]byte) (uint32, []zkt.Byte32, error)) trieGeneratorFn {
+	return func(db ethdb.KeyValueWriter, in chan trieKV, out chan common.Hash) error {
+		var (
+			memdb  = memorydb.New()
+			triedb = trie.NewDatabaseWithConfig(memdb, &trie.Config{Zktrie: true})
+			fail   error
+		)
+		t, err := trie.NewZkTrie(common.Hash{}, trie.NewZktrieDatabaseFromTriedb(triedb))
+		if err != nil {
+			fail = err
+		}
+		for leaf := range in {
+			// keep consuming the leaves after a failure, the feeder stops on its own
+			if fail != nil {
+				continue
+			}
+			flag, fields, err := leafFields(leaf.value)
+			if err == nil {
+				err = t.Tree().TryUpdate(zkt.NewHashFromBytes(leaf.key[:]), flag, fields)
+			}
+			if err != nil {
+				fail = fmt.Errorf("failed to insert zktrie leaf %x: %w", leaf.key, err)
+			}
+		}
+		if fail != nil {
+			out <- common.Hash{}
+			return fail
+		}
+		if db == nil {
+			out <- t.Hash()
+			return nil
+		}
+		root, _, err := t.Commit(nil)
+		if err == nil {
+			err = triedb.Commit(root, false, nil)
+		}
+		if err != nil {
+			out <- common.Hash{}
+			return err
+		}
+		it := memdb.NewIterator(nil, nil)
+		defer it.Release()
+		for it.Next() {
+			if err := db.Put(it.Key(), it.Value()); err != nil {
+				out <- common.Hash{}
+				return err
+			}
+		}
+		out <- root
+		return nil
+	}
+}
+
+// trieGenerators returns the trie generators reproducing the account trie and the
+// storage tries of the state.
+func (t *Tree) trieGenerators() (trieGeneratorFn, trieGeneratorFn) {
+	if t.triedb != nil && t.triedb.Zktrie {
+		return zktrieGenerate(zkAccountFields), zktrieGenerate(zkStorageFields)
+	}
+	return stackTrieGenerate, stackTrieGenerate
+}
+
+// generateZktrie is the snapshot generator of zktrie states. As the snapshot isn't
+// ordered like the trie, existing snapshot data can't be verified range by range
+// with the trie. Instead, the stale data is wiped when the generation starts and
+// the tries are iterated in full, writing out all accounts and storage slots.
+func (dl *diskLayer) generateZktrie(stats *generatorStats) {
+	var (
+		accMarker   []byte
+		storeMarker []byte
+	)
+	if len(dl.genMarker) > 0 { // []byte{} is the start, use nil for that
+		accMarker = dl.genMarker[:common.HashLength]
+		if len(dl.genMarker) > common.HashLength {
+			storeMarker = dl.genMarker[common.HashLength:]
+		}
+	}
+	var (
+		batch  = dl.diskdb.NewBatch()
+		logged = time.Now()
+		abort  chan *generatorStats
+	)
+	stats.Log("Resuming state snapshot generation", dl.root, dl.genMarker)
+
+	checkAndFlush := func(currentLocation []byte) error {
+		select {
+		case abort = <-dl.genAbort:
+		default:
+		}
+		if batch.ValueSize() > ethdb.IdealBatchSize || abort != nil {
+			journalProgress(batch, currentLocation, stats)
+
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+
+			dl.lock.Lock()
+			dl.genMarker = currentLocation
+			dl.lock.Unlock()
+
+			if abort != nil {
+				stats.Log("Aborting state snapshot generation", dl.root, currentLocation)
+				return errors.New("aborted")
+			}
+		}
+		if time.Since(logged) > 8*time.Second {
+			stats.Log("Generating state snapshot", dl.root, currentLocation)
+			logged = time.Now()
+		}
+		return nil
+	}
+
+	generateStorage := func(accountHash common.Hash, root common.Hash, origin []byte) error {
+		tr, err := trie.NewZkTrie(root, trie.NewZktrieDatabaseFromTriedb(dl.triedb))
+		if err != nil {
+			stats.Log("Trie missing, state snapshotting paused", dl.root, dl.genMarker)
+			return errMissingTrie
+		}
+		it := trie.NewIterator(tr.NodeIterator(origin))
+		for it.Next() {
+			rawdb.WriteStorageSnapshot(batch, accountHash, common.BytesToHash(it.Key), it.Value)
+			snapGeneratedStorageMeter.Mark(1)
+			stats.storage += common.StorageSize(1 + 2*common.HashLength + len(it.Value))
+			stats.slots++
+
+			// If we've exceeded our batch allowance or termination was requested, flush to disk
+			if err := checkAndFlush(append(accountHash.Bytes(), it.Key...)); err != nil {
+				return err
+			}
+		}
+		return it.Err
+	}
+
+	generateAccounts := func() error {
+		// Nothing is covered by the snapshot before the first flush, so wiping the
+		// stale data doesn't interfere with the flattening of the diff layers.
+		if accMarker == nil {
+			if err := wipeContent(dl.diskdb); err != nil {
+				return err
+			}
+		}
+		tr, err := trie.NewZkTrie(dl.root, trie.NewZktrieDatabaseFromTriedb(dl.triedb))
+		if err != nil {
+			stats.Log("Trie missing, state snapshotting paused", dl.root, dl.genMarker)
+			return errMissingTrie
+		}
+		it := trie.NewIterator(tr.NodeIterator(accMarker))
+		for it.Next() {
+			accountHash := common.BytesToHash(it.Key)
+			acc, err := types.UnmarshalStateAccount(it.Value)
+			if err != nil {
+				log.Crit("Invalid account encountered during snapshot creation", "err", err)
+			}
+			// If the account is not yet in-progress, write it out
+			var (
+				marker      = accountHash.Bytes()
+				storeOrigin []byte
+			)
+			if accMarker != nil && bytes.Equal(marker, accMarker) {
+				if storeMarker != nil {
+					marker, storeOrigin = common.CopyBytes(dl.genMarker), storeMarker
+				}
+			} else {
+				data := SlimAccountRLP(acc.Nonce, acc.Balance, acc.Root, acc.KeccakCodeHash, acc.PoseidonCodeHash, acc.CodeSize)
+				rawdb.WriteAccountSnapshot(batch, accountHash, data)
+				snapGeneratedAccountMeter.Mark(1)
+				stats.storage += common.StorageSize(1 + common.HashLength + len(data))
+				stats.accounts++
+			}
+			// If we've exceeded our batch allowance or termination was requested, flush to disk
+			if err := checkAndFlush(marker); err != nil {
+				return err
+			}
+			if acc.Root != (common.Hash{}) {
+				if err := generateStorage(accountHash, acc.Root, storeOrigin); err != nil {
+					return err
+				}
+			}
+		}
+		return it.Err
+	}
+
+	if err := generateAccounts(); err != nil {
+		if abort == nil { // aborted by internal error, wait the signal
+			abort = <-dl.genAbort
+		}
+		abort <- stats
+		return
+	}
+	// Snapshot fully generated, set the marker to nil.
+	journalProgress(batch, nil, stats)
+	if err := batch.Write(); err != nil {
+		log.Error("Failed to flush batch", "err", err)
+
+		abort = <-dl.genAbort
+		abort <- stats
+		return
+	}
+	batch.Reset()
+
+	log.Info("Generated state snapshot", "accounts", stats.accounts, "slots", stats.slots,
+		"storage", stats.storage, "elapsed", common.PrettyDuration(time.Since(stats.start)))
+
+	dl.lock.Lock()
+	dl.genMarker = nil
+	close(dl.genPending)
+	dl.lock.Unlock()
+
+	// Someone will be looking for us, wait it out
+	abort = <-dl.genAbort
+	abort <- nil
+}
@@ -0,0 +1,292 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/fastcache"
+
+	"github.com/scroll-tech/go-ethereum/common"
+	"github.com/scroll-tech/go-ethereum/core/rawdb"
+	"github.com/scroll-tech/go-ethereum/core/types"
+	"github.com/scroll-tech/go-ethereum/ethdb"
+	"github.com/scroll-tech/go-ethereum/ethdb/memorydb"
+	"github.com/scroll-tech/go-ethereum/trie"
+)
+
+// makeZkState creates a zktrie state of n accounts, every other of which has a
+// storage trie of n slots, and commits it to the disk database.
+func makeZkState(diskdb ethdb.KeyValueStore, n int) (*trie.Database, common.Hash) {
+	var (
+		triedb = trie.NewDatabaseWithConfig(diskdb, &trie.Config{Zktrie: true})
+		zkdb   = trie.NewZktrieDatabaseFromTriedb(triedb)
+	)
+	accTrie, _ := trie.NewZkTrie(common.Hash{}, zkdb)
+	for i := 0; i < n; i++ {
+		acc := &types.StateAccount{
+			Nonce:            uint64(i),
+			Balance:          big.NewInt(int64(i)),
+			KeccakCodeHash:   emptyKeccakCode.Bytes(),
+			PoseidonCodeHash: emptyPoseidonCode.Bytes(),
+		}
+		if i%2 == 0 {
+			stTrie, _ := trie.NewZkTrie(common.Hash{}, zkdb)
+			for j := 0; j < n; j++ {
+				stTrie.Update(common.BigToHash(big.NewInt(int64(j))).Bytes(), common.BigToHash(big.NewInt(int64(i*n+j+1))).Bytes())
+			}
+			acc.Root, _, _ = stTrie.Commit(nil)
+		}
+		accTrie.TryUpdateAccount(common.BigToAddress(big.NewInt(int64(i))).Bytes(), acc)
+	}
+	root, _, _ := accTrie.Commit(nil)
+	triedb.Commit(root, false, nil)
+	return triedb, root
+}
+
+// zkStateKeys returns the keys of the accounts and storage slots of a zktrie state
+// in iteration order.
+func zkStateKeys(t *testing.T, triedb *trie.Database, root common.Hash) [][]byte {
+	accTrie, err := trie.NewZkTrie(root, trie.NewZktrieDatabaseFromTriedb(triedb))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys [][]byte
+	accIt := trie.NewIterator(accTrie.NodeIterator(nil))
+	for accIt.Next() {
+		keys = append(keys, common.CopyBytes(accIt.Key))
+
+		acc, err := types.UnmarshalStateAccount(accIt.Value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if acc.Root == (common.Hash{}) {
+			continue
+		}
+		stTrie, err := trie.NewZkTrie(acc.Root, trie.NewZktrieDatabaseFromTriedb(triedb))
+		if err != nil {
+			t.Fatal(err)
+		}
+		stIt := trie.NewIterator(stTrie.NodeIterator(nil))
+		for stIt.Next() {
+			keys = append(keys, append(common.CopyBytes(accIt.Key), stIt.Key...))
+		}
+	}
+	if accIt.Err != nil {
+		t.Fatal(accIt.Err)
+	}
+	return keys
+}
+
+func checkZkSnapRoot(t *testing.T, snap *diskLayer, trieRoot common.Hash) {
+	t.Helper()
+	snaps := &Tree{diskdb: snap.diskdb, triedb: snap.triedb, layers: map[common.Hash]snapshot{snap.root: snap}}
+	if err := snaps.Verify(trieRoot); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func waitGeneration(t *testing.T, snap *diskLayer) {
+	t.Helper()
+	select {
+	case <-snap.genPending:
+		// Snapshot generation succeeded
+
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Snapshot generation failed")
+	}
+}
+
+func stopGeneration(snap *diskLayer) {
+	stop := make(chan *generatorStats)
+	snap.genAbort <- stop
+	<-stop
+}
+
+// Tests that the keys of the zktrie are compared in iteration order.
+func TestCompareZkKeys(t *testing.T) {
+	diskdb := memorydb.New()
+	triedb, root := makeZkState(diskdb, 32)
+
+	keys := zkStateKeys(t, triedb, root)
+	for i := 1; i < len(keys); i++ {
+		if compareZkKeys(keys[i-1], keys[i]) >= 0 {
+			t.Fatalf("key %d %x not ordered before key %d %x", i-1, keys[i-1], i, keys[i])
+		}
+		if compareZkKeys(keys[i], keys[i-1]) <= 0 {
+			t.Fatalf("key %d %x not ordered after key %d %x", i, keys[i], i-1, keys[i-1])
+		}
+	}
+	for _, key := range keys {
+		if compareZkKeys(key, key) != 0 {
+			t.Fatalf("key %x not equal to itself", key)
+		}
+		if compareZkKeys(key, []byte{}) <= 0 {
+			t.Fatalf("key %x not ordered after the empty marker", key)
+		}
+	}
+}
+
+// Tests that a snapshot is generated from a zktrie state and that stale snapshot
+// data is dropped.
+func TestZktrieGeneration(t *testing.T) {
+	diskdb := memorydb.New()
+	triedb, root := makeZkState(diskdb, 16)
+
+	stale := common.HexToHash("0xdeadbeef")
+	rawdb.WriteAccountSnapshot(diskdb, stale, SlimAccountRLP(1, big.NewInt(1), common.Hash{}, emptyKeccakCode.Bytes(), emptyPoseidonCode.Bytes(), 0))
+	rawdb.WriteStorageSnapshot(diskdb, stale, stale, stale.Bytes())
+
+	snap := generateSnapshot(diskdb, triedb, 16, root)
+	waitGeneration(t, snap)
+	defer stopGeneration(snap)
+
+	checkZkSnapRoot(t, snap, root)
+
+	if blob := rawdb.ReadAccountSnapshot(diskdb, stale); len(blob) != 0 {
+		t.Errorf("stale account not wiped: %x", blob)
+	}
+	if blob := rawdb.ReadStorageSnapshot(diskdb, stale, stale); len(blob) != 0 {
+		t.Errorf("stale storage slot not wiped: %x", blob)
+	}
+	// Check that the snapshot is keyed by the node keys of the trie
+	tr, _ := trie.NewZkTrie(root, trie.NewZktrieDatabaseFromTriedb(triedb))
+	for it := trie.NewIterator(tr.NodeIterator(nil)); it.Next(); {
+		acc, err := snap.Account(common.BytesToHash(it.Key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, _ := types.UnmarshalStateAccount(it.Value)
+		if acc == nil || acc.Nonce != want.Nonce || acc.Balance.Cmp(want.Balance) != 0 || !bytes.Equal(acc.Root, want.Root[:]) {
+			t.Fatalf("account %x mismatch: have %v, want %v", it.Key, acc, want)
+		}
+	}
+}
+
+// Tests that an interrupted zktrie snapshot generation is resumed from the marker,
+// including a marker in the middle of a storage trie.
+func TestZktrieGenerationResume(t *testing.T) {
+	// Generate the complete snapshot to compare against
+	fulldb := memorydb.New()
+	triedb, root := makeZkState(fulldb, 16)
+	snap := generateSnapshot(fulldb, triedb, 16, root)
+	waitGeneration(t, snap)
+	stopGeneration(snap)
+
+	// Resume the generation in the middle of a storage trie, with the data covered
+	// by the marker already generated
+	keys := zkStateKeys(t, triedb, root)
+	var marker []byte
+	for i := len(keys) / 2; i < len(keys); i++ {
+		if len(keys[i]) == 2*common.HashLength {
+			marker = keys[i]
+			break
+		}
+	}
+	if marker == nil {
+		t.Fatal("no storage slot after the middle of the state")
+	}
+	diskdb := memorydb.New()
+	triedb, _ = makeZkState(diskdb, 16)
+	it := fulldb.NewIterator(nil, nil)
+	for it.Next() {
+		key := it.Key()
+		switch {
+		case bytes.HasPrefix(key, rawdb.SnapshotAccountPrefix) && len(key) == len(rawdb.SnapshotAccountPrefix)+common.HashLength:
+			key = key[len(rawdb.SnapshotAccountPrefix):]
+		case bytes.HasPrefix(key, rawdb.SnapshotStoragePrefix) && len(key) == len(rawdb.SnapshotStoragePrefix)+2*common.HashLength:
+			key = key[len(rawdb.SnapshotStoragePrefix):]
+		default:
+			continue
+		}
+		if compareZkKeys(key, marker) <= 0 {
+			diskdb.Put(it.Key(), it.Value())
+		}
+	}
+	it.Release()
+
+	snap = &diskLayer{
+		diskdb:     diskdb,
+		triedb:     triedb,
+		root:       root,
+		cache:      fastcache.New(16 * 1024 * 1024),
+		genMarker:  marker,
+		genPending: make(chan struct{}),
+		genAbort:   make(chan chan *generatorStats),
+	}
+	// Check that only the data covered by the marker is available
+	for _, key := range keys {
+		var err error
+		if len(key) == common.HashLength {
+			_, err = snap.AccountRLP(common.BytesToHash(key))
+		} else {
+			_, err = snap.Storage(common.BytesToHash(key[:common.HashLength]), common.BytesToHash(key[common.HashLength:]))
+		}
+		if covered := compareZkKeys(key, marker) <= 0; covered && err != nil {
+			t.Fatalf("key %x covered by the marker not available: %v", key, err)
+		} else if !covered && err != ErrNotCoveredYet {
+			t.Fatalf("key %x not covered by the marker available: %v", key, err)
+		}
+	}
+	go snap.generate(&generatorStats{start: time.Now()})
+	waitGeneration(t, snap)
+	defer stopGeneration(snap)
+
+	checkZkSnapRoot(t, snap, root)
+	for _, prefix := range [][]byte{rawdb.SnapshotAccountPrefix, rawdb.SnapshotStoragePrefix} {
+		want, have := fulldb.NewIterator(prefix, nil), diskdb.NewIterator(prefix, nil)
+		for want.Next() {
+			if !have.Next() {
+				t.Fatalf("missing snapshot entry %x", want.Key())
+			}
+			if !bytes.Equal(want.Key(), have.Key()) || !bytes.Equal(want.Value(), have.Value()) {
+				t.Fatalf("snapshot entry mismatch: have %x: %x, want %x: %x", have.Key(), have.Value(), want.Key(), want.Value())
+			}
+		}
+		if have.Next() {
+			t.Fatalf("extra snapshot entry %x", have.Key())
+		}
+		want.Release()
+		have.Release()
+	}
+}
+
+// Tests that the zktrie generator reports leaves it fails to insert instead of
+// producing a root without them.
+func TestZktrieGenerateLeafError(t *testing.T) {
+	var (
+		in   = make(chan trieKV)
+		out  = make(chan common.Hash, 1)
+		errc = make(chan error, 1)
+	)
+	go func() {
+		errc <- zktrieGenerate(zkAccountFields)(nil, in, out)
+	}()
+	in <- trieKV{common.Hash{1}, []byte{0xde, 0xad}} // not an RLP encoded account
+	in <- trieKV{common.Hash{2}, []byte{0xbe, 0xef}} // leaves are consumed after the failure
+	close(in)
+
+	if root := <-out; root != (common.Hash{}) {
+		t.Errorf("unexpected root %x", root)
+	}
+	if err := <-errc; err == nil {
+		t.Fatal("leaf insertion error not reported")
+	}
+}
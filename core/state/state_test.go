@@ -23,6 +23,7 @@ import (
 
 	"github.com/scroll-tech/go-ethereum/common"
 	"github.com/scroll-tech/go-ethereum/core/rawdb"
+	"github.com/scroll-tech/go-ethereum/core/state/snapshot"
 	"github.com/scroll-tech/go-ethereum/ethdb"
 	"github.com/scroll-tech/go-ethereum/trie"
 )
@@ -96,6 +97,81 @@ func TestDump(t *testing.T) {
 	}
 }
 
+func TestZktrieDump(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	sdb, _ := New(common.Hash{}, NewDatabaseWithConfig(db, &trie.Config{Preimages: true, Zktrie: true}), nil)
+
+	// generate a few entries
+	addr1, addr2 := common.BytesToAddress([]byte{0x01}), common.BytesToAddress([]byte{0x01, 0x02})
+	sdb.AddBalance(addr1, big.NewInt(22))
+	sdb.SetCode(addr2, []byte{3, 3, 3, 3, 3, 3, 3})
+	sdb.SetState(addr2, common.HexToHash("0x1"), common.HexToHash("0x2a"))
+	sdb.Commit(false)
+
+	dump := sdb.RawDump(nil)
+	if len(dump.Accounts) != 2 {
+		t.Fatalf("account count mismatch: got %d, want 2", len(dump.Accounts))
+	}
+	if balance := dump.Accounts[addr1].Balance; balance != "22" {
+		t.Errorf("balance mismatch: got %s, want 22", balance)
+	}
+	account := dump.Accounts[addr2]
+	if !bytes.Equal(account.Code, []byte{3, 3, 3, 3, 3, 3, 3}) {
+		t.Errorf("code mismatch: got %x", account.Code)
+	}
+	if value := account.Storage[common.HexToHash("0x1")]; value != common.Bytes2Hex(common.HexToHash("0x2a").Bytes()) {
+		t.Errorf("storage mismatch: got %s", value)
+	}
+}
+
+func TestZktrieSnapshot(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	sdb, _ := New(common.Hash{}, NewDatabaseWithConfig(db, &trie.Config{Zktrie: true}), nil)
+
+	addr := common.BytesToAddress([]byte{0x01})
+	sdb.AddBalance(addr, big.NewInt(22))
+	sdb.SetState(addr, common.HexToHash("0x1"), common.HexToHash("0x2a"))
+	root, _ := sdb.Commit(false)
+	sdb.Database().TrieDB().Commit(root, false, nil)
+
+	snaps, err := snapshot.New(db, sdb.Database().TrieDB(), 10, root, false, true, false)
+	if err != nil {
+		t.Fatalf("failed to create snapshot: %v", err)
+	}
+	if err := snaps.Verify(root); err != nil {
+		t.Fatalf("snapshot verification failed: %v", err)
+	}
+	// Read the state through the snapshot and update it
+	sdb, _ = New(root, sdb.Database(), snaps)
+	if balance := sdb.GetBalance(addr); balance.Cmp(big.NewInt(22)) != 0 {
+		t.Errorf("balance mismatch: got %v, want 22", balance)
+	}
+	if value := sdb.GetState(addr, common.HexToHash("0x1")); value != common.HexToHash("0x2a") {
+		t.Errorf("storage mismatch: got %x", value)
+	}
+	sdb.AddBalance(addr, big.NewInt(20))
+	sdb.SetState(addr, common.HexToHash("0x1"), common.Hash{})
+	sdb.SetState(addr, common.HexToHash("0x2"), common.HexToHash("0x2b"))
+	root, _ = sdb.Commit(false)
+
+	if snaps.Snapshot(root) == nil {
+		t.Fatalf("snapshot layer missing for %x", root)
+	}
+	sdb, _ = New(root, sdb.Database(), snaps)
+	if balance := sdb.GetBalance(addr); balance.Cmp(big.NewInt(42)) != 0 {
+		t.Errorf("balance mismatch: got %v, want 42", balance)
+	}
+	if value := sdb.GetState(addr, common.HexToHash("0x1")); value != (common.Hash{}) {
+		t.Errorf("deleted storage mismatch: got %x", value)
+	}
+	if value := sdb.GetState(addr, common.HexToHash("0x2")); value != common.HexToHash("0x2b") {
+		t.Errorf("storage mismatch: got %x", value)
+	}
+	if err := snaps.Verify(root); err != nil {
+		t.Fatalf("snapshot verification failed: %v", err)
+	}
+}
+
 func TestNull(t *testing.T) {
 	s := newStateTest()
 	address := common.HexToAddress("0x823140710bf13990e4500136726d8b55")
@@ -193,6 +193,16 @@ func (s *StateDB) IsZktrie() bool {
 	return s.db.TrieDB().Zktrie
 }
 
+// snapshotKey returns the key of an account or a storage slot in the snapshot,
+// which is the Poseidon secure key for zktrie states.
+func (s *StateDB) snapshotKey(key []byte) common.Hash {
+	if s.IsZktrie() {
+		k, _ := zkt.ToSecureKeyBytes(key)
+		return common.BytesToHash(k.Bytes())
+	}
+	return crypto.HashData(s.hasher, key)
+}
+
 func (s *StateDB) AddLog(log *types.Log) {
 	s.journal.append(addLogChange{txhash: s.thash})
 
@@ -572,7 +582,7 @@ func (s *StateDB) getDeletedStateObject(addr common.Address) *stateObject {
 			defer func(start time.Time) { s.SnapshotAccountReads += time.Since(start) }(time.Now())
 		}
 		var acc *snapshot.Account
-		if acc, err = s.snap.Account(crypto.HashData(s.hasher, addr.Bytes())); err == nil {
+		if acc, err = s.snap.Account(s.snapshotKey(addr.Bytes())); err == nil {
 			if acc == nil {
 				return nil
 			}
@@ -696,9 +706,12 @@ func (db *StateDB) ForEachStorage(addr common.Address, cb func(key, value common
 		}
 
 		if len(it.Value) > 0 {
-			_, content, _, err := rlp.Split(it.Value)
-			if err != nil {
-				return err
+			content := it.Value
+			if !db.IsZktrie() {
+				var err error
+				if _, content, _, err = rlp.Split(it.Value); err != nil {
+					return err
+				}
 			}
 			if !cb(key, common.BytesToHash(content)) {
 				return nil
@@ -28,6 +28,15 @@ import (
 
 // NewStateSync create a new state trie download scheduler.
 func NewStateSync(root common.Hash, database ethdb.KeyValueReader, bloom *trie.SyncBloom, onLeaf func(paths [][]byte, leaf []byte) error) *trie.Sync {
+	return newStateSync(root, database, bloom, onLeaf, false)
+}
+
+// NewZktrieStateSync create a new state trie download scheduler for a zktrie state.
+func NewZktrieStateSync(root common.Hash, database ethdb.KeyValueReader, bloom *trie.SyncBloom, onLeaf func(paths [][]byte, leaf []byte) error) *trie.Sync {
+	return newStateSync(root, database, bloom, onLeaf, true)
+}
+
+func newStateSync(root common.Hash, database ethdb.KeyValueReader, bloom *trie.SyncBloom, onLeaf func(paths [][]byte, leaf []byte) error, zktrie bool) *trie.Sync {
 	// Register the storage slot callback if the external callback is specified.
 	var onSlot func(paths [][]byte, hexpath []byte, leaf []byte, parent common.Hash) error
 	if onLeaf != nil {
@@ -44,14 +53,23 @@ func NewStateSync(root common.Hash, database ethdb.KeyValueReader, bloom *trie.S
 				return err
 			}
 		}
-		var obj types.StateAccount
-		if err := rlp.Decode(bytes.NewReader(leaf), &obj); err != nil {
+		obj := new(types.StateAccount)
+		if zktrie {
+			var err error
+			if obj, err = types.UnmarshalStateAccount(leaf); err != nil {
+				return err
+			}
+		} else if err := rlp.Decode(bytes.NewReader(leaf), obj); err != nil {
 			return err
 		}
 		syncer.AddSubTrie(obj.Root, hexpath, parent, onSlot)
 		syncer.AddCodeEntry(common.BytesToHash(obj.KeccakCodeHash), hexpath, parent)
 		return nil
 	}
-	syncer = trie.NewSync(root, database, onAccount, bloom)
+	if zktrie {
+		syncer = trie.NewZktrieSync(root, database, onAccount, bloom)
+	} else {
+		syncer = trie.NewSync(root, database, onAccount, bloom)
+	}
 	return syncer
 }
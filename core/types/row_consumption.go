@@ -21,6 +21,43 @@ func (rc RowConsumption) IsOverflown() bool {
 	})
 }
 
+// Headroom returns the number of rows left in the most utilized subcircuit
+func (rc RowConsumption) Headroom() uint64 {
+	headroom := uint64(RowConsumptionLimit)
+	for _, detail := range rc {
+		if detail.RowNumber >= RowConsumptionLimit {
+			return 0
+		}
+		headroom = min(headroom, RowConsumptionLimit-detail.RowNumber)
+	}
+	return headroom
+}
+
+// OverflownSubCircuits returns the names of the overflown subcircuits
+func (rc RowConsumption) OverflownSubCircuits() []string {
+	var names []string
+	for _, detail := range rc {
+		if detail.RowNumber > RowConsumptionLimit {
+			names = append(names, detail.Name)
+		}
+	}
+	return names
+}
+
+// SubCircuitHeadroom returns the number of rows left in the given subcircuit
+func (rc RowConsumption) SubCircuitHeadroom(name string) uint64 {
+	for _, detail := range rc {
+		if detail.Name != name {
+			continue
+		}
+		if detail.RowNumber >= RowConsumptionLimit {
+			return 0
+		}
+		return RowConsumptionLimit - detail.RowNumber
+	}
+	return RowConsumptionLimit
+}
+
 // Difference returns rc - other
 // Assumes that rc > other for all subcircuits
 func (rc RowConsumption) Difference(other RowConsumption) RowConsumption {
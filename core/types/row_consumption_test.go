@@ -78,3 +78,21 @@ func TestRowConsumptionDifference(t *testing.T) {
 		assert.Equal(t, makeMap(test.expected), makeMap(test.rc1.Difference(test.rc2)))
 	}
 }
+
+func TestRowConsumptionHeadroom(t *testing.T) {
+	assert.Equal(t, uint64(RowConsumptionLimit), RowConsumption{}.Headroom())
+	assert.Equal(t, uint64(RowConsumptionLimit-456), RowConsumption{{"sc1", 123}, {"sc2", 456}}.Headroom())
+	assert.Equal(t, uint64(0), RowConsumption{{"sc1", 123}, {"sc2", RowConsumptionLimit + 1}}.Headroom())
+}
+
+func TestRowConsumptionOverflownSubCircuits(t *testing.T) {
+	assert.Empty(t, RowConsumption{{"sc1", 123}, {"sc2", RowConsumptionLimit}}.OverflownSubCircuits())
+	assert.Equal(t, []string{"sc1", "sc3"}, RowConsumption{{"sc1", RowConsumptionLimit + 1}, {"sc2", 456}, {"sc3", RowConsumptionLimit + 2}}.OverflownSubCircuits())
+}
+
+func TestRowConsumptionSubCircuitHeadroom(t *testing.T) {
+	rc := RowConsumption{{"sc1", 123}, {"sc2", RowConsumptionLimit + 1}}
+	assert.Equal(t, uint64(RowConsumptionLimit-123), rc.SubCircuitHeadroom("sc1"))
+	assert.Equal(t, uint64(0), rc.SubCircuitHeadroom("sc2"))
+	assert.Equal(t, uint64(RowConsumptionLimit), rc.SubCircuitHeadroom("sc3"))
+}
@@ -27,6 +27,7 @@ import (
 	"os"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/scroll-tech/go-ethereum/common"
@@ -35,10 +36,13 @@ import (
 	"github.com/scroll-tech/go-ethereum/core/rawdb"
 	"github.com/scroll-tech/go-ethereum/core/state"
 	"github.com/scroll-tech/go-ethereum/core/types"
+	"github.com/scroll-tech/go-ethereum/core/vm"
+	"github.com/scroll-tech/go-ethereum/ethdb"
 	"github.com/scroll-tech/go-ethereum/internal/ethapi"
 	"github.com/scroll-tech/go-ethereum/log"
 	"github.com/scroll-tech/go-ethereum/rlp"
 	"github.com/scroll-tech/go-ethereum/rollup/ccc"
+	"github.com/scroll-tech/go-ethereum/rollup/tracing"
 	"github.com/scroll-tech/go-ethereum/rpc"
 	"github.com/scroll-tech/go-ethereum/trie"
 )
@@ -114,6 +118,16 @@ func (api *PrivateMinerAPI) Stop() {
 	api.e.StopMining()
 }
 
+// ClearHalt clears the halt of block production after a block failing the circuit capacity
+// check could not be reorged, e.g. because the reorg exceeded --miner.maxreorgdepth. Before
+// clearing the halt, the operator must rewind the chain below the failing block reported in
+// the logs with debug_setHead, otherwise the next block is built on top of the failing block.
+// Block production is then resumed with miner_start.
+func (api *PrivateMinerAPI) ClearHalt() bool {
+	api.e.Miner().ClearHalt()
+	return true
+}
+
 // SetExtra sets the extra data string that is included when this miner mines a block.
 func (api *PrivateMinerAPI) SetExtra(extra string) (bool, error) {
 	if err := api.e.Miner().SetExtra([]byte(extra)); err != nil {
@@ -280,6 +294,88 @@ func (api *PrivateAdminAPI) SetL1MessageSyncedL1Height(height uint64) error {
 	return nil
 }
 
+// ReadmissionResult is the outcome of readmitting a single skipped transaction.
+type ReadmissionResult struct {
+	Index      hexutil.Uint64 `json:"index"`
+	Hash       common.Hash    `json:"hash"`
+	Readmitted bool           `json:"readmitted"`
+	Error      string         `json:"error,omitempty"`
+}
+
+// ReadmitSkippedTransactions re-validates the skipped transactions between the two indices provided
+// (inclusive) against the current state and adds the ones that are still valid to the transaction pool,
+// e.g. after a circuit upgrade raised the capacity limits. L1 messages cannot be readmitted. Every attempt
+// is recorded in the database for auditing.
+func (api *PrivateAdminAPI) ReadmitSkippedTransactions(fromIndex uint64, toIndex uint64) ([]ReadmissionResult, error) {
+	if fromIndex > toIndex {
+		return nil, fmt.Errorf("invalid index range: from %d > to %d", fromIndex, toIndex)
+	}
+
+	db := api.eth.ChainDb()
+	results := []ReadmissionResult{}
+	seen := make(map[common.Hash]struct{})
+
+	for index := fromIndex; index <= toIndex; index++ {
+		hash := rawdb.ReadSkippedTransactionHash(db, index)
+		if hash == nil {
+			break
+		}
+		// the index includes duplicates if a transaction was skipped again after a reorg
+		if _, ok := seen[*hash]; ok {
+			continue
+		}
+		seen[*hash] = struct{}{}
+
+		err := api.readmitSkippedTransaction(*hash)
+		result := ReadmissionResult{Index: hexutil.Uint64(index), Hash: *hash, Readmitted: err == nil}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+
+		rawdb.WriteSkippedTransactionReadmission(db, &rawdb.SkippedTransactionReadmission{
+			TxHash:       *hash,
+			SkippedIndex: index,
+			BlockNumber:  api.eth.BlockChain().CurrentHeader().Number.Uint64(),
+			Time:         uint64(time.Now().Unix()),
+			Readmitted:   result.Readmitted,
+			Error:        result.Error,
+		})
+		log.Info("Readmitting skipped transaction", "index", index, "hash", hash.String(), "readmitted", result.Readmitted, "err", err)
+	}
+
+	return results, nil
+}
+
+// readmitSkippedTransaction adds a skipped transaction to the transaction pool if it is still valid.
+func (api *PrivateAdminAPI) readmitSkippedTransaction(hash common.Hash) error {
+	db := api.eth.ChainDb()
+
+	stx := rawdb.ReadSkippedTransaction(db, hash)
+	if stx == nil {
+		return errors.New("skipped transaction not found")
+	}
+	if stx.Readmitted {
+		return errors.New("already readmitted")
+	}
+	if stx.Tx.IsL1MessageTx() {
+		return errors.New("L1 messages cannot be readmitted")
+	}
+	if lookup := rawdb.ReadTxLookupEntry(db, hash); lookup != nil {
+		return fmt.Errorf("already included in block %d", *lookup)
+	}
+
+	// the transaction pool rejects skipped transactions, mark it as readmitted first.
+	// The transaction is added as a remote one: it was originally received from the
+	// network and must not be exempted from the pool's pricing and eviction rules.
+	rawdb.SetSkippedTransactionReadmitted(db, hash, true)
+	if err := api.eth.TxPool().AddRemote(stx.Tx); err != nil {
+		rawdb.SetSkippedTransactionReadmitted(db, hash, false)
+		return err
+	}
+	return nil
+}
+
 // PublicDebugAPI is the collection of Ethereum full node APIs exposed
 // over the public debugging endpoint.
 type PublicDebugAPI struct {
@@ -467,10 +563,14 @@ func (api *PrivateDebugAPI) StorageRangeAt(blockHash common.Hash, txIndex int, c
 func storageRangeAt(st state.Trie, start []byte, maxResult int) (StorageRangeResult, error) {
 	it := trie.NewIterator(st.NodeIterator(start))
 	result := StorageRangeResult{Storage: storageMap{}}
+	_, zktrie := st.(*trie.ZkTrie)
 	for i := 0; i < maxResult && it.Next(); i++ {
-		_, content, _, err := rlp.Split(it.Value)
-		if err != nil {
-			return StorageRangeResult{}, err
+		content := it.Value
+		if !zktrie {
+			var err error
+			if _, content, _, err = rlp.Split(it.Value); err != nil {
+				return StorageRangeResult{}, err
+			}
 		}
 		e := storageEntry{Value: common.BytesToHash(content)}
 		if preimage := st.GetKey(it.Key); preimage != nil {
@@ -634,6 +734,9 @@ func (api *PrivateDebugAPI) GetAccessibleState(from, to rpc.BlockNumber) (uint64
 // ScrollAPI provides private RPC methods to query the L1 message database.
 type ScrollAPI struct {
 	eth *Ethereum
+
+	checkerMu sync.Mutex
+	checker   *ccc.Checker // circuit capacity checker for row consumption estimates, created on first use
 }
 
 // l1MessageTxRPC is the RPC-layer representation of an L1 message.
@@ -695,6 +798,34 @@ func (api *ScrollAPI) GetLatestRelayedQueueIndex(ctx context.Context) (queueInde
 	return &lastIncluded, nil
 }
 
+// l1MessageQueueStatsRPC is the RPC-layer representation of the L1 message queue statistics.
+type l1MessageQueueStatsRPC struct {
+	NextQueueIndex          uint64   `json:"nextQueueIndex"`
+	PendingCount            uint64   `json:"pendingCount"`
+	OldestPendingAge        *float64 `json:"oldestPendingAge"`        // in seconds
+	AverageInclusionLatency *float64 `json:"averageInclusionLatency"` // in seconds
+}
+
+// GetL1MessageQueueStats returns the number of synced L1 messages not included in the canonical chain,
+// the age of the oldest one, and the average time it took the sequencer to include an L1 message after
+// syncing it. The inclusion latency is only tracked by the sequencer.
+func (api *ScrollAPI) GetL1MessageQueueStats(ctx context.Context) (*l1MessageQueueStatsRPC, error) {
+	stats := api.eth.Miner().L1MessageQueueStats()
+	result := &l1MessageQueueStatsRPC{
+		NextQueueIndex: stats.NextQueueIndex,
+		PendingCount:   stats.PendingCount,
+	}
+	if stats.OldestPendingAge != nil {
+		age := stats.OldestPendingAge.Seconds()
+		result.OldestPendingAge = &age
+	}
+	if stats.AverageInclusionLatency != nil {
+		latency := stats.AverageInclusionLatency.Seconds()
+		result.AverageInclusionLatency = &latency
+	}
+	return result, nil
+}
+
 // rpcMarshalBlock uses the generalized output filler, then adds the total difficulty field, which requires
 // a `ScrollAPI`.
 func (api *ScrollAPI) rpcMarshalBlock(ctx context.Context, b *types.Block, fullTx bool) (map[string]interface{}, error) {
@@ -791,6 +922,27 @@ func (api *ScrollAPI) EstimateL1DataFee(ctx context.Context, args ethapi.Transac
 	return &result, nil
 }
 
+// VerifyProof checks an account proof returned by eth_getProof in the standard
+// format against the given state root. It returns an error describing why the proof
+// does not verify, or the proven account and storage values do not match the ones
+// in the proof result.
+func (api *ScrollAPI) VerifyProof(ctx context.Context, root common.Hash, proof ethapi.AccountResult) (bool, error) {
+	if !api.eth.blockchain.Config().Scroll.ZktrieEnabled() {
+		return false, errors.New("proof verification is only supported on zktrie chains")
+	}
+	if err := ethapi.VerifyZktrieProof(root, &proof); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// SubCircuitOverflow describes by how much a subcircuit exceeded its row limit.
+type SubCircuitOverflow struct {
+	Name      string         `json:"name"`
+	RowNumber hexutil.Uint64 `json:"rowNumber"`
+	Excess    hexutil.Uint64 `json:"excess"`
+}
+
 // RPCTransaction is the standard RPC transaction return type with some additional skip-related fields.
 type RPCTransaction struct {
 	ethapi.RPCTransaction
@@ -798,31 +950,104 @@ type RPCTransaction struct {
 	SkipBlockNumber *hexutil.Big `json:"skipBlockNumber"`
 	SkipBlockHash   *common.Hash `json:"skipBlockHash,omitempty"`
 
+	// circuit capacity report, only available for transactions skipped due to a circuit capacity overflow
+	SkipRowConsumption types.RowConsumption `json:"skipRowConsumption,omitempty"`
+	SkipOverflows      []SubCircuitOverflow `json:"skipOverflows,omitempty"`
+
+	// set if the transaction was readmitted into the transaction pool after it had been skipped
+	Readmitted bool `json:"readmitted,omitempty"`
+
 	// wrapped traces, currently only available for `scroll_getSkippedTransaction` API, when `MinerStoreSkippedTxTracesFlag` is set
 	Traces *types.BlockTrace `json:"traces,omitempty"`
 }
 
-// GetSkippedTransaction returns a skipped transaction by its hash.
-func (api *ScrollAPI) GetSkippedTransaction(ctx context.Context, hash common.Hash) (*RPCTransaction, error) {
-	stx := rawdb.ReadSkippedTransaction(api.eth.ChainDb(), hash)
-	if stx == nil {
-		return nil, nil
-	}
+// newRPCSkippedTransaction returns a skipped transaction that will serialize to the RPC representation.
+func (api *ScrollAPI) newRPCSkippedTransaction(stx *rawdb.SkippedTransactionV2, withTraces bool) (*RPCTransaction, error) {
 	var rpcTx RPCTransaction
 	rpcTx.RPCTransaction = *ethapi.NewRPCTransaction(stx.Tx, common.Hash{}, 0, 0, nil, api.eth.blockchain.Config())
 	rpcTx.SkipReason = stx.Reason
 	rpcTx.SkipBlockNumber = (*hexutil.Big)(new(big.Int).SetUint64(stx.BlockNumber))
 	rpcTx.SkipBlockHash = stx.BlockHash
-	if len(stx.TracesBytes) != 0 {
+	rpcTx.Readmitted = stx.Readmitted
+	rpcTx.SkipRowConsumption = stx.RowConsumption
+	for _, detail := range stx.RowConsumption {
+		if detail.RowNumber > types.RowConsumptionLimit {
+			rpcTx.SkipOverflows = append(rpcTx.SkipOverflows, SubCircuitOverflow{
+				Name:      detail.Name,
+				RowNumber: hexutil.Uint64(detail.RowNumber),
+				Excess:    hexutil.Uint64(detail.RowNumber - types.RowConsumptionLimit),
+			})
+		}
+	}
+	if withTraces && len(stx.TracesBytes) != 0 {
 		traces := &types.BlockTrace{}
 		if err := json.Unmarshal(stx.TracesBytes, traces); err != nil {
-			return nil, fmt.Errorf("fail to Unmarshal traces for skipped tx, hash: %s, err: %w", hash.String(), err)
+			return nil, fmt.Errorf("fail to Unmarshal traces for skipped tx, hash: %s, err: %w", stx.Tx.Hash().String(), err)
 		}
 		rpcTx.Traces = traces
 	}
 	return &rpcTx, nil
 }
 
+// GetSkippedTransaction returns a skipped transaction by its hash.
+func (api *ScrollAPI) GetSkippedTransaction(ctx context.Context, hash common.Hash) (*RPCTransaction, error) {
+	stx := rawdb.ReadSkippedTransaction(api.eth.ChainDb(), hash)
+	if stx == nil {
+		return nil, nil
+	}
+	return api.newRPCSkippedTransaction(stx, true)
+}
+
+// maxSkippedTransactionsInRange is the maximum number of skipped transactions
+// returned by GetSkippedTransactionsInRange.
+const maxSkippedTransactionsInRange = 1000
+
+// GetSkippedTransactionsInRange returns the skipped transactions that were skipped in blocks between
+// fromBlock and toBlock (inclusive). If reason is provided, only transactions whose skip reason contains
+// it are returned. Traces are omitted, use GetSkippedTransaction to retrieve them. An error is returned
+// if the range contains more than maxSkippedTransactionsInRange skipped transactions.
+func (api *ScrollAPI) GetSkippedTransactionsInRange(ctx context.Context, fromBlock uint64, toBlock uint64, reason *string) ([]*RPCTransaction, error) {
+	if fromBlock > toBlock {
+		return nil, fmt.Errorf("invalid block range: from %d > to %d", fromBlock, toBlock)
+	}
+
+	// fetch one more hash than allowed to detect ranges that are too large
+	hashes := rawdb.ReadSkippedTransactionHashesInBlockRange(api.eth.ChainDb(), fromBlock, toBlock, maxSkippedTransactionsInRange+1)
+	if len(hashes) > maxSkippedTransactionsInRange {
+		return nil, fmt.Errorf("more than %d skipped transactions in block range [%d, %d], narrow the range", maxSkippedTransactionsInRange, fromBlock, toBlock)
+	}
+
+	txs := []*RPCTransaction{}
+	seen := make(map[common.Hash]struct{})
+
+	for _, hash := range hashes {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if _, ok := seen[hash]; ok {
+			continue
+		}
+		seen[hash] = struct{}{}
+
+		// transactions skipped again after a reorg are indexed for every block they were skipped in,
+		// but the record only keeps the latest block
+		stx := rawdb.ReadSkippedTransactionWithoutTraces(api.eth.ChainDb(), hash)
+		if stx == nil || stx.BlockNumber < fromBlock || stx.BlockNumber > toBlock {
+			continue
+		}
+		if reason != nil && !strings.Contains(stx.Reason, *reason) {
+			continue
+		}
+		rpcTx, err := api.newRPCSkippedTransaction(stx, false)
+		if err != nil {
+			return nil, err
+		}
+		txs = append(txs, rpcTx)
+	}
+
+	return txs, nil
+}
+
 // GetSkippedTransactionHashes returns a list of skipped transaction hashes between the two indices provided (inclusive).
 func (api *ScrollAPI) GetSkippedTransactionHashes(ctx context.Context, from uint64, to uint64) ([]common.Hash, error) {
 	it := rawdb.IterateSkippedTransactionsFrom(api.eth.ChainDb(), from)
@@ -859,3 +1084,405 @@ func (api *ScrollAPI) CalculateRowConsumptionByBlockNumber(ctx context.Context,
 	asyncChecker.Wait()
 	return rawdb.ReadBlockRowConsumption(api.eth.ChainDb(), block.Hash()), checkErr
 }
+
+// SubCircuitRowUsageEstimate is the estimated row usage of a single subcircuit.
+type SubCircuitRowUsageEstimate struct {
+	Name       string         `json:"name"`
+	RowNumber  hexutil.Uint64 `json:"rowNumber"`
+	Percentage float64        `json:"percentage"` // percentage of types.RowConsumptionLimit
+}
+
+// RowConsumptionEstimate is the result of scroll_estimateRowConsumption.
+type RowConsumptionEstimate struct {
+	SubCircuits      []SubCircuitRowUsageEstimate `json:"subCircuits"`
+	FitsInEmptyBlock bool                         `json:"fitsInEmptyBlock"`
+}
+
+// estimationSigner returns a fixed sender for all transactions. It is used to trace
+// unsigned transactions built from call arguments.
+type estimationSigner struct {
+	types.Signer
+	from common.Address
+}
+
+func (s estimationSigner) Sender(tx *types.Transaction) (common.Address, error) {
+	return s.from, nil
+}
+
+// EstimateRowConsumption executes the given transaction on top of the state of the given block
+// (pending by default) and returns how many rows of each subcircuit it would use in an otherwise
+// empty block. Transactions that do not fit in an empty block are skipped by the sequencer.
+func (api *ScrollAPI) EstimateRowConsumption(ctx context.Context, args ethapi.TransactionArgs, blockNrOrHash *rpc.BlockNumberOrHash) (*RowConsumptionEstimate, error) {
+	bNrOrHash := rpc.BlockNumberOrHashWithNumber(rpc.PendingBlockNumber)
+	if blockNrOrHash != nil {
+		bNrOrHash = *blockNrOrHash
+	}
+	statedb, parent, err := api.eth.APIBackend.StateAndHeaderByNumberOrHash(ctx, bNrOrHash)
+	if statedb == nil || err != nil {
+		return nil, err
+	}
+	chainConfig := api.eth.blockchain.Config()
+
+	// fill in the missing fields, the transaction is not signed so we can pick any defaults
+	var from common.Address
+	if args.From != nil {
+		from = *args.From
+	}
+	if args.Nonce == nil {
+		nonce := hexutil.Uint64(statedb.GetNonce(from))
+		args.Nonce = &nonce
+	}
+	if args.Gas == nil {
+		gas, err := ethapi.DoEstimateGas(ctx, api.eth.APIBackend, args, bNrOrHash, api.eth.APIBackend.RPCGasCap())
+		if err != nil {
+			return nil, err
+		}
+		args.Gas = &gas
+	}
+	if args.ChainID == nil {
+		args.ChainID = (*hexutil.Big)(chainConfig.ChainID)
+	}
+	tx := args.ToTransaction()
+	// cache the sender so that tracing does not try to recover it from the missing signature
+	signer := types.MakeSigner(chainConfig, new(big.Int).Add(parent.Number, common.Big1))
+	if _, err := types.Sender(estimationSigner{Signer: signer, from: from}, tx); err != nil {
+		return nil, err
+	}
+
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Number:     new(big.Int).Add(parent.Number, common.Big1),
+		GasLimit:   parent.GasLimit,
+		Time:       parent.Time,
+		Difficulty: parent.Difficulty,
+		Coinbase:   parent.Coinbase,
+		BaseFee:    parent.BaseFee,
+	}
+	if header.BaseFee != nil && tx.GasFeeCap().Sign() == 0 {
+		// allow estimating transactions without gas price, like eth_call does
+		header.BaseFee = new(big.Int)
+	}
+	block := types.NewBlockWithHeader(header).WithBody([]*types.Transaction{tx}, nil)
+
+	coinbase := parent.Coinbase
+	if chainConfig.Scroll.FeeVaultEnabled() {
+		coinbase = *chainConfig.Scroll.FeeVaultAddress
+	}
+	var startL1QueueIndex uint64
+	if index := rawdb.ReadFirstQueueIndexNotInL2Block(api.eth.ChainDb(), parent.Hash()); index != nil {
+		startL1QueueIndex = *index
+	}
+	env := tracing.CreateTraceEnvHelper(
+		chainConfig,
+		&vm.LogConfig{
+			DisableStorage:   true,
+			DisableStack:     true,
+			EnableMemory:     false,
+			EnableReturnData: true,
+		},
+		core.NewEVMBlockContext(header, api.eth.blockchain, chainConfig, nil),
+		startL1QueueIndex,
+		coinbase,
+		statedb,
+		parent.Root,
+		block,
+		true,
+	)
+	proof, err := statedb.GetProof(coinbase)
+	if err != nil {
+		log.Debug("Proof for coinbase not available", "coinbase", coinbase, "err", err)
+	}
+	env.Proofs[coinbase.String()] = types.WrapProof(proof)
+
+	trace, err := env.GetBlockTrace(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to trace transaction: %w", err)
+	}
+
+	api.checkerMu.Lock()
+	defer api.checkerMu.Unlock()
+	if api.checker == nil {
+		api.checker = ccc.NewChecker(false)
+	}
+	api.checker.Reset()
+	rc, err := api.checker.ApplyTransaction(trace)
+	if err != nil && !errors.Is(err, ccc.ErrBlockRowConsumptionOverflow) {
+		return nil, fmt.Errorf("failed to check circuit capacity: %w", err)
+	}
+
+	estimate := &RowConsumptionEstimate{
+		SubCircuits:      []SubCircuitRowUsageEstimate{},
+		FitsInEmptyBlock: err == nil && (rc == nil || !rc.IsOverflown()),
+	}
+	if rc != nil {
+		for _, detail := range *rc {
+			estimate.SubCircuits = append(estimate.SubCircuits, SubCircuitRowUsageEstimate{
+				Name:       detail.Name,
+				RowNumber:  hexutil.Uint64(detail.RowNumber),
+				Percentage: float64(detail.RowNumber) * 100 / types.RowConsumptionLimit,
+			})
+		}
+	}
+	return estimate, nil
+}
+
+// chunkBlockRangeRPC is the RPC-layer representation of the block range of a chunk.
+type chunkBlockRangeRPC struct {
+	StartBlockNumber uint64 `json:"startBlockNumber"`
+	EndBlockNumber   uint64 `json:"endBlockNumber"`
+}
+
+func toChunkBlockRangesRPC(ranges []*rawdb.ChunkBlockRange) []chunkBlockRangeRPC {
+	rpcRanges := make([]chunkBlockRangeRPC, len(ranges))
+	for i, r := range ranges {
+		rpcRanges[i] = chunkBlockRangeRPC{StartBlockNumber: r.StartBlockNumber, EndBlockNumber: r.EndBlockNumber}
+	}
+	return rpcRanges
+}
+
+// batchValidationFailureRPC is the RPC-layer representation of a batch validation failure.
+type batchValidationFailureRPC struct {
+	BatchIndex        uint64               `json:"batchIndex"`
+	CodecVersion      uint8                `json:"codecVersion"`
+	ChunkBlockRanges  []chunkBlockRangeRPC `json:"chunkBlockRanges"`
+	Reason            string               `json:"reason"`
+	L1StateRoot       common.Hash          `json:"l1StateRoot"`
+	LocalStateRoot    common.Hash          `json:"localStateRoot"`
+	L1WithdrawRoot    common.Hash          `json:"l1WithdrawRoot"`
+	LocalWithdrawRoot common.Hash          `json:"localWithdrawRoot"`
+	L1BatchHash       common.Hash          `json:"l1BatchHash"`
+	LocalBatchHash    common.Hash          `json:"localBatchHash"`
+	DetectedAt        uint64               `json:"detectedAt"`
+}
+
+// GetBatchValidationFailures returns the batches that failed validation against L1,
+// optionally starting at the provided batch index.
+func (api *ScrollAPI) GetBatchValidationFailures(ctx context.Context, fromBatchIndex *uint64) ([]*batchValidationFailureRPC, error) {
+	var from uint64
+	if fromBatchIndex != nil {
+		from = *fromBatchIndex
+	}
+
+	failures := rawdb.ReadBatchValidationFailures(api.eth.ChainDb(), from)
+	rpcFailures := make([]*batchValidationFailureRPC, len(failures))
+	for i, f := range failures {
+		rpcFailures[i] = &batchValidationFailureRPC{
+			BatchIndex:        f.BatchIndex,
+			CodecVersion:      f.CodecVersion,
+			ChunkBlockRanges:  toChunkBlockRangesRPC(f.ChunkBlockRanges),
+			Reason:            f.Reason,
+			L1StateRoot:       f.L1StateRoot,
+			LocalStateRoot:    f.LocalStateRoot,
+			L1WithdrawRoot:    f.L1WithdrawRoot,
+			LocalWithdrawRoot: f.LocalWithdrawRoot,
+			L1BatchHash:       f.L1BatchHash,
+			LocalBatchHash:    f.LocalBatchHash,
+			DetectedAt:        f.DetectedAt,
+		}
+	}
+	return rpcFailures, nil
+}
+
+// maxFinalizedBatchRange is the maximum number of batches returned by a single scroll_getFinalizedBatchRange call.
+const maxFinalizedBatchRange = 1000
+
+// batchRPC is the RPC-layer representation of a committed or finalized batch.
+// StateRoot, WithdrawRoot and TotalL1MessagePopped are only known once the batch is finalized.
+type batchRPC struct {
+	BatchIndex           uint64               `json:"batchIndex"`
+	BatchHash            *common.Hash         `json:"batchHash"`
+	CodecVersion         uint8                `json:"codecVersion"`
+	BlobVersionedHashes  []common.Hash        `json:"blobVersionedHashes"`
+	ChunkBlockRanges     []chunkBlockRangeRPC `json:"chunkBlockRanges"`
+	TotalL1MessagePopped *uint64              `json:"totalL1MessagePopped"`
+	StateRoot            *common.Hash         `json:"stateRoot"`
+	WithdrawRoot         *common.Hash         `json:"withdrawRoot"`
+	Status               string               `json:"status"`
+}
+
+// readBatch assembles the RPC representation of a batch from the rollup sync database.
+// It returns nil if the batch is unknown or has been reverted.
+func readBatch(db ethdb.Reader, batchIndex uint64) *batchRPC {
+	chunkBlockRanges := rawdb.ReadBatchChunkRanges(db, batchIndex)
+	if len(chunkBlockRanges) == 0 {
+		return nil
+	}
+
+	batch := &batchRPC{
+		BatchIndex:          batchIndex,
+		BlobVersionedHashes: []common.Hash{},
+		ChunkBlockRanges:    toChunkBlockRangesRPC(chunkBlockRanges),
+		Status:              ethapi.BatchStatusCommitted,
+	}
+
+	// committed batch metadata is not available for batches committed before it was introduced
+	if committedBatchMeta := rawdb.ReadCommittedBatchMeta(db, batchIndex); committedBatchMeta != nil {
+		batch.CodecVersion = committedBatchMeta.Version
+		if committedBatchMeta.BlobVersionedHashes != nil {
+			batch.BlobVersionedHashes = committedBatchMeta.BlobVersionedHashes
+		}
+		if committedBatchMeta.BatchHash != (common.Hash{}) {
+			batchHash := committedBatchMeta.BatchHash
+			batch.BatchHash = &batchHash
+		}
+	}
+
+	if finalizedBatchMeta := rawdb.ReadFinalizedBatchMeta(db, batchIndex); finalizedBatchMeta != nil {
+		batch.BatchHash = &finalizedBatchMeta.BatchHash
+		batch.TotalL1MessagePopped = &finalizedBatchMeta.TotalL1MessagePopped
+		batch.StateRoot = &finalizedBatchMeta.StateRoot
+		batch.WithdrawRoot = &finalizedBatchMeta.WithdrawRoot
+		batch.Status = ethapi.BatchStatusFinalized
+	}
+
+	return batch
+}
+
+// findBatchIndexByL2BlockNumber returns the index of the batch that contains the given L2 block,
+// or nil if the block is not part of any batch known to the rollup sync service.
+func findBatchIndexByL2BlockNumber(db ethdb.Database, number uint64) *uint64 {
+	if batchIndex := rawdb.ReadBatchIndexByL2BlockNumber(db, number); batchIndex != nil {
+		return batchIndex
+	}
+
+	// batches committed before the L2 block index was introduced are not indexed, search them instead
+	containsBlock := func(batchIndex uint64) (found bool, below bool) {
+		chunkBlockRanges := rawdb.ReadBatchChunkRanges(db, batchIndex)
+		if len(chunkBlockRanges) == 0 {
+			return false, false
+		}
+		start, end := chunkBlockRanges[0].StartBlockNumber, chunkBlockRanges[len(chunkBlockRanges)-1].EndBlockNumber
+		return start <= number && number <= end, end < number
+	}
+
+	// finalized batches are contiguous, binary search them. batches committed before the
+	// rollup sync service started are missing and are treated as lower than the target.
+	lastFinalizedBatchIndex := rawdb.ReadLastFinalizedBatchIndex(db)
+	if lastFinalizedBatchIndex != nil {
+		lo, hi := uint64(0), *lastFinalizedBatchIndex+1
+		for lo < hi {
+			mid := lo + (hi-lo)/2
+			if chunkBlockRanges := rawdb.ReadBatchChunkRanges(db, mid); len(chunkBlockRanges) == 0 || chunkBlockRanges[len(chunkBlockRanges)-1].EndBlockNumber < number {
+				lo = mid + 1
+			} else {
+				hi = mid
+			}
+		}
+		if lo <= *lastFinalizedBatchIndex {
+			if found, _ := containsBlock(lo); found {
+				return &lo
+			}
+			return nil
+		}
+	}
+
+	// the block is not finalized yet, scan the committed batches after the last finalized one
+	var batchIndex uint64
+	if lastFinalizedBatchIndex != nil {
+		batchIndex = *lastFinalizedBatchIndex + 1
+	}
+	for ; ; batchIndex++ {
+		found, below := containsBlock(batchIndex)
+		if found {
+			return &batchIndex
+		}
+		if !below {
+			return nil
+		}
+	}
+}
+
+// GetBatchByIndex returns the batch with the given index, or nil if it is unknown to the rollup sync service.
+func (api *ScrollAPI) GetBatchByIndex(ctx context.Context, batchIndex uint64) (*batchRPC, error) {
+	return readBatch(api.eth.ChainDb(), batchIndex), nil
+}
+
+// GetBatchByL2BlockNumber returns the batch that contains the given L2 block, or nil if the block has not been committed yet.
+func (api *ScrollAPI) GetBatchByL2BlockNumber(ctx context.Context, number uint64) (*batchRPC, error) {
+	batchIndex := findBatchIndexByL2BlockNumber(api.eth.ChainDb(), number)
+	if batchIndex == nil {
+		return nil, nil
+	}
+	return readBatch(api.eth.ChainDb(), *batchIndex), nil
+}
+
+// GetFinalizedBatchRange returns the finalized batches between the two indices provided (inclusive).
+// Batches that are not finalized yet are omitted from the result.
+func (api *ScrollAPI) GetFinalizedBatchRange(ctx context.Context, from uint64, to uint64) ([]*batchRPC, error) {
+	if from > to {
+		return nil, fmt.Errorf("invalid batch range, from: %v, to: %v", from, to)
+	}
+	if to-from >= maxFinalizedBatchRange {
+		return nil, fmt.Errorf("batch range too large, max: %v, requested: %v", maxFinalizedBatchRange, to-from+1)
+	}
+
+	lastFinalizedBatchIndex := rawdb.ReadLastFinalizedBatchIndex(api.eth.ChainDb())
+	if lastFinalizedBatchIndex == nil || from > *lastFinalizedBatchIndex {
+		return []*batchRPC{}, nil
+	}
+	if to > *lastFinalizedBatchIndex {
+		to = *lastFinalizedBatchIndex
+	}
+
+	batches := make([]*batchRPC, 0, to-from+1)
+	for batchIndex := from; batchIndex <= to; batchIndex++ {
+		batch := readBatch(api.eth.ChainDb(), batchIndex)
+		if batch == nil || batch.Status != ethapi.BatchStatusFinalized {
+			continue
+		}
+		batches = append(batches, batch)
+	}
+	return batches, nil
+}
+
+// preconfirmationRPC is the RPC-layer representation of a preconfirmation or its retraction.
+type preconfirmationRPC struct {
+	TxHash      common.Hash    `json:"txHash"`
+	BlockNumber hexutil.Uint64 `json:"blockNumber"`
+	Index       hexutil.Uint   `json:"index"`
+	Receipt     *types.Receipt `json:"receipt,omitempty"`
+	Retracted   bool           `json:"retracted"`
+	Reason      string         `json:"reason,omitempty"`
+}
+
+// Preconfirmations creates a subscription that is notified whenever the sequencer adds a transaction
+// to the block it is building. If that block is discarded or replaced after failing the circuit capacity
+// check, a notification with retracted set follows for each of its transactions.
+func (api *ScrollAPI) Preconfirmations(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		// the worker drops events that subscribers cannot take in time, buffer them to keep up
+		preconfs := make(chan core.PreconfirmationEvent, 128)
+		preconfsSub := api.eth.Miner().SubscribePreconfirmationEvent(preconfs)
+		defer preconfsSub.Unsubscribe()
+
+		for {
+			select {
+			case ev := <-preconfs:
+				preconf := &preconfirmationRPC{
+					TxHash:      ev.TxHash,
+					BlockNumber: hexutil.Uint64(ev.BlockNumber),
+					Index:       hexutil.Uint(ev.Index),
+					Receipt:     ev.Receipt,
+					Retracted:   ev.Retracted,
+				}
+				if ev.Reason != nil {
+					preconf.Reason = ev.Reason.Error()
+				}
+				notifier.Notify(rpcSub.ID, preconf)
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
@@ -87,9 +87,37 @@ func (b *EthAPIBackend) HeaderByNumber(ctx context.Context, number rpc.BlockNumb
 		number = rpc.BlockNumber(*finalizedBlockHeightPtr)
 		return b.eth.blockchain.GetHeaderByNumber(uint64(number)), nil
 	}
+	if number == rpc.SafeBlockNumber {
+		safeBlockHeight, err := b.safeBlockHeight()
+		if err != nil {
+			return nil, err
+		}
+		return b.eth.blockchain.GetHeaderByNumber(safeBlockHeight), nil
+	}
 	return b.eth.blockchain.GetHeaderByNumber(uint64(number)), nil
 }
 
+// safeBlockHeight returns the highest L2 block included in a batch that is committed on L1 and not reverted.
+func (b *EthAPIBackend) safeBlockHeight() (uint64, error) {
+	if !b.eth.config.EnableRollupVerify {
+		return 0, errors.New("sync L1 finalized batch feature not enabled, cannot query L2 safe block height")
+	}
+	// every finalized batch is also committed, but nodes upgraded from a version that
+	// did not track committed batches may only know about the finalized ones.
+	committedBlockHeightPtr := rawdb.ReadCommittedL2BlockNumber(b.eth.ChainDb())
+	finalizedBlockHeightPtr := rawdb.ReadFinalizedL2BlockNumber(b.eth.ChainDb())
+	switch {
+	case committedBlockHeightPtr == nil && finalizedBlockHeightPtr == nil:
+		return 0, errors.New("L2 safe block height not found in database")
+	case committedBlockHeightPtr == nil:
+		return *finalizedBlockHeightPtr, nil
+	case finalizedBlockHeightPtr != nil && *finalizedBlockHeightPtr > *committedBlockHeightPtr:
+		return *finalizedBlockHeightPtr, nil
+	default:
+		return *committedBlockHeightPtr, nil
+	}
+}
+
 func (b *EthAPIBackend) HeaderByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*types.Header, error) {
 	if blockNr, ok := blockNrOrHash.Number(); ok {
 		return b.HeaderByNumber(ctx, blockNr)
@@ -132,6 +160,13 @@ func (b *EthAPIBackend) BlockByNumber(ctx context.Context, number rpc.BlockNumbe
 		number = rpc.BlockNumber(*finalizedBlockHeightPtr)
 		return b.eth.blockchain.GetBlockByNumber(uint64(number)), nil
 	}
+	if number == rpc.SafeBlockNumber {
+		safeBlockHeight, err := b.safeBlockHeight()
+		if err != nil {
+			return nil, err
+		}
+		return b.eth.blockchain.GetBlockByNumber(safeBlockHeight), nil
+	}
 	return b.eth.blockchain.GetBlockByNumber(uint64(number)), nil
 }
 
@@ -18,18 +18,31 @@ package eth
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"math/big"
 	"reflect"
 	"sort"
+	"strings"
 	"testing"
 
 	"github.com/davecgh/go-spew/spew"
 
 	"github.com/scroll-tech/go-ethereum/common"
+	"github.com/scroll-tech/go-ethereum/common/hexutil"
+	"github.com/scroll-tech/go-ethereum/consensus/ethash"
+	"github.com/scroll-tech/go-ethereum/core"
 	"github.com/scroll-tech/go-ethereum/core/rawdb"
 	"github.com/scroll-tech/go-ethereum/core/state"
+	"github.com/scroll-tech/go-ethereum/core/types"
+	"github.com/scroll-tech/go-ethereum/core/vm"
 	"github.com/scroll-tech/go-ethereum/crypto"
+	"github.com/scroll-tech/go-ethereum/eth/ethconfig"
+	"github.com/scroll-tech/go-ethereum/internal/ethapi"
+	"github.com/scroll-tech/go-ethereum/params"
+	"github.com/scroll-tech/go-ethereum/rollup/ccc"
+	"github.com/scroll-tech/go-ethereum/rpc"
 	"github.com/scroll-tech/go-ethereum/trie"
 )
 
@@ -220,3 +233,222 @@ func TestStorageRangeAt(t *testing.T) {
 		}
 	}
 }
+
+func TestReadBatch(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+
+	// batches 0-1 were committed before the rollup sync service started, 2-4 are finalized, 5-6 are committed
+	for batchIndex := uint64(2); batchIndex <= 6; batchIndex++ {
+		start := batchIndex * 10
+		ranges := []*rawdb.ChunkBlockRange{{StartBlockNumber: start, EndBlockNumber: start + 4}, {StartBlockNumber: start + 5, EndBlockNumber: start + 9}}
+		rawdb.WriteBatchChunkRanges(db, batchIndex, ranges)
+		if batchIndex >= 5 {
+			// only batches committed after the upgrade are indexed
+			rawdb.WriteL2BlockBatchIndex(db, batchIndex, ranges)
+		}
+		rawdb.WriteCommittedBatchMeta(db, batchIndex, &rawdb.CommittedBatchMeta{
+			Version:             3,
+			BlobVersionedHashes: []common.Hash{common.BigToHash(new(big.Int).SetUint64(batchIndex))},
+			ChunkBlockRanges:    ranges,
+			BatchHash:           common.BigToHash(new(big.Int).SetUint64(batchIndex + 100)),
+		})
+		if batchIndex <= 4 {
+			rawdb.WriteFinalizedBatchMeta(db, batchIndex, &rawdb.FinalizedBatchMeta{
+				BatchHash:            common.BigToHash(new(big.Int).SetUint64(batchIndex + 100)),
+				TotalL1MessagePopped: batchIndex,
+				StateRoot:            common.BigToHash(new(big.Int).SetUint64(batchIndex + 200)),
+				WithdrawRoot:         common.BigToHash(new(big.Int).SetUint64(batchIndex + 300)),
+			})
+		}
+	}
+	rawdb.WriteLastFinalizedBatchIndex(db, 4)
+
+	if batch := readBatch(db, 1); batch != nil {
+		t.Fatalf("expected nil for unknown batch, got %+v", batch)
+	}
+
+	finalized := readBatch(db, 3)
+	if finalized == nil || finalized.Status != ethapi.BatchStatusFinalized || *finalized.StateRoot != common.BigToHash(big.NewInt(203)) || *finalized.TotalL1MessagePopped != 3 {
+		t.Fatalf("unexpected finalized batch: %+v", finalized)
+	}
+	if len(finalized.ChunkBlockRanges) != 2 || finalized.ChunkBlockRanges[1].EndBlockNumber != 39 {
+		t.Fatalf("unexpected chunk block ranges: %+v", finalized.ChunkBlockRanges)
+	}
+
+	committed := readBatch(db, 6)
+	if committed == nil || committed.Status != ethapi.BatchStatusCommitted || committed.StateRoot != nil || *committed.BatchHash != common.BigToHash(big.NewInt(106)) {
+		t.Fatalf("unexpected committed batch: %+v", committed)
+	}
+
+	for _, tt := range []struct {
+		number   uint64
+		expected *uint64
+	}{
+		{number: 5, expected: nil},
+		{number: 20, expected: newUint64(2)},
+		{number: 35, expected: newUint64(3)},
+		{number: 49, expected: newUint64(4)},
+		{number: 50, expected: newUint64(5)},
+		{number: 69, expected: newUint64(6)},
+		{number: 70, expected: nil},
+	} {
+		got := findBatchIndexByL2BlockNumber(db, tt.number)
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("block %d: batch index mismatch, expected %v, got %v", tt.number, spew.Sdump(tt.expected), spew.Sdump(got))
+		}
+	}
+}
+
+func newUint64(n uint64) *uint64 { return &n }
+
+func TestReadmitSkippedTransactions(t *testing.T) {
+	var (
+		db        = rawdb.NewMemoryDatabase()
+		config    = params.TestChainConfig
+		key, _    = crypto.GenerateKey()
+		address   = crypto.PubkeyToAddress(key.PublicKey)
+		signer    = types.LatestSigner(config)
+		genesis   = &core.Genesis{Config: config, Alloc: core.GenesisAlloc{address: {Balance: big.NewInt(params.Ether)}}}
+		gasPrice  = big.NewInt(10 * params.GWei)
+		recipient = common.Address{0x42}
+	)
+	genesis.MustCommit(db)
+	chain, err := core.NewBlockChain(db, nil, config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+	poolConfig := core.DefaultTxPoolConfig
+	poolConfig.Journal = "" // do not write a journal file into the package directory
+	pool := core.NewTxPool(poolConfig, config, chain)
+	defer pool.Stop()
+	pool.SetIsMiner(true)
+
+	otherKey, _ := crypto.GenerateKey()
+	valid := types.MustSignNewTx(key, signer, &types.LegacyTx{Nonce: 0, To: &recipient, Gas: params.TxGas, GasPrice: gasPrice})
+	invalid := types.MustSignNewTx(otherKey, signer, &types.LegacyTx{Nonce: 0, To: &recipient, Gas: params.TxGas, GasPrice: gasPrice})
+	rawdb.WriteSkippedTransaction(db, valid, nil, nil, "row consumption overflow", 1, nil)
+	rawdb.WriteSkippedTransaction(db, invalid, nil, nil, "row consumption overflow", 1, nil)
+
+	// the transaction pool of a miner rejects skipped transactions
+	if err := pool.AddRemote(valid); !errors.Is(err, core.ErrAlreadyKnown) {
+		t.Fatalf("expected skipped transaction to be rejected, got %v", err)
+	}
+
+	api := NewPrivateAdminAPI(&Ethereum{chainDb: db, blockchain: chain, txPool: pool})
+	results, err := api.ReadmitSkippedTransactions(0, 10)
+	if err != nil {
+		t.Fatalf("failed to readmit skipped transactions: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	if !results[0].Readmitted || results[0].Hash != valid.Hash() || results[0].Error != "" {
+		t.Errorf("unexpected result for valid transaction: %+v", results[0])
+	}
+	if results[1].Readmitted || results[1].Hash != invalid.Hash() || !strings.Contains(results[1].Error, core.ErrInsufficientFunds.Error()) {
+		t.Errorf("unexpected result for invalid transaction: %+v", results[1])
+	}
+
+	// the readmitted transaction is in the pool as a remote transaction, the other one is still skipped
+	if pool.Get(valid.Hash()) == nil {
+		t.Error("readmitted transaction is not in the transaction pool")
+	}
+	if pool.Get(invalid.Hash()) != nil {
+		t.Error("rejected transaction is in the transaction pool")
+	}
+	if locals := pool.Locals(); len(locals) != 0 {
+		t.Errorf("readmitted transaction should not be local, locals: %v", locals)
+	}
+	if rawdb.IsSkippedTransaction(db, valid.Hash()) {
+		t.Error("readmitted transaction is still marked as skipped")
+	}
+	if !rawdb.IsSkippedTransaction(db, invalid.Hash()) {
+		t.Error("rejected transaction is no longer marked as skipped")
+	}
+	if num := rawdb.ReadNumSkippedTransactionReadmissions(db); num != 2 {
+		t.Errorf("expected 2 readmission records, got %d", num)
+	}
+
+	// transactions are readmitted at most once
+	results, err = api.ReadmitSkippedTransactions(0, 0)
+	if err != nil {
+		t.Fatalf("failed to readmit skipped transactions: %v", err)
+	}
+	if len(results) != 1 || results[0].Readmitted || results[0].Error != "already readmitted" {
+		t.Errorf("unexpected result for second readmission: %+v", results)
+	}
+}
+
+func TestEstimateRowConsumption(t *testing.T) {
+	var (
+		db        = rawdb.NewMemoryDatabase()
+		config    = params.TestChainConfig
+		key, _    = crypto.GenerateKey()
+		address   = crypto.PubkeyToAddress(key.PublicKey)
+		genesis   = &core.Genesis{Config: config, Alloc: core.GenesisAlloc{address: {Balance: big.NewInt(params.Ether)}}}
+		recipient = common.Address{0x42}
+		latest    = rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
+	)
+	genesis.MustCommit(db)
+	chain, err := core.NewBlockChain(db, nil, config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+	eth := &Ethereum{config: &ethconfig.Config{RPCGasCap: 50000000}, chainDb: db, blockchain: chain}
+	eth.APIBackend = &EthAPIBackend{eth: eth}
+	api := NewScrollAPI(eth)
+
+	// an unsigned transfer of half of the sender's balance, the sender is taken from args.From
+	transfer := func(from common.Address, gas *hexutil.Uint64) ethapi.TransactionArgs {
+		nonce := hexutil.Uint64(0)
+		return ethapi.TransactionArgs{
+			From:    &from,
+			To:      &recipient,
+			Gas:     gas,
+			Value:   (*hexutil.Big)(big.NewInt(params.Ether / 2)),
+			Nonce:   &nonce,
+			ChainID: (*hexutil.Big)(config.ChainID),
+		}
+	}
+	gas := hexutil.Uint64(params.TxGas)
+
+	// the transaction fits in an empty block
+	estimate, err := api.EstimateRowConsumption(context.Background(), transfer(address, &gas), &latest)
+	if err != nil {
+		t.Fatalf("failed to estimate row consumption: %v", err)
+	}
+	if !estimate.FitsInEmptyBlock || len(estimate.SubCircuits) == 0 {
+		t.Fatalf("unexpected estimate: %+v", estimate)
+	}
+	for _, sc := range estimate.SubCircuits {
+		if expected := float64(sc.RowNumber) * 100 / types.RowConsumptionLimit; sc.Percentage != expected {
+			t.Errorf("subcircuit %s: expected percentage %v, got %v", sc.Name, expected, sc.Percentage)
+		}
+	}
+
+	// the sender must be able to pay for the transaction
+	if _, err := api.EstimateRowConsumption(context.Background(), transfer(common.Address{0x1}, &gas), &latest); err == nil || !strings.Contains(err.Error(), "insufficient funds") {
+		t.Fatalf("expected estimation to fail for a sender without balance, got %v", err)
+	}
+
+	// the transaction overflows the circuit capacity, the gas limit defaults to the estimated gas
+	args := transfer(address, &gas)
+	api.checker = ccc.NewChecker(false)
+	api.checker.Skip(args.ToTransaction().Hash(), ccc.ErrBlockRowConsumptionOverflow)
+	estimate, err = api.EstimateRowConsumption(context.Background(), transfer(address, nil), &latest)
+	if err != nil {
+		t.Fatalf("failed to estimate row consumption: %v", err)
+	}
+	if estimate.FitsInEmptyBlock {
+		t.Fatalf("expected transaction not to fit in an empty block: %+v", estimate)
+	}
+	overflown := false
+	for _, sc := range estimate.SubCircuits {
+		overflown = overflown || sc.Percentage > 100
+	}
+	if !overflown {
+		t.Errorf("expected an overflown subcircuit: %+v", estimate.SubCircuits)
+	}
+}
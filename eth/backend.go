@@ -147,6 +147,9 @@ func New(stack *node.Node, config *ethconfig.Config, l1Client sync_service.EthCl
 	if err := pruner.RecoverPruning(stack.ResolvePath(""), chainDb, stack.ResolvePath(config.TrieCleanCacheJournal)); err != nil {
 		log.Error("Failed to recover state", "error", err)
 	}
+	if err := pruner.RecoverZktriePruning(stack.ResolvePath(""), chainDb, stack.ResolvePath(config.TrieCleanCacheJournal)); err != nil {
+		log.Error("Failed to recover zktrie state", "error", err)
+	}
 	eth := &Ethereum{
 		config:            config,
 		chainDb:           chainDb,
@@ -192,6 +195,7 @@ func New(stack *node.Node, config *ethconfig.Config, l1Client sync_service.EthCl
 			TrieDirtyDisabled:   config.NoPruning,
 			TrieTimeLimit:       config.TrieTimeout,
 			SnapshotLimit:       config.SnapshotCache,
+			ZktrieSnapshot:      config.ZktrieSnapshot,
 			Preimages:           config.Preimages,
 		}
 	)
@@ -215,6 +219,9 @@ func New(stack *node.Node, config *ethconfig.Config, l1Client sync_service.EthCl
 	}
 	eth.bloomIndexer.Start(eth.blockchain)
 
+	// index transactions skipped by older versions for scroll_getSkippedTransactionsInRange
+	rawdb.IndexSkippedTransactionBlocks(chainDb)
+
 	if config.TxPool.Journal != "" {
 		config.TxPool.Journal = stack.ResolvePath(config.TxPool.Journal)
 	}
@@ -228,12 +235,41 @@ func New(stack *node.Node, config *ethconfig.Config, l1Client sync_service.EthCl
 	eth.syncService.Start()
 
 	if config.EnableRollupVerify {
+		rollupSyncConfig := rollup_sync_service.DefaultConfig
+		if config.RollupVerifyFailurePolicy != "" {
+			if rollupSyncConfig.ValidationFailurePolicy, err = rollup_sync_service.ParseValidationFailurePolicy(config.RollupVerifyFailurePolicy); err != nil {
+				return nil, err
+			}
+		}
+		rollupSyncConfig.DeriveBlocks = config.EnableRollupDerivation
+		var blobClients rollup_sync_service.BlobClients
+		if config.RollupBlobDir != "" {
+			blobClients = append(blobClients, rollup_sync_service.NewBlobDirectoryClient(config.RollupBlobDir))
+		}
+		if config.RollupBlobBeaconNode != "" {
+			beaconNodeClient, err := rollup_sync_service.NewBeaconNodeClient(context.Background(), config.RollupBlobBeaconNode, nil)
+			if err != nil {
+				return nil, fmt.Errorf("cannot initialize beacon node blob client: %w", err)
+			}
+			blobClients = append(blobClients, beaconNodeClient)
+		}
+		if config.RollupBlobArchive != "" {
+			blobClients = append(blobClients, rollup_sync_service.NewBlobArchiveClient(config.RollupBlobArchive, nil))
+		}
+		if len(blobClients) != 0 {
+			rollupSyncConfig.BlobClient = blobClients
+		} else if config.EnableRollupDerivation {
+			log.Warn("No blob source configured, blocks can only be derived from batches committed without blobs")
+		}
+
 		// initialize and start rollup event sync service
-		eth.rollupSyncService, err = rollup_sync_service.NewRollupSyncService(context.Background(), chainConfig, eth.chainDb, l1Client, eth.blockchain, stack)
+		eth.rollupSyncService, err = rollup_sync_service.NewRollupSyncService(context.Background(), chainConfig, eth.chainDb, l1Client, eth.blockchain, stack, rollupSyncConfig)
 		if err != nil {
 			return nil, fmt.Errorf("cannot initialize rollup event sync service: %w", err)
 		}
 		eth.rollupSyncService.Start()
+	} else if config.RollupBlobDir != "" || config.RollupBlobBeaconNode != "" || config.RollupBlobArchive != "" {
+		log.Warn("Blob sources are only used by rollup verification, ignoring them")
 	}
 
 	// Permit the downloader to use the trie cache allowance during fast sync
@@ -489,6 +525,9 @@ func (s *Ethereum) StartMining(threads int) error {
 		}
 		th.SetThreads(threads)
 	}
+	if s.miner.Halted() {
+		return errors.New("block production halted by a failed reorg, rewind the chain and call miner_clearHalt first")
+	}
 	// If the miner was not running, initialize it
 	if !s.IsMining() {
 		// Propagate the initial price point to the transaction pool
@@ -553,8 +592,13 @@ func (s *Ethereum) SyncService() *sync_service.SyncService { return s.syncServic
 // Protocols returns all the currently configured
 // network protocols to start.
 func (s *Ethereum) Protocols() []p2p.Protocol {
+	// blocks are derived from L1, do not sync them from peers
+	if s.config.EnableRollupDerivation {
+		return nil
+	}
 	protos := eth.MakeProtocols((*ethHandler)(s.handler), s.networkID, s.ethDialCandidates)
-	if !s.blockchain.Config().Scroll.ZktrieEnabled() && s.config.SnapshotCache > 0 {
+	// The zktrie state is served from the tries themselves, without snapshots
+	if s.blockchain.Config().Scroll.ZktrieEnabled() || s.config.SnapshotCache > 0 {
 		protos = append(protos, snap.MakeProtocols((*snapHandler)(s.handler), s.snapDialCandidates)...)
 	}
 	return protos
@@ -34,6 +34,7 @@ import (
 	"github.com/scroll-tech/go-ethereum/eth/protocols/eth"
 	"github.com/scroll-tech/go-ethereum/ethdb"
 	"github.com/scroll-tech/go-ethereum/event"
+	"github.com/scroll-tech/go-ethereum/params"
 	"github.com/scroll-tech/go-ethereum/trie"
 )
 
@@ -415,6 +416,11 @@ func (dl *downloadTester) Snapshots() *snapshot.Tree {
 	return nil
 }
 
+// Config implements the BlockChain interface for the downloader.
+func (dl *downloadTester) Config() *params.ChainConfig {
+	return params.TestChainConfig
+}
+
 type downloadTesterPeer struct {
 	dl            *downloadTester
 	id            string
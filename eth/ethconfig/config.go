@@ -83,9 +83,10 @@ var Defaults = Config{
 	TrieTimeout:             60 * time.Minute,
 	SnapshotCache:           102,
 	Miner: miner.Config{
-		GasCeil:  8000000,
-		GasPrice: big.NewInt(params.GWei),
-		Recommit: 3 * time.Second,
+		GasCeil:               8000000,
+		GasPrice:              big.NewInt(params.GWei),
+		Recommit:              3 * time.Second,
+		CCCMaxOverflowRetries: 8,
 	},
 	TxPool:        core.DefaultTxPoolConfig,
 	RPCGasCap:     50000000,
@@ -167,6 +168,7 @@ type Config struct {
 	TrieDirtyCache          int
 	TrieTimeout             time.Duration
 	SnapshotCache           int
+	ZktrieSnapshot          bool // Whether to maintain snapshots of zktrie states
 	Preimages               bool
 
 	// Mining options
@@ -213,6 +215,18 @@ type Config struct {
 	// Enable verification of batch consistency between L1 and L2 in rollup
 	EnableRollupVerify bool
 
+	// How to react to batches that fail verification: "halt", "diverge" or "rollback"
+	RollupVerifyFailurePolicy string
+
+	// Rebuild L2 blocks from the batch data committed on L1 instead of syncing them from peers
+	EnableRollupDerivation bool
+
+	// Sources of EIP-4844 blobs used to derive L2 blocks from blob batches, queried in the order:
+	// local directory, beacon node, blob archive
+	RollupBlobDir        string
+	RollupBlobBeaconNode string
+	RollupBlobArchive    string
+
 	// Max block range for eth_getLogs api method
 	MaxBlockRange int64
 
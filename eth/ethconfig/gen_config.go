@@ -18,51 +18,57 @@ import (
 // MarshalTOML marshals as TOML.
 func (c Config) MarshalTOML() (interface{}, error) {
 	type Config struct {
-		Genesis                 *core.Genesis `toml:",omitempty"`
-		NetworkId               uint64
-		SyncMode                downloader.SyncMode
-		EthDiscoveryURLs        []string
-		SnapDiscoveryURLs       []string
-		NoPruning               bool
-		NoPrefetch              bool
-		TxLookupLimit           uint64                 `toml:",omitempty"`
-		Whitelist               map[uint64]common.Hash `toml:"-"`
-		LightServ               int                    `toml:",omitempty"`
-		LightIngress            int                    `toml:",omitempty"`
-		LightEgress             int                    `toml:",omitempty"`
-		LightPeers              int                    `toml:",omitempty"`
-		LightNoPrune            bool                   `toml:",omitempty"`
-		LightNoSyncServe        bool                   `toml:",omitempty"`
-		SyncFromCheckpoint      bool                   `toml:",omitempty"`
-		UltraLightServers       []string               `toml:",omitempty"`
-		UltraLightFraction      int                    `toml:",omitempty"`
-		UltraLightOnlyAnnounce  bool                   `toml:",omitempty"`
-		SkipBcVersionCheck      bool                   `toml:"-"`
-		DatabaseHandles         int                    `toml:"-"`
-		DatabaseCache           int
-		DatabaseFreezer         string
-		TrieCleanCache          int
-		TrieCleanCacheJournal   string        `toml:",omitempty"`
-		TrieCleanCacheRejournal time.Duration `toml:",omitempty"`
-		TrieDirtyCache          int
-		TrieTimeout             time.Duration
-		SnapshotCache           int
-		Preimages               bool
-		Miner                   miner.Config
-		Ethash                  ethash.Config
-		TxPool                  core.TxPoolConfig
-		GPO                     gasprice.Config
-		EnablePreimageRecording bool
-		DocRoot                 string `toml:"-"`
-		RPCGasCap               uint64
-		RPCEVMTimeout           time.Duration
-		RPCTxFeeCap             float64
-		Checkpoint              *params.TrustedCheckpoint      `toml:",omitempty"`
-		CheckpointOracle        *params.CheckpointOracleConfig `toml:",omitempty"`
-		OverrideArrowGlacier    *big.Int                       `toml:",omitempty"`
-		CheckCircuitCapacity    bool
-		EnableRollupVerify      bool
-		MaxBlockRange           int64
+		Genesis                   *core.Genesis `toml:",omitempty"`
+		NetworkId                 uint64
+		SyncMode                  downloader.SyncMode
+		EthDiscoveryURLs          []string
+		SnapDiscoveryURLs         []string
+		NoPruning                 bool
+		NoPrefetch                bool
+		TxLookupLimit             uint64                 `toml:",omitempty"`
+		Whitelist                 map[uint64]common.Hash `toml:"-"`
+		LightServ                 int                    `toml:",omitempty"`
+		LightIngress              int                    `toml:",omitempty"`
+		LightEgress               int                    `toml:",omitempty"`
+		LightPeers                int                    `toml:",omitempty"`
+		LightNoPrune              bool                   `toml:",omitempty"`
+		LightNoSyncServe          bool                   `toml:",omitempty"`
+		SyncFromCheckpoint        bool                   `toml:",omitempty"`
+		UltraLightServers         []string               `toml:",omitempty"`
+		UltraLightFraction        int                    `toml:",omitempty"`
+		UltraLightOnlyAnnounce    bool                   `toml:",omitempty"`
+		SkipBcVersionCheck        bool                   `toml:"-"`
+		DatabaseHandles           int                    `toml:"-"`
+		DatabaseCache             int
+		DatabaseFreezer           string
+		TrieCleanCache            int
+		TrieCleanCacheJournal     string        `toml:",omitempty"`
+		TrieCleanCacheRejournal   time.Duration `toml:",omitempty"`
+		TrieDirtyCache            int
+		TrieTimeout               time.Duration
+		SnapshotCache             int
+		ZktrieSnapshot            bool
+		Preimages                 bool
+		Miner                     miner.Config
+		Ethash                    ethash.Config
+		TxPool                    core.TxPoolConfig
+		GPO                       gasprice.Config
+		EnablePreimageRecording   bool
+		DocRoot                   string `toml:"-"`
+		RPCGasCap                 uint64
+		RPCEVMTimeout             time.Duration
+		RPCTxFeeCap               float64
+		Checkpoint                *params.TrustedCheckpoint      `toml:",omitempty"`
+		CheckpointOracle          *params.CheckpointOracleConfig `toml:",omitempty"`
+		OverrideArrowGlacier      *big.Int                       `toml:",omitempty"`
+		CheckCircuitCapacity      bool
+		EnableRollupVerify        bool
+		RollupVerifyFailurePolicy string
+		EnableRollupDerivation    bool
+		RollupBlobDir             string
+		RollupBlobBeaconNode      string
+		RollupBlobArchive         string
+		MaxBlockRange             int64
 	}
 	var enc Config
 	enc.Genesis = c.Genesis
@@ -94,6 +100,7 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.TrieDirtyCache = c.TrieDirtyCache
 	enc.TrieTimeout = c.TrieTimeout
 	enc.SnapshotCache = c.SnapshotCache
+	enc.ZktrieSnapshot = c.ZktrieSnapshot
 	enc.Preimages = c.Preimages
 	enc.Miner = c.Miner
 	enc.Ethash = c.Ethash
@@ -109,6 +116,11 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.OverrideArrowGlacier = c.OverrideArrowGlacier
 	enc.CheckCircuitCapacity = c.CheckCircuitCapacity
 	enc.EnableRollupVerify = c.EnableRollupVerify
+	enc.RollupVerifyFailurePolicy = c.RollupVerifyFailurePolicy
+	enc.EnableRollupDerivation = c.EnableRollupDerivation
+	enc.RollupBlobDir = c.RollupBlobDir
+	enc.RollupBlobBeaconNode = c.RollupBlobBeaconNode
+	enc.RollupBlobArchive = c.RollupBlobArchive
 	enc.MaxBlockRange = c.MaxBlockRange
 	return &enc, nil
 }
@@ -116,51 +128,57 @@ func (c Config) MarshalTOML() (interface{}, error) {
 // UnmarshalTOML unmarshals from TOML.
 func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	type Config struct {
-		Genesis                 *core.Genesis `toml:",omitempty"`
-		NetworkId               *uint64
-		SyncMode                *downloader.SyncMode
-		EthDiscoveryURLs        []string
-		SnapDiscoveryURLs       []string
-		NoPruning               *bool
-		NoPrefetch              *bool
-		TxLookupLimit           *uint64                `toml:",omitempty"`
-		Whitelist               map[uint64]common.Hash `toml:"-"`
-		LightServ               *int                   `toml:",omitempty"`
-		LightIngress            *int                   `toml:",omitempty"`
-		LightEgress             *int                   `toml:",omitempty"`
-		LightPeers              *int                   `toml:",omitempty"`
-		LightNoPrune            *bool                  `toml:",omitempty"`
-		LightNoSyncServe        *bool                  `toml:",omitempty"`
-		SyncFromCheckpoint      *bool                  `toml:",omitempty"`
-		UltraLightServers       []string               `toml:",omitempty"`
-		UltraLightFraction      *int                   `toml:",omitempty"`
-		UltraLightOnlyAnnounce  *bool                  `toml:",omitempty"`
-		SkipBcVersionCheck      *bool                  `toml:"-"`
-		DatabaseHandles         *int                   `toml:"-"`
-		DatabaseCache           *int
-		DatabaseFreezer         *string
-		TrieCleanCache          *int
-		TrieCleanCacheJournal   *string        `toml:",omitempty"`
-		TrieCleanCacheRejournal *time.Duration `toml:",omitempty"`
-		TrieDirtyCache          *int
-		TrieTimeout             *time.Duration
-		SnapshotCache           *int
-		Preimages               *bool
-		Miner                   *miner.Config
-		Ethash                  *ethash.Config
-		TxPool                  *core.TxPoolConfig
-		GPO                     *gasprice.Config
-		EnablePreimageRecording *bool
-		DocRoot                 *string `toml:"-"`
-		RPCGasCap               *uint64
-		RPCEVMTimeout           *time.Duration
-		RPCTxFeeCap             *float64
-		Checkpoint              *params.TrustedCheckpoint      `toml:",omitempty"`
-		CheckpointOracle        *params.CheckpointOracleConfig `toml:",omitempty"`
-		OverrideArrowGlacier    *big.Int                       `toml:",omitempty"`
-		CheckCircuitCapacity    *bool
-		EnableRollupVerify      *bool
-		MaxBlockRange           *int64
+		Genesis                   *core.Genesis `toml:",omitempty"`
+		NetworkId                 *uint64
+		SyncMode                  *downloader.SyncMode
+		EthDiscoveryURLs          []string
+		SnapDiscoveryURLs         []string
+		NoPruning                 *bool
+		NoPrefetch                *bool
+		TxLookupLimit             *uint64                `toml:",omitempty"`
+		Whitelist                 map[uint64]common.Hash `toml:"-"`
+		LightServ                 *int                   `toml:",omitempty"`
+		LightIngress              *int                   `toml:",omitempty"`
+		LightEgress               *int                   `toml:",omitempty"`
+		LightPeers                *int                   `toml:",omitempty"`
+		LightNoPrune              *bool                  `toml:",omitempty"`
+		LightNoSyncServe          *bool                  `toml:",omitempty"`
+		SyncFromCheckpoint        *bool                  `toml:",omitempty"`
+		UltraLightServers         []string               `toml:",omitempty"`
+		UltraLightFraction        *int                   `toml:",omitempty"`
+		UltraLightOnlyAnnounce    *bool                  `toml:",omitempty"`
+		SkipBcVersionCheck        *bool                  `toml:"-"`
+		DatabaseHandles           *int                   `toml:"-"`
+		DatabaseCache             *int
+		DatabaseFreezer           *string
+		TrieCleanCache            *int
+		TrieCleanCacheJournal     *string        `toml:",omitempty"`
+		TrieCleanCacheRejournal   *time.Duration `toml:",omitempty"`
+		TrieDirtyCache            *int
+		TrieTimeout               *time.Duration
+		SnapshotCache             *int
+		ZktrieSnapshot            *bool
+		Preimages                 *bool
+		Miner                     *miner.Config
+		Ethash                    *ethash.Config
+		TxPool                    *core.TxPoolConfig
+		GPO                       *gasprice.Config
+		EnablePreimageRecording   *bool
+		DocRoot                   *string `toml:"-"`
+		RPCGasCap                 *uint64
+		RPCEVMTimeout             *time.Duration
+		RPCTxFeeCap               *float64
+		Checkpoint                *params.TrustedCheckpoint      `toml:",omitempty"`
+		CheckpointOracle          *params.CheckpointOracleConfig `toml:",omitempty"`
+		OverrideArrowGlacier      *big.Int                       `toml:",omitempty"`
+		CheckCircuitCapacity      *bool
+		EnableRollupVerify        *bool
+		RollupVerifyFailurePolicy *string
+		EnableRollupDerivation    *bool
+		RollupBlobDir             *string
+		RollupBlobBeaconNode      *string
+		RollupBlobArchive         *string
+		MaxBlockRange             *int64
 	}
 	var dec Config
 	if err := unmarshal(&dec); err != nil {
@@ -253,6 +271,9 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.SnapshotCache != nil {
 		c.SnapshotCache = *dec.SnapshotCache
 	}
+	if dec.ZktrieSnapshot != nil {
+		c.ZktrieSnapshot = *dec.ZktrieSnapshot
+	}
 	if dec.Preimages != nil {
 		c.Preimages = *dec.Preimages
 	}
@@ -298,6 +319,21 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.EnableRollupVerify != nil {
 		c.EnableRollupVerify = *dec.EnableRollupVerify
 	}
+	if dec.RollupVerifyFailurePolicy != nil {
+		c.RollupVerifyFailurePolicy = *dec.RollupVerifyFailurePolicy
+	}
+	if dec.EnableRollupDerivation != nil {
+		c.EnableRollupDerivation = *dec.EnableRollupDerivation
+	}
+	if dec.RollupBlobDir != nil {
+		c.RollupBlobDir = *dec.RollupBlobDir
+	}
+	if dec.RollupBlobBeaconNode != nil {
+		c.RollupBlobBeaconNode = *dec.RollupBlobBeaconNode
+	}
+	if dec.RollupBlobArchive != nil {
+		c.RollupBlobArchive = *dec.RollupBlobArchive
+	}
 	if dec.MaxBlockRange != nil {
 		c.MaxBlockRange = *dec.MaxBlockRange
 	}
@@ -143,11 +143,21 @@ func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 	}
 	head := header.Number.Uint64()
 
+	// Resolve the L1-derived block tags against the current rollup state, the end
+	// is resolved again on every call just like "latest"
+	var err error
+	if f.begin, err = resolveBlockTag(ctx, f.backend, f.begin); err != nil {
+		return nil, err
+	}
+	resolvedEnd, err := resolveBlockTag(ctx, f.backend, f.end)
+	if err != nil {
+		return nil, err
+	}
 	if f.begin == -1 {
 		f.begin = int64(head)
 	}
-	end := uint64(f.end)
-	if f.end == -1 {
+	end := uint64(resolvedEnd)
+	if resolvedEnd == -1 {
 		end = head
 	}
 
@@ -156,10 +166,7 @@ func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 		return nil, fmt.Errorf("block range is larger than max block range, block range = %d, max block range = %d", int64(end)-f.begin+1, f.maxBlockRange)
 	}
 	// Gather all indexed logs, and finish with non indexed ones
-	var (
-		logs []*types.Log
-		err  error
-	)
+	var logs []*types.Log
 	size, sections := f.backend.BloomStatus()
 	if indexed := sections * size; indexed > uint64(f.begin) {
 		if indexed > end {
@@ -176,6 +183,23 @@ func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 	return logs, err
 }
 
+// resolveBlockTag converts the "safe" and "finalized" block tags into the
+// number of the block they currently refer to. Other values are returned as is.
+func resolveBlockTag(ctx context.Context, backend Backend, number int64) (int64, error) {
+	if number != rpc.SafeBlockNumber.Int64() && number != rpc.FinalizedBlockNumber.Int64() {
+		return number, nil
+	}
+	header, err := backend.HeaderByNumber(ctx, rpc.BlockNumber(number))
+	if err != nil {
+		return 0, err
+	}
+	if header == nil {
+		tag, _ := rpc.BlockNumber(number).MarshalText()
+		return 0, fmt.Errorf("%s block not found", tag)
+	}
+	return header.Number.Int64(), nil
+}
+
 // indexedLogs returns the logs matching the filter criteria based on the bloom
 // bits indexed available locally or via the network.
 func (f *Filter) indexedLogs(ctx context.Context, end uint64) ([]*types.Log, error) {
@@ -21,6 +21,7 @@ package filters
 import (
 	"context"
 	"fmt"
+	"math/big"
 	"sync"
 	"time"
 
@@ -188,16 +189,23 @@ func (es *EventSystem) subscribe(sub *subscription) *Subscription {
 // given criteria to the given logs channel. Default value for the from and to
 // block is "latest". If the fromBlock > toBlock an error is returned.
 func (es *EventSystem) SubscribeLogs(crit ethereum.FilterQuery, logs chan []*types.Log) (*Subscription, error) {
+	// "safe" and "finalized" are validated against the blocks they currently refer to,
+	// the subscription keeps the tags and resolves them again for every event
+	fromBlock, toBlock, err := es.resolveLogsRange(crit)
+	if err != nil {
+		return nil, err
+	}
+
 	var from, to rpc.BlockNumber
-	if crit.FromBlock == nil {
+	if fromBlock == nil {
 		from = rpc.LatestBlockNumber
 	} else {
-		from = rpc.BlockNumber(crit.FromBlock.Int64())
+		from = rpc.BlockNumber(fromBlock.Int64())
 	}
-	if crit.ToBlock == nil {
+	if toBlock == nil {
 		to = rpc.LatestBlockNumber
 	} else {
-		to = rpc.BlockNumber(crit.ToBlock.Int64())
+		to = rpc.BlockNumber(toBlock.Int64())
 	}
 
 	// only interested in pending logs
@@ -223,6 +231,28 @@ func (es *EventSystem) SubscribeLogs(crit ethereum.FilterQuery, logs chan []*typ
 	return nil, fmt.Errorf("invalid from and to block combination: from > to")
 }
 
+// resolveLogsRange returns the block range of a log filter criteria, with the "safe"
+// and "finalized" block tags converted into the blocks they currently refer to.
+func (es *EventSystem) resolveLogsRange(crit ethereum.FilterQuery) (from, to *big.Int, err error) {
+	resolve := func(number *big.Int) (*big.Int, error) {
+		if number == nil {
+			return nil, nil
+		}
+		resolved, err := resolveBlockTag(context.Background(), es.backend, number.Int64())
+		if err != nil {
+			return nil, err
+		}
+		return big.NewInt(resolved), nil
+	}
+	if from, err = resolve(crit.FromBlock); err != nil {
+		return nil, nil, err
+	}
+	if to, err = resolve(crit.ToBlock); err != nil {
+		return nil, nil, err
+	}
+	return from, to, nil
+}
+
 // subscribeMinedPendingLogs creates a subscription that returned mined and
 // pending logs that match the given criteria.
 func (es *EventSystem) subscribeMinedPendingLogs(crit ethereum.FilterQuery, logs chan []*types.Log) *Subscription {
@@ -313,7 +343,11 @@ func (es *EventSystem) handleLogs(filters filterIndex, ev []*types.Log) {
 		return
 	}
 	for _, f := range filters[LogsSubscription] {
-		matchedLogs := filterLogs(ev, f.logsCrit.FromBlock, f.logsCrit.ToBlock, f.logsCrit.Addresses, f.logsCrit.Topics)
+		from, to, err := es.resolveLogsRange(f.logsCrit)
+		if err != nil {
+			continue
+		}
+		matchedLogs := filterLogs(ev, from, to, f.logsCrit.Addresses, f.logsCrit.Topics)
 		if len(matchedLogs) > 0 {
 			f.logs <- matchedLogs
 		}
@@ -334,7 +368,11 @@ func (es *EventSystem) handlePendingLogs(filters filterIndex, ev []*types.Log) {
 
 func (es *EventSystem) handleRemovedLogs(filters filterIndex, ev core.RemovedLogsEvent) {
 	for _, f := range filters[LogsSubscription] {
-		matchedLogs := filterLogs(ev.Logs, f.logsCrit.FromBlock, f.logsCrit.ToBlock, f.logsCrit.Addresses, f.logsCrit.Topics)
+		from, to, err := es.resolveLogsRange(f.logsCrit)
+		if err != nil {
+			continue
+		}
+		matchedLogs := filterLogs(ev.Logs, from, to, f.logsCrit.Addresses, f.logsCrit.Topics)
 		if len(matchedLogs) > 0 {
 			f.logs <- matchedLogs
 		}
@@ -18,6 +18,7 @@ package filters
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math/big"
 	"math/rand"
@@ -65,14 +66,29 @@ func (b *testBackend) HeaderByNumber(ctx context.Context, blockNr rpc.BlockNumbe
 		hash common.Hash
 		num  uint64
 	)
-	if blockNr == rpc.LatestBlockNumber {
+	switch blockNr {
+	case rpc.LatestBlockNumber:
 		hash = rawdb.ReadHeadBlockHash(b.db)
 		number := rawdb.ReadHeaderNumber(b.db, hash)
 		if number == nil {
 			return nil, nil
 		}
 		num = *number
-	} else {
+	case rpc.SafeBlockNumber:
+		number := rawdb.ReadCommittedL2BlockNumber(b.db)
+		if number == nil {
+			return nil, errors.New("safe block not found")
+		}
+		num = *number
+		hash = rawdb.ReadCanonicalHash(b.db, num)
+	case rpc.FinalizedBlockNumber:
+		number := rawdb.ReadFinalizedL2BlockNumber(b.db)
+		if number == nil {
+			return nil, errors.New("finalized block not found")
+		}
+		num = *number
+		hash = rawdb.ReadCanonicalHash(b.db, num)
+	default:
 		num = uint64(blockNr)
 		hash = rawdb.ReadCanonicalHash(b.db, num)
 	}
@@ -519,6 +535,62 @@ func TestLogFilter(t *testing.T) {
 	}
 }
 
+// TestLogFilterSafeBlockTag tests that log filters up to the "safe" block match the logs
+// up to the block it refers to when the logs are posted, not when the filter is created.
+func TestLogFilterSafeBlockTag(t *testing.T) {
+	t.Parallel()
+
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		backend = &testBackend{db: db}
+		api     = NewPublicFilterAPI(backend, false, deadline, ethconfig.Defaults.MaxBlockRange)
+
+		allLogs = []*types.Log{
+			{Address: common.Address{1}, BlockNumber: 1},
+			{Address: common.Address{1}, BlockNumber: 2},
+			{Address: common.Address{1}, BlockNumber: 3},
+			{Address: common.Address{1}, BlockNumber: 4},
+		}
+	)
+	for number := uint64(0); number <= 4; number++ {
+		header := &types.Header{Number: new(big.Int).SetUint64(number), Difficulty: common.Big0}
+		rawdb.WriteHeader(db, header)
+		rawdb.WriteCanonicalHash(db, header.Hash(), number)
+	}
+
+	// the safe block must be known when the filter is created
+	crit := FilterCriteria{FromBlock: big.NewInt(0), ToBlock: big.NewInt(rpc.SafeBlockNumber.Int64())}
+	if _, err := api.NewFilter(crit); err == nil {
+		t.Fatal("created filter with unknown safe block")
+	}
+	rawdb.WriteCommittedL2BlockNumber(db, 2)
+	id, err := api.NewFilter(crit)
+	if err != nil {
+		t.Fatalf("failed to create filter: %v", err)
+	}
+
+	time.Sleep(1 * time.Second)
+	backend.logsFeed.Send(allLogs)
+	time.Sleep(100 * time.Millisecond)
+	rawdb.WriteCommittedL2BlockNumber(db, 4)
+	backend.logsFeed.Send(allLogs)
+
+	expected := []*types.Log{allLogs[0], allLogs[1], allLogs[0], allLogs[1], allLogs[2], allLogs[3]}
+	var fetched []*types.Log
+	timeout := time.Now().Add(1 * time.Second)
+	for len(fetched) < len(expected) && time.Now().Before(timeout) {
+		results, err := api.GetFilterChanges(id)
+		if err != nil {
+			t.Fatalf("Unable to fetch logs: %v", err)
+		}
+		fetched = append(fetched, results.([]*types.Log)...)
+		time.Sleep(100 * time.Millisecond)
+	}
+	if !reflect.DeepEqual(fetched, expected) {
+		t.Fatalf("invalid logs, want %d log(s), got %d", len(expected), len(fetched))
+	}
+}
+
 // TestPendingLogsSubscription tests if a subscription receives the correct pending logs that are posted to the event feed.
 func TestPendingLogsSubscription(t *testing.T) {
 	t.Parallel()
@@ -30,6 +30,7 @@ import (
 	"github.com/scroll-tech/go-ethereum/core/types"
 	"github.com/scroll-tech/go-ethereum/crypto"
 	"github.com/scroll-tech/go-ethereum/params"
+	"github.com/scroll-tech/go-ethereum/rpc"
 )
 
 func makeReceipt(addr common.Address) *types.Receipt {
@@ -226,4 +227,43 @@ func TestFilters(t *testing.T) {
 	if len(logs) != 0 {
 		t.Error("expected 0 log, got", len(logs))
 	}
+	// the safe block is not known yet
+	filter = NewRangeFilter(backend, 0, rpc.SafeBlockNumber.Int64(), []common.Address{addr}, nil)
+	if _, err := filter.Logs(context.Background()); err == nil {
+		t.Error("expected error for unknown safe block")
+	}
+
+	// the safe block is resolved again when the same filter is queried later
+	rawdb.WriteCommittedL2BlockNumber(db, 3)
+	filter = NewRangeFilter(backend, 0, rpc.SafeBlockNumber.Int64(), []common.Address{addr}, nil)
+	logs, _ = filter.Logs(context.Background())
+	if len(logs) != 2 {
+		t.Error("expected 2 log, got", len(logs))
+	}
+	rawdb.WriteCommittedL2BlockNumber(db, 1000)
+	logs, _ = filter.Logs(context.Background())
+	if len(logs) != 2 {
+		t.Error("expected 2 log, got", len(logs))
+	}
+
+	rawdb.WriteFinalizedL2BlockNumber(db, 1)
+	rawdb.WriteCommittedL2BlockNumber(db, 999)
+
+	filter = NewRangeFilter(backend, 0, rpc.SafeBlockNumber.Int64(), []common.Address{addr}, nil)
+	logs, _ = filter.Logs(context.Background())
+	if len(logs) != 3 {
+		t.Error("expected 3 log, got", len(logs))
+	}
+
+	filter = NewRangeFilter(backend, rpc.FinalizedBlockNumber.Int64(), rpc.SafeBlockNumber.Int64(), []common.Address{addr}, nil)
+	logs, _ = filter.Logs(context.Background())
+	if len(logs) != 3 {
+		t.Error("expected 3 log, got", len(logs))
+	}
+
+	filter = NewRangeFilter(backend, rpc.SafeBlockNumber.Int64(), -1, []common.Address{addr}, nil)
+	logs, _ = filter.Logs(context.Background())
+	if len(logs) != 2 {
+		t.Error("expected 2 log, got", len(logs))
+	}
 }
@@ -164,6 +164,9 @@ func handleMessage(backend Backend, peer *Peer) error {
 		if req.Bytes > softResponseLimit {
 			req.Bytes = softResponseLimit
 		}
+		if isZktrie(backend.Chain()) {
+			return p2p.Send(peer.rw, AccountRangeMsg, serviceZkAccountRange(backend.Chain(), &req))
+		}
 		// Retrieve the requested state and bail out if non existent
 		tr, err := trie.New(req.Root, backend.Chain().StateCache().TrieDB())
 		if err != nil {
@@ -246,6 +249,9 @@ func handleMessage(backend Backend, peer *Peer) error {
 		if req.Bytes > softResponseLimit {
 			req.Bytes = softResponseLimit
 		}
+		if isZktrie(backend.Chain()) {
+			return p2p.Send(peer.rw, StorageRangesMsg, serviceZkStorageRanges(backend.Chain(), &req))
+		}
 		// TODO(karalabe): Do we want to enforce > 0 accounts and 1 account if origin is set?
 		// TODO(karalabe):   - Logging locally is not ideal as remote faulst annoy the local user
 		// TODO(karalabe):   - Dropping the remote peer is less flexible wrt client bugs (slow is better than non-functional)
@@ -427,6 +433,13 @@ func handleMessage(backend Backend, peer *Peer) error {
 		if req.Bytes > softResponseLimit {
 			req.Bytes = softResponseLimit
 		}
+		if isZktrie(backend.Chain()) {
+			res, err := serviceZkTrieNodes(backend.Chain(), &req, start)
+			if err != nil {
+				return err
+			}
+			return p2p.Send(peer.rw, TrieNodesMsg, res)
+		}
 		// Make sure we have the state associated with the request
 		triedb := backend.Chain().StateCache().TrieDB()
 
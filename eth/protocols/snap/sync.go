@@ -394,7 +394,8 @@ type SyncPeer interface {
 //   - The peer delivers a stale response after a previous timeout
 //   - The peer delivers a refusal to serve the requested state
 type Syncer struct {
-	db ethdb.KeyValueStore // Database to store the trie nodes into (and dedup)
+	db     ethdb.KeyValueStore // Database to store the trie nodes into (and dedup)
+	zktrie bool                // Whether the state being synced is stored in zktries
 
 	root    common.Hash    // Current state trie root being synced
 	tasks   []*accountTask // Current account task set being synced
@@ -454,10 +455,11 @@ type Syncer struct {
 }
 
 // NewSyncer creates a new snapshot syncer to download the Ethereum state over the
-// snap protocol.
-func NewSyncer(db ethdb.KeyValueStore) *Syncer {
+// snap protocol. If zktrie is set, the state is downloaded as zktries.
+func NewSyncer(db ethdb.KeyValueStore, zktrie bool) *Syncer {
 	return &Syncer{
-		db: db,
+		db:     db,
+		zktrie: zktrie,
 
 		peers:    make(map[string]SyncPeer),
 		peerJoin: new(event.Feed),
@@ -552,6 +554,9 @@ func (s *Syncer) Sync(root common.Hash, cancel chan struct{}) error {
 		trieTasks: make(map[common.Hash]trie.SyncPath),
 		codeTasks: make(map[common.Hash]struct{}),
 	}
+	if s.zktrie {
+		s.healer.scheduler = state.NewZktrieStateSync(root, s.db, nil, s.onHealState)
+	}
 	s.statelessPeers = make(map[string]struct{})
 	s.lock.Unlock()
 
@@ -1765,8 +1770,8 @@ func (s *Syncer) processAccountResponse(res *accountResponse) {
 			}
 		}
 		// Check if the account is a contract with an unknown storage trie
-		if account.Root != emptyRoot {
-			if node, err := s.db.Get(account.Root[:]); err != nil || node == nil {
+		if account.Root != s.storageEmptyRoot() {
+			if node, err := s.db.Get(s.trieNodeKey(account.Root)); err != nil || node == nil {
 				// If there was a previous large state retrieval in progress,
 				// don't restart it from scratch. This happens if a sync cycle
 				// is interrupted and resumed later. However, *do* update the
@@ -1868,6 +1873,12 @@ func (s *Syncer) processStorageResponse(res *storageResponse) {
 		slots           int
 		oldStorageBytes = s.storageBytes
 	)
+	// Track where the delivered chunk of a large contract starts, the zktrie nodes
+	// are reconstructed for the range it covers
+	var chunkOrigin common.Hash
+	if res.subTask != nil {
+		chunkOrigin = res.subTask.Next
+	}
 	// Iterate over all the accounts and reconstruct their storage tries from the
 	// delivered slots
 	for i, account := range res.accounts {
@@ -1997,28 +2008,41 @@ func (s *Syncer) processStorageResponse(res *storageResponse) {
 		slots += len(res.hashes[i])
 
 		if i < len(res.hashes)-1 || res.subTask == nil {
-			tr := trie.NewStackTrie(batch)
-			for j := 0; j < len(res.hashes[i]); j++ {
-				tr.Update(res.hashes[i][j][:], res.slots[i][j])
+			if s.zktrie {
+				s.commitZkRange(nil, nil, res.hashes[i], res.slots[i], zkStorageFlag, nil, batch)
+			} else {
+				tr := trie.NewStackTrie(batch)
+				for j := 0; j < len(res.hashes[i]); j++ {
+					tr.Update(res.hashes[i][j][:], res.slots[i][j])
+				}
+				tr.Commit()
 			}
-			tr.Commit()
+		} else if s.zktrie {
+			// The zktrie nodes of a large contract can't be generated on the fly,
+			// write the ones within the delivered chunk and leave the gluing
+			// points to the healer
+			last := res.subTask.Last
+			if !res.subTask.done {
+				last = res.hashes[i][len(res.hashes[i])-1]
+			}
+			s.commitZkRange(chunkOrigin[:], last[:], res.hashes[i], res.slots[i], zkStorageFlag, nil, res.subTask.genBatch)
 		}
 		// Persist the received storage segements. These flat state maybe
 		// outdated during the sync, but it can be fixed later during the
 		// snapshot generation.
 		for j := 0; j < len(res.hashes[i]); j++ {
-			rawdb.WriteStorageSnapshot(batch, account, res.hashes[i][j], res.slots[i][j])
+			rawdb.WriteStorageSnapshot(batch, s.snapshotKey(account), s.snapshotKey(res.hashes[i][j]), res.slots[i][j])
 
 			// If we're storing large contracts, generate the trie nodes
 			// on the fly to not trash the gluing points
-			if i == len(res.hashes)-1 && res.subTask != nil {
+			if i == len(res.hashes)-1 && res.subTask != nil && !s.zktrie {
 				res.subTask.genTrie.Update(res.hashes[i][j][:], res.slots[i][j])
 			}
 		}
 	}
 	// Large contracts could have generated new trie nodes, flush them to disk
 	if res.subTask != nil {
-		if res.subTask.done {
+		if res.subTask.done && !s.zktrie {
 			if root, err := res.subTask.genTrie.Commit(); err != nil {
 				log.Error("Failed to commit stack slots", "err", err)
 			} else if root == res.subTask.root {
@@ -2149,13 +2173,25 @@ func (s *Syncer) forwardAccountTask(task *accountTask) {
 			s.accountBytes += common.StorageSize(len(key) + len(value))
 		},
 	}
+	var (
+		origin = task.Next
+		leaves [][]byte
+		flag   uint32
+	)
 	for i, hash := range res.hashes {
 		if task.needCode[i] || task.needState[i] {
 			break
 		}
 		slim := snapshot.SlimAccountRLP(res.accounts[i].Nonce, res.accounts[i].Balance, res.accounts[i].Root, res.accounts[i].KeccakCodeHash, res.accounts[i].PoseidonCodeHash, res.accounts[i].CodeSize)
-		rawdb.WriteAccountSnapshot(batch, hash, slim)
+		rawdb.WriteAccountSnapshot(batch, s.snapshotKey(hash), slim)
 
+		// Zktrie nodes are generated from the entire persisted range below
+		if s.zktrie {
+			var leaf []byte
+			leaf, flag = zkAccountLeaf(res.accounts[i])
+			leaves = append(leaves, leaf)
+			continue
+		}
 		// If the task is complete, drop it into the stack trie to generate
 		// account trie nodes for it
 		if !task.needHeal[i] {
@@ -2183,10 +2219,20 @@ func (s *Syncer) forwardAccountTask(task *accountTask) {
 	// All accounts marked as complete, track if the entire task is done
 	task.done = !res.cont
 
+	// Write the zktrie nodes within the persisted range, except the ones above
+	// the accounts whose storage was chunked and needs healing
+	if s.zktrie {
+		last := task.Last
+		if !task.done {
+			last = res.hashes[len(res.hashes)-1]
+		}
+		s.commitZkRange(origin[:], last[:], res.hashes, leaves, flag, func(i int) bool { return task.needHeal[i] }, task.genBatch)
+	}
+
 	// Stack trie could have generated trie nodes, push them to disk (we need to
 	// flush after finalizing task.done. It's fine even if we crash and lose this
 	// write as it will only cause more data to be downloaded during heal.
-	if task.done {
+	if task.done && !s.zktrie {
 		if _, err := task.genTrie.Commit(); err != nil {
 			log.Error("Failed to commit stack account", "err", err)
 		}
@@ -2269,17 +2315,6 @@ func (s *Syncer) OnAccounts(peer SyncPeer, id uint64, hashes []common.Hash, acco
 	}
 	proofdb := nodes.NodeSet()
 
-	var end []byte
-	if len(keys) > 0 {
-		end = keys[len(keys)-1]
-	}
-	cont, err := trie.VerifyRangeProof(root, req.origin[:], end, keys, accounts, proofdb)
-	if err != nil {
-		logger.Warn("Account range failed proof", "err", err)
-		// Signal this request as failed, and ready for rescheduling
-		s.scheduleRevertAccountRequest(req)
-		return err
-	}
 	accs := make([]*types.StateAccount, len(accounts))
 	for i, account := range accounts {
 		acc := new(types.StateAccount)
@@ -2288,6 +2323,33 @@ func (s *Syncer) OnAccounts(peer SyncPeer, id uint64, hashes []common.Hash, acco
 		}
 		accs[i] = acc
 	}
+	var (
+		cont bool
+		err  error
+	)
+	if s.zktrie {
+		// Zktrie leaves commit to the fields of the accounts, not their RLP
+		var (
+			leaves = make([][]byte, len(accs))
+			flag   uint32
+		)
+		for i, acc := range accs {
+			leaves[i], flag = zkAccountLeaf(acc)
+		}
+		cont, err = trie.VerifyZktrieRangeProof(root, req.origin[:], keys, leaves, flag, proof)
+	} else {
+		var end []byte
+		if len(keys) > 0 {
+			end = keys[len(keys)-1]
+		}
+		cont, err = trie.VerifyRangeProof(root, req.origin[:], end, keys, accounts, proofdb)
+	}
+	if err != nil {
+		logger.Warn("Account range failed proof", "err", err)
+		// Signal this request as failed, and ready for rescheduling
+		s.scheduleRevertAccountRequest(req)
+		return err
+	}
 	response := &accountResponse{
 		task:     req.task,
 		hashes:   hashes,
@@ -2507,7 +2569,23 @@ func (s *Syncer) OnStorage(peer SyncPeer, id uint64, hashes [][]common.Hash, slo
 			}
 		}
 		var err error
-		if len(nodes) == 0 {
+		if s.zktrie {
+			var zkproof [][]byte
+			if len(nodes) > 0 {
+				zkproof = proof
+			}
+			// Without a proof the response must cover the entire storage trie
+			var zkcont bool
+			zkcont, err = trie.VerifyZktrieRangeProof(req.roots[i], req.origin[:], keys, slots[i], zkStorageFlag, zkproof)
+			if err != nil {
+				s.scheduleRevertStorageRequest(req) // reschedule request
+				logger.Warn("Storage range failed proof", "err", err)
+				return err
+			}
+			if zkproof != nil {
+				cont = zkcont
+			}
+		} else if len(nodes) == 0 {
 			// No proof has been attached, the response must cover the entire key
 			// space and hash to the origin root.
 			_, err = trie.VerifyRangeProof(req.roots[i], nil, nil, keys, slots[i], nil)
@@ -2613,9 +2691,19 @@ func (s *Syncer) OnTrieNodes(peer SyncPeer, id uint64, trienodes [][]byte) error
 	nodes := make([][]byte, len(req.hashes))
 	for i, j := 0, 0; i < len(trienodes); i++ {
 		// Find the next hash that we've been served, leaving misses with nils
-		hasher.Reset()
-		hasher.Write(trienodes[i])
-		hasher.Read(hash)
+		if s.zktrie {
+			zkhash, err := zkNodeHash(trienodes[i])
+			if err != nil {
+				logger.Warn("Invalid healing trienode", "err", err)
+				s.scheduleRevertTrienodeHealRequest(req)
+				return err
+			}
+			copy(hash, zkhash[:])
+		} else {
+			hasher.Reset()
+			hasher.Write(trienodes[i])
+			hasher.Read(hash)
+		}
 
 		for j < len(req.hashes) && !bytes.Equal(hash, req.hashes[j][:]) {
 			j++
@@ -2746,8 +2834,15 @@ func (s *Syncer) onHealByteCodes(peer SyncPeer, id uint64, bytecodes [][]byte) e
 // Note it's not concurrent safe, please handle the concurrent issue outside.
 func (s *Syncer) onHealState(paths [][]byte, value []byte) error {
 	if len(paths) == 1 {
-		var account types.StateAccount
-		if err := rlp.DecodeBytes(value, &account); err != nil {
+		account := new(types.StateAccount)
+		if s.zktrie {
+			// The leaves of a zktrie carry the account fields, and the paths
+			// are the node keys the snapshot is keyed by
+			var err error
+			if account, err = types.UnmarshalStateAccount(value); err != nil {
+				return nil
+			}
+		} else if err := rlp.DecodeBytes(value, account); err != nil {
 			return nil
 		}
 		blob := snapshot.SlimAccountRLP(account.Nonce, account.Balance, account.Root, account.KeccakCodeHash, account.PoseidonCodeHash, account.CodeSize)
@@ -0,0 +1,396 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snap
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+
+	zktrie "github.com/scroll-tech/zktrie/trie"
+	zkt "github.com/scroll-tech/zktrie/types"
+
+	"github.com/scroll-tech/go-ethereum/common"
+	"github.com/scroll-tech/go-ethereum/core"
+	"github.com/scroll-tech/go-ethereum/core/state/snapshot"
+	"github.com/scroll-tech/go-ethereum/core/types"
+	"github.com/scroll-tech/go-ethereum/ethdb"
+	"github.com/scroll-tech/go-ethereum/light"
+	"github.com/scroll-tech/go-ethereum/log"
+	"github.com/scroll-tech/go-ethereum/trie"
+)
+
+// The snap protocol is served from the zktrie itself if the chain uses it for
+// its state. The accounts and storage slots are identified on the wire by their
+// path keys (the bit-reversed node keys), whose order is the iteration order of
+// the zktrie, so the ranges can be requested and chunked like the ones of the
+// Merkle Patricia trie. The ranges are proven with the nodes of the zktrie on
+// the paths of their first and last keys, and the trie nodes are requested by
+// their bit paths.
+
+// errZkAccountNotFound is returned if an account whose storage is requested is
+// not in the account trie.
+var errZkAccountNotFound = errors.New("account not found")
+
+// isZktrie returns whether the state of the chain is stored in zktries.
+func isZktrie(chain *core.BlockChain) bool {
+	return chain.StateCache().TrieDB().Zktrie
+}
+
+// openZkTrie opens the zktrie with the given root in the trie database of the
+// chain.
+func openZkTrie(chain *core.BlockChain, root common.Hash) (*trie.ZkTrie, error) {
+	return trie.NewZkTrie(root, trie.NewZktrieDatabaseFromTriedb(chain.StateCache().TrieDB()))
+}
+
+// zkAccount retrieves the account with the given path key from an account trie.
+func zkAccount(accTrie *trie.ZkTrie, hash common.Hash) (*types.StateAccount, error) {
+	blob, err := accTrie.Tree().TryGet(zkt.NewHashFromBytes(trie.ZktriePathKey(hash[:])))
+	if err != nil {
+		return nil, err
+	}
+	if blob == nil {
+		return nil, errZkAccountNotFound
+	}
+	return types.UnmarshalStateAccount(blob)
+}
+
+// proveZkRange generates the proof of a range of leaves between origin and last
+// (if non-zero), both given as path keys.
+func proveZkRange(tr *trie.ZkTrie, origin common.Hash, last common.Hash) ([][]byte, error) {
+	proof := light.NewNodeSet()
+	if err := tr.ProveNodeKey(trie.ZktriePathKey(origin[:]), proof); err != nil {
+		return nil, err
+	}
+	if last != (common.Hash{}) {
+		if err := tr.ProveNodeKey(trie.ZktriePathKey(last[:]), proof); err != nil {
+			return nil, err
+		}
+	}
+	var proofs [][]byte
+	for _, blob := range proof.NodeList() {
+		proofs = append(proofs, blob)
+	}
+	return proofs, nil
+}
+
+// serviceZkAccountRange assembles the response to an account range query from
+// the zktrie of the chain.
+func serviceZkAccountRange(chain *core.BlockChain, req *GetAccountRangePacket) *AccountRangePacket {
+	// Retrieve the requested state and bail out if non existent
+	tr, err := openZkTrie(chain, req.Root)
+	if err != nil {
+		return &AccountRangePacket{ID: req.ID}
+	}
+	it := trie.NewIterator(tr.NodeIterator(trie.ZktriePathKey(req.Origin[:])))
+
+	// Iterate over the requested range and pile accounts up
+	var (
+		accounts []*AccountData
+		size     uint64
+		last     common.Hash
+	)
+	for size < req.Bytes && it.Next() {
+		account, err := types.UnmarshalStateAccount(it.Value)
+		if err != nil {
+			log.Warn("Failed to decode zktrie account", "key", it.Key, "err", err)
+			return &AccountRangePacket{ID: req.ID}
+		}
+		hash := common.BytesToHash(trie.ZktriePathKey(it.Key))
+		if bytes.Compare(hash[:], req.Origin[:]) < 0 {
+			// The iterator only seeks by the bits of the node key that are used
+			// as the path, skip the leaf the origin was incremented from
+			continue
+		}
+		body := snapshot.SlimAccountRLP(account.Nonce, account.Balance, account.Root, account.KeccakCodeHash, account.PoseidonCodeHash, account.CodeSize)
+
+		// Track the returned interval for the proofs
+		last = hash
+
+		// Assemble the reply item
+		size += uint64(common.HashLength + len(body))
+		accounts = append(accounts, &AccountData{
+			Hash: hash,
+			Body: body,
+		})
+		// If we've exceeded the request threshold, abort
+		if bytes.Compare(hash[:], req.Limit[:]) >= 0 {
+			break
+		}
+	}
+	if it.Err != nil {
+		log.Warn("Failed to iterate zktrie accounts", "root", req.Root, "err", it.Err)
+		return &AccountRangePacket{ID: req.ID}
+	}
+	// Generate the proofs for the first and last account
+	proofs, err := proveZkRange(tr, req.Origin, last)
+	if err != nil {
+		log.Warn("Failed to prove account range", "origin", req.Origin, "last", last, "err", err)
+		return &AccountRangePacket{ID: req.ID}
+	}
+	return &AccountRangePacket{
+		ID:       req.ID,
+		Accounts: accounts,
+		Proof:    proofs,
+	}
+}
+
+// serviceZkStorageRanges assembles the response to a storage ranges query from
+// the zktries of the chain.
+func serviceZkStorageRanges(chain *core.BlockChain, req *GetStorageRangesPacket) *StorageRangesPacket {
+	// Calculate the hard limit at which to abort, even if mid storage trie
+	hardLimit := uint64(float64(req.Bytes) * (1 + stateLookupSlack))
+
+	accTrie, err := openZkTrie(chain, req.Root)
+	if err != nil {
+		return &StorageRangesPacket{ID: req.ID}
+	}
+	// Retrieve storage ranges until the packet limit is reached
+	var (
+		slots  [][]*StorageData
+		proofs [][]byte
+		size   uint64
+	)
+	for _, account := range req.Accounts {
+		// If we've exceeded the requested data limit, abort without opening
+		// a new storage range (that we'd need to prove due to exceeded size)
+		if size >= req.Bytes {
+			break
+		}
+		// The first account might start from a different origin and end sooner
+		var origin common.Hash
+		if len(req.Origin) > 0 {
+			origin, req.Origin = common.BytesToHash(req.Origin), nil
+		}
+		var limit = common.HexToHash("0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff")
+		if len(req.Limit) > 0 {
+			limit, req.Limit = common.BytesToHash(req.Limit), nil
+		}
+		// Retrieve the requested state and bail out if non existent
+		acc, err := zkAccount(accTrie, account)
+		if err != nil {
+			return &StorageRangesPacket{ID: req.ID}
+		}
+		stTrie, err := openZkTrie(chain, acc.Root)
+		if err != nil {
+			return &StorageRangesPacket{ID: req.ID}
+		}
+		it := trie.NewIterator(stTrie.NodeIterator(trie.ZktriePathKey(origin[:])))
+
+		// Iterate over the requested range and pile slots up
+		var (
+			storage []*StorageData
+			last    common.Hash
+			abort   bool
+		)
+		for it.Next() {
+			if size >= hardLimit {
+				abort = true
+				break
+			}
+			hash, slot := common.BytesToHash(trie.ZktriePathKey(it.Key)), common.CopyBytes(it.Value)
+			if bytes.Compare(hash[:], origin[:]) < 0 {
+				// The iterator only seeks by the bits of the node key that are
+				// used as the path, skip the slot the origin was incremented from
+				continue
+			}
+
+			// Track the returned interval for the proofs
+			last = hash
+
+			// Assemble the reply item
+			size += uint64(common.HashLength + len(slot))
+			storage = append(storage, &StorageData{
+				Hash: hash,
+				Body: slot,
+			})
+			// If we've exceeded the request threshold, abort
+			if bytes.Compare(hash[:], limit[:]) >= 0 {
+				break
+			}
+		}
+		if it.Err != nil {
+			log.Warn("Failed to iterate zktrie storage", "root", acc.Root, "err", it.Err)
+			return &StorageRangesPacket{ID: req.ID}
+		}
+		slots = append(slots, storage)
+
+		// Generate the proofs for the first and last storage slot, but only if
+		// the response was capped. If the entire storage trie included in the
+		// response, no need for any proofs.
+		if origin != (common.Hash{}) || abort {
+			proof, err := proveZkRange(stTrie, origin, last)
+			if err != nil {
+				log.Warn("Failed to prove storage range", "origin", origin, "last", last, "err", err)
+				return &StorageRangesPacket{ID: req.ID}
+			}
+			proofs = proof
+
+			// Proof terminates the reply as proofs are only added if a node
+			// refuses to serve more data
+			break
+		}
+	}
+	return &StorageRangesPacket{
+		ID:    req.ID,
+		Slots: slots,
+		Proof: proofs,
+	}
+}
+
+// serviceZkTrieNodes assembles the response to a trie nodes query from the
+// zktries of the chain. The first element of a storage pathset is the path key
+// of the account, the others, like the one of an account pathset, are the
+// compact encoded bit paths of the nodes.
+func serviceZkTrieNodes(chain *core.BlockChain, req *GetTrieNodesPacket, start time.Time) (*TrieNodesPacket, error) {
+	// Make sure we have the state associated with the request
+	accTrie, err := openZkTrie(chain, req.Root)
+	if err != nil {
+		// We don't have the requested state available, bail out
+		return &TrieNodesPacket{ID: req.ID}, nil
+	}
+	// Retrieve trie nodes until the packet size limit is reached
+	var (
+		nodes [][]byte
+		bytes uint64
+		loads int // Trie hash expansions to cound database reads
+	)
+	for _, pathset := range req.Paths {
+		switch len(pathset) {
+		case 0:
+			// Ensure we penalize invalid requests
+			return nil, fmt.Errorf("%w: zero-item pathset requested", errBadRequest)
+
+		case 1:
+			// If we're only retrieving an account trie node, fetch it directly
+			blob, resolved, err := accTrie.TryGetNode(pathset[0])
+			loads += resolved // always account database reads, even for failures
+			if err != nil || blob == nil {
+				break
+			}
+			nodes = append(nodes, blob)
+			bytes += uint64(len(blob))
+
+		default:
+			// Storage slots requested, open the storage trie and retrieve from there
+			account, err := zkAccount(accTrie, common.BytesToHash(pathset[0]))
+			loads++ // always account database reads, even for failures
+			if err != nil {
+				break
+			}
+			stTrie, err := openZkTrie(chain, account.Root)
+			loads++ // always account database reads, even for failures
+			if err != nil {
+				break
+			}
+			for _, path := range pathset[1:] {
+				blob, resolved, err := stTrie.TryGetNode(path)
+				loads += resolved // always account database reads, even for failures
+				if err != nil || blob == nil {
+					break
+				}
+				nodes = append(nodes, blob)
+				bytes += uint64(len(blob))
+
+				// Sanity check limits to avoid DoS on the store trie loads
+				if bytes > req.Bytes || loads > maxTrieNodeLookups || time.Since(start) > maxTrieNodeTimeSpent {
+					break
+				}
+			}
+		}
+		// Abort request processing if we've exceeded our limits
+		if bytes > req.Bytes || loads > maxTrieNodeLookups || time.Since(start) > maxTrieNodeTimeSpent {
+			break
+		}
+	}
+	return &TrieNodesPacket{
+		ID:    req.ID,
+		Nodes: nodes,
+	}, nil
+}
+
+// zkStorageFlag is the compression flag of the storage slot leaves of a zktrie.
+const zkStorageFlag = 1
+
+// zkAccountLeaf returns the value fields of the zktrie leaf of an account as a
+// single blob, along with their compression flag.
+func zkAccountLeaf(account *types.StateAccount) ([]byte, uint32) {
+	fields, flag := account.MarshalFields()
+	blob := make([]byte, 0, len(fields)*32)
+	for _, field := range fields {
+		blob = append(blob, field[:]...)
+	}
+	return blob, flag
+}
+
+// zkKeys converts a list of path keys into the format expected by the zktrie
+// range functions.
+func zkKeys(hashes []common.Hash) [][]byte {
+	keys := make([][]byte, len(hashes))
+	for i, hash := range hashes {
+		keys[i] = common.CopyBytes(hash[:])
+	}
+	return keys
+}
+
+// zkNodeHash calculates the hash of an encoded zktrie node.
+func zkNodeHash(blob []byte) (common.Hash, error) {
+	node, err := zktrie.NewNodeFromBytes(blob)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	hash, err := node.NodeHash()
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return common.BytesToHash(hash.Bytes()), nil
+}
+
+// storageEmptyRoot returns the root hash of an empty storage trie.
+func (s *Syncer) storageEmptyRoot() common.Hash {
+	if s.zktrie {
+		return common.Hash{}
+	}
+	return emptyRoot
+}
+
+// trieNodeKey returns the database key of the trie node with the given hash.
+func (s *Syncer) trieNodeKey(hash common.Hash) []byte {
+	if s.zktrie {
+		return trie.ZktrieNodeKey(hash)
+	}
+	return hash[:]
+}
+
+// snapshotKey converts the hash of an account or storage slot, as sent over the
+// wire, into the key of its snapshot entry. The snapshot of a zktrie state is
+// keyed by the node keys instead of the path keys.
+func (s *Syncer) snapshotKey(hash common.Hash) common.Hash {
+	if s.zktrie {
+		return common.BytesToHash(trie.ZktriePathKey(hash[:]))
+	}
+	return hash
+}
+
+// commitZkRange writes the zktrie nodes of a range of leaves into the database,
+// see trie.CommitZktrieRange.
+func (s *Syncer) commitZkRange(origin, last []byte, hashes []common.Hash, values [][]byte, flag uint32, skip func(int) bool, db ethdb.KeyValueWriter) {
+	if err := trie.CommitZktrieRange(origin, last, zkKeys(hashes), values, flag, skip, db); err != nil {
+		log.Error("Failed to commit zktrie range", "err", err)
+	}
+}
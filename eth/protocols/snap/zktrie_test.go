@@ -0,0 +1,300 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snap
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/scroll-tech/go-ethereum/common"
+	"github.com/scroll-tech/go-ethereum/consensus/ethash"
+	"github.com/scroll-tech/go-ethereum/core"
+	"github.com/scroll-tech/go-ethereum/core/rawdb"
+	"github.com/scroll-tech/go-ethereum/core/types"
+	"github.com/scroll-tech/go-ethereum/core/vm"
+	"github.com/scroll-tech/go-ethereum/crypto"
+	"github.com/scroll-tech/go-ethereum/ethdb"
+	"github.com/scroll-tech/go-ethereum/p2p"
+	"github.com/scroll-tech/go-ethereum/p2p/enode"
+	"github.com/scroll-tech/go-ethereum/params"
+	"github.com/scroll-tech/go-ethereum/trie"
+)
+
+// zkTestBackend is a snap backend serving the state of a chain, or delivering
+// the responses to a syncer.
+type zkTestBackend struct {
+	chain  *core.BlockChain
+	syncer *Syncer
+}
+
+func (b *zkTestBackend) Chain() *core.BlockChain                   { return b.chain }
+func (b *zkTestBackend) RunPeer(peer *Peer, handler Handler) error { return handler(peer) }
+func (b *zkTestBackend) PeerInfo(id enode.ID) interface{}          { return nil }
+
+func (b *zkTestBackend) Handle(peer *Peer, packet Packet) error {
+	switch packet := packet.(type) {
+	case *AccountRangePacket:
+		hashes, accounts, err := packet.Unpack()
+		if err != nil {
+			return err
+		}
+		return b.syncer.OnAccounts(peer, packet.ID, hashes, accounts, packet.Proof)
+
+	case *StorageRangesPacket:
+		hashset, slotset := packet.Unpack()
+		return b.syncer.OnStorage(peer, packet.ID, hashset, slotset, packet.Proof)
+
+	case *ByteCodesPacket:
+		return b.syncer.OnByteCodes(peer, packet.ID, packet.Codes)
+
+	case *TrieNodesPacket:
+		return b.syncer.OnTrieNodes(peer, packet.ID, packet.Nodes)
+
+	default:
+		return fmt.Errorf("unexpected snap packet type: %T", packet)
+	}
+}
+
+// zkCappedPeer is a snap peer requesting small ranges, so that the account trie
+// and the larger storage tries are delivered in chunks which need healing.
+type zkCappedPeer struct {
+	*Peer
+}
+
+func (p *zkCappedPeer) RequestAccountRange(id uint64, root common.Hash, origin, limit common.Hash, bytes uint64) error {
+	return p.Peer.RequestAccountRange(id, root, origin, limit, 2000)
+}
+
+func (p *zkCappedPeer) RequestStorageRanges(id uint64, root common.Hash, accounts []common.Hash, origin, limit []byte, bytes uint64) error {
+	return p.Peer.RequestStorageRanges(id, root, accounts, origin, limit, 1000)
+}
+
+// zkStorageSetter is the code of the test contracts, which store the second word
+// of the calldata in the slot given by the first word.
+var zkStorageSetter = []byte{
+	byte(vm.PUSH1), 0x20, byte(vm.CALLDATALOAD),
+	byte(vm.PUSH1), 0x00, byte(vm.CALLDATALOAD),
+	byte(vm.SSTORE), byte(vm.STOP),
+}
+
+// zkTestAccount returns the address of the i-th test account, avoiding the precompiles.
+func zkTestAccount(i int) common.Address {
+	return common.BigToAddress(big.NewInt(int64(i + 0x1000)))
+}
+
+// makeZkChain creates a chain with a zktrie state of about the given number of
+// accounts. Every tenth of them is a contract with storage, the first one having
+// a large storage. The blocks following the genesis update and delete storage
+// slots, shrinking the large storage trie, and delete accounts. As SELFDESTRUCT
+// is disabled, accounts are deleted by touching empty accounts, one of which
+// holds storage.
+func makeZkChain(t *testing.T, accounts int) *core.BlockChain {
+	config := *params.TestChainConfig
+	config.Scroll.UseZktrie = true
+
+	var (
+		key, _ = crypto.GenerateKey()
+		sender = crypto.PubkeyToAddress(key.PublicKey)
+		signer = types.LatestSigner(&config)
+		alloc  = core.GenesisAlloc{sender: {Balance: big.NewInt(params.Ether)}}
+		large  = zkTestAccount(0)
+		empty  []common.Address
+	)
+	for i := 0; i < accounts; i++ {
+		addr := zkTestAccount(i)
+		account := core.GenesisAccount{Balance: big.NewInt(int64(i + 1))}
+		if i%10 == 0 {
+			slots := 20
+			if i == 0 {
+				slots = 300
+			}
+			account.Code = zkStorageSetter
+			account.Storage = make(map[common.Hash]common.Hash)
+			for j := 0; j < slots; j++ {
+				account.Storage[common.BigToHash(big.NewInt(int64(j)))] = common.BigToHash(big.NewInt(int64(i*1000 + j + 1)))
+			}
+		}
+		if i%50 == 5 {
+			// empty accounts are deleted once touched, storage included
+			account.Balance = new(big.Int)
+			if i == 5 {
+				account.Storage = map[common.Hash]common.Hash{{1}: {1}, {2}: {2}}
+			}
+			empty = append(empty, addr)
+		}
+		alloc[addr] = account
+	}
+	db := rawdb.NewMemoryDatabase()
+	genesis := (&core.Genesis{Config: &config, Alloc: alloc, GasLimit: 30_000_000}).MustCommit(db)
+
+	chain, err := core.NewBlockChain(db, nil, &config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	blocks, _ := core.GenerateChain(&config, genesis, ethash.NewFaker(), db, 3, func(n int, b *core.BlockGen) {
+		send := func(to common.Address, data []byte) {
+			tx, err := types.SignTx(types.NewTransaction(b.TxNonce(sender), to, new(big.Int), 100_000, big.NewInt(params.GWei), data), signer, key)
+			if err != nil {
+				t.Fatalf("failed to sign transaction: %v", err)
+			}
+			b.AddTx(tx)
+		}
+		store := func(contract common.Address, slot, value int64) {
+			send(contract, append(common.BigToHash(big.NewInt(slot)).Bytes(), common.BigToHash(big.NewInt(value)).Bytes()...))
+		}
+		switch n {
+		case 0:
+			// shrink the large storage trie
+			for j := int64(0); j < 280; j++ {
+				store(large, j, 0)
+			}
+		case 1:
+			// update, add and delete slots of the other contracts
+			for i := 10; i < accounts; i += 10 {
+				contract := zkTestAccount(i)
+				store(contract, 0, 0)
+				store(contract, 1, int64(i))
+				store(contract, 100, int64(i))
+			}
+		case 2:
+			// delete the empty accounts
+			for _, addr := range empty {
+				send(addr, nil)
+			}
+		}
+	})
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+
+	statedb, err := chain.State()
+	if err != nil {
+		t.Fatalf("failed to open head state: %v", err)
+	}
+	for _, addr := range empty {
+		if statedb.Exist(addr) {
+			t.Fatalf("empty account %x not deleted", addr)
+		}
+	}
+	if slot := statedb.GetState(large, common.Hash{}); slot != (common.Hash{}) {
+		t.Fatalf("storage slot of %x not deleted", large)
+	}
+	return chain
+}
+
+// verifyZkState checks that the zktrie state of the source database is entirely
+// present in the synced one, along with the contract codes and the snapshot.
+func verifyZkState(t *testing.T, src, dst ethdb.KeyValueStore, root common.Hash) {
+	t.Helper()
+
+	srcTrie, err := trie.NewZkTrie(root, trie.NewZktrieDatabase(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dstTrie, err := trie.NewZkTrie(root, trie.NewZktrieDatabase(dst))
+	if err != nil {
+		t.Fatalf("synced state missing: %v", err)
+	}
+	var (
+		accounts, slots int
+		srcIt           = trie.NewIterator(srcTrie.NodeIterator(nil))
+		dstIt           = trie.NewIterator(dstTrie.NodeIterator(nil))
+	)
+	for srcIt.Next() {
+		if !dstIt.Next() {
+			t.Fatalf("account %x missing: %v", srcIt.Key, dstIt.Err)
+		}
+		if !bytes.Equal(srcIt.Key, dstIt.Key) || !bytes.Equal(srcIt.Value, dstIt.Value) {
+			t.Fatalf("account mismatch: have %x, want %x", dstIt.Key, srcIt.Key)
+		}
+		accounts++
+
+		account, err := types.UnmarshalStateAccount(dstIt.Value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if blob := rawdb.ReadAccountSnapshot(dst, common.BytesToHash(dstIt.Key)); len(blob) == 0 {
+			t.Fatalf("account %x missing from snapshot", dstIt.Key)
+		}
+		if hash := common.BytesToHash(account.KeccakCodeHash); hash != emptyKeccakCodeHash {
+			if code := rawdb.ReadCode(dst, hash); !bytes.Equal(crypto.Keccak256(code), hash[:]) {
+				t.Fatalf("account %x: code missing", dstIt.Key)
+			}
+		}
+		storage, err := trie.NewZkTrie(account.Root, trie.NewZktrieDatabase(dst))
+		if err != nil {
+			t.Fatalf("account %x: storage trie missing: %v", dstIt.Key, err)
+		}
+		it := trie.NewIterator(storage.NodeIterator(nil))
+		for it.Next() {
+			slots++
+		}
+		if it.Err != nil {
+			t.Fatalf("account %x: storage trie incomplete: %v", dstIt.Key, it.Err)
+		}
+	}
+	if srcIt.Err != nil || dstIt.Err != nil {
+		t.Fatalf("iteration failed: %v, %v", srcIt.Err, dstIt.Err)
+	}
+	if dstIt.Next() {
+		t.Fatalf("unexpected account %x", dstIt.Key)
+	}
+	t.Logf("accounts: %d, slots: %d", accounts, slots)
+}
+
+// Tests that the zktrie state of a chain can be snap synced between two nodes,
+// with the ranges delivered in chunks that need to be healed.
+func TestZktrieSync(t *testing.T) {
+	chain := makeZkChain(t, 400)
+	defer chain.Stop()
+
+	var (
+		version  = ProtocolVersions[0]
+		app, net = p2p.MsgPipe()
+		server   = newPeer(version, p2p.NewPeer(enode.ID{1}, "client", nil), app)
+		client   = newPeer(version, p2p.NewPeer(enode.ID{2}, "server", nil), net)
+		db       = rawdb.NewMemoryDatabase()
+		syncer   = NewSyncer(db, true)
+	)
+	defer app.Close()
+	defer net.Close()
+
+	go handle(&zkTestBackend{chain: chain}, server)
+	go handle(&zkTestBackend{syncer: syncer}, client)
+
+	if err := syncer.Register(&zkCappedPeer{client}); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- syncer.Sync(chain.CurrentBlock().Root(), make(chan struct{})) }()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("sync failed: %v", err)
+		}
+	case <-time.After(time.Minute):
+		t.Fatal("sync timed out")
+	}
+	if syncer.trienodeHealSynced == 0 {
+		t.Error("chunked state not healed")
+	}
+	verifyZkState(t, chain.StateCache().TrieDB().DiskDB(), db, chain.CurrentBlock().Root())
+}
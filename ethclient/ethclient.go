@@ -385,6 +385,28 @@ func (ec *Client) GetSkippedTransaction(ctx context.Context, txHash common.Hash)
 	return tx, ec.c.CallContext(ctx, &tx, "scroll_getSkippedTransaction", txHash)
 }
 
+// GetSkippedTransactionsInRange returns the transactions skipped in the given block range (inclusive).
+// If reason is not empty, only transactions whose skip reason contains it are returned.
+func (ec *Client) GetSkippedTransactionsInRange(ctx context.Context, fromBlock uint64, toBlock uint64, reason string) ([]*eth.RPCTransaction, error) {
+	var txs []*eth.RPCTransaction
+	var reasonArg *string
+	if reason != "" {
+		reasonArg = &reason
+	}
+	return txs, ec.c.CallContext(ctx, &txs, "scroll_getSkippedTransactionsInRange", fromBlock, toBlock, reasonArg)
+}
+
+// EstimateRowConsumption estimates how many circuit rows the given call would use when executed
+// in an otherwise empty block on top of the given block. If blockNumber is nil, the latest known
+// block is used.
+func (ec *Client) EstimateRowConsumption(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) (*eth.RowConsumptionEstimate, error) {
+	var estimate eth.RowConsumptionEstimate
+	if err := ec.c.CallContext(ctx, &estimate, "scroll_estimateRowConsumption", toCallArg(msg), toBlockNumArg(blockNumber)); err != nil {
+		return nil, err
+	}
+	return &estimate, nil
+}
+
 type rpcRowConsumption struct {
 	RowConsumption types.RowConsumption `json:"rowConsumption"`
 }
@@ -19,6 +19,7 @@ package gethclient
 
 import (
 	"context"
+	"fmt"
 	"math/big"
 	"runtime"
 	"runtime/debug"
@@ -29,6 +30,7 @@ import (
 	"github.com/scroll-tech/go-ethereum/core/types"
 	"github.com/scroll-tech/go-ethereum/p2p"
 	"github.com/scroll-tech/go-ethereum/rpc"
+	"github.com/scroll-tech/go-ethereum/trie/zkproof"
 )
 
 // Client is a wrapper around rpc.Client that implements geth-specific functionality.
@@ -65,7 +67,7 @@ type AccountResult struct {
 	Balance          *big.Int        `json:"balance"`
 	KeccakCodeHash   common.Hash     `json:"keccakCodeHash"`
 	PoseidonCodeHash common.Hash     `json:"poseidonCodeHash"`
-	CodeSize         common.Hash     `json:"codeSize"`
+	CodeSize         uint64          `json:"codeSize"`
 	Nonce            uint64          `json:"nonce"`
 	StorageHash      common.Hash     `json:"storageHash"`
 	StorageProof     []StorageResult `json:"storageProof"`
@@ -92,9 +94,9 @@ func (ec *Client) GetProof(ctx context.Context, account common.Address, keys []s
 		Address          common.Address  `json:"address"`
 		AccountProof     []string        `json:"accountProof"`
 		Balance          *hexutil.Big    `json:"balance"`
-		KeccakCodeHash   common.Hash     `json:"keccakodeHash"`
+		KeccakCodeHash   common.Hash     `json:"keccakCodeHash"`
 		PoseidonCodeHash common.Hash     `json:"poseidonCodeHash"`
-		CodeSize         common.Hash     `json:"codeSize"`
+		CodeSize         hexutil.Uint64  `json:"codeSize"`
 		Nonce            hexutil.Uint64  `json:"nonce"`
 		StorageHash      common.Hash     `json:"storageHash"`
 		StorageProof     []storageResult `json:"storageProof"`
@@ -118,12 +120,58 @@ func (ec *Client) GetProof(ctx context.Context, account common.Address, keys []s
 		Nonce:            uint64(res.Nonce),
 		KeccakCodeHash:   res.KeccakCodeHash,
 		PoseidonCodeHash: res.PoseidonCodeHash,
-		CodeSize:         res.CodeSize,
+		CodeSize:         uint64(res.CodeSize),
 		StorageHash:      res.StorageHash,
+		StorageProof:     storageResults,
 	}
 	return &result, err
 }
 
+// VerifyProof checks the zktrie proofs of an account result returned by GetProof
+// against the given state root, and that the proven account and storage values
+// match the ones in the result.
+func VerifyProof(root common.Hash, result *AccountResult) error {
+	accountProof, err := decodeProof(result.AccountProof)
+	if err != nil {
+		return fmt.Errorf("invalid account proof: %v", err)
+	}
+	claim := &zkproof.AccountClaim{
+		Address:          result.Address,
+		AccountProof:     accountProof,
+		Balance:          result.Balance,
+		Nonce:            result.Nonce,
+		CodeSize:         result.CodeSize,
+		KeccakCodeHash:   result.KeccakCodeHash,
+		PoseidonCodeHash: result.PoseidonCodeHash,
+		StorageHash:      result.StorageHash,
+	}
+	for _, slot := range result.StorageProof {
+		proof, err := decodeProof(slot.Proof)
+		if err != nil {
+			return fmt.Errorf("invalid storage proof of %s: %v", slot.Key, err)
+		}
+		claim.StorageProof = append(claim.StorageProof, zkproof.StorageClaim{
+			Key:   common.HexToHash(slot.Key),
+			Value: slot.Value,
+			Proof: proof,
+		})
+	}
+	return zkproof.VerifyAccountClaim(root, claim)
+}
+
+// decodeProof decodes the hex encoded nodes of a proof.
+func decodeProof(proof []string) ([][]byte, error) {
+	nodes := make([][]byte, len(proof))
+	for i, node := range proof {
+		blob, err := hexutil.Decode(node)
+		if err != nil {
+			return nil, err
+		}
+		nodes[i] = blob
+	}
+	return nodes, nil
+}
+
 // OverrideAccount specifies the state of an account to be overridden.
 type OverrideAccount struct {
 	Nonce     uint64                      `json:"nonce"`
@@ -24,11 +24,14 @@ import (
 
 	"github.com/scroll-tech/go-ethereum"
 	"github.com/scroll-tech/go-ethereum/common"
+	"github.com/scroll-tech/go-ethereum/common/hexutil"
 	"github.com/scroll-tech/go-ethereum/consensus/ethash"
 	"github.com/scroll-tech/go-ethereum/core"
 	"github.com/scroll-tech/go-ethereum/core/rawdb"
+	"github.com/scroll-tech/go-ethereum/core/state"
 	"github.com/scroll-tech/go-ethereum/core/types"
 	"github.com/scroll-tech/go-ethereum/crypto"
+	"github.com/scroll-tech/go-ethereum/crypto/codehash"
 	"github.com/scroll-tech/go-ethereum/eth"
 	"github.com/scroll-tech/go-ethereum/eth/ethconfig"
 	"github.com/scroll-tech/go-ethereum/ethclient"
@@ -36,6 +39,7 @@ import (
 	"github.com/scroll-tech/go-ethereum/params"
 	"github.com/scroll-tech/go-ethereum/rollup/rcfg"
 	"github.com/scroll-tech/go-ethereum/rpc"
+	"github.com/scroll-tech/go-ethereum/trie"
 )
 
 var (
@@ -343,3 +347,93 @@ func testCallContractNoGas(t *testing.T, client *rpc.Client) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
+
+// Tests that the proofs of a zktrie state are verified against the state root,
+// and that results not matching the proven values are rejected.
+func TestVerifyProof(t *testing.T) {
+	var (
+		db       = state.NewDatabaseWithConfig(rawdb.NewMemoryDatabase(), &trie.Config{Zktrie: true})
+		contract = common.Address{0xc0}
+		slot     = common.Hash{0x01}
+	)
+	statedb, _ := state.New(common.Hash{}, db, nil)
+	statedb.SetBalance(testAddr, testBalance)
+	statedb.SetNonce(testAddr, 3)
+	statedb.SetCode(contract, []byte{0x60, 0x00})
+	statedb.SetState(contract, slot, common.Hash{0xff})
+	root, err := statedb.Commit(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statedb, err = state.New(root, db, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// proofResult assembles the result of GetProof for an account.
+	proofResult := func(addr common.Address, keys ...common.Hash) *AccountResult {
+		proof, err := statedb.GetProof(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result := &AccountResult{
+			Address:          addr,
+			AccountProof:     toHexProof(proof),
+			Balance:          statedb.GetBalance(addr),
+			KeccakCodeHash:   statedb.GetKeccakCodeHash(addr),
+			PoseidonCodeHash: statedb.GetPoseidonCodeHash(addr),
+			CodeSize:         uint64(statedb.GetCodeSize(addr)),
+			Nonce:            statedb.GetNonce(addr),
+		}
+		if storageTrie := statedb.StorageTrie(addr); storageTrie != nil {
+			result.StorageHash = storageTrie.Hash()
+		} else {
+			result.KeccakCodeHash, result.PoseidonCodeHash = codehash.EmptyKeccakCodeHash, codehash.EmptyPoseidonCodeHash
+		}
+		for _, key := range keys {
+			proof, err := statedb.GetStorageProof(addr, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result.StorageProof = append(result.StorageProof, StorageResult{
+				Key:   key.Hex(),
+				Value: statedb.GetState(addr, key).Big(),
+				Proof: toHexProof(proof),
+			})
+		}
+		return result
+	}
+	if err := VerifyProof(root, proofResult(testAddr)); err != nil {
+		t.Fatalf("failed to verify account: %v", err)
+	}
+	if err := VerifyProof(root, proofResult(contract, slot, common.Hash{0x02})); err != nil {
+		t.Fatalf("failed to verify contract: %v", err)
+	}
+	if err := VerifyProof(root, proofResult(emptyAddr)); err != nil {
+		t.Fatalf("failed to verify missing account: %v", err)
+	}
+
+	// Results differing from the proven values
+	bad := proofResult(testAddr)
+	bad.Balance = new(big.Int).Add(testBalance, common.Big1)
+	if err := VerifyProof(root, bad); err == nil {
+		t.Fatal("wrong balance verified")
+	}
+	bad = proofResult(contract, slot)
+	bad.StorageProof[0].Value = common.Big1
+	if err := VerifyProof(root, bad); err == nil {
+		t.Fatal("wrong storage value verified")
+	}
+	bad = proofResult(testAddr)
+	bad.AccountProof = bad.AccountProof[1:]
+	if err := VerifyProof(root, bad); err == nil {
+		t.Fatal("incomplete proof verified")
+	}
+}
+
+func toHexProof(proof [][]byte) []string {
+	nodes := make([]string, len(proof))
+	for i, node := range proof {
+		nodes[i] = hexutil.Encode(node)
+	}
+	return nodes
+}
@@ -38,6 +38,7 @@ import (
 	"github.com/scroll-tech/go-ethereum/consensus/ethash"
 	"github.com/scroll-tech/go-ethereum/consensus/misc"
 	"github.com/scroll-tech/go-ethereum/core"
+	"github.com/scroll-tech/go-ethereum/core/rawdb"
 	"github.com/scroll-tech/go-ethereum/core/state"
 	"github.com/scroll-tech/go-ethereum/core/types"
 	"github.com/scroll-tech/go-ethereum/core/vm"
@@ -49,6 +50,16 @@ import (
 	"github.com/scroll-tech/go-ethereum/rlp"
 	"github.com/scroll-tech/go-ethereum/rollup/fees"
 	"github.com/scroll-tech/go-ethereum/rpc"
+	"github.com/scroll-tech/go-ethereum/trie/zkproof"
+)
+
+// L1 finalization statuses of an L2 block, as reported in transaction receipts.
+const (
+	// BatchStatusCommitted means the block is part of a batch committed on L1.
+	BatchStatusCommitted = "committed"
+
+	// BatchStatusFinalized means the block is part of a batch finalized on L1.
+	BatchStatusFinalized = "finalized"
 )
 
 // PublicEthereumAPI provides an API to access Ethereum related information.
@@ -657,34 +668,61 @@ func (s *PublicBlockChainAPI) GetBalance(ctx context.Context, address common.Add
 	return (*hexutil.Big)(state.GetBalance(address)), state.Error()
 }
 
+// Proof formats of GetProof on zktrie chains.
+const (
+	// ProofFormatStandard returns the proofs as lists of raw zktrie node bytes.
+	ProofFormatStandard = "standard"
+
+	// ProofFormatNative returns the proofs as structured SMT paths.
+	ProofFormatNative = "native"
+)
+
 // Result structs for GetProof
 type AccountResult struct {
-	Address          common.Address  `json:"address"`
-	AccountProof     []string        `json:"accountProof"`
-	Balance          *hexutil.Big    `json:"balance"`
-	PoseidonCodeHash common.Hash     `json:"poseidonCodeHash"`
-	KeccakCodeHash   common.Hash     `json:"keccakCodeHash"`
-	CodeSize         hexutil.Uint64  `json:"codeSize"`
-	Nonce            hexutil.Uint64  `json:"nonce"`
-	StorageHash      common.Hash     `json:"storageHash"`
-	StorageProof     []StorageResult `json:"storageProof"`
+	Address          common.Address   `json:"address"`
+	AccountProof     []string         `json:"accountProof"`
+	AccountPath      *zkproof.SMTPath `json:"accountPath,omitempty"`
+	Balance          *hexutil.Big     `json:"balance"`
+	PoseidonCodeHash common.Hash      `json:"poseidonCodeHash"`
+	KeccakCodeHash   common.Hash      `json:"keccakCodeHash"`
+	CodeSize         hexutil.Uint64   `json:"codeSize"`
+	Nonce            hexutil.Uint64   `json:"nonce"`
+	StorageHash      common.Hash      `json:"storageHash"`
+	StorageProof     []StorageResult  `json:"storageProof"`
 }
 
 type StorageResult struct {
-	Key   string       `json:"key"`
-	Value *hexutil.Big `json:"value"`
-	Proof []string     `json:"proof"`
+	Key   string           `json:"key"`
+	Value *hexutil.Big     `json:"value"`
+	Proof []string         `json:"proof"`
+	Path  *zkproof.SMTPath `json:"path,omitempty"`
 }
 
 // GetProof returns the Merkle-proof for a given account and optionally some storage keys.
-func (s *PublicBlockChainAPI) GetProof(ctx context.Context, address common.Address, storageKeys []string, blockNrOrHash rpc.BlockNumberOrHash) (*AccountResult, error) {
+// On zktrie chains the proofs are returned in the requested format, which defaults to
+// the raw node bytes.
+func (s *PublicBlockChainAPI) GetProof(ctx context.Context, address common.Address, storageKeys []string, blockNrOrHash rpc.BlockNumberOrHash, format *string) (*AccountResult, error) {
+	zktrie := s.b.ChainConfig().Scroll.ZktrieEnabled()
+
+	native := false
+	if format != nil {
+		switch *format {
+		case ProofFormatStandard:
+		case ProofFormatNative:
+			if !zktrie {
+				return nil, errors.New("native proof format is only supported on zktrie chains")
+			}
+			native = true
+		default:
+			return nil, fmt.Errorf("unknown proof format %q", *format)
+		}
+	}
+
 	state, _, err := s.b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
 	if state == nil || err != nil {
 		return nil, err
 	}
 
-	zktrie := s.b.ChainConfig().Scroll.ZktrieEnabled()
-
 	storageTrie := state.StorageTrie(address)
 	var storageHash common.Hash
 	if !zktrie {
@@ -710,9 +748,17 @@ func (s *PublicBlockChainAPI) GetProof(ctx context.Context, address common.Addre
 			if storageError != nil {
 				return nil, storageError
 			}
-			storageProof[i] = StorageResult{key, (*hexutil.Big)(state.GetState(address, common.HexToHash(key)).Big()), toHexSlice(proof)}
+			storageProof[i] = StorageResult{Key: key, Value: (*hexutil.Big)(state.GetState(address, common.HexToHash(key)).Big())}
+			if native {
+				if storageProof[i].Path, err = zkproof.NewSMTPath(proof); err != nil {
+					return nil, err
+				}
+				storageProof[i].Proof = []string{}
+			} else {
+				storageProof[i].Proof = toHexSlice(proof)
+			}
 		} else {
-			storageProof[i] = StorageResult{key, &hexutil.Big{}, []string{}}
+			storageProof[i] = StorageResult{Key: key, Value: &hexutil.Big{}, Proof: []string{}}
 		}
 	}
 
@@ -722,7 +768,7 @@ func (s *PublicBlockChainAPI) GetProof(ctx context.Context, address common.Addre
 		return nil, proofErr
 	}
 
-	return &AccountResult{
+	result := &AccountResult{
 		Address:          address,
 		AccountProof:     toHexSlice(accountProof),
 		Balance:          (*hexutil.Big)(state.GetBalance(address)),
@@ -732,7 +778,45 @@ func (s *PublicBlockChainAPI) GetProof(ctx context.Context, address common.Addre
 		Nonce:            hexutil.Uint64(state.GetNonce(address)),
 		StorageHash:      storageHash,
 		StorageProof:     storageProof,
-	}, state.Error()
+	}
+	if native {
+		if result.AccountPath, err = zkproof.NewSMTPath(accountProof); err != nil {
+			return nil, err
+		}
+		result.AccountProof = []string{}
+	}
+	return result, state.Error()
+}
+
+// VerifyZktrieProof checks the zktrie proofs of an account result, as returned by
+// GetProof in the standard format, against the given state root.
+func VerifyZktrieProof(root common.Hash, result *AccountResult) error {
+	accountProof, err := fromHexSlice(result.AccountProof)
+	if err != nil {
+		return fmt.Errorf("invalid account proof: %v", err)
+	}
+	claim := &zkproof.AccountClaim{
+		Address:          result.Address,
+		AccountProof:     accountProof,
+		Balance:          result.Balance.ToInt(),
+		Nonce:            uint64(result.Nonce),
+		CodeSize:         uint64(result.CodeSize),
+		KeccakCodeHash:   result.KeccakCodeHash,
+		PoseidonCodeHash: result.PoseidonCodeHash,
+		StorageHash:      result.StorageHash,
+	}
+	for _, slot := range result.StorageProof {
+		proof, err := fromHexSlice(slot.Proof)
+		if err != nil {
+			return fmt.Errorf("invalid storage proof of %s: %v", slot.Key, err)
+		}
+		claim.StorageProof = append(claim.StorageProof, zkproof.StorageClaim{
+			Key:   common.HexToHash(slot.Key),
+			Value: slot.Value.ToInt(),
+			Proof: proof,
+		})
+	}
+	return zkproof.VerifyAccountClaim(root, claim)
 }
 
 // GetHeaderByNumber returns the requested canonical block header.
@@ -1754,6 +1838,14 @@ func marshalReceipt(ctx context.Context, b Backend, receipt *types.Receipt, bigb
 	if receipt.ContractAddress != (common.Address{}) {
 		fields["contractAddress"] = receipt.ContractAddress
 	}
+	// Assign the rollup batch that includes the block, if it has been committed on L1.
+	if batchIndex := rawdb.ReadBatchIndexByL2BlockNumber(b.ChainDb(), blockNumber); batchIndex != nil {
+		fields["batchIndex"] = hexutil.Uint64(*batchIndex)
+		fields["l1FinalizationStatus"] = BatchStatusCommitted
+		if finalized := rawdb.ReadFinalizedL2BlockNumber(b.ChainDb()); finalized != nil && blockNumber <= *finalized {
+			fields["l1FinalizationStatus"] = BatchStatusFinalized
+		}
+	}
 	return fields, nil
 }
 
@@ -2183,3 +2275,16 @@ func toHexSlice(b [][]byte) []string {
 	}
 	return r
 }
+
+// fromHexSlice decodes a slice of hex strings, as created by toHexSlice.
+func fromHexSlice(s []string) ([][]byte, error) {
+	r := make([][]byte, len(s))
+	for i := range s {
+		b, err := hexutil.Decode(s[i])
+		if err != nil {
+			return nil, err
+		}
+		r[i] = b
+	}
+	return r, nil
+}
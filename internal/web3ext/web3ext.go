@@ -200,6 +200,11 @@ web3._extend({
 			call: 'admin_setL1MessageSyncedL1Height',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'readmitSkippedTransactions',
+			call: 'admin_readmitSkippedTransactions',
+			params: 2
+		}),
 	],
 	properties: [
 		new web3._extend.Property({
@@ -640,6 +645,10 @@ web3._extend({
 			name: 'stop',
 			call: 'miner_stop'
 		}),
+		new web3._extend.Method({
+			name: 'clearHalt',
+			call: 'miner_clearHalt'
+		}),
 		new web3._extend.Method({
 			name: 'setEtherbase',
 			call: 'miner_setEtherbase',
@@ -929,6 +938,12 @@ web3._extend({
 			call: 'scroll_getSkippedTransactionHashes',
 			params: 2
 		}),
+		new web3._extend.Method({
+			name: 'getSkippedTransactionsInRange',
+			call: 'scroll_getSkippedTransactionsInRange',
+			params: 3,
+			inputFormatter: [null, null, null]
+		}),
 		new web3._extend.Method({
 			name: 'estimateL1DataFee',
 			call: 'scroll_estimateL1DataFee',
@@ -936,12 +951,44 @@ web3._extend({
 			inputFormatter: [web3._extend.formatters.inputCallFormatter, web3._extend.formatters.inputBlockNumberFormatter],
 			outputFormatter: web3._extend.utils.toDecimal
 		}),
+		new web3._extend.Method({
+			name: 'verifyProof',
+			call: 'scroll_verifyProof',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'estimateRowConsumption',
+			call: 'scroll_estimateRowConsumption',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputCallFormatter, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
 		new web3._extend.Method({
 			name: 'calculateRowConsumptionByBlockNumber',
 			call: 'scroll_calculateRowConsumptionByBlockNumber',
 			params: 1,
 			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter]
 		}),
+		new web3._extend.Method({
+			name: 'getBatchValidationFailures',
+			call: 'scroll_getBatchValidationFailures',
+			params: 1,
+			inputFormatter: [null]
+		}),
+		new web3._extend.Method({
+			name: 'getBatchByIndex',
+			call: 'scroll_getBatchByIndex',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'getBatchByL2BlockNumber',
+			call: 'scroll_getBatchByL2BlockNumber',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'getFinalizedBatchRange',
+			call: 'scroll_getFinalizedBatchRange',
+			params: 2
+		}),
 	],
 	properties:
 	[
@@ -953,6 +1000,10 @@ web3._extend({
 			name: 'latestRelayedQueueIndex',
 			getter: 'scroll_getLatestRelayedQueueIndex'
 		}),
+		new web3._extend.Property({
+			name: 'l1MessageQueueStats',
+			getter: 'scroll_getL1MessageQueueStats'
+		}),
 		new web3._extend.Property({
 			name: 'numSkippedTransactions',
 			getter: 'scroll_getNumSkippedTransactions'
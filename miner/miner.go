@@ -60,6 +60,16 @@ type Config struct {
 	StoreSkippedTxTraces bool // Whether store the wrapped traces when storing a skipped tx
 	MaxAccountsNum       int  // Maximum number of accounts that miner will fetch the pending transactions of when building a new block
 	CCCMaxWorkers        int  // Maximum number of workers to use for async CCC tasks
+
+	CCCMaxOverflowRetries int    // Maximum number of transactions to try after a circuit capacity overflow before sealing the block
+	MaxReorgDepth         uint64 // Maximum number of blocks a reorg may replace after a block failed CCC, also the number of blocks sealed ahead of CCC (0 = CCCMaxWorkers + 1)
+
+	L1MessageMaxAge         time.Duration // Age after which pending L1 messages are included with a raised per-block quota (0 = disabled)
+	L1MessageForcedPerBlock uint64        // Per-block L1 message quota while L1 messages are overdue (0 = twice the chain's NumL1MessagesPerBlock)
+
+	Ordering                  string           // Transaction ordering policy: "price" (default), "fifo", "fair" or "priority"
+	OrderingMaxTxsPerSender   int              // Maximum number of transactions per sender and block of the "fair" ordering policy
+	OrderingPriorityAddresses []common.Address // Senders of transactions to these addresses are processed first by the "priority" ordering policy
 }
 
 // Miner creates blocks and searches for proof-of-work values.
@@ -174,6 +184,17 @@ func (miner *Miner) Mining() bool {
 	return miner.worker.isRunning()
 }
 
+// Halted returns whether block production was halted because a block failing the circuit
+// capacity check could not be reorged.
+func (miner *Miner) Halted() bool {
+	return miner.worker.isHalted()
+}
+
+// ClearHalt allows block production to be started again after it was halted.
+func (miner *Miner) ClearHalt() {
+	miner.worker.clearHalt()
+}
+
 func (miner *Miner) Hashrate() uint64 {
 	if pow, ok := miner.engine.(consensus.PoW); ok {
 		return uint64(pow.Hashrate())
@@ -236,6 +257,24 @@ func (miner *Miner) DisablePreseal() {
 	miner.worker.disablePreseal()
 }
 
+// SubscribeSequencerReorgEvent starts delivering an event whenever the worker replaces
+// blocks that failed the circuit capacity check.
+func (miner *Miner) SubscribeSequencerReorgEvent(ch chan<- core.SequencerReorgEvent) event.Subscription {
+	return miner.worker.reorgFeed.Subscribe(ch)
+}
+
+// SubscribePreconfirmationEvent starts delivering an event whenever the worker adds a
+// transaction to the block it is building, or retracts an earlier preconfirmation.
+// Events are delivered asynchronously and dropped if subscribers do not keep up.
+func (miner *Miner) SubscribePreconfirmationEvent(ch chan<- core.PreconfirmationEvent) event.Subscription {
+	return miner.worker.preconfFeed.Subscribe(ch)
+}
+
+// L1MessageQueueStats returns statistics about the L1 messages waiting to be included in a block.
+func (miner *Miner) L1MessageQueueStats() *L1MessageQueueStats {
+	return miner.worker.l1MessageQueueStats()
+}
+
 // SubscribePendingLogs starts delivering logs from pending transactions
 // to the given channel.
 func (miner *Miner) SubscribePendingLogs(ch chan<- []*types.Log) event.Subscription {
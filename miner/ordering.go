@@ -0,0 +1,256 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"container/heap"
+	"fmt"
+	"math/big"
+
+	"github.com/scroll-tech/go-ethereum/common"
+	"github.com/scroll-tech/go-ethereum/core/types"
+)
+
+const (
+	// OrderingPrice orders transactions by effective tip, the default geth ordering.
+	OrderingPrice = "price"
+	// OrderingFIFO orders transactions by the time they were first seen by the node.
+	OrderingFIFO = "fifo"
+	// OrderingFair orders transactions by price but caps the number of transactions per sender and block.
+	OrderingFair = "fair"
+	// OrderingPriority orders senders of transactions to priority addresses first, then all others by price.
+	OrderingPriority = "priority"
+
+	// DefaultMaxTxsPerSender is the default per-sender cap of the "fair" ordering policy.
+	DefaultMaxTxsPerSender = 4
+)
+
+// OrderingPolicy decides in which order the worker tries to include pending L2 transactions.
+// L1 messages are always processed first and are not subject to the ordering policy. The worker
+// consults the policy for every set of L2 transactions it processes: a transaction prioritized
+// after a circuit capacity overflow, local and remote pending transactions, transactions that
+// arrive while the block is built, and the transactions of a block that is rebuilt after a reorg.
+type OrderingPolicy interface {
+	// Order returns an ordered set of the given transactions. txs maps each sender to its
+	// nonce-sorted pending transactions, and the returned set must preserve the nonce order
+	// of each sender. The policy takes ownership of the txs map.
+	Order(signer types.Signer, txs map[common.Address]types.Transactions, baseFee *big.Int) types.OrderedTransactionSet
+}
+
+// NewOrderingPolicy creates the ordering policy selected in the miner config.
+func NewOrderingPolicy(config *Config) (OrderingPolicy, error) {
+	switch config.Ordering {
+	case "", OrderingPrice:
+		return priceOrdering{}, nil
+	case OrderingFIFO:
+		return fifoOrdering{}, nil
+	case OrderingFair:
+		maxTxsPerSender := config.OrderingMaxTxsPerSender
+		if maxTxsPerSender <= 0 {
+			maxTxsPerSender = DefaultMaxTxsPerSender
+		}
+		return fairOrdering{maxTxsPerSender: maxTxsPerSender}, nil
+	case OrderingPriority:
+		if len(config.OrderingPriorityAddresses) == 0 {
+			return nil, fmt.Errorf("ordering policy %q requires at least one priority address", OrderingPriority)
+		}
+		addresses := make(map[common.Address]struct{}, len(config.OrderingPriorityAddresses))
+		for _, addr := range config.OrderingPriorityAddresses {
+			addresses[addr] = struct{}{}
+		}
+		return priorityOrdering{addresses: addresses}, nil
+	default:
+		return nil, fmt.Errorf("unknown ordering policy %q, supported: %s, %s, %s, %s", config.Ordering, OrderingPrice, OrderingFIFO, OrderingFair, OrderingPriority)
+	}
+}
+
+// priceOrdering orders transactions by effective tip.
+type priceOrdering struct{}
+
+func (priceOrdering) Order(signer types.Signer, txs map[common.Address]types.Transactions, baseFee *big.Int) types.OrderedTransactionSet {
+	return types.NewTransactionsByPriceAndNonce(signer, txs, baseFee)
+}
+
+// fifoOrdering orders transactions by arrival time.
+type fifoOrdering struct{}
+
+func (fifoOrdering) Order(signer types.Signer, txs map[common.Address]types.Transactions, baseFee *big.Int) types.OrderedTransactionSet {
+	return newTransactionsByTimeAndNonce(signer, txs, baseFee)
+}
+
+// senderLimiter is implemented by ordering policies that cap the number of L2 transactions per
+// sender and block. The cap applies to the whole block, the worker enforces it across all the
+// transaction sets it processes.
+type senderLimiter interface {
+	txsPerSenderLimit() int
+}
+
+// fairOrdering orders transactions by effective tip, but includes at most maxTxsPerSender
+// transactions of each sender so that a single busy sender cannot fill the whole block.
+type fairOrdering struct {
+	maxTxsPerSender int
+}
+
+func (o fairOrdering) txsPerSenderLimit() int {
+	return o.maxTxsPerSender
+}
+
+func (o fairOrdering) Order(signer types.Signer, txs map[common.Address]types.Transactions, baseFee *big.Int) types.OrderedTransactionSet {
+	// a single set never needs more transactions than the cap, the worker
+	// enforces the cap for the whole block
+	for from, accTxs := range txs {
+		if len(accTxs) > o.maxTxsPerSender {
+			txs[from] = accTxs[:o.maxTxsPerSender]
+		}
+	}
+	return types.NewTransactionsByPriceAndNonce(signer, txs, baseFee)
+}
+
+// priorityOrdering processes senders that have pending transactions to one of the priority
+// addresses first, then all other senders. Both groups are ordered by effective tip.
+type priorityOrdering struct {
+	addresses map[common.Address]struct{}
+}
+
+func (o priorityOrdering) Order(signer types.Signer, txs map[common.Address]types.Transactions, baseFee *big.Int) types.OrderedTransactionSet {
+	prioritized := make(map[common.Address]types.Transactions)
+	for from, accTxs := range txs {
+		for _, tx := range accTxs {
+			if tx.To() == nil {
+				continue
+			}
+			if _, ok := o.addresses[*tx.To()]; ok {
+				prioritized[from] = accTxs
+				delete(txs, from)
+				break
+			}
+		}
+	}
+	return orderedTransactionSets{
+		types.NewTransactionsByPriceAndNonce(signer, prioritized, baseFee),
+		types.NewTransactionsByPriceAndNonce(signer, txs, baseFee),
+	}
+}
+
+// orderedTransactionSets processes several transaction sets one after another.
+type orderedTransactionSets []types.OrderedTransactionSet
+
+func (s orderedTransactionSets) current() types.OrderedTransactionSet {
+	for _, set := range s {
+		if set.Peek() != nil {
+			return set
+		}
+	}
+	return nil
+}
+
+func (s orderedTransactionSets) Peek() *types.Transaction {
+	if set := s.current(); set != nil {
+		return set.Peek()
+	}
+	return nil
+}
+
+func (s orderedTransactionSets) Shift() {
+	if set := s.current(); set != nil {
+		set.Shift()
+	}
+}
+
+func (s orderedTransactionSets) Pop() {
+	if set := s.current(); set != nil {
+		set.Pop()
+	}
+}
+
+// txByTime implements heap.Interface, ordering transactions by arrival time.
+type txByTime types.Transactions
+
+func (s txByTime) Len() int { return len(s) }
+func (s txByTime) Less(i, j int) bool {
+	return s[i].Time().Before(s[j].Time())
+}
+func (s txByTime) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+
+func (s *txByTime) Push(x interface{}) {
+	*s = append(*s, x.(*types.Transaction))
+}
+
+func (s *txByTime) Pop() interface{} {
+	old := *s
+	n := len(old)
+	x := old[n-1]
+	*s = old[0 : n-1]
+	return x
+}
+
+// transactionsByTimeAndNonce returns transactions in the order they arrived at the node,
+// while honouring the nonce order of each sender.
+type transactionsByTimeAndNonce struct {
+	txs     map[common.Address]types.Transactions // Per account nonce-sorted list of transactions
+	heads   txByTime                              // Next transaction for each unique account (time heap)
+	signer  types.Signer                          // Signer for the set of transactions
+	baseFee *big.Int                              // Current base fee
+}
+
+func newTransactionsByTimeAndNonce(signer types.Signer, txs map[common.Address]types.Transactions, baseFee *big.Int) *transactionsByTimeAndNonce {
+	heads := make(txByTime, 0, len(txs))
+	for from, accTxs := range txs {
+		acc, _ := types.Sender(signer, accTxs[0])
+		// Remove transaction if sender doesn't match from, or if it cannot pay the base fee.
+		if _, err := accTxs[0].EffectiveGasTip(baseFee); acc != from || err != nil {
+			delete(txs, from)
+			continue
+		}
+		heads = append(heads, accTxs[0])
+		txs[from] = accTxs[1:]
+	}
+	heap.Init(&heads)
+
+	return &transactionsByTimeAndNonce{
+		txs:     txs,
+		heads:   heads,
+		signer:  signer,
+		baseFee: baseFee,
+	}
+}
+
+// Peek returns the transaction that arrived first.
+func (t *transactionsByTimeAndNonce) Peek() *types.Transaction {
+	if len(t.heads) == 0 {
+		return nil
+	}
+	return t.heads[0]
+}
+
+// Shift replaces the current head with the next one from the same account.
+func (t *transactionsByTimeAndNonce) Shift() {
+	acc, _ := types.Sender(t.signer, t.heads[0])
+	if txs, ok := t.txs[acc]; ok && len(txs) > 0 {
+		if _, err := txs[0].EffectiveGasTip(t.baseFee); err == nil {
+			t.heads[0], t.txs[acc] = txs[0], txs[1:]
+			heap.Fix(&t.heads, 0)
+			return
+		}
+	}
+	heap.Pop(&t.heads)
+}
+
+// Pop removes the current head, *not* replacing it with the next one from the same account.
+func (t *transactionsByTimeAndNonce) Pop() {
+	heap.Pop(&t.heads)
+}
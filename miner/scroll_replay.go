@@ -0,0 +1,349 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sync/atomic"
+	"time"
+
+	"github.com/scroll-tech/go-ethereum/common"
+	"github.com/scroll-tech/go-ethereum/common/hexutil"
+	"github.com/scroll-tech/go-ethereum/consensus"
+	"github.com/scroll-tech/go-ethereum/core"
+	"github.com/scroll-tech/go-ethereum/core/rawdb"
+	"github.com/scroll-tech/go-ethereum/core/types"
+	"github.com/scroll-tech/go-ethereum/crypto"
+	"github.com/scroll-tech/go-ethereum/ethdb"
+	"github.com/scroll-tech/go-ethereum/event"
+	"github.com/scroll-tech/go-ethereum/log"
+	"github.com/scroll-tech/go-ethereum/rollup/sync_service"
+)
+
+// ReplayStep is an event observed by the sequencer, as recorded for a replay.
+type ReplayStep struct {
+	Time       uint64          `json:"time"`                 // Unix time at which the event happened
+	Txs        []hexutil.Bytes `json:"txs,omitempty"`        // Binary encoded L2 transactions received by the txpool
+	L1Messages []hexutil.Bytes `json:"l1Messages,omitempty"` // Binary encoded L1 messages synced from L1
+	Seal       bool            `json:"seal,omitempty"`       // Whether the deadline of the pending block was reached
+}
+
+// ReplayResult is the outcome of a replay.
+type ReplayResult struct {
+	Blocks         []*types.Block                // Canonical blocks built on top of the parent
+	RowConsumption []*types.RowConsumption       // Row consumption of each block, nil if the block was not checked
+	Skipped        []*rawdb.SkippedTransactionV2 // Transactions skipped while building blocks
+	Reorgs         []core.SequencerReorgEvent    // Reorgs caused by blocks failing the circuit capacity check
+}
+
+// Replay deterministically rebuilds sequencer blocks on top of the current head of the chain from
+// a recording of the transactions and L1 messages that the sequencer received. The clock is taken
+// from the recording, and the circuit capacity check of a block completes once cccDelay more blocks
+// were built, the way asynchronous checks lag behind block building on a live sequencer.
+//
+// If the recording contains L1 messages, the L1 messages that are not included in the head block
+// yet are removed from the database first. The pending block at the end of the recording is discarded.
+//
+// Replay writes the blocks it builds to the database of the chain, it is meant to be run on a chain
+// backed by rawdb.NewOverlayDatabase to leave the sequencer's database untouched.
+func Replay(chain *core.BlockChain, config *Config, steps []ReplayStep, cccDelay int) (*ReplayResult, error) {
+	db := chain.Database()
+	parent := chain.CurrentBlock()
+	for _, step := range steps {
+		if len(step.L1Messages) > 0 {
+			discardPendingL1Messages(db, parent)
+			break
+		}
+	}
+
+	txPool := core.NewTxPool(replayTxPoolConfig, chain.Config(), chain)
+	defer txPool.Stop()
+
+	r := &replayer{cccDelay: cccDelay}
+	engine := &replayEngine{Engine: chain.Engine(), now: func() time.Time { return r.clock }}
+	r.w = newIdleWorker(config, chain.Config(), engine, &replayBackend{chain: chain, txPool: txPool}, new(event.TypeMux), nil)
+	r.w.setEtherbase(config.Etherbase)
+	r.w.now = func() time.Time { return r.clock }
+	r.w.checkCCC = func(block *types.Block) error {
+		r.unchecked = append(r.unchecked, block)
+		return nil
+	}
+	atomic.StoreInt32(&r.w.running, 1)
+
+	reorgCh := make(chan core.SequencerReorgEvent, 1)
+	reorgSub := r.w.reorgFeed.Subscribe(reorgCh)
+	defer reorgSub.Unsubscribe()
+	r.reorgCh = reorgCh
+
+	numSkipped := rawdb.ReadNumSkippedTransactions(db)
+	for i, step := range steps {
+		r.clock = time.Unix(int64(step.Time), 0)
+		if err := r.apply(step); err != nil {
+			return nil, fmt.Errorf("step %d: %w", i, err)
+		}
+	}
+	// the checks of all built blocks complete eventually
+	if err := r.check(0); err != nil {
+		return nil, err
+	}
+
+	head := chain.CurrentBlock()
+	for number := parent.NumberU64() + 1; number <= head.NumberU64(); number++ {
+		block := chain.GetBlockByNumber(number)
+		r.result.Blocks = append(r.result.Blocks, block)
+		r.result.RowConsumption = append(r.result.RowConsumption, rawdb.ReadBlockRowConsumption(db, block.Hash()))
+	}
+	for index := numSkipped; index < rawdb.ReadNumSkippedTransactions(db); index++ {
+		if txHash := rawdb.ReadSkippedTransactionHash(db, index); txHash != nil {
+			r.result.Skipped = append(r.result.Skipped, rawdb.ReadSkippedTransaction(db, *txHash))
+		}
+	}
+	return &r.result, nil
+}
+
+// replayTxPoolConfig is the txpool configuration of replays, transactions are not journaled.
+var replayTxPoolConfig = func() core.TxPoolConfig {
+	config := core.DefaultTxPoolConfig
+	config.Journal = ""
+	return config
+}()
+
+// discardPendingL1Messages removes the L1 messages that are not included in the chain up to parent.
+func discardPendingL1Messages(db ethdb.Database, parent *types.Block) {
+	var next uint64
+	if index := rawdb.ReadFirstQueueIndexNotInL2Block(db, parent.Hash()); index != nil {
+		next = *index
+	}
+	highest := rawdb.ReadHighestSyncedQueueIndex(db)
+
+	batch := db.NewBatch()
+	for index := next; index <= highest; index++ {
+		rawdb.DeleteL1Message(batch, index)
+		rawdb.DeleteL1MessageSyncTime(batch, index)
+	}
+	if next > 0 {
+		rawdb.WriteHighestSyncedQueueIndex(batch, next-1)
+	} else {
+		rawdb.WriteHighestSyncedQueueIndex(batch, 0)
+	}
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to discard pending L1 messages", "err", err)
+	}
+}
+
+// replayer drives a worker through the steps of a recording, the way the main loop of a live
+// sequencer reacts to the same events.
+type replayer struct {
+	w        *worker
+	clock    time.Time
+	cccDelay int
+
+	unchecked []*types.Block // committed blocks whose circuit capacity check has not completed yet
+	reorgCh   <-chan core.SequencerReorgEvent
+	result    ReplayResult
+}
+
+// apply feeds a recorded step to the worker.
+func (r *replayer) apply(step ReplayStep) error {
+	db := r.w.eth.ChainDb()
+	for _, enc := range step.L1Messages {
+		tx := new(types.Transaction)
+		if err := tx.UnmarshalBinary(enc); err != nil {
+			return fmt.Errorf("invalid L1 message: %w", err)
+		}
+		if !tx.IsL1MessageTx() {
+			return fmt.Errorf("transaction %s is not an L1 message", tx.Hash().Hex())
+		}
+		rawdb.WriteL1Message(db, *tx.AsL1MessageTx())
+		rawdb.WriteL1MessageSyncTime(db, tx.AsL1MessageTx().QueueIndex, step.Time)
+	}
+
+	if r.w.current == nil {
+		if err := r.next(); err != nil {
+			return err
+		}
+	}
+
+	if len(step.Txs) > 0 {
+		txs := make([]*types.Transaction, 0, len(step.Txs))
+		for _, enc := range step.Txs {
+			tx := new(types.Transaction)
+			if err := tx.UnmarshalBinary(enc); err != nil {
+				return fmt.Errorf("invalid transaction: %w", err)
+			}
+			txs = append(txs, tx)
+		}
+
+		var accepted types.Transactions
+		for i, err := range r.w.eth.TxPool().AddRemotesSync(txs) {
+			if err != nil {
+				log.Debug("Recorded transaction rejected by txpool", "hash", txs[i].Hash().Hex(), "err", err)
+				continue
+			}
+			accepted = append(accepted, txs[i])
+		}
+		if r.w.current != nil && len(accepted) > 0 {
+			shouldCommit, _ := r.w.processTxnSlice(accepted)
+			if shouldCommit || (r.w.current.deadlineReached && len(r.w.current.txs) > 0) {
+				if err := r.commit(); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if step.Seal && r.w.current != nil {
+		r.w.current.deadlineReached = true
+		if len(r.w.current.txs) > 0 {
+			return r.commit()
+		}
+	}
+	return nil
+}
+
+// commit seals the pending block and starts building the next one.
+func (r *replayer) commit() error {
+	if _, err := r.w.commit(); err != nil {
+		if !errors.As(err, new(retryableCommitError)) {
+			return err
+		}
+		log.Debug("Failed to commit to a block, retrying", "err", err)
+		r.w.current = nil
+	}
+	return r.next()
+}
+
+// next completes the circuit capacity checks that are due, and starts building a block on top
+// of the head, like the main loop does when a new head is announced.
+func (r *replayer) next() error {
+	for {
+		if err := r.check(r.cccDelay); err != nil {
+			return err
+		}
+		blockHash, err := r.w.tryCommitNewWork(r.clock, r.w.chain.CurrentHeader().Hash(), nil, nil)
+		if errors.As(err, new(retryableCommitError)) && len(r.unchecked) > 0 {
+			// a live sequencer retries until the check of the ancestor completes
+			log.Debug("Failed to commit to a block, retrying", "err", err)
+			if err := r.check(len(r.unchecked) - 1); err != nil {
+				return err
+			}
+			continue
+		} else if err != nil {
+			return err
+		}
+		if blockHash == (common.Hash{}) {
+			// the pending block waits for more transactions
+			return nil
+		}
+	}
+}
+
+// check completes the circuit capacity checks of the oldest committed blocks until at most
+// keep blocks are left unchecked, and replaces a block failing the check.
+func (r *replayer) check(keep int) error {
+	for len(r.unchecked) > keep {
+		block := r.unchecked[0]
+		r.unchecked = r.unchecked[1:]
+		if !r.w.isCanonical(block.Header()) {
+			continue
+		}
+		if _, err := r.w.asyncChecker.Validate(block); err != nil {
+			log.Warn("block failed CCC", "hash", block.Hash().Hex(), "number", block.NumberU64(), "err", err)
+			// the replacement chain is validated while it is built
+			r.unchecked = nil
+			if err := r.w.handleReorg(&reorgTrigger{block: block, reason: err}); err != nil {
+				return err
+			}
+			r.result.Reorgs = append(r.result.Reorgs, <-r.reorgCh)
+		}
+	}
+	return nil
+}
+
+// replayBackend provides a worker with the chain that blocks are replayed on.
+type replayBackend struct {
+	chain  *core.BlockChain
+	txPool *core.TxPool
+}
+
+func (b *replayBackend) BlockChain() *core.BlockChain           { return b.chain }
+func (b *replayBackend) TxPool() *core.TxPool                   { return b.txPool }
+func (b *replayBackend) ChainDb() ethdb.Database                { return b.chain.Database() }
+func (b *replayBackend) SyncService() *sync_service.SyncService { return nil }
+
+// replayEngine wraps the consensus engine of the chain for replays. The sequencer's signing key
+// is not available, so clique blocks are prepared without consulting the signer snapshot, are
+// not sealed, and seals are not verified. Timestamps are taken from the replay clock instead of
+// the wall clock.
+type replayEngine struct {
+	consensus.Engine
+	now func() time.Time
+}
+
+// Prepare implements consensus.Engine, preparing clique headers as if the sequencer signed them
+// in turn.
+func (e *replayEngine) Prepare(chain consensus.ChainHeaderReader, header *types.Header) error {
+	config := chain.Config().Clique
+	if config == nil {
+		return e.Engine.Prepare(chain, header)
+	}
+	parent := chain.GetHeader(header.ParentHash, header.Number.Uint64()-1)
+	if parent == nil {
+		return consensus.ErrUnknownAncestor
+	}
+	header.Coinbase = common.Address{}
+	header.Nonce = types.BlockNonce{}
+	header.Difficulty = new(big.Int).Set(replayDifficulty)
+
+	extra := make([]byte, replayExtraVanity, replayExtraVanity+replayExtraSeal)
+	copy(extra, header.Extra)
+	header.Extra = append(extra, make([]byte, replayExtraSeal)...)
+	header.MixDigest = common.Hash{}
+
+	now := uint64(e.now().Unix())
+	header.Time = parent.Time + config.Period
+	if config.RelaxedPeriod || header.Time < now {
+		header.Time = now
+	}
+	return nil
+}
+
+// VerifyHeader implements consensus.Engine, blocks built by the replay are trusted.
+func (e *replayEngine) VerifyHeader(chain consensus.ChainHeaderReader, header *types.Header, seal bool) error {
+	return nil
+}
+
+// Seal implements consensus.Engine, returning the block as is.
+func (e *replayEngine) Seal(chain consensus.ChainHeaderReader, block *types.Block, results chan<- *types.Block, stop <-chan struct{}) error {
+	go func() {
+		select {
+		case results <- block:
+		case <-stop:
+		}
+	}()
+	return nil
+}
+
+const (
+	replayExtraVanity = 32                     // Fixed number of extra-data prefix bytes reserved for signer vanity
+	replayExtraSeal   = crypto.SignatureLength // Fixed number of extra-data suffix bytes reserved for signer seal
+)
+
+// replayDifficulty is the difficulty of clique blocks signed in turn.
+var replayDifficulty = big.NewInt(2)
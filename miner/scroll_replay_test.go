@@ -0,0 +1,135 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/scroll-tech/go-ethereum/common"
+	"github.com/scroll-tech/go-ethereum/common/hexutil"
+	"github.com/scroll-tech/go-ethereum/consensus/clique"
+	"github.com/scroll-tech/go-ethereum/core"
+	"github.com/scroll-tech/go-ethereum/core/rawdb"
+	"github.com/scroll-tech/go-ethereum/core/types"
+	"github.com/scroll-tech/go-ethereum/core/vm"
+	"github.com/scroll-tech/go-ethereum/crypto"
+	"github.com/scroll-tech/go-ethereum/ethdb"
+	"github.com/scroll-tech/go-ethereum/params"
+)
+
+// newReplayTestChain creates a chain with a genesis block only, backed by an overlay of its
+// database if overlay is set. It returns the chain and its underlying database.
+func newReplayTestChain(t *testing.T, overlay bool) (*core.BlockChain, ethdb.Database) {
+	chainConfig := *params.AllCliqueProtocolChanges
+	chainConfig.Clique = &params.CliqueConfig{Period: 1, Epoch: 30000, RelaxedPeriod: true}
+	chainConfig.LondonBlock = big.NewInt(0)
+	chainConfig.Scroll.FeeVaultAddress = &common.Address{}
+	chainConfig.Scroll.L1Config = &params.L1Config{NumL1MessagesPerBlock: 10}
+
+	db := rawdb.NewMemoryDatabase()
+	gspec := core.Genesis{
+		Config:    &chainConfig,
+		Timestamp: 1000,
+		ExtraData: make([]byte, 32+common.AddressLength+crypto.SignatureLength),
+		Alloc:     core.GenesisAlloc{testBankAddress: {Balance: testBankFunds}},
+	}
+	copy(gspec.ExtraData[32:], testBankAddress.Bytes())
+	gspec.MustCommit(db)
+
+	chainDb := db
+	if overlay {
+		chainDb = rawdb.NewOverlayDatabase(db)
+	}
+	chain, err := core.NewBlockChain(chainDb, nil, &chainConfig, clique.New(chainConfig.Clique, chainDb), vm.Config{}, nil, nil)
+	require.NoError(t, err)
+	return chain, db
+}
+
+func TestReplay(t *testing.T) {
+	signer := types.LatestSigner(params.AllCliqueProtocolChanges)
+	var txs []hexutil.Bytes
+	for nonce := uint64(0); nonce < 3; nonce++ {
+		tx := types.MustSignNewTx(testBankKey, signer, &types.LegacyTx{
+			Nonce:    nonce,
+			To:       &testUserAddress,
+			Value:    big.NewInt(1000),
+			Gas:      params.TxGas,
+			GasPrice: big.NewInt(10 * params.InitialBaseFee),
+		})
+		enc, err := tx.MarshalBinary()
+		require.NoError(t, err)
+		txs = append(txs, enc)
+	}
+	msg, err := types.NewTx(&types.L1MessageTx{QueueIndex: 0, Gas: 25000, To: &common.Address{1}, Value: big.NewInt(0), Sender: common.Address{2}}).MarshalBinary()
+	require.NoError(t, err)
+
+	steps := []ReplayStep{
+		{Time: 1010, Txs: txs[:2], L1Messages: []hexutil.Bytes{msg}},
+		{Time: 1011, Seal: true},
+		{Time: 1020, Txs: txs[2:]},
+		{Time: 1021, Seal: true},
+		{Time: 1030},
+	}
+	config := &Config{
+		GasCeil:        params.GenesisGasLimit,
+		MaxAccountsNum: math.MaxInt,
+		CCCMaxWorkers:  2,
+		Etherbase:      testBankAddress,
+	}
+
+	chain, _ := newReplayTestChain(t, false)
+	defer chain.Stop()
+	result, err := Replay(chain, config, steps, 1)
+	require.NoError(t, err)
+
+	require.Len(t, result.Blocks, 2)
+	require.Len(t, result.RowConsumption, 2)
+	require.Empty(t, result.Skipped)
+	require.Empty(t, result.Reorgs)
+
+	first, second := result.Blocks[0], result.Blocks[1]
+	require.Equal(t, uint64(1010), first.Time())
+	require.Equal(t, 3, first.Transactions().Len())
+	require.True(t, first.Transactions()[0].IsL1MessageTx())
+	require.Equal(t, uint64(1011), second.Time()) // building starts once the first block is sealed
+	require.Equal(t, 1, second.Transactions().Len())
+	require.Equal(t, chain.CurrentBlock().Hash(), second.Hash())
+	for _, rc := range result.RowConsumption {
+		require.NotNil(t, rc)
+	}
+
+	// replaying the same recording on an overlay of another chain builds the same blocks,
+	// without modifying the database below the overlay
+	other, otherDb := newReplayTestChain(t, true)
+	defer other.Stop()
+	genesis := other.CurrentBlock().Hash()
+	otherResult, err := Replay(other, config, steps, 1)
+	require.NoError(t, err)
+	require.Len(t, otherResult.Blocks, len(result.Blocks))
+	for i, block := range result.Blocks {
+		require.Equal(t, block.Hash(), otherResult.Blocks[i].Hash())
+	}
+	require.Equal(t, genesis, rawdb.ReadHeadBlockHash(otherDb))
+	require.Nil(t, rawdb.ReadL1Message(otherDb, 0))
+	for _, block := range otherResult.Blocks {
+		require.False(t, rawdb.HasBody(otherDb, block.Hash(), block.NumberU64()))
+	}
+}
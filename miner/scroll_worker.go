@@ -39,6 +39,7 @@ import (
 	"github.com/scroll-tech/go-ethereum/params"
 	"github.com/scroll-tech/go-ethereum/rollup/ccc"
 	"github.com/scroll-tech/go-ethereum/rollup/fees"
+	"github.com/scroll-tech/go-ethereum/rollup/tracing"
 	"github.com/scroll-tech/go-ethereum/trie"
 )
 
@@ -49,6 +50,21 @@ const (
 
 	// chainHeadChanSize is the size of channel listening to ChainHeadEvent.
 	chainHeadChanSize = 10
+
+	// l1ReorgChanSize is the size of channel listening to L1ReorgEvent.
+	l1ReorgChanSize = 10
+
+	// preconfChanSize is the number of preconfirmations queued for delivery, preconfirmations
+	// are dropped once the queue is full.
+	preconfChanSize = 4096
+
+	// minRowConsumptionHeadroom is the number of rows that must be left in every subcircuit
+	// for the worker to keep trying transactions after a circuit capacity overflow.
+	minRowConsumptionHeadroom = types.RowConsumptionLimit / 100
+
+	// maxReplacementAttempts is the number of times a replacement block is rebuilt during
+	// a reorg before the reorg is given up.
+	maxReplacementAttempts = 10
 )
 
 var (
@@ -56,6 +72,8 @@ var (
 
 	ErrUnexpectedL1MessageIndex = errors.New("unexpected L1 message index")
 
+	errPendingBlockDiscarded = errors.New("pending block discarded")
+
 	// Metrics for the skipped txs
 	l1SkippedCounter = metrics.NewRegisteredCounter("miner/skipped_txs/l1", nil)
 	l2SkippedCounter = metrics.NewRegisteredCounter("miner/skipped_txs/l2", nil)
@@ -67,12 +85,23 @@ var (
 	cccStallTimer      = metrics.NewRegisteredTimer("miner/ccc_stall", nil)
 	idleTimer          = metrics.NewRegisteredTimer("miner/idle", nil)
 
+	overflowRetryCounter = metrics.NewRegisteredCounter("miner/overflow_retry", nil)
+
 	commitReasonCCCCounter      = metrics.NewRegisteredCounter("miner/commit_reason_ccc", nil)
 	commitReasonDeadlineCounter = metrics.NewRegisteredCounter("miner/commit_reason_deadline", nil)
 	commitGasCounter            = metrics.NewRegisteredCounter("miner/commit_gas", nil)
 
 	missingRCOnRestartCounter = metrics.NewRegisteredCounter("miner/missing_rc_on_restart", nil)
 	missingAncestorRCCounter  = metrics.NewRegisteredCounter("miner/missing_ancestor_rc", nil)
+
+	reorgCounter         = metrics.NewRegisteredCounter("miner/reorg/count", nil)
+	reorgRejectedCounter = metrics.NewRegisteredCounter("miner/reorg/rejected", nil)
+	reorgDepthHistogram  = metrics.NewRegisteredHistogram("miner/reorg/depth", nil, metrics.NewExpDecaySample(1028, 0.015))
+
+	preconfDroppedCounter = metrics.NewRegisteredCounter("miner/preconf/dropped", nil)
+
+	l1MsgForcedCounter         = metrics.NewRegisteredCounter("miner/l1msg/forced", nil)
+	l1MsgInclusionLatencyTimer = metrics.NewRegisteredTimer("miner/l1msg/inclusion_latency", nil)
 )
 
 // prioritizedTransaction represents a single transaction that
@@ -89,8 +118,15 @@ type work struct {
 	cccLogger       *ccc.Logger
 	vmConfig        vm.Config
 
-	reorging    bool
-	reorgReason error
+	reorging     bool
+	reorgedBlock *types.Block // canonical block that is being replaced
+	reorgReason  error
+
+	// circuit capacity overflows of L2 transactions in this block
+	overflowRetries int
+	overflowRc      types.RowConsumption // row consumption reported by the last overflowing transaction
+
+	senderTxs map[common.Address]int // number of L2 transactions of each sender in this block
 
 	// accumulated state
 	nextL1MsgIndex uint64
@@ -127,6 +163,8 @@ type worker struct {
 
 	// Feeds
 	pendingLogsFeed event.Feed
+	reorgFeed       event.Feed
+	preconfFeed     event.Feed
 
 	// Subscriptions
 	mux          *event.TypeMux
@@ -134,11 +172,14 @@ type worker struct {
 	txsSub       event.Subscription
 	chainHeadCh  chan core.ChainHeadEvent
 	chainHeadSub event.Subscription
+	l1ReorgCh    chan core.L1ReorgEvent
+	l1ReorgSub   event.Subscription
 
 	// Channels
-	startCh chan struct{}
-	exitCh  chan struct{}
-	reorgCh chan reorgTrigger
+	startCh   chan struct{}
+	exitCh    chan struct{}
+	reorgCh   chan reorgTrigger
+	preconfCh chan core.PreconfirmationEvent
 
 	wg      sync.WaitGroup
 	current *work
@@ -166,9 +207,20 @@ type worker struct {
 	// External functions
 	isLocalBlock func(block *types.Block) bool // Function used to determine whether the specified block is mined by local miner.
 
-	prioritizedTx *prioritizedTransaction
+	prioritizedTx   *prioritizedTransaction
+	orderingPolicy  OrderingPolicy
+	maxTxsPerSender int // Per-sender cap of L2 transactions in a block imposed by the ordering policy, 0 if none
+
+	asyncChecker  *ccc.AsyncChecker
+	checkCCC      func(block *types.Block) error                          // Starts the circuit capacity check of a committed block
+	validateBlock func(block *types.Block) (*types.RowConsumption, error) // Runs the circuit capacity check of a replacement block
+	now           func() time.Time                                        // Clock used for new blocks and L1 message ages
 
-	asyncChecker *ccc.AsyncChecker
+	halted int32 // Set when a required reorg failed, block production stays stopped until the halt is cleared
+
+	l1MsgStatsMu       sync.Mutex // The lock used to protect the L1 message inclusion statistics below
+	l1MsgLatencySum    time.Duration
+	l1MsgIncludedCount uint64
 
 	// Test hooks
 	beforeTxHook func() // Method to call before processing a transaction.
@@ -178,6 +230,33 @@ type worker struct {
 }
 
 func newWorker(config *Config, chainConfig *params.ChainConfig, engine consensus.Engine, eth Backend, mux *event.TypeMux, isLocalBlock func(*types.Block) bool, init bool) *worker {
+	worker := newIdleWorker(config, chainConfig, engine, eth, mux, isLocalBlock)
+
+	// Subscribe NewTxsEvent for tx pool
+	worker.txsSub = eth.TxPool().SubscribeNewTxsEvent(worker.txsCh)
+
+	// Subscribe events for blockchain
+	worker.chainHeadSub = eth.BlockChain().SubscribeChainHeadEvent(worker.chainHeadCh)
+
+	// Subscribe L1 reorg events for L1 message sync service
+	if syncService := eth.SyncService(); syncService != nil {
+		worker.l1ReorgSub = syncService.SubscribeL1ReorgEvent(worker.l1ReorgCh)
+	}
+
+	worker.wg.Add(2)
+	go worker.mainLoop()
+	go worker.preconfLoop()
+
+	// Submit first work to initialize pending state.
+	if init {
+		worker.startCh <- struct{}{}
+	}
+	return worker
+}
+
+// newIdleWorker creates a worker that does not subscribe to any events, building blocks is
+// left to the caller.
+func newIdleWorker(config *Config, chainConfig *params.ChainConfig, engine consensus.Engine, eth Backend, mux *event.TypeMux, isLocalBlock func(*types.Block) bool) *worker {
 	worker := &worker{
 		config:       config,
 		chainConfig:  chainConfig,
@@ -188,31 +267,32 @@ func newWorker(config *Config, chainConfig *params.ChainConfig, engine consensus
 		isLocalBlock: isLocalBlock,
 		txsCh:        make(chan core.NewTxsEvent, txChanSize),
 		chainHeadCh:  make(chan core.ChainHeadEvent, chainHeadChanSize),
+		l1ReorgCh:    make(chan core.L1ReorgEvent, l1ReorgChanSize),
 		exitCh:       make(chan struct{}),
 		startCh:      make(chan struct{}, 1),
 		reorgCh:      make(chan reorgTrigger, 1),
+		preconfCh:    make(chan core.PreconfirmationEvent, preconfChanSize),
+	}
+	orderingPolicy, err := NewOrderingPolicy(config)
+	if err != nil {
+		log.Error("Invalid transaction ordering policy, using default", "err", err)
+		orderingPolicy = priceOrdering{}
+	}
+	worker.orderingPolicy = orderingPolicy
+	if limiter, ok := orderingPolicy.(senderLimiter); ok {
+		worker.maxTxsPerSender = limiter.txsPerSenderLimit()
 	}
-	worker.asyncChecker = ccc.NewAsyncChecker(worker.chain, config.CCCMaxWorkers, false).WithOnFailingBlock(worker.onBlockFailingCCC)
-
-	// Subscribe NewTxsEvent for tx pool
-	worker.txsSub = eth.TxPool().SubscribeNewTxsEvent(worker.txsCh)
 
-	// Subscribe events for blockchain
-	worker.chainHeadSub = eth.BlockChain().SubscribeChainHeadEvent(worker.chainHeadCh)
+	worker.asyncChecker = ccc.NewAsyncChecker(worker.chain, config.CCCMaxWorkers, false).WithOnFailingBlock(worker.onBlockFailingCCC)
+	worker.checkCCC = worker.asyncChecker.Check
+	worker.validateBlock = worker.asyncChecker.Validate
+	worker.now = time.Now
 
 	// Sanitize account fetch limit.
 	if worker.config.MaxAccountsNum == 0 {
 		log.Warn("Sanitizing miner account fetch limit", "provided", worker.config.MaxAccountsNum, "updated", math.MaxInt)
 		worker.config.MaxAccountsNum = math.MaxInt
 	}
-
-	worker.wg.Add(1)
-	go worker.mainLoop()
-
-	// Submit first work to initialize pending state.
-	if init {
-		worker.startCh <- struct{}{}
-	}
 	return worker
 }
 
@@ -275,6 +355,10 @@ func (w *worker) pendingBlockAndReceipts() (*types.Block, types.Receipts) {
 
 // start sets the running status as 1 and triggers new work submitting.
 func (w *worker) start() {
+	if w.isHalted() {
+		log.Error("Refusing to start sealing, a required reorg failed, see miner_clearHalt")
+		return
+	}
 	atomic.StoreInt32(&w.running, 1)
 	w.startCh <- struct{}{}
 }
@@ -289,6 +373,27 @@ func (w *worker) isRunning() bool {
 	return atomic.LoadInt32(&w.running) == 1
 }
 
+// halt stops block production until the halt is cleared, it is used when a block failing the
+// circuit capacity check cannot be replaced and building on top of it would extend an invalid chain.
+func (w *worker) halt(failingBlock *types.Block, err error) {
+	atomic.StoreInt32(&w.halted, 1)
+	atomic.StoreInt32(&w.running, 0)
+	log.Error("Halting block production, rewind the chain below the failing block with debug_setHead, then call miner_clearHalt and miner_start",
+		"number", failingBlock.NumberU64(), "hash", failingBlock.Hash().Hex(), "err", err)
+}
+
+// isHalted returns whether block production was halted by a failed reorg.
+func (w *worker) isHalted() bool {
+	return atomic.LoadInt32(&w.halted) == 1
+}
+
+// clearHalt allows block production to be started again after it was halted by a failed reorg.
+func (w *worker) clearHalt() {
+	if atomic.CompareAndSwapInt32(&w.halted, 1, 0) {
+		log.Warn("Block production halt cleared", "head", w.chain.CurrentHeader().Number)
+	}
+}
+
 // close terminates all background threads maintained by the worker.
 // Note the worker does not support being closed multiple times.
 func (w *worker) close() {
@@ -327,6 +432,11 @@ func (w *worker) mainLoop() {
 	defer w.asyncChecker.Wait()
 	defer w.txsSub.Unsubscribe()
 	defer w.chainHeadSub.Unsubscribe()
+	defer func() {
+		if w.l1ReorgSub != nil {
+			w.l1ReorgSub.Unsubscribe()
+		}
+	}()
 	defer func() {
 		// training wheels on
 		// lets not crash the node and allow us some time to inspect
@@ -354,11 +464,12 @@ func (w *worker) mainLoop() {
 		var retryableCommitError *retryableCommitError
 		if errors.As(err, &retryableCommitError) {
 			log.Warn("failed to commit to a block, retrying", "err", err)
-			if _, err = w.tryCommitNewWork(time.Now(), w.current.header.ParentHash, w.current.reorging, w.current.reorgReason); err != nil {
+			if _, err = w.tryCommitNewWork(w.now(), w.current.header.ParentHash, w.current.reorgedBlock, w.current.reorgReason); err != nil {
 				continue
 			}
 		} else if err != nil {
 			log.Error("failed to mine block", "err", err)
+			w.retractPending(err)
 			w.current = nil
 		}
 
@@ -372,15 +483,18 @@ func (w *worker) mainLoop() {
 					return
 				}
 			}
-			_, err = w.tryCommitNewWork(time.Now(), w.chain.CurrentHeader().Hash(), false, nil)
+			_, err = w.tryCommitNewWork(w.now(), w.chain.CurrentHeader().Hash(), nil, nil)
 		case trigger := <-w.reorgCh:
 			idleTimer.UpdateSince(idleStart)
 			err = w.handleReorg(&trigger)
 		case chainHead := <-w.chainHeadCh:
 			idleTimer.UpdateSince(idleStart)
 			if w.isCanonical(chainHead.Block.Header()) {
-				_, err = w.tryCommitNewWork(time.Now(), chainHead.Block.Hash(), false, nil)
+				_, err = w.tryCommitNewWork(w.now(), chainHead.Block.Hash(), nil, nil)
 			}
+		case ev := <-w.l1ReorgCh:
+			idleTimer.UpdateSince(idleStart)
+			err = w.handleL1Reorg(ev)
 		case <-w.current.deadlineCh():
 			idleTimer.UpdateSince(idleStart)
 			w.current.deadlineReached = true
@@ -432,11 +546,86 @@ func (w *worker) updateSnapshot() {
 
 func (w *worker) collectPendingL1Messages(startIndex uint64) []types.L1MessageTx {
 	maxCount := w.chainConfig.Scroll.L1Config.NumL1MessagesPerBlock
+	// messages are included in queue order, so startIndex is the oldest pending message
+	age := w.l1MessageAge(startIndex, w.now())
+	if w.config.L1MessageMaxAge > 0 && age != nil && *age > w.config.L1MessageMaxAge {
+		maxCount = w.config.L1MessageForcedPerBlock
+		if maxCount == 0 {
+			maxCount = 2 * w.chainConfig.Scroll.L1Config.NumL1MessagesPerBlock
+		}
+		l1MsgForcedCounter.Inc(1)
+		log.Debug("Raising L1 message quota for overdue messages", "queueIndex", startIndex, "age", *age, "quota", maxCount)
+	}
 	return rawdb.ReadL1MessagesFrom(w.eth.ChainDb(), startIndex, maxCount)
 }
 
+// l1MessageAge returns the time since the L1 message with the given queue index was synced,
+// or nil if the message is not synced or its sync time is unknown.
+func (w *worker) l1MessageAge(queueIndex uint64, now time.Time) *time.Duration {
+	syncTime := rawdb.ReadL1MessageSyncTime(w.eth.ChainDb(), queueIndex)
+	if syncTime == nil {
+		return nil
+	}
+	age := now.Sub(time.Unix(int64(*syncTime), 0))
+	if age < 0 {
+		age = 0
+	}
+	return &age
+}
+
+// trackL1MessageInclusion records how long the L1 messages included in block waited after being synced.
+func (w *worker) trackL1MessageInclusion(block *types.Block) {
+	blockTime := time.Unix(int64(block.Time()), 0)
+	for _, tx := range block.Transactions() {
+		if !tx.IsL1MessageTx() {
+			// L1 messages are always at the beginning of a block
+			break
+		}
+		latency := w.l1MessageAge(tx.AsL1MessageTx().QueueIndex, blockTime)
+		if latency == nil {
+			continue
+		}
+		l1MsgInclusionLatencyTimer.Update(*latency)
+
+		w.l1MsgStatsMu.Lock()
+		w.l1MsgLatencySum += *latency
+		w.l1MsgIncludedCount++
+		w.l1MsgStatsMu.Unlock()
+	}
+}
+
+// L1MessageQueueStats summarizes the L1 messages that are waiting to be included in a block.
+type L1MessageQueueStats struct {
+	NextQueueIndex          uint64         // queue index of the first L1 message not included in the chain
+	PendingCount            uint64         // number of synced L1 messages not included in the chain
+	OldestPendingAge        *time.Duration // time since the oldest pending L1 message was synced, nil if unknown
+	AverageInclusionLatency *time.Duration // average time from syncing to including an L1 message, nil if none was included yet
+}
+
+// l1MessageQueueStats computes the statistics of the L1 messages pending on top of the current head.
+func (w *worker) l1MessageQueueStats() *L1MessageQueueStats {
+	db := w.eth.ChainDb()
+	stats := &L1MessageQueueStats{}
+	if index := rawdb.ReadFirstQueueIndexNotInL2Block(db, w.chain.CurrentHeader().Hash()); index != nil {
+		stats.NextQueueIndex = *index
+	}
+	highest := rawdb.ReadHighestSyncedQueueIndex(db)
+	if highest >= stats.NextQueueIndex && rawdb.ReadL1Message(db, highest) != nil {
+		stats.PendingCount = highest - stats.NextQueueIndex + 1
+		stats.OldestPendingAge = w.l1MessageAge(stats.NextQueueIndex, w.now())
+	}
+
+	w.l1MsgStatsMu.Lock()
+	defer w.l1MsgStatsMu.Unlock()
+	if w.l1MsgIncludedCount > 0 {
+		average := w.l1MsgLatencySum / time.Duration(w.l1MsgIncludedCount)
+		stats.AverageInclusionLatency = &average
+	}
+	return stats
+}
+
 // newWork
-func (w *worker) newWork(now time.Time, parentHash common.Hash, reorging bool, reorgReason error) error {
+func (w *worker) newWork(now time.Time, parentHash common.Hash, reorgedBlock *types.Block, reorgReason error) error {
 	parent := w.chain.GetBlockByHash(parentHash)
 	header := &types.Header{
 		ParentHash: parent.Hash(),
@@ -450,7 +639,7 @@ func (w *worker) newWork(now time.Time, parentHash common.Hash, reorging bool, r
 		// if we are replacing a failing block, reuse the timestamp to make sure
 		// the information we get from AsyncChecker is reliable. Changing timestamp
 		// might alter execution flow of reorged transactions.
-		header.Time = w.chain.GetHeaderByNumber(header.Number.Uint64()).Time
+		header.Time = reorgedBlock.Time()
 	}
 
 	parentState, err := w.chain.StateAt(parent.Root())
@@ -493,6 +682,7 @@ func (w *worker) newWork(now time.Time, parentHash common.Hash, reorging bool, r
 		deadline = time.Unix(int64(header.Time+w.chainConfig.Clique.Period), 0)
 	}
 
+	w.retractPending(errPendingBlockDiscarded)
 	w.current = &work{
 		deadlineTimer:  time.NewTimer(time.Until(deadline)),
 		cccLogger:      cccLogger,
@@ -503,8 +693,10 @@ func (w *worker) newWork(now time.Time, parentHash common.Hash, reorging bool, r
 		receipts:       types.Receipts{},
 		coalescedLogs:  []*types.Log{},
 		gasPool:        new(core.GasPool).AddGas(header.GasLimit),
+		senderTxs:      make(map[common.Address]int),
 		nextL1MsgIndex: nextL1MsgIndex,
-		reorging:       reorging,
+		reorging:       reorgedBlock != nil,
+		reorgedBlock:   reorgedBlock,
 		reorgReason:    reorgReason,
 	}
 
@@ -515,8 +707,8 @@ func (w *worker) newWork(now time.Time, parentHash common.Hash, reorging bool, r
 }
 
 // tryCommitNewWork
-func (w *worker) tryCommitNewWork(now time.Time, parent common.Hash, reorging bool, reorgReason error) (common.Hash, error) {
-	err := w.newWork(now, parent, reorging, reorgReason)
+func (w *worker) tryCommitNewWork(now time.Time, parent common.Hash, reorgedBlock *types.Block, reorgReason error) (common.Hash, error) {
+	err := w.newWork(now, parent, reorgedBlock, reorgReason)
 	if err != nil {
 		return common.Hash{}, fmt.Errorf("failed creating new work: %w", err)
 	}
@@ -618,7 +810,7 @@ func (w *worker) processTxPool() (bool, error) {
 	if w.prioritizedTx != nil {
 		from, _ := types.Sender(signer, w.prioritizedTx.tx) // error already checked before
 		txList := map[common.Address]types.Transactions{from: []*types.Transaction{w.prioritizedTx.tx}}
-		txs := types.NewTransactionsByPriceAndNonce(signer, txList, w.current.header.BaseFee)
+		txs := w.orderingPolicy.Order(signer, txList, w.current.header.BaseFee)
 
 		if shouldCommit, err := w.processTxns(txs); err != nil {
 			return false, fmt.Errorf("failed to include prioritized tx: %w", err)
@@ -628,7 +820,7 @@ func (w *worker) processTxPool() (bool, error) {
 	}
 
 	if len(localTxs) > 0 {
-		txs := types.NewTransactionsByPriceAndNonce(signer, localTxs, w.current.header.BaseFee)
+		txs := w.orderingPolicy.Order(signer, localTxs, w.current.header.BaseFee)
 		if shouldCommit, err := w.processTxns(txs); err != nil {
 			return false, fmt.Errorf("failed to include locals: %w", err)
 		} else if shouldCommit {
@@ -637,7 +829,7 @@ func (w *worker) processTxPool() (bool, error) {
 	}
 
 	if len(remoteTxs) > 0 {
-		txs := types.NewTransactionsByPriceAndNonce(signer, remoteTxs, w.current.header.BaseFee)
+		txs := w.orderingPolicy.Order(signer, remoteTxs, w.current.header.BaseFee)
 		if shouldCommit, err := w.processTxns(txs); err != nil {
 			return false, fmt.Errorf("failed to include remotes: %w", err)
 		} else if shouldCommit {
@@ -648,38 +840,52 @@ func (w *worker) processTxPool() (bool, error) {
 	return false, nil
 }
 
-// processTxnSlice
+// processTxnSlice processes the L1 messages among the given transactions in queue order,
+// followed by the L2 transactions in the order of the ordering policy.
 func (w *worker) processTxnSlice(txns types.Transactions) (bool, error) {
+	var l1Messages []types.L1MessageTx
 	txsMap := make(map[common.Address]types.Transactions)
 	signer := types.MakeSigner(w.chainConfig, w.current.header.Number)
 	for _, tx := range txns {
+		if tx.IsL1MessageTx() {
+			l1Messages = append(l1Messages, *tx.AsL1MessageTx())
+			continue
+		}
 		acc, _ := types.Sender(signer, tx)
 		txsMap[acc] = append(txsMap[acc], tx)
 	}
-	txset := types.NewTransactionsByPriceAndNonce(signer, txsMap, w.current.header.BaseFee)
-	return w.processTxns(txset)
-}
 
-// processReorgedTxns
-func (w *worker) processReorgedTxns(reason error) (bool, error) {
-	reorgedBlock := w.chain.GetBlockByNumber(w.current.header.Number.Uint64())
-	if reorgedBlock == nil {
+	if len(l1Messages) > 0 {
+		l1Txs, err := types.NewL1MessagesByQueueIndex(l1Messages)
+		if err != nil {
+			return false, fmt.Errorf("failed to create L1 message set: %w", err)
+		}
+		if shouldCommit, err := w.processTxns(l1Txs); err != nil || shouldCommit {
+			return shouldCommit, err
+		}
+	}
+	if len(txsMap) == 0 {
 		return false, nil
 	}
+	return w.processTxns(w.orderingPolicy.Order(signer, txsMap, w.current.header.BaseFee))
+}
 
-	commitGasCounter.Dec(int64(reorgedBlock.GasUsed()))
-	reorgedTxns := reorgedBlock.Transactions()
+// processReorgedTxns
+func (w *worker) processReorgedTxns(reason error) (bool, error) {
+	reorgedTxns := w.current.reorgedBlock.Transactions()
 	var errorWithTxnIdx *ccc.ErrorWithTxnIdx
 	if len(reorgedTxns) > 0 && errors.As(reason, &errorWithTxnIdx) {
 		if errorWithTxnIdx.ShouldSkip {
-			w.skipTransaction(reorgedTxns[errorWithTxnIdx.TxIdx], reason)
+			var rc types.RowConsumption
+			if errorWithTxnIdx.AccRc != nil {
+				rc = *errorWithTxnIdx.AccRc
+			}
+			w.skipTransaction(reorgedTxns[errorWithTxnIdx.TxIdx], reason, rc)
 		}
 
 		// if errorWithTxnIdx.TxIdx is 0, we will end up creating an empty block.
-		// This is necessary to make sure that same height can not fail CCC check multiple times.
-		// Each reorg forces a block to be appended to the chain. If we let the same block to trigger
-		// multiple reorgs, we can't guarantee an upper bound on reorg depth anymore. We can revisit this
-		// when we can handle reorgs on sidechains that we are building to replace the canonical chain.
+		// This is necessary to make sure that a replacement block that fails CCC again is rebuilt
+		// with fewer transactions, so that building the replacement side chain terminates.
 		reorgedTxns = reorgedTxns[:errorWithTxnIdx.TxIdx]
 	}
 
@@ -695,6 +901,12 @@ func (w *worker) processTxns(txs types.OrderedTransactionSet) (bool, error) {
 			break
 		}
 
+		// the sender already has as many transactions in this block as the ordering policy allows
+		if w.maxTxsPerSender > 0 && !tx.IsL1MessageTx() && w.current.senderTxs[w.txSender(tx)] >= w.maxTxsPerSender {
+			txs.Pop()
+			continue
+		}
+
 		shouldCommit, err := w.processTxn(tx)
 		if shouldCommit {
 			return true, nil
@@ -706,9 +918,10 @@ func (w *worker) processTxns(txs types.OrderedTransactionSet) (bool, error) {
 		default:
 			w.onTxFailing(w.current.txs.Len(), tx, err)
 			if errors.Is(err, ccc.ErrBlockRowConsumptionOverflow) && w.current.txs.Len() > 0 {
-				return true, nil
+				if tx.IsL1MessageTx() || !w.shouldRetryAfterOverflow(tx) {
+					return true, nil
+				}
 			}
-
 			if tx.IsL1MessageTx() {
 				txs.Shift()
 			} else {
@@ -720,6 +933,53 @@ func (w *worker) processTxns(txs types.OrderedTransactionSet) (bool, error) {
 	return false, nil
 }
 
+// shouldRetryAfterOverflow is called after an L2 transaction overflowed the circuit capacity of
+// a non-empty block. It reports whether the worker should keep trying smaller transactions from
+// other senders instead of sealing the block right away.
+func (w *worker) shouldRetryAfterOverflow(tx *types.Transaction) bool {
+	if w.current.overflowRetries >= w.config.CCCMaxOverflowRetries {
+		return false
+	}
+	w.current.overflowRetries++
+
+	// respect the block building deadline
+	if w.current.deadlineReached {
+		return false
+	}
+	select {
+	case <-w.current.deadlineCh():
+		w.current.deadlineReached = true
+		return false
+	default:
+	}
+
+	// no point in trying further candidates if the subcircuits that overflowed are (almost) full
+	if !hasOverflowHeadroom(w.current.overflowRc, w.current.cccLogger.RowConsumption()) {
+		log.Trace("Not enough circuit capacity left for more transactions", "overflow", w.current.overflowRc)
+		return false
+	}
+
+	overflowRetryCounter.Inc(1)
+	log.Trace("Circuit capacity overflow, trying other transactions", "tx", tx.Hash().String(), "overflow", w.current.overflowRc.OverflownSubCircuits(), "retries", w.current.overflowRetries)
+	return true
+}
+
+// hasOverflowHeadroom reports whether every subcircuit that overflowed in overflowRc still has
+// room for more transactions in the block row consumption rc. If the overflowing subcircuits are
+// unknown, every subcircuit of rc must have room left.
+func hasOverflowHeadroom(overflowRc, rc types.RowConsumption) bool {
+	overflown := overflowRc.OverflownSubCircuits()
+	if len(overflown) == 0 {
+		return rc.Headroom() >= minRowConsumptionHeadroom
+	}
+	for _, name := range overflown {
+		if rc.SubCircuitHeadroom(name) < minRowConsumptionHeadroom {
+			return false
+		}
+	}
+	return true
+}
+
 // processTxn
 func (w *worker) processTxn(tx *types.Transaction) (bool, error) {
 	if w.beforeTxHook != nil {
@@ -760,6 +1020,10 @@ func (w *worker) processTxn(tx *types.Transaction) (bool, error) {
 	receipt, err := core.ApplyTransaction(w.chain.Config(), w.chain, nil /* coinbase will default to chainConfig.Scroll.FeeVaultAddress */, w.current.gasPool,
 		w.current.state, w.current.header, tx, &w.current.header.GasUsed, w.current.vmConfig)
 	if err != nil {
+		if errors.Is(err, ccc.ErrBlockRowConsumptionOverflow) {
+			// keep the report of the overflowing subcircuits in case the transaction gets skipped
+			w.current.overflowRc = w.current.cccLogger.RowConsumption()
+		}
 		w.current.state.RevertToSnapshot(snapState)
 		*w.current.gasPool = snapGasPool
 		w.current.header.GasUsed = snapGasUsed
@@ -771,16 +1035,27 @@ func (w *worker) processTxn(tx *types.Transaction) (bool, error) {
 	w.current.coalescedLogs = append(w.current.coalescedLogs, receipt.Logs...)
 	w.current.txs = append(w.current.txs, tx)
 	w.current.receipts = append(w.current.receipts, receipt)
+	if !w.current.reorging {
+		// replacement blocks are preconfirmed once the replacement chain becomes canonical
+		w.preconfirm(w.current.header.Number.Uint64(), w.current.txs.Len()-1, tx, receipt)
+	}
 
 	if !tx.IsL1MessageTx() {
 		// only consider block size limit for L2 transactions
 		w.current.blockSize += tx.Size()
+		w.current.senderTxs[w.txSender(tx)]++
 	} else {
 		w.current.nextL1MsgIndex = tx.AsL1MessageTx().QueueIndex + 1
 	}
 	return false, nil
 }
 
+// txSender returns the sender of a transaction that is processed for the current block.
+func (w *worker) txSender(tx *types.Transaction) common.Address {
+	from, _ := types.Sender(types.MakeSigner(w.chainConfig, w.current.header.Number), tx) // error already checked before
+	return from
+}
+
 // retryableCommitError wraps an error that happened during commit phase and indicates that worker can retry to build a new block
 type retryableCommitError struct {
 	inner error
@@ -879,9 +1154,10 @@ func (w *worker) commit() (common.Hash, error) {
 	}
 
 	currentHeight := w.current.header.Number.Uint64()
-	maxReorgDepth := uint64(w.config.CCCMaxWorkers + 1)
-	if !w.current.reorging && currentHeight > maxReorgDepth {
-		ancestorHeight := currentHeight - maxReorgDepth
+	// a block failing CCC can at most be maxReorgDepth blocks deep when it is detected
+	ancestorDepth := w.maxReorgDepth()
+	if !w.current.reorging && currentHeight > ancestorDepth {
+		ancestorHeight := currentHeight - ancestorDepth
 		ancestorHash := w.chain.GetHeaderByNumber(ancestorHeight).Hash()
 		if rawdb.ReadBlockRowConsumption(w.chain.Database(), ancestorHash) == nil {
 			missingAncestorRCCounter.Inc(1)
@@ -892,6 +1168,16 @@ func (w *worker) commit() (common.Hash, error) {
 		}
 	}
 
+	if w.current.reorging {
+		// replacement blocks are built as a side chain, handleReorg validates it and makes it canonical at once
+		if err := w.chain.WriteSideBlockWithState(block, w.current.receipts, w.current.state); err != nil {
+			return common.Hash{}, err
+		}
+		log.Info("Successfully sealed replacement block", "number", block.Number(), "sealhash", sealHash, "hash", blockHash)
+		w.current = nil
+		return blockHash, nil
+	}
+
 	// A new block event will trigger a reorg in the txpool, pause reorgs to defer this until we fetch txns for next block.
 	// We may end up trying to process txns that we already included in the previous block, but they will all fail the nonce check
 	w.eth.TxPool().PauseReorgs()
@@ -903,12 +1189,13 @@ func (w *worker) commit() (common.Hash, error) {
 	}
 
 	log.Info("Successfully sealed new block", "number", block.Number(), "sealhash", sealHash, "hash", blockHash)
+	w.trackL1MessageInclusion(block)
 
 	// Broadcast the block and announce chain insertion event
 	w.mux.Post(core.NewMinedBlockEvent{Block: block})
 
 	checkStart := time.Now()
-	if err = w.asyncChecker.Check(block); err != nil {
+	if err = w.checkCCC(block); err != nil {
 		log.Error("failed to launch CCC background task", "err", err)
 	}
 	cccStallTimer.UpdateSince(checkStart)
@@ -933,6 +1220,71 @@ func copyReceipts(receipts []*types.Receipt) []*types.Receipt {
 	return result
 }
 
+// preconfirm announces that tx was added to the block being built at the given index.
+func (w *worker) preconfirm(blockNumber uint64, index int, tx *types.Transaction, receipt *types.Receipt) {
+	// the receipt and its logs are updated once the block is sealed, send a copy without the block hash
+	cpy := *receipt
+	cpy.BlockHash = common.Hash{}
+	cpy.Logs = make([]*types.Log, len(receipt.Logs))
+	for i, l := range receipt.Logs {
+		logCpy := *l
+		logCpy.BlockHash = common.Hash{}
+		cpy.Logs[i] = &logCpy
+	}
+
+	w.sendPreconfirmation(core.PreconfirmationEvent{
+		TxHash:      tx.Hash(),
+		BlockNumber: blockNumber,
+		Index:       uint(index),
+		Receipt:     &cpy,
+	})
+}
+
+// retract announces that the preconfirmations of the given transactions of a block are no longer valid.
+func (w *worker) retract(blockNumber uint64, txs types.Transactions, reason error) {
+	for index, tx := range txs {
+		w.sendPreconfirmation(core.PreconfirmationEvent{
+			TxHash:      tx.Hash(),
+			BlockNumber: blockNumber,
+			Index:       uint(index),
+			Retracted:   true,
+			Reason:      reason,
+		})
+	}
+}
+
+// sendPreconfirmation queues a preconfirmation for delivery without blocking block building,
+// it is dropped if subscribers fell so far behind that the queue is full.
+func (w *worker) sendPreconfirmation(ev core.PreconfirmationEvent) {
+	select {
+	case w.preconfCh <- ev:
+	default:
+		preconfDroppedCounter.Inc(1)
+		log.Warn("Dropping preconfirmation, subscribers are not keeping up", "tx", ev.TxHash, "number", ev.BlockNumber, "retracted", ev.Retracted)
+	}
+}
+
+// preconfLoop delivers the queued preconfirmations to the subscribers in order.
+func (w *worker) preconfLoop() {
+	defer w.wg.Done()
+	for {
+		select {
+		case ev := <-w.preconfCh:
+			w.preconfFeed.Send(ev)
+		case <-w.exitCh:
+			return
+		}
+	}
+}
+
+// retractPending retracts the preconfirmations of the block being built, if it is about to be discarded.
+func (w *worker) retractPending(reason error) {
+	if w.current == nil || w.current.reorging {
+		return
+	}
+	w.retract(w.current.header.Number.Uint64(), w.current.txs, reason)
+}
+
 func (w *worker) onTxFailing(txIndex int, tx *types.Transaction, err error) {
 	if !w.isRunning() {
 		return
@@ -940,12 +1292,14 @@ func (w *worker) onTxFailing(txIndex int, tx *types.Transaction, err error) {
 
 	if errors.Is(err, ccc.ErrBlockRowConsumptionOverflow) {
 		if txIndex > 0 {
-			if !tx.IsL1MessageTx() {
+			nextBlock := w.current.header.Number.Uint64() + 1
+			if !tx.IsL1MessageTx() && (w.prioritizedTx == nil || w.prioritizedTx.blockNumber != nextBlock) {
 				// prioritize overflowing L2 message as the first txn next block
 				// no need to prioritize L1 messages, they are fetched in order
 				// and processed first in every block anyways
+				// if several txns overflow the same block, the first one is prioritized
 				w.prioritizedTx = &prioritizedTransaction{
-					blockNumber: w.current.header.Number.Uint64() + 1,
+					blockNumber: nextBlock,
 					tx:          tx,
 				}
 			}
@@ -953,7 +1307,7 @@ func (w *worker) onTxFailing(txIndex int, tx *types.Transaction, err error) {
 		}
 
 		// first txn overflowed the circuit, skip
-		w.skipTransaction(tx, err)
+		w.skipTransaction(tx, err, w.current.overflowRc)
 	} else if tx.IsL1MessageTx() {
 		if errors.Is(err, ErrUnexpectedL1MessageIndex) {
 			log.Warn(
@@ -968,7 +1322,7 @@ func (w *worker) onTxFailing(txIndex int, tx *types.Transaction, err error) {
 		queueIndex := tx.AsL1MessageTx().QueueIndex
 		log.Warn("Skipping L1 message", "queueIndex", queueIndex, "tx", tx.Hash().String(), "block",
 			w.current.header.Number, "reason", err)
-		rawdb.WriteSkippedTransaction(w.eth.ChainDb(), tx, nil, err.Error(),
+		rawdb.WriteSkippedTransaction(w.eth.ChainDb(), tx, w.skippedTxTraces(tx), nil, err.Error(),
 			w.current.header.Number.Uint64(), nil)
 		w.current.nextL1MsgIndex = queueIndex + 1
 		l1SkippedCounter.Inc(1)
@@ -978,10 +1332,11 @@ func (w *worker) onTxFailing(txIndex int, tx *types.Transaction, err error) {
 	}
 }
 
-// skipTransaction
-func (w *worker) skipTransaction(tx *types.Transaction, err error) {
-	log.Info("Circuit capacity limit reached for a single tx", "isL1Message", tx.IsL1MessageTx(), "tx", tx.Hash().String())
-	rawdb.WriteSkippedTransaction(w.eth.ChainDb(), tx, nil, err.Error(),
+// skipTransaction skips a transaction that overflows the circuit capacity on its own,
+// rc is the row consumption reported by the circuit capacity checker for it.
+func (w *worker) skipTransaction(tx *types.Transaction, err error, rc types.RowConsumption) {
+	log.Info("Circuit capacity limit reached for a single tx", "isL1Message", tx.IsL1MessageTx(), "tx", tx.Hash().String(), "rc", rc)
+	rawdb.WriteSkippedTransaction(w.eth.ChainDb(), tx, w.skippedTxTraces(tx), rc, err.Error(),
 		w.current.header.Number.Uint64(), nil)
 	if tx.IsL1MessageTx() {
 		w.current.nextL1MsgIndex = tx.AsL1MessageTx().QueueIndex + 1
@@ -1006,6 +1361,26 @@ func totalFees(block *types.Block, receipts []*types.Receipt) *big.Float {
 	return new(big.Float).Quo(new(big.Float).SetInt(feesWei), new(big.Float).SetInt(big.NewInt(params.Ether)))
 }
 
+// skippedTxTraces traces a skipped transaction on top of the current pending state,
+// it returns nil unless storing the traces of skipped transactions is enabled.
+func (w *worker) skippedTxTraces(tx *types.Transaction) *types.BlockTrace {
+	if !w.config.StoreSkippedTxTraces {
+		return nil
+	}
+	parent := w.chain.GetBlock(w.current.header.ParentHash, w.current.header.Number.Uint64()-1)
+	if parent == nil {
+		return nil
+	}
+	block := types.NewBlockWithHeader(w.current.header).WithBody([]*types.Transaction{tx}, nil)
+	traces, err := tracing.NewTracerWrapper().CreateTraceEnvAndGetBlockTrace(w.chainConfig, w.chain, w.engine, w.eth.ChainDb(),
+		w.current.state.Copy(), parent, block, true)
+	if err != nil {
+		log.Warn("Failed to trace skipped transaction", "tx", tx.Hash().String(), "err", err)
+		return nil
+	}
+	return traces
+}
+
 func (w *worker) forceTestErr(tx *types.Transaction) {
 	if w.skipTxHash == tx.Hash() {
 		w.current.cccLogger.ForceError()
@@ -1036,35 +1411,156 @@ func (w *worker) onBlockFailingCCC(failingBlock *types.Block, err error) {
 	}
 }
 
-// handleReorg reorgs all blocks following the trigger block
+// handleReorg replaces the trigger block and all blocks following it. The replacement blocks
+// are built and validated as a side chain, and swapped in with a single reorg. If the blocks
+// cannot be replaced, block production is halted rather than extending the failing chain.
 func (w *worker) handleReorg(trigger *reorgTrigger) error {
-	parentHash := trigger.block.ParentHash()
-	reorgReason := trigger.reason
+	if !w.isCanonical(trigger.block.Header()) {
+		// trigger block is no longer part of the canonical chain, we are done
+		return nil
+	}
+	if err := w.replaceBlocks(trigger); err != nil {
+		w.halt(trigger.block, err)
+		return err
+	}
+	return nil
+}
 
-	for {
-		if !w.isCanonical(trigger.block.Header()) {
-			// trigger block is no longer part of the canonical chain, we are done
-			return nil
+// replaceBlocks builds the replacement side chain of a reorg and makes it canonical.
+func (w *worker) replaceBlocks(trigger *reorgTrigger) error {
+	oldHead := w.chain.CurrentBlock()
+	depth := oldHead.NumberU64() - trigger.block.NumberU64() + 1
+	if maxDepth := w.maxReorgDepth(); depth > maxDepth {
+		reorgRejectedCounter.Inc(1)
+		log.Error("Refusing to reorg beyond the maximum reorg depth",
+			"number", trigger.block.NumberU64(), "hash", trigger.block.Hash().Hex(), "depth", depth, "maxDepth", maxDepth)
+		return fmt.Errorf("reorg depth %d exceeds maximum %d", depth, maxDepth)
+	}
+
+	reorgedBlocks := make([]*types.Block, 0, depth)
+	for number := trigger.block.NumberU64(); number <= oldHead.NumberU64(); number++ {
+		reorgedBlocks = append(reorgedBlocks, w.chain.GetBlockByNumber(number))
+	}
+
+	sidechain, err := w.buildSidechain(trigger.block.ParentHash(), reorgedBlocks, trigger.reason)
+	if err != nil {
+		return fmt.Errorf("failed to build replacement chain: %w", err)
+	}
+	newHead := sidechain[len(sidechain)-1]
+	if err := w.chain.SetCanonical(newHead); err != nil {
+		return fmt.Errorf("failed to set replacement chain as canonical: %w", err)
+	}
+	log.Warn("Replaced blocks failing circuit capacity check", "number", trigger.block.NumberU64(), "depth", depth,
+		"oldHead", oldHead.Hash().Hex(), "newHead", newHead.Hash().Hex(), "reason", trigger.reason)
+
+	reorgCounter.Inc(1)
+	reorgDepthHistogram.Update(int64(depth))
+	for _, block := range reorgedBlocks {
+		w.retract(block.NumberU64(), block.Transactions(), trigger.reason)
+	}
+	for i := range sidechain {
+		commitGasCounter.Dec(int64(reorgedBlocks[i].GasUsed()))
+		commitGasCounter.Inc(int64(sidechain[i].GasUsed()))
+
+		// Broadcast the replacement blocks
+		w.mux.Post(core.NewMinedBlockEvent{Block: sidechain[i]})
+
+		receipts := w.chain.GetReceiptsByHash(sidechain[i].Hash())
+		for index, tx := range sidechain[i].Transactions() {
+			w.preconfirm(sidechain[i].NumberU64(), index, tx, receipts[index])
 		}
+	}
+	w.reorgFeed.Send(core.SequencerReorgEvent{
+		FailedBlock: trigger.block,
+		OldHead:     oldHead,
+		NewHead:     newHead,
+		Depth:       depth,
+		Reason:      trigger.reason,
+	})
+	return nil
+}
 
-		newBlockHash, err := w.tryCommitNewWork(time.Now(), parentHash, true, reorgReason)
-		if err != nil {
-			return err
+// buildSidechain builds a replacement for each of the reorged blocks on top of parentHash,
+// without changing the canonical chain. Every replacement block is validated by the circuit
+// capacity checker, a replacement block failing the check of one of its transactions is
+// rebuilt the same way as the block that triggered the reorg. Any other failure, or running
+// out of attempts for a replacement block, fails the whole side chain.
+func (w *worker) buildSidechain(parentHash common.Hash, reorgedBlocks []*types.Block, reason error) ([]*types.Block, error) {
+	sidechain := make([]*types.Block, 0, len(reorgedBlocks))
+	reorgedBlock := reorgedBlocks[0]
+	attempts, lastErr := 0, reason
+	for len(sidechain) < len(reorgedBlocks) {
+		if attempts == maxReplacementAttempts {
+			return nil, fmt.Errorf("failed to build replacement for block %d after %d attempts: %w",
+				reorgedBlocks[len(sidechain)].NumberU64(), attempts, lastErr)
 		}
+		attempts++
 
-		// we created replacement blocks for all existing blocks in canonical chain, but not quite ready to commit the new HEAD
-		if newBlockHash == (common.Hash{}) {
-			// force committing the new canonical head to trigger a reorg in blockchain
-			// otherwise we might ignore CCC errors from the new side chain since it is not canonical yet
-			newBlockHash, err = w.commit()
-			if err != nil {
-				return err
+		blockHash, err := w.tryCommitNewWork(w.now(), parentHash, reorgedBlock, reason)
+		if err == nil && blockHash == (common.Hash{}) {
+			// the replacement block is not full yet, commit it anyway since the side chain
+			// has to replace all reorged blocks
+			blockHash, err = w.commit()
+		}
+		if errors.As(err, new(retryableCommitError)) {
+			log.Warn("failed to commit replacement block, retrying", "number", reorgedBlock.NumberU64(), "err", err)
+			lastErr = err
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		block := w.chain.GetBlockByHash(blockHash)
+		if block == nil {
+			return nil, fmt.Errorf("replacement block for %d was not committed", reorgedBlock.NumberU64())
+		}
+
+		if _, err := w.validateBlock(block); err != nil {
+			if !errors.As(err, new(*ccc.ErrorWithTxnIdx)) {
+				return nil, fmt.Errorf("failed to check replacement block %d: %w", block.NumberU64(), err)
 			}
+			log.Warn("replacement block failed CCC", "hash", block.Hash().Hex(), "number", block.NumberU64(), "err", err)
+			reorgedBlock, reason, lastErr = block, err, err
+			continue
+		}
+
+		sidechain = append(sidechain, block)
+		attempts = 0
+		if len(sidechain) < len(reorgedBlocks) {
+			reorgedBlock = reorgedBlocks[len(sidechain)]
 		}
+		parentHash = blockHash
+		reason = nil // clear reorg reason after trigger block gets replaced
+	}
+	return sidechain, nil
+}
 
-		parentHash = newBlockHash
-		reorgReason = nil // clear reorg reason after trigger block gets reorged
+// maxReorgDepth returns the maximum number of blocks a reorg may replace.
+func (w *worker) maxReorgDepth() uint64 {
+	if w.config.MaxReorgDepth > 0 {
+		return w.config.MaxReorgDepth
 	}
+	// commit refuses to build on a chain whose ancestor this deep has not passed CCC yet
+	return uint64(w.config.CCCMaxWorkers + 1)
+}
+
+// handleL1Reorg discards the pending block if it includes L1 messages that
+// were removed from the database due to an L1 reorg.
+func (w *worker) handleL1Reorg(ev core.L1ReorgEvent) error {
+	head := w.chain.CurrentHeader()
+	if index := rawdb.ReadFirstQueueIndexNotInL2Block(w.eth.ChainDb(), head.Hash()); index != nil && *index > ev.FirstRemovedQueueIndex {
+		// sealed blocks cannot be rolled back here, this requires manual intervention
+		log.Error("L1 reorg removed L1 messages that are already included in L2 chain",
+			"head", head.Number, "firstQueueIndexNotInHead", *index, "firstRemovedQueueIndex", ev.FirstRemovedQueueIndex)
+	}
+
+	if w.current == nil || w.current.reorging || w.current.nextL1MsgIndex <= ev.FirstRemovedQueueIndex {
+		return nil
+	}
+
+	log.Warn("Discarding pending block due to L1 reorg", "number", w.current.header.Number,
+		"nextL1MsgIndex", w.current.nextL1MsgIndex, "firstRemovedQueueIndex", ev.FirstRemovedQueueIndex)
+	_, err := w.tryCommitNewWork(w.now(), w.current.header.ParentHash, nil, nil)
+	return err
 }
 
 func (w *worker) isCanonical(header *types.Header) bool {
@@ -17,9 +17,14 @@
 package miner
 
 import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
 	"math"
 	"math/big"
 	"math/rand"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -39,6 +44,7 @@ import (
 	"github.com/scroll-tech/go-ethereum/ethdb"
 	"github.com/scroll-tech/go-ethereum/event"
 	"github.com/scroll-tech/go-ethereum/params"
+	"github.com/scroll-tech/go-ethereum/rollup/ccc"
 	"github.com/scroll-tech/go-ethereum/rollup/sync_service"
 )
 
@@ -65,6 +71,9 @@ var (
 	testUserKey, _  = crypto.GenerateKey()
 	testUserAddress = crypto.PubkeyToAddress(testUserKey.PublicKey)
 
+	// Funded accounts used by the ordering policy tests
+	testOrderingKeys = []*ecdsa.PrivateKey{mustGenerateKey(), mustGenerateKey(), mustGenerateKey()}
+
 	// Test transactions
 	pendingTxs []*types.Transaction
 	newTxs     []*types.Transaction
@@ -127,6 +136,9 @@ func newTestWorkerBackend(t *testing.T, chainConfig *params.ChainConfig, engine
 		Config: chainConfig,
 		Alloc:  core.GenesisAlloc{testBankAddress: {Balance: testBankFunds}},
 	}
+	for _, key := range testOrderingKeys {
+		gspec.Alloc[crypto.PubkeyToAddress(key.PublicKey)] = core.GenesisAccount{Balance: testBankFunds}
+	}
 
 	switch e := engine.(type) {
 	case *clique.Clique:
@@ -224,7 +236,7 @@ func testGenerateBlockAndImport(t *testing.T, isClique bool) {
 		db          = rawdb.NewMemoryDatabase()
 	)
 	if isClique {
-		chainConfig = params.AllCliqueProtocolChanges
+		chainConfig = copyCliqueChainConfig()
 		chainConfig.Clique = &params.CliqueConfig{Period: 1, Epoch: 30000}
 		engine = clique.New(chainConfig.Clique, db)
 	} else {
@@ -285,7 +297,7 @@ func testGenerateBlockWithL1Msg(t *testing.T, isClique bool) {
 	rawdb.WriteL1Messages(db, msgs)
 
 	if isClique {
-		chainConfig = params.AllCliqueProtocolChanges
+		chainConfig = copyCliqueChainConfig()
 		chainConfig.Clique = &params.CliqueConfig{Period: 1, Epoch: 30000}
 		engine = clique.New(chainConfig.Clique, db)
 	} else {
@@ -341,7 +353,7 @@ func TestAcceptableTxlimit(t *testing.T) {
 		chainConfig *params.ChainConfig
 		db          = rawdb.NewMemoryDatabase()
 	)
-	chainConfig = params.AllCliqueProtocolChanges
+	chainConfig = copyCliqueChainConfig()
 	chainConfig.Clique = &params.CliqueConfig{Period: 1, Epoch: 30000}
 	chainConfig.Scroll.FeeVaultAddress = &common.Address{}
 	engine = clique.New(chainConfig.Clique, db)
@@ -401,7 +413,7 @@ func TestUnacceptableTxlimit(t *testing.T) {
 		chainConfig *params.ChainConfig
 		db          = rawdb.NewMemoryDatabase()
 	)
-	chainConfig = params.AllCliqueProtocolChanges
+	chainConfig = copyCliqueChainConfig()
 	chainConfig.Clique = &params.CliqueConfig{Period: 1, Epoch: 30000}
 	chainConfig.Scroll.FeeVaultAddress = &common.Address{}
 	engine = clique.New(chainConfig.Clique, db)
@@ -460,7 +472,7 @@ func TestL1MsgCorrectOrder(t *testing.T) {
 		chainConfig *params.ChainConfig
 		db          = rawdb.NewMemoryDatabase()
 	)
-	chainConfig = params.AllCliqueProtocolChanges
+	chainConfig = copyCliqueChainConfig()
 	chainConfig.Clique = &params.CliqueConfig{Period: 1, Epoch: 30000}
 	chainConfig.Scroll.FeeVaultAddress = &common.Address{}
 	engine = clique.New(chainConfig.Clique, db)
@@ -523,7 +535,7 @@ func l1MessageTest(t *testing.T, msgs []types.L1MessageTx, withL2Tx bool, callba
 	)
 	rawdb.WriteL1Messages(db, msgs)
 
-	chainConfig = params.AllCliqueProtocolChanges
+	chainConfig = copyCliqueChainConfig()
 	chainConfig.Clique = &params.CliqueConfig{Period: 1, Epoch: 30000}
 	engine = clique.New(chainConfig.Clique, db)
 	maxTxPerBlock := 4
@@ -585,6 +597,80 @@ func l1MessageTest(t *testing.T, msgs []types.L1MessageTx, withL2Tx bool, callba
 	}
 }
 
+func TestL1MessageForcedInclusion(t *testing.T) {
+	var (
+		engine      consensus.Engine
+		chainConfig *params.ChainConfig
+		db          = rawdb.NewMemoryDatabase()
+	)
+	chainConfig = copyCliqueChainConfig()
+	chainConfig.Clique = &params.CliqueConfig{Period: 1, Epoch: 30000}
+	chainConfig.Scroll.FeeVaultAddress = &common.Address{}
+	engine = clique.New(chainConfig.Clique, db)
+
+	maxTxPerBlock := 10
+	chainConfig.Scroll.MaxTxPerBlock = &maxTxPerBlock
+	chainConfig.Scroll.L1Config = &params.L1Config{
+		NumL1MessagesPerBlock: 1,
+	}
+
+	// messages 0 to 2 are overdue, message 3 was just synced
+	now := uint64(time.Now().Unix())
+	for i := uint64(0); i < 4; i++ {
+		rawdb.WriteL1Message(db, types.L1MessageTx{QueueIndex: i, Gas: 21016, To: &common.Address{1}, Data: []byte{0x01}, Sender: common.Address{2}})
+		syncTime := now - 3600
+		if i == 3 {
+			syncTime = now
+		}
+		rawdb.WriteL1MessageSyncTime(db, i, syncTime)
+	}
+
+	chainConfig.LondonBlock = big.NewInt(0)
+	w, b := newTestWorker(t, chainConfig, engine, db, 0)
+	defer w.close()
+
+	config := *w.config
+	config.L1MessageMaxAge = time.Minute
+	config.L1MessageForcedPerBlock = 3
+	w.config = &config
+
+	b.genesis.MustCommit(db)
+
+	stats := w.l1MessageQueueStats()
+	assert.Equal(t, uint64(0), stats.NextQueueIndex)
+	assert.Equal(t, uint64(4), stats.PendingCount)
+	require.NotNil(t, stats.OldestPendingAge)
+	assert.GreaterOrEqual(t, *stats.OldestPendingAge, time.Hour)
+	assert.Nil(t, stats.AverageInclusionLatency)
+
+	// Wait for mined blocks.
+	sub := w.mux.Subscribe(core.NewMinedBlockEvent{})
+	defer sub.Unsubscribe()
+
+	// Start mining!
+	w.start()
+
+	select {
+	case ev := <-sub.Chan():
+		block := ev.Data.(core.NewMinedBlockEvent).Block
+		// the raised quota allows including all overdue messages at once
+		assert.Equal(t, 3, len(block.Transactions()))
+		for i, tx := range block.Transactions() {
+			assert.True(t, tx.IsL1MessageTx())
+			assert.Equal(t, uint64(i), tx.AsL1MessageTx().QueueIndex)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("timeout")
+	}
+	w.stop()
+
+	stats = w.l1MessageQueueStats()
+	assert.Equal(t, uint64(3), stats.NextQueueIndex)
+	assert.Equal(t, uint64(1), stats.PendingCount)
+	require.NotNil(t, stats.AverageInclusionLatency)
+	assert.GreaterOrEqual(t, *stats.AverageInclusionLatency, time.Hour)
+}
+
 func TestL1SingleMessageOverGasLimit(t *testing.T) {
 	assert := assert.New(t)
 
@@ -830,7 +916,7 @@ func TestPrioritizeOverflowTx(t *testing.T) {
 	assert := assert.New(t)
 
 	var (
-		chainConfig = params.AllCliqueProtocolChanges
+		chainConfig = copyCliqueChainConfig()
 		db          = rawdb.NewMemoryDatabase()
 	)
 
@@ -991,7 +1077,7 @@ func TestPending(t *testing.T) {
 		chainConfig *params.ChainConfig
 		db          = rawdb.NewMemoryDatabase()
 	)
-	chainConfig = params.AllCliqueProtocolChanges
+	chainConfig = copyCliqueChainConfig()
 	chainConfig.Clique = &params.CliqueConfig{Period: 1, Epoch: 30000}
 	chainConfig.Scroll.FeeVaultAddress = &common.Address{}
 	engine = clique.New(chainConfig.Clique, db)
@@ -1035,7 +1121,7 @@ func TestReorg(t *testing.T) {
 		chainConfig *params.ChainConfig
 		db          = rawdb.NewMemoryDatabase()
 	)
-	chainConfig = params.AllCliqueProtocolChanges
+	chainConfig = copyCliqueChainConfig()
 	chainConfig.Clique = &params.CliqueConfig{Period: 1, Epoch: 30000, RelaxedPeriod: true}
 	chainConfig.Scroll.FeeVaultAddress = &common.Address{}
 	engine = clique.New(chainConfig.Clique, db)
@@ -1149,7 +1235,7 @@ func TestRestartHeadCCC(t *testing.T) {
 		chainConfig *params.ChainConfig
 		db          = rawdb.NewMemoryDatabase()
 	)
-	chainConfig = params.AllCliqueProtocolChanges
+	chainConfig = copyCliqueChainConfig()
 	chainConfig.Clique = &params.CliqueConfig{Period: 1, Epoch: 30000, RelaxedPeriod: true}
 	chainConfig.Scroll.FeeVaultAddress = &common.Address{}
 	engine = clique.New(chainConfig.Clique, db)
@@ -1185,3 +1271,513 @@ func TestRestartHeadCCC(t *testing.T) {
 	// head should be rechecked by CCC
 	require.NotNil(t, rawdb.ReadBlockRowConsumption(db, headHash))
 }
+
+func TestSequencerReorgEvent(t *testing.T) {
+	var (
+		engine      consensus.Engine
+		chainConfig *params.ChainConfig
+		db          = rawdb.NewMemoryDatabase()
+	)
+	chainConfig = copyCliqueChainConfig()
+	chainConfig.Clique = &params.CliqueConfig{Period: 1, Epoch: 30000, RelaxedPeriod: true}
+	chainConfig.Scroll.FeeVaultAddress = &common.Address{}
+	engine = clique.New(chainConfig.Clique, db)
+
+	maxTxPerBlock := 2
+	chainConfig.Scroll.MaxTxPerBlock = &maxTxPerBlock
+	chainConfig.Scroll.L1Config = &params.L1Config{
+		NumL1MessagesPerBlock: 10,
+	}
+
+	chainConfig.LondonBlock = big.NewInt(0)
+	w, b := newTestWorker(t, chainConfig, engine, db, 0)
+	defer w.close()
+
+	b.genesis.MustCommit(db)
+	for i := 0; i < 20; i++ {
+		b.txPool.AddLocal(b.newRandomTx(true))
+	}
+
+	const reorgHeight = 5
+	w.asyncChecker.ScheduleError(reorgHeight, 1)
+
+	reorgCh := make(chan core.SequencerReorgEvent, 1)
+	sub := w.reorgFeed.Subscribe(reorgCh)
+	defer sub.Unsubscribe()
+
+	w.start()
+
+	var ev core.SequencerReorgEvent
+	select {
+	case ev = <-reorgCh:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("timeout")
+	}
+	w.stop()
+
+	require.Equal(t, uint64(reorgHeight), ev.FailedBlock.NumberU64())
+	require.Equal(t, ev.OldHead.NumberU64()-reorgHeight+1, ev.Depth)
+	require.LessOrEqual(t, ev.Depth, w.maxReorgDepth())
+	require.Equal(t, ev.OldHead.NumberU64(), ev.NewHead.NumberU64())
+	require.Error(t, ev.Reason)
+
+	// the whole replacement chain is canonical and passed CCC
+	block := ev.NewHead
+	for block.NumberU64() >= reorgHeight {
+		require.Equal(t, block.Hash(), w.chain.GetBlockByNumber(block.NumberU64()).Hash())
+		require.NotNil(t, rawdb.ReadBlockRowConsumption(db, block.Hash()))
+		block = w.chain.GetBlockByHash(block.ParentHash())
+	}
+	replacement := w.chain.GetBlockByNumber(reorgHeight)
+	require.NotEqual(t, ev.FailedBlock.Hash(), replacement.Hash())
+	// should skip second txn
+	require.Equal(t, ev.FailedBlock.Transactions()[:1].Len(), replacement.Transactions().Len())
+	require.Equal(t, ev.FailedBlock.Transactions()[0].Hash(), replacement.Transactions()[0].Hash())
+}
+
+func TestPreconfirmations(t *testing.T) {
+	var (
+		engine      consensus.Engine
+		chainConfig *params.ChainConfig
+		db          = rawdb.NewMemoryDatabase()
+	)
+	chainConfig = copyCliqueChainConfig()
+	chainConfig.Clique = &params.CliqueConfig{Period: 1, Epoch: 30000, RelaxedPeriod: true}
+	chainConfig.Scroll.FeeVaultAddress = &common.Address{}
+	engine = clique.New(chainConfig.Clique, db)
+
+	maxTxPerBlock := 2
+	chainConfig.Scroll.MaxTxPerBlock = &maxTxPerBlock
+	chainConfig.Scroll.L1Config = &params.L1Config{
+		NumL1MessagesPerBlock: 10,
+	}
+
+	chainConfig.LondonBlock = big.NewInt(0)
+	w, b := newTestWorker(t, chainConfig, engine, db, 0)
+	defer w.close()
+
+	b.genesis.MustCommit(db)
+	for i := 0; i < 20; i++ {
+		b.txPool.AddLocal(b.newRandomTx(true))
+	}
+
+	const reorgHeight = 5
+	w.asyncChecker.ScheduleError(reorgHeight, 1)
+
+	preconfCh := make(chan core.PreconfirmationEvent, 1024)
+	preconfSub := w.preconfFeed.Subscribe(preconfCh)
+	defer preconfSub.Unsubscribe()
+	reorgCh := make(chan core.SequencerReorgEvent, 1)
+	reorgSub := w.reorgFeed.Subscribe(reorgCh)
+	defer reorgSub.Unsubscribe()
+
+	w.start()
+
+	var reorg core.SequencerReorgEvent
+	select {
+	case reorg = <-reorgCh:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("timeout")
+	}
+	w.stop()
+
+	// replay the preconfirmations to find out which transactions are still considered included
+	type location struct {
+		number uint64
+		index  uint
+	}
+	preconfirmed := make(map[common.Hash]location)
+	var retracted []common.Hash
+	for {
+		// preconfirmations are delivered asynchronously, wait for the queue to drain
+		var ev core.PreconfirmationEvent
+		select {
+		case ev = <-preconfCh:
+		case <-time.After(500 * time.Millisecond):
+		}
+		if ev.TxHash == (common.Hash{}) {
+			break
+		}
+		if ev.Retracted {
+			require.Equal(t, preconfirmed[ev.TxHash], location{ev.BlockNumber, ev.Index})
+			require.Error(t, ev.Reason)
+			delete(preconfirmed, ev.TxHash)
+			retracted = append(retracted, ev.TxHash)
+			continue
+		}
+		require.NotNil(t, ev.Receipt)
+		require.Equal(t, ev.TxHash, ev.Receipt.TxHash)
+		preconfirmed[ev.TxHash] = location{ev.BlockNumber, ev.Index}
+	}
+
+	// all transactions of the failed block were retracted
+	for _, tx := range reorg.FailedBlock.Transactions() {
+		require.Contains(t, retracted, tx.Hash())
+	}
+	// all transactions in the canonical chain are preconfirmed at their final location
+	for number := uint64(1); number <= reorg.NewHead.NumberU64(); number++ {
+		for index, tx := range w.chain.GetBlockByNumber(number).Transactions() {
+			require.Equal(t, location{number, uint(index)}, preconfirmed[tx.Hash()])
+			delete(preconfirmed, tx.Hash())
+		}
+	}
+	// the rest belongs to blocks built on top of the new head
+	for _, loc := range preconfirmed {
+		require.Greater(t, loc.number, reorg.NewHead.NumberU64())
+	}
+}
+
+func TestPreconfirmationsSlowSubscriber(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	w, _ := newTestWorker(t, ethashChainConfig, ethash.NewFaker(), db, 0)
+	defer w.close()
+
+	// the subscriber never reads its events
+	preconfCh := make(chan core.PreconfirmationEvent)
+	preconfSub := w.preconfFeed.Subscribe(preconfCh)
+	defer preconfSub.Unsubscribe()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		tx := newOrderingTestTx(0, 0, testUserAddress, 10)
+		for i := 0; i < preconfChanSize+10; i++ {
+			w.preconfirm(1, i, tx, new(types.Receipt))
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("preconfirming blocked on a slow subscriber")
+	}
+	// the queue filled up behind the blocked delivery and the rest was dropped
+	require.Equal(t, preconfChanSize, len(w.preconfCh))
+}
+
+func TestReorgExceedingMaxDepth(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	w, b := newTestWorker(t, ethashChainConfig, ethash.NewFaker(), db, 5)
+	defer w.close()
+
+	config := *w.config
+	config.MaxReorgDepth = 2
+	w.config = &config
+
+	head := b.chain.CurrentBlock()
+	require.Equal(t, uint64(5), head.NumberU64())
+
+	// replacing blocks 3 to 5 exceeds the maximum depth of 2
+	err := w.handleReorg(&reorgTrigger{
+		block:  b.chain.GetBlockByNumber(3),
+		reason: ccc.ErrBlockRowConsumptionOverflow,
+	})
+	require.ErrorContains(t, err, "exceeds maximum")
+	require.Equal(t, head.Hash(), b.chain.CurrentBlock().Hash())
+
+	// the failing block stays canonical, so block production must not continue on top of it
+	require.True(t, w.isHalted())
+	w.start()
+	require.False(t, w.isRunning())
+
+	// the operator rewinds the chain below the failing block and clears the halt
+	require.NoError(t, b.chain.SetHead(2))
+	w.clearHalt()
+	require.False(t, w.isHalted())
+	w.start()
+	require.True(t, w.isRunning())
+}
+
+// mineReorgTestChain starts a clique sequencer, stops it once it has sealed a few blocks and
+// returns the worker, ready to handle a reorg triggered by the returned head block.
+func mineReorgTestChain(t *testing.T) (*worker, *types.Block) {
+	db := rawdb.NewMemoryDatabase()
+	chainConfig := copyCliqueChainConfig()
+	chainConfig.Clique = &params.CliqueConfig{Period: 1, Epoch: 30000, RelaxedPeriod: true}
+	chainConfig.Scroll.FeeVaultAddress = &common.Address{}
+	chainConfig.Scroll.L1Config = nil
+	maxTxPerBlock := 1
+	chainConfig.Scroll.MaxTxPerBlock = &maxTxPerBlock
+	engine := clique.New(chainConfig.Clique, db)
+
+	w, b := newTestWorker(t, chainConfig, engine, db, 0)
+	t.Cleanup(w.close)
+	for i := 0; i < 10; i++ {
+		b.txPool.AddLocal(b.newRandomTx(false))
+	}
+
+	w.start()
+	require.Eventually(t, func() bool { return b.chain.CurrentBlock().NumberU64() >= 2 }, 10*time.Second, 10*time.Millisecond)
+	w.stop()
+	return w, b.chain.CurrentBlock()
+}
+
+func TestReorgPersistentCheckError(t *testing.T) {
+	w, head := mineReorgTestChain(t)
+
+	// the replacement block cannot be checked at all, e.g. because its parent state is missing
+	var calls int32
+	w.validateBlock = func(block *types.Block) (*types.RowConsumption, error) {
+		atomic.AddInt32(&calls, 1)
+		return nil, errors.New("missing trie node")
+	}
+	w.reorgCh <- reorgTrigger{block: head, reason: ccc.ErrBlockRowConsumptionOverflow}
+
+	require.Eventually(t, w.isHalted, 10*time.Second, 10*time.Millisecond)
+	require.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	require.True(t, w.isCanonical(head.Header()))
+	w.start()
+	require.False(t, w.isRunning())
+}
+
+func TestReorgReplacementAttemptsExhausted(t *testing.T) {
+	w, head := mineReorgTestChain(t)
+
+	// every replacement block fails the check of its first transaction
+	var calls int32
+	w.validateBlock = func(block *types.Block) (*types.RowConsumption, error) {
+		atomic.AddInt32(&calls, 1)
+		return nil, &ccc.ErrorWithTxnIdx{TxIdx: 0}
+	}
+	w.reorgCh <- reorgTrigger{block: head, reason: ccc.ErrBlockRowConsumptionOverflow}
+
+	require.Eventually(t, w.isHalted, 10*time.Second, 10*time.Millisecond)
+	require.Equal(t, int32(maxReplacementAttempts), atomic.LoadInt32(&calls))
+	require.True(t, w.isCanonical(head.Header()))
+}
+
+func TestPackSmallerTxsAfterOverflow(t *testing.T) {
+	newTx := func(sender int, nonce uint64, gas uint64, gasPriceMultiplier int64) *types.Transaction {
+		signer := types.LatestSigner(params.AllCliqueProtocolChanges)
+		return types.MustSignNewTx(testOrderingKeys[sender], signer, &types.LegacyTx{
+			Nonce:    nonce,
+			To:       &testUserAddress,
+			Value:    big.NewInt(1000),
+			Gas:      gas,
+			GasPrice: big.NewInt(gasPriceMultiplier * params.InitialBaseFee),
+		})
+	}
+	txA := newTx(0, 0, params.TxGas, 40)
+	txB := newTx(1, 0, 50000, 30) // overflows the circuit
+	txC := newTx(2, 0, params.TxGas, 20)
+	txD := newTx(2, 1, 60000, 20) // reserves more gas than txB, but fits the remaining capacity
+	txE := newTx(1, 1, params.TxGas, 30)
+	pending := []*types.Transaction{txA, txB, txC, txD, txE}
+
+	var w *worker
+	setup := func(worker *worker) {
+		w = worker
+		w.skip(txB.Hash())
+	}
+
+	// without retries, the block is sealed at the first overflow
+	txs := mineBlockWithTxs(t, Config{}, pending, setup)
+	assert.Equal(t, []common.Hash{txA.Hash()}, txHashes(txs))
+	require.NotNil(t, w.prioritizedTx)
+	assert.Equal(t, txB.Hash(), w.prioritizedTx.tx.Hash())
+
+	// with retries, transactions of other senders are packed
+	txs = mineBlockWithTxs(t, Config{CCCMaxOverflowRetries: 4}, pending, setup)
+	assert.Equal(t, []common.Hash{txA.Hash(), txC.Hash(), txD.Hash()}, txHashes(txs))
+	require.NotNil(t, w.prioritizedTx)
+	assert.Equal(t, txB.Hash(), w.prioritizedTx.tx.Hash())
+}
+
+func TestHasOverflowHeadroom(t *testing.T) {
+	overflowRc := types.RowConsumption{{Name: "evm", RowNumber: types.RowConsumptionLimit + 1}, {Name: "state", RowNumber: 10}}
+	almostFull := uint64(types.RowConsumptionLimit - minRowConsumptionHeadroom + 1)
+
+	// only the subcircuits that overflowed matter
+	assert.True(t, hasOverflowHeadroom(overflowRc, types.RowConsumption{{Name: "evm", RowNumber: 100}, {Name: "state", RowNumber: almostFull}}))
+	assert.False(t, hasOverflowHeadroom(overflowRc, types.RowConsumption{{Name: "evm", RowNumber: almostFull}, {Name: "state", RowNumber: 100}}))
+
+	// without a report of the overflowing subcircuits, every subcircuit must have room left
+	assert.True(t, hasOverflowHeadroom(nil, types.RowConsumption{{Name: "evm", RowNumber: 100}, {Name: "state", RowNumber: 100}}))
+	assert.False(t, hasOverflowHeadroom(nil, types.RowConsumption{{Name: "evm", RowNumber: 100}, {Name: "state", RowNumber: almostFull}}))
+}
+
+func TestSkippedTransactionCircuitCapacityReport(t *testing.T) {
+	tx0 := newOrderingTestTx(0, 0, testUserAddress, 20) // overflows the circuit on its own
+	tx1 := newOrderingTestTx(1, 0, testUserAddress, 10)
+
+	var w *worker
+	txs := mineBlockWithTxs(t, Config{StoreSkippedTxTraces: true}, []*types.Transaction{tx0, tx1}, func(worker *worker) {
+		w = worker
+		w.skip(tx0.Hash())
+	})
+	assert.Equal(t, []common.Hash{tx1.Hash()}, txHashes(txs))
+
+	stx := rawdb.ReadSkippedTransaction(w.eth.ChainDb(), tx0.Hash())
+	require.NotNil(t, stx)
+	assert.True(t, stx.RowConsumption.IsOverflown())
+	assert.NotEmpty(t, stx.TracesBytes)
+
+	var traces types.BlockTrace
+	require.NoError(t, json.Unmarshal(stx.TracesBytes, &traces))
+	require.Len(t, traces.Transactions, 1)
+	assert.Equal(t, tx0.Hash().String(), traces.Transactions[0].TxHash)
+}
+
+// copyCliqueChainConfig returns a copy of the clique test chain config, which tests can
+// modify without affecting each other.
+func copyCliqueChainConfig() *params.ChainConfig {
+	config := *params.AllCliqueProtocolChanges
+	return &config
+}
+
+func mustGenerateKey() *ecdsa.PrivateKey {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// newOrderingTestTx creates a transfer from the ordering test account with the given index.
+func newOrderingTestTx(sender int, nonce uint64, to common.Address, gasPriceMultiplier int64) *types.Transaction {
+	signer := types.LatestSigner(params.AllCliqueProtocolChanges)
+	return types.MustSignNewTx(testOrderingKeys[sender], signer, &types.LegacyTx{
+		Nonce:    nonce,
+		To:       &to,
+		Value:    big.NewInt(1000),
+		Gas:      params.TxGas,
+		GasPrice: big.NewInt(gasPriceMultiplier * params.InitialBaseFee),
+	})
+}
+
+// mineBlockWithTxs mines a block from the given remote transactions with the given
+// miner config and returns the included transactions.
+func mineBlockWithTxs(t *testing.T, config Config, txs []*types.Transaction, setup func(w *worker)) types.Transactions {
+	var (
+		db          = rawdb.NewMemoryDatabase()
+		chainConfig = copyCliqueChainConfig()
+	)
+	chainConfig.Clique = &params.CliqueConfig{Period: 1, Epoch: 30000}
+	chainConfig.Scroll.FeeVaultAddress = &common.Address{}
+	chainConfig.Scroll.L1Config = nil
+	engine := clique.New(chainConfig.Clique, db)
+
+	b := newTestWorkerBackend(t, chainConfig, engine, db, 0)
+	for _, err := range b.txPool.AddRemotesSync(txs) {
+		require.NoError(t, err)
+	}
+
+	config.Recommit = time.Second
+	config.GasCeil = params.GenesisGasLimit
+	config.MaxAccountsNum = math.MaxInt
+	config.CCCMaxWorkers = 2
+	w := newWorker(&config, chainConfig, engine, b, new(event.TypeMux), nil, false)
+	w.setEtherbase(testBankAddress)
+	defer w.close()
+	if setup != nil {
+		setup(w)
+	}
+
+	sub := w.mux.Subscribe(core.NewMinedBlockEvent{})
+	defer sub.Unsubscribe()
+
+	w.start()
+
+	select {
+	case ev := <-sub.Chan():
+		return ev.Data.(core.NewMinedBlockEvent).Block.Transactions()
+	case <-time.After(3 * time.Second):
+		t.Fatalf("timeout")
+	}
+	return nil
+}
+
+func TestOrderingPolicyPrice(t *testing.T) {
+	tx0 := newOrderingTestTx(0, 0, testUserAddress, 10)
+	tx1 := newOrderingTestTx(1, 0, testUserAddress, 30)
+	tx2 := newOrderingTestTx(2, 0, testUserAddress, 20)
+
+	txs := mineBlockWithTxs(t, Config{}, []*types.Transaction{tx0, tx1, tx2}, nil)
+	assert.Equal(t, []common.Hash{tx1.Hash(), tx2.Hash(), tx0.Hash()}, txHashes(txs))
+}
+
+func TestOrderingPolicyFIFO(t *testing.T) {
+	// created in order of arrival, with decreasing gas prices
+	tx0 := newOrderingTestTx(0, 0, testUserAddress, 10)
+	time.Sleep(time.Millisecond)
+	tx1 := newOrderingTestTx(1, 0, testUserAddress, 30)
+	time.Sleep(time.Millisecond)
+	tx2 := newOrderingTestTx(2, 0, testUserAddress, 20)
+	time.Sleep(time.Millisecond)
+	tx3 := newOrderingTestTx(0, 1, testUserAddress, 40)
+
+	txs := mineBlockWithTxs(t, Config{Ordering: OrderingFIFO}, []*types.Transaction{tx3, tx2, tx1, tx0}, nil)
+	assert.Equal(t, []common.Hash{tx0.Hash(), tx1.Hash(), tx2.Hash(), tx3.Hash()}, txHashes(txs))
+}
+
+func TestOrderingPolicyFair(t *testing.T) {
+	var pending []*types.Transaction
+	for nonce := uint64(0); nonce < 5; nonce++ {
+		pending = append(pending, newOrderingTestTx(0, nonce, testUserAddress, 30))
+	}
+	tx1 := newOrderingTestTx(1, 0, testUserAddress, 10)
+	pending = append(pending, tx1)
+
+	txs := mineBlockWithTxs(t, Config{Ordering: OrderingFair, OrderingMaxTxsPerSender: 2}, pending, nil)
+	assert.Equal(t, []common.Hash{pending[0].Hash(), pending[1].Hash(), tx1.Hash()}, txHashes(txs))
+}
+
+func TestOrderingPolicyFairCapPerBlock(t *testing.T) {
+	// sender 0 is local and reaches the cap with its pending transactions, another
+	// transaction of sender 0 arrives while the block is being built
+	local0 := newOrderingTestTx(0, 0, testUserAddress, 30)
+	local1 := newOrderingTestTx(0, 1, testUserAddress, 30)
+	late := newOrderingTestTx(0, 2, testUserAddress, 30)
+	remote := newOrderingTestTx(1, 0, testUserAddress, 10)
+
+	var lateAdded int32
+	setup := func(w *worker) {
+		for _, err := range w.eth.TxPool().AddLocals([]*types.Transaction{local0, local1}) {
+			require.NoError(t, err)
+		}
+		var once sync.Once
+		w.beforeTxHook = func() {
+			once.Do(func() {
+				go func() {
+					w.eth.TxPool().AddRemotesSync([]*types.Transaction{late})
+					atomic.StoreInt32(&lateAdded, 1)
+				}()
+			})
+		}
+	}
+
+	config := Config{Ordering: OrderingFair, OrderingMaxTxsPerSender: 2}
+	txs := mineBlockWithTxs(t, config, []*types.Transaction{remote}, setup)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&lateAdded))
+	assert.Equal(t, []common.Hash{local0.Hash(), local1.Hash(), remote.Hash()}, txHashes(txs))
+}
+
+func TestOrderingPolicyPriority(t *testing.T) {
+	priorityAddress := common.Address{0x42}
+	tx0 := newOrderingTestTx(0, 0, testUserAddress, 30)
+	tx1 := newOrderingTestTx(1, 0, testUserAddress, 20)
+	tx2 := newOrderingTestTx(2, 0, testUserAddress, 10)
+	tx3 := newOrderingTestTx(2, 1, priorityAddress, 10)
+
+	config := Config{Ordering: OrderingPriority, OrderingPriorityAddresses: []common.Address{priorityAddress}}
+	txs := mineBlockWithTxs(t, config, []*types.Transaction{tx0, tx1, tx2, tx3}, nil)
+	assert.Equal(t, []common.Hash{tx2.Hash(), tx3.Hash(), tx0.Hash(), tx1.Hash()}, txHashes(txs))
+}
+
+func TestNewOrderingPolicy(t *testing.T) {
+	for _, ordering := range []string{"", OrderingPrice, OrderingFIFO, OrderingFair} {
+		_, err := NewOrderingPolicy(&Config{Ordering: ordering})
+		assert.NoError(t, err, ordering)
+	}
+	_, err := NewOrderingPolicy(&Config{Ordering: OrderingPriority})
+	assert.Error(t, err)
+	_, err = NewOrderingPolicy(&Config{Ordering: "random"})
+	assert.Error(t, err)
+}
+
+func txHashes(txs types.Transactions) []common.Hash {
+	hashes := make([]common.Hash, 0, len(txs))
+	for _, tx := range txs {
+		hashes = append(hashes, tx.Hash())
+	}
+	return hashes
+}
@@ -197,6 +197,13 @@ type Config struct {
 	L1Confirmations rpc.BlockNumber `toml:",omitempty"`
 	// L1 bridge deployment block number
 	L1DeploymentBlock uint64 `toml:",omitempty"`
+	// Initial and maximum number of L1 blocks per eth_getLogs query
+	L1FetchBlockRange    uint64 `toml:",omitempty"`
+	L1MaxFetchBlockRange uint64 `toml:",omitempty"`
+	// Maximum number of parallel eth_getLogs queries
+	L1FetchConcurrency int `toml:",omitempty"`
+	// Number of L1 endpoints that must agree on logs and block hashes
+	L1Quorum int `toml:",omitempty"`
 }
 
 // IPCEndpoint resolves an IPC endpoint based on a configured value, taking into
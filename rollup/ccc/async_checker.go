@@ -2,12 +2,14 @@ package ccc
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/sourcegraph/conc/stream"
 
 	"github.com/scroll-tech/go-ethereum/common"
+	"github.com/scroll-tech/go-ethereum/consensus"
 	"github.com/scroll-tech/go-ethereum/core"
 	"github.com/scroll-tech/go-ethereum/core/rawdb"
 	"github.com/scroll-tech/go-ethereum/core/state"
@@ -43,6 +45,7 @@ type AsyncChecker struct {
 
 	workers      *stream.Stream
 	freeCheckers chan *Checker
+	validator    *Checker // used by Validate, independent of the async workers
 
 	// local state to keep track of the chain progressing and terminate tasks early if needed
 	currentHead       *types.Header
@@ -80,6 +83,7 @@ func NewAsyncChecker(bc Blockchain, numWorkers int, lightMode bool) *AsyncChecke
 			}
 			return checkers
 		}(numWorkers),
+		validator:         NewChecker(lightMode),
 		workers:           stream.New().WithMaxGoroutines(numWorkers),
 		currentHead:       bc.CurrentHeader(),
 		forkCtx:           forkCtx,
@@ -158,18 +162,59 @@ func (c *AsyncChecker) checkerTask(block *types.Block, ccc *Checker, forkCtx con
 		}
 	}
 
+	accRc, err := c.checkBlock(forkCtx, parent, block, ccc)
+	if errors.Is(err, context.Canceled) {
+		return noopCb
+	} else if err != nil {
+		return failingCallback
+	}
+
+	return func() {
+		if isForkStillActive(forkCtx) {
+			// all good, write the row consumption
+			log.Debug("CCC passed", "blockhash", block.Hash(), "height", block.NumberU64())
+			rawdb.WriteBlockRowConsumption(c.bc.Database(), block.Hash(), accRc)
+		}
+	}
+}
+
+// Validate runs the circuit capacity check of a block synchronously and writes its row
+// consumption if it passes. Unlike Check, it does not track the chain the block belongs to,
+// and does not invoke the failing block callback. It is used for blocks that are not part
+// of the canonical chain yet.
+func (c *AsyncChecker) Validate(block *types.Block) (*types.RowConsumption, error) {
+	checkStart := time.Now()
+	defer checkTimer.UpdateSince(checkStart)
+
+	parent := c.bc.GetBlock(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return nil, consensus.ErrUnknownAncestor
+	}
+
+	accRc, err := c.checkBlock(context.Background(), parent, block, c.validator)
+	if err != nil {
+		failCounter.Inc(1)
+		return nil, err
+	}
+	log.Debug("CCC passed", "blockhash", block.Hash(), "height", block.NumberU64())
+	rawdb.WriteBlockRowConsumption(c.bc.Database(), block.Hash(), accRc)
+	return accRc, nil
+}
+
+// checkBlock runs the circuit capacity check of all transactions in a block and returns
+// the accumulated row consumption. Failing transactions are reported as ErrorWithTxnIdx.
+// It returns context.Canceled if forkCtx is cancelled before the check completes.
+func (c *AsyncChecker) checkBlock(forkCtx context.Context, parent, block *types.Block, ccc *Checker) (*types.RowConsumption, error) {
 	if c.blockNumberToFail == block.NumberU64() {
-		err = &ErrorWithTxnIdx{
+		c.blockNumberToFail = 0
+		return nil, &ErrorWithTxnIdx{
 			TxIdx: uint(c.txnIdxToFail),
-			err:   err,
 		}
-		c.blockNumberToFail = 0
-		return failingCallback
 	}
 
 	statedb, err := c.bc.StateAt(parent.Root())
 	if err != nil {
-		return failingCallback
+		return nil, err
 	}
 
 	header := block.Header()
@@ -178,13 +223,12 @@ func (c *AsyncChecker) checkerTask(block *types.Block, ccc *Checker, forkCtx con
 	accRc := new(types.RowConsumption)
 	for txIdx, tx := range block.Transactions() {
 		if !isForkStillActive(forkCtx) {
-			return noopCb
+			return nil, context.Canceled
 		}
 
-		var curRc *types.RowConsumption
-		curRc, err = c.checkTx(parent, header, statedb, tx, ccc)
+		curRc, err := c.checkTx(parent, header, statedb, tx, ccc)
 		if err != nil {
-			err = &ErrorWithTxnIdx{
+			return nil, &ErrorWithTxnIdx{
 				TxIdx: uint(txIdx),
 				err:   err,
 				// if the txn is the first in block or the additional resource utilization caused
@@ -192,18 +236,10 @@ func (c *AsyncChecker) checkerTask(block *types.Block, ccc *Checker, forkCtx con
 				ShouldSkip: txIdx == 0 || curRc == nil || curRc.Difference(*accRc).IsOverflown(),
 				AccRc:      curRc,
 			}
-			return failingCallback
 		}
 		accRc = curRc
 	}
-
-	return func() {
-		if isForkStillActive(forkCtx) {
-			// all good, write the row consumption
-			log.Debug("CCC passed", "blockhash", block.Hash(), "height", block.NumberU64())
-			rawdb.WriteBlockRowConsumption(c.bc.Database(), block.Hash(), accRc)
-		}
-	}
+	return accRc, nil
 }
 
 func (c *AsyncChecker) checkTx(parent *types.Block, header *types.Header, state *state.StateDB, tx *types.Transaction, ccc *Checker) (*types.RowConsumption, error) {
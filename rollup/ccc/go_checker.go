@@ -0,0 +1,364 @@
+package ccc
+
+import (
+	"encoding/json"
+	"math/big"
+	"sync"
+
+	"github.com/holiman/uint256"
+
+	"github.com/scroll-tech/go-ethereum/common"
+	"github.com/scroll-tech/go-ethereum/common/hexutil"
+	"github.com/scroll-tech/go-ethereum/core/types"
+	"github.com/scroll-tech/go-ethereum/core/vm"
+	"github.com/scroll-tech/go-ethereum/log"
+	"github.com/scroll-tech/go-ethereum/params"
+)
+
+// GoChecker is a circuit capacity checker that does not depend on libzkp. Instead of running
+// the circuits of Scroll's zkEVM, it replays the struct logs and call traces of a block trace
+// through the same per-opcode estimates that Logger uses during block building.
+//
+// Block traces are collected without the stack, so the number of bytes an opcode copies or
+// hashes is derived from its gas cost. Gas costs include memory expansion, which makes these
+// lengths upper bounds, and the resulting RowConsumption a conservative estimate.
+type GoChecker struct {
+	sync.Mutex
+	logger *Logger
+	txNum  uint64
+
+	// errors forced by tests, see ScheduleError and Skip
+	countdown int
+	nextError *error
+	skipHash  string
+	skipError error
+}
+
+// NewGoChecker creates a new GoChecker. Light mode has no effect on the Go checker.
+func NewGoChecker(lightMode bool) *GoChecker {
+	return &GoChecker{logger: NewLogger()}
+}
+
+// Reset resets the accumulated row consumption of the checker.
+func (ccc *GoChecker) Reset() {
+	ccc.Lock()
+	defer ccc.Unlock()
+
+	ccc.logger = NewLogger()
+	ccc.txNum = 0
+}
+
+// ApplyTransaction appends a tx's wrapped BlockTrace into the ccc, and return the accumulated RowConsumption.
+func (ccc *GoChecker) ApplyTransaction(traces *types.BlockTrace) (*types.RowConsumption, error) {
+	ccc.Lock()
+	defer ccc.Unlock()
+
+	if len(traces.Transactions) != 1 || len(traces.ExecutionResults) != 1 {
+		log.Error("malformatted BlockTrace in ApplyTransaction", "id", ccc.txNum, "BlockTrace", traces)
+		return nil, ErrUnknown
+	}
+	if ccc.nextError != nil {
+		ccc.countdown--
+		if ccc.countdown == 0 {
+			err := *ccc.nextError
+			ccc.nextError = nil
+			return nil, err
+		}
+	}
+
+	rc, err := ccc.apply(traces)
+	if err == nil && ccc.skipError != nil && traces.Transactions[0].TxHash == ccc.skipHash {
+		// report the transaction as overflowing the circuit on its own
+		forced := append(types.RowConsumption{{Name: "forced", RowNumber: types.RowConsumptionLimit + 1}}, *rc...)
+		return &forced, ccc.skipError
+	}
+	return rc, err
+}
+
+// ApplyBlock gets a block's RowConsumption.
+func (ccc *GoChecker) ApplyBlock(traces *types.BlockTrace) (*types.RowConsumption, error) {
+	ccc.Lock()
+	defer ccc.Unlock()
+
+	if len(traces.Transactions) != len(traces.ExecutionResults) {
+		log.Error("malformatted BlockTrace in ApplyBlock", "BlockTrace", traces)
+		return nil, ErrUnknown
+	}
+	ccc.logger = NewLogger()
+	ccc.txNum = 0
+	return ccc.apply(traces)
+}
+
+// CheckTxNum compares whether the tx_count in ccc match the expected.
+func (ccc *GoChecker) CheckTxNum(expected int) (bool, uint64, error) {
+	ccc.Lock()
+	defer ccc.Unlock()
+
+	return ccc.txNum == uint64(expected), ccc.txNum, nil
+}
+
+// ScheduleError schedules an error for the cnt-th next transaction (see `ApplyTransaction`), only used in tests.
+func (ccc *GoChecker) ScheduleError(cnt int, err error) {
+	ccc.Lock()
+	defer ccc.Unlock()
+
+	ccc.countdown = cnt
+	ccc.nextError = &err
+}
+
+// Skip forces the checker to always return an error for a given txn, only used in tests.
+func (ccc *GoChecker) Skip(txnHash common.Hash, err error) {
+	ccc.Lock()
+	defer ccc.Unlock()
+
+	ccc.skipHash = txnHash.String()
+	ccc.skipError = err
+}
+
+// SetLightMode sets to ccc light mode, which the Go checker does not distinguish.
+func (ccc *GoChecker) SetLightMode(lightMode bool) error {
+	return nil
+}
+
+func (ccc *GoChecker) apply(traces *types.BlockTrace) (*types.RowConsumption, error) {
+	for _, code := range traces.Bytecodes {
+		ccc.logger.logBytecodeAccess(code.KeccakCodeHash, code.CodeSize)
+	}
+	for i, tx := range traces.Transactions {
+		if err := ccc.logger.logTraceTransaction(tx, traces.ExecutionResults[i]); err != nil {
+			log.Error("failed to estimate row consumption", "id", ccc.txNum, "txHash", tx.TxHash, "err", err)
+			return nil, ErrUnknown
+		}
+		ccc.txNum++
+	}
+
+	rc := ccc.logger.RowConsumption()
+	if rc.IsOverflown() {
+		return &rc, ErrBlockRowConsumptionOverflow
+	}
+	return &rc, nil
+}
+
+// traceCallFrame is a call frame as recorded by the callTracer.
+type traceCallFrame struct {
+	Type   string           `json:"type"`
+	To     string           `json:"to,omitempty"`
+	Input  string           `json:"input"`
+	Output string           `json:"output,omitempty"`
+	Error  string           `json:"error,omitempty"`
+	Calls  []traceCallFrame `json:"calls,omitempty"`
+}
+
+// traceStack is a stackReader that only knows a single stack item, the length of the data
+// an opcode operates on. All other items read as zero.
+type traceStack struct {
+	lengthPos int
+	length    uint256.Int
+}
+
+func (s *traceStack) Back(n int) *uint256.Int {
+	if n == s.lengthPos {
+		return &s.length
+	}
+	return new(uint256.Int)
+}
+
+// traceDataLength derives an upper bound of the number of bytes that op copies or hashes
+// from its gas cost, and the position of that length on the stack.
+func traceDataLength(op vm.OpCode, cost uint64) (int, uint64) {
+	words := func(constant, perWord uint64) uint64 {
+		if cost < constant {
+			return 0
+		}
+		return (cost - constant) / perWord * 32
+	}
+
+	switch op {
+	case vm.CALLDATACOPY, vm.CODECOPY, vm.RETURNDATACOPY, vm.MCOPY:
+		return 2, words(vm.GasFastestStep, params.CopyGas)
+	case vm.EXTCODECOPY:
+		return 3, words(params.WarmStorageReadCostEIP2929, params.CopyGas)
+	case vm.SHA3:
+		return 1, words(params.Sha3Gas, params.Sha3WordGas)
+	case vm.LOG0, vm.LOG1, vm.LOG2, vm.LOG3, vm.LOG4:
+		constant := params.LogGas + uint64(op-vm.LOG0)*params.LogTopicGas
+		if cost < constant {
+			return 1, 0
+		}
+		return 1, (cost - constant) / params.LogDataGas
+	}
+	return -1, 0
+}
+
+// logTraceTransaction accumulates the resource usage of a traced transaction, mirroring what
+// Logger does while the transaction is executed.
+func (l *Logger) logTraceTransaction(tx *types.TransactionData, result *types.ExecutionResult) error {
+	data, err := hexutil.Decode(tx.Data)
+	if err != nil {
+		return err
+	}
+
+	// CaptureStart
+	if tx.To == nil {
+		l.logRawBytecode(data) // init bytecode
+	} else {
+		l.logPrecompileAccess(*tx.To, uint64(len(data)), sliceInputFn(data))
+	}
+	txSize := traceTransactionSize(tx, data)
+	if tx.Type != types.L1MessageTxType {
+		l.sigCount++
+		l.l2TxnsRlpSize += txSize
+	}
+	l.keccakUsage += computeKeccakRows(txSize)
+	l.keccakUsage += computeKeccakRows(64) // ecrecover per txn
+
+	// CaptureState
+	for _, structLog := range result.StructLogs {
+		if structLog.Error != "" {
+			continue
+		}
+		op := vm.StringToOp(structLog.Op)
+		lengthPos, length := traceDataLength(op, structLog.GasCost)
+		stack := &traceStack{lengthPos: lengthPos}
+		stack.length.SetUint64(length)
+
+		l.evmUsage += evmUsagePerOpCode[op]
+		l.stateUsage += stateUsagePerOpCode[op](stack, structLog.Depth)
+
+		switch op {
+		case vm.EXTCODECOPY, vm.CALLDATACOPY, vm.RETURNDATACOPY, vm.CODECOPY, vm.MCOPY:
+			l.logCopy(length)
+		case vm.SHA3:
+			l.keccakUsage += computeKeccakRows(length)
+			l.logCopy(length)
+		case vm.LOG0, vm.LOG1, vm.LOG2, vm.LOG3, vm.LOG4:
+			l.logCopy(length)
+		case vm.EXP:
+			const rowsPerExpCall = 8
+			l.expUsage += rowsPerExpCall
+		}
+	}
+
+	if tx.To == nil && result.AccountCreated != nil && !result.Failed {
+		l.logBytecodeAccess(result.AccountCreated.KeccakCodeHash, result.AccountCreated.CodeSize) // deployed bytecode
+	}
+
+	// Calls, creations and returned data are taken from the call trace, since their lengths
+	// cannot be derived from gas costs. The callTracer does not keep the revert data of nested
+	// calls, callers copying it are accounted for by RETURNDATACOPY.
+	l.logCopy(uint64(len(common.FromHex(result.ReturnValue))))
+	if len(result.CallTrace) == 0 {
+		return nil
+	}
+	var root traceCallFrame
+	if err := json.Unmarshal(result.CallTrace, &root); err != nil {
+		return err
+	}
+	for i := range root.Calls {
+		l.logTraceCallFrame(&root.Calls[i])
+	}
+	return nil
+}
+
+// logTraceCallFrame accumulates the resource usage of a nested call frame and its children.
+func (l *Logger) logTraceCallFrame(frame *traceCallFrame) {
+	input := common.FromHex(frame.Input)
+	output := common.FromHex(frame.Output)
+
+	switch frame.Type {
+	case "CALL", "CALLCODE", "DELEGATECALL", "STATICCALL":
+		to := common.HexToAddress(frame.To)
+		l.logPrecompileAccess(to, uint64(len(input)), sliceInputFn(input))
+		if !isTracePrecompile(to) {
+			l.logCopy(uint64(len(output))) // RETURN
+		}
+	case "CREATE", "CREATE2":
+		l.logCopy(uint64(len(input)))
+		l.logRawBytecode(input) // init bytecode
+		if frame.Error == "" {
+			l.logCopy(uint64(len(output))) // RETURN
+			l.logRawBytecode(output)       // deployed bytecode
+		}
+	}
+
+	for i := range frame.Calls {
+		l.logTraceCallFrame(&frame.Calls[i])
+	}
+}
+
+// isTracePrecompile reports whether addr is one of the precompiles known to logPrecompileAccess.
+func isTracePrecompile(addr common.Address) bool {
+	return addr != (common.Address{}) && new(big.Int).SetBytes(addr.Bytes()).Cmp(big.NewInt(9)) <= 0
+}
+
+func sliceInputFn(input []byte) func(int64, int64) ([]byte, error) {
+	return func(argOffset, argLen int64) ([]byte, error) {
+		padded := make([]byte, argLen)
+		if argOffset < int64(len(input)) {
+			copy(padded, input[argOffset:])
+		}
+		return padded, nil
+	}
+}
+
+// traceTransactionSize returns the encoded size of a traced transaction.
+func traceTransactionSize(tx *types.TransactionData, data []byte) uint64 {
+	var inner types.TxData
+	switch tx.Type {
+	case types.LegacyTxType:
+		inner = &types.LegacyTx{
+			Nonce:    tx.Nonce,
+			GasPrice: tx.GasPrice.ToInt(),
+			Gas:      tx.Gas,
+			To:       tx.To,
+			Value:    tx.Value.ToInt(),
+			Data:     data,
+			V:        tx.V.ToInt(),
+			R:        tx.R.ToInt(),
+			S:        tx.S.ToInt(),
+		}
+	case types.AccessListTxType:
+		inner = &types.AccessListTx{
+			ChainID:    tx.ChainId.ToInt(),
+			Nonce:      tx.Nonce,
+			GasPrice:   tx.GasPrice.ToInt(),
+			Gas:        tx.Gas,
+			To:         tx.To,
+			Value:      tx.Value.ToInt(),
+			Data:       data,
+			AccessList: tx.AccessList,
+			V:          tx.V.ToInt(),
+			R:          tx.R.ToInt(),
+			S:          tx.S.ToInt(),
+		}
+	case types.DynamicFeeTxType:
+		inner = &types.DynamicFeeTx{
+			ChainID:    tx.ChainId.ToInt(),
+			Nonce:      tx.Nonce,
+			GasTipCap:  tx.GasTipCap.ToInt(),
+			GasFeeCap:  tx.GasFeeCap.ToInt(),
+			Gas:        tx.Gas,
+			To:         tx.To,
+			Value:      tx.Value.ToInt(),
+			Data:       data,
+			AccessList: tx.AccessList,
+			V:          tx.V.ToInt(),
+			R:          tx.R.ToInt(),
+			S:          tx.S.ToInt(),
+		}
+	case types.L1MessageTxType:
+		inner = &types.L1MessageTx{
+			QueueIndex: tx.Nonce,
+			Gas:        tx.Gas,
+			To:         tx.To,
+			Value:      tx.Value.ToInt(),
+			Data:       data,
+			Sender:     tx.From,
+		}
+	default:
+		// unknown transaction type, assume the largest envelope we know of
+		const maxEnvelopeSize = 256
+		return uint64(len(data)) + maxEnvelopeSize
+	}
+	return uint64(types.NewTx(inner).Size())
+}
@@ -0,0 +1,33 @@
+//go:build circuit_capacity_checker
+
+package ccc
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var recordCalibrationFlag = flag.Bool("record-calibration", false, "Record libzkp row consumption of calibration traces in testdata/")
+
+// TestRecordCalibration records the row consumption that libzkp computes for the calibration
+// blocks, which TestGoCheckerCalibration compares the Go checker against.
+func TestRecordCalibration(t *testing.T) {
+	if !*recordCalibrationFlag {
+		t.Skip("run with -record-calibration to record libzkp outputs")
+	}
+	require.NoError(t, os.MkdirAll(calibrationDir, 0755))
+
+	for _, block := range generateCalibrationBlocks(t) {
+		rc, err := NewChecker(false).ApplyBlock(block.trace)
+		require.NoError(t, err)
+
+		data, err := json.MarshalIndent(calibrationCase{Trace: block.trace, RowConsumption: *rc}, "", "  ")
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(calibrationDir, block.name+".json"), data, 0644))
+	}
+}
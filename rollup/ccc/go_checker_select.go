@@ -0,0 +1,32 @@
+//go:build ccc_go && !circuit_capacity_checker
+
+package ccc
+
+import (
+	"bytes"
+	"unsafe"
+
+	"github.com/scroll-tech/go-ethereum/core/types"
+)
+
+// Checker is the pure-Go circuit capacity checker when building with the ccc_go tag.
+type Checker = GoChecker
+
+// NewChecker creates a new Checker
+func NewChecker(lightMode bool) *Checker {
+	return NewGoChecker(lightMode)
+}
+
+// ApplyTransactionRustTrace applies a trace created by MakeRustTrace.
+func (ccc *GoChecker) ApplyTransactionRustTrace(rustTrace unsafe.Pointer) (*types.RowConsumption, error) {
+	return ccc.ApplyTransaction((*types.BlockTrace)(rustTrace))
+}
+
+// MakeRustTrace does not need to encode the trace for the Go checker, it is passed through as is.
+func MakeRustTrace(trace *types.BlockTrace, buffer *bytes.Buffer) unsafe.Pointer {
+	return unsafe.Pointer(trace)
+}
+
+// FreeRustTrace is a no-op, traces passed to the Go checker are garbage collected.
+func FreeRustTrace(ptr unsafe.Pointer) {
+}
@@ -0,0 +1,256 @@
+package ccc
+
+import (
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/scroll-tech/go-ethereum/common"
+	"github.com/scroll-tech/go-ethereum/consensus/ethash"
+	"github.com/scroll-tech/go-ethereum/core"
+	"github.com/scroll-tech/go-ethereum/core/rawdb"
+	"github.com/scroll-tech/go-ethereum/core/types"
+	"github.com/scroll-tech/go-ethereum/core/vm"
+	"github.com/scroll-tech/go-ethereum/crypto"
+	"github.com/scroll-tech/go-ethereum/params"
+	"github.com/scroll-tech/go-ethereum/rollup/tracing"
+)
+
+const calibrationDir = "testdata/calibration"
+
+var (
+	calibrationKey, _   = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	calibrationAddr     = crypto.PubkeyToAddress(calibrationKey.PublicKey)
+	hasherAddr          = common.HexToAddress("0x1000")
+	precompileCallerAdr = common.HexToAddress("0x2000")
+	nestedCallerAddr    = common.HexToAddress("0x3000")
+	factoryAddr         = common.HexToAddress("0x4000")
+
+	// hasherCode copies its calldata to memory, hashes and logs it, and returns the hash.
+	hasherCode = common.FromHex("" +
+		"600260030a50" + // EXP
+		"3660006000" + "37" + // CALLDATACOPY(0, 0, CALLDATASIZE)
+		"366000" + "20" + // SHA3(0, CALLDATASIZE)
+		"6000" + "52" + // MSTORE(0, hash)
+		"6001366000" + "a1" + // LOG1(0, CALLDATASIZE, 1)
+		"60206000" + "f3") // RETURN(0, 32)
+
+	// precompileCallerCode passes its calldata to sha256 and identity, and calls ecrecover.
+	precompileCallerCode = common.FromHex("" +
+		"3660006000" + "37" + // CALLDATACOPY(0, 0, CALLDATASIZE)
+		"602060003660006002" + "5afa50" + // STATICCALL(GAS, 0x02, 0, CALLDATASIZE, 0, 32)
+		"602060003660006004" + "5afa50" + // STATICCALL(GAS, 0x04, 0, CALLDATASIZE, 0, 32)
+		"602060006080600060" + "01" + "5afa50" + // STATICCALL(GAS, 0x01, 0, 128, 0, 32)
+		"00")
+
+	// nestedCallerCode forwards its calldata to the hasher and copies the returned hash.
+	nestedCallerCode = common.FromHex("" +
+		"3660006000" + "37" + // CALLDATACOPY(0, 0, CALLDATASIZE)
+		"6020600036600060007300000000000000000000000000000000000010005af150" + // CALL(GAS, hasher, 0, 0, CALLDATASIZE, 0, 32)
+		"602060006000" + "3e" + // RETURNDATACOPY(0, 0, 32)
+		"00")
+
+	// factoryCode deploys its calldata as init code.
+	factoryCode = common.FromHex("" +
+		"3660006000" + "37" + // CALLDATACOPY(0, 0, CALLDATASIZE)
+		"3660006000" + "f050" + // CREATE(0, 0, CALLDATASIZE)
+		"00")
+)
+
+// initCode returns init code that deploys runtime.
+func initCode(runtime []byte) []byte {
+	header := []byte{
+		0x60, byte(len(runtime)), 0x60, 0x0c, 0x60, 0x00, 0x39, // CODECOPY(0, 12, len)
+		0x60, byte(len(runtime)), 0x60, 0x00, 0xf3, // RETURN(0, len)
+	}
+	return append(header, runtime...)
+}
+
+// calibrationBlock is a traced block together with the row consumption that Logger estimated
+// while executing it.
+type calibrationBlock struct {
+	name   string
+	trace  *types.BlockTrace
+	logger types.RowConsumption
+}
+
+// generateCalibrationBlocks builds a chain with blocks that exercise the subcircuits tracked
+// by Logger, and returns their traces.
+func generateCalibrationBlocks(t *testing.T) []calibrationBlock {
+	config := params.TestChainConfig
+	db := rawdb.NewMemoryDatabase()
+	genesis := (&core.Genesis{
+		Config: config,
+		Alloc: core.GenesisAlloc{
+			calibrationAddr:     {Balance: new(big.Int).Mul(big.NewInt(1000), big.NewInt(params.Ether))},
+			hasherAddr:          {Balance: common.Big0, Code: hasherCode},
+			precompileCallerAdr: {Balance: common.Big0, Code: precompileCallerCode},
+			nestedCallerAddr:    {Balance: common.Big0, Code: nestedCallerCode},
+			factoryAddr:         {Balance: common.Big0, Code: factoryCode},
+		},
+	}).MustCommit(db)
+
+	payload := make([]byte, 1000)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+	blocks := []struct {
+		name string
+		txs  func(nonce uint64, gasPrice *big.Int) []*types.Transaction
+	}{
+		{"transfer", func(nonce uint64, gasPrice *big.Int) []*types.Transaction {
+			return []*types.Transaction{types.NewTransaction(nonce, common.HexToAddress("0xdead"), big.NewInt(1000), params.TxGas, gasPrice, nil)}
+		}},
+		{"keccak", func(nonce uint64, gasPrice *big.Int) []*types.Transaction {
+			return []*types.Transaction{types.NewTransaction(nonce, hasherAddr, nil, 1_000_000, gasPrice, payload)}
+		}},
+		{"precompiles", func(nonce uint64, gasPrice *big.Int) []*types.Transaction {
+			return []*types.Transaction{types.NewTransaction(nonce, precompileCallerAdr, nil, 1_000_000, gasPrice, payload)}
+		}},
+		{"nested_call", func(nonce uint64, gasPrice *big.Int) []*types.Transaction {
+			return []*types.Transaction{types.NewTransaction(nonce, nestedCallerAddr, nil, 1_000_000, gasPrice, payload)}
+		}},
+		{"create", func(nonce uint64, gasPrice *big.Int) []*types.Transaction {
+			return []*types.Transaction{
+				types.NewContractCreation(nonce, nil, 1_000_000, gasPrice, initCode(hasherCode)),
+				types.NewTransaction(nonce+1, factoryAddr, nil, 1_000_000, gasPrice, initCode(precompileCallerCode)),
+			}
+		}},
+		{"mixed", func(nonce uint64, gasPrice *big.Int) []*types.Transaction {
+			return []*types.Transaction{
+				types.NewTransaction(nonce, hasherAddr, nil, 1_000_000, gasPrice, payload[:100]),
+				types.NewTransaction(nonce+1, nestedCallerAddr, nil, 1_000_000, gasPrice, payload[:500]),
+				types.NewTransaction(nonce+2, precompileCallerAdr, nil, 1_000_000, gasPrice, payload[:200]),
+			}
+		}},
+	}
+
+	chain, err := core.NewBlockChain(db, nil, config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	require.NoError(t, err)
+	defer chain.Stop()
+
+	chainBlocks, _ := core.GenerateChain(config, genesis, ethash.NewFaker(), db, len(blocks), func(i int, block *core.BlockGen) {
+		signer := types.MakeSigner(config, block.Number())
+		for _, tx := range blocks[i].txs(block.TxNonce(calibrationAddr), block.BaseFee()) {
+			signed, err := types.SignTx(tx, signer, calibrationKey)
+			require.NoError(t, err)
+			block.AddTx(signed)
+		}
+	})
+	_, err = chain.InsertChain(chainBlocks)
+	require.NoError(t, err)
+
+	var result []calibrationBlock
+	for i, block := range chainBlocks {
+		parent := chain.GetBlockByHash(block.ParentHash())
+
+		statedb, err := chain.StateAt(parent.Root())
+		require.NoError(t, err)
+		logger := NewLogger()
+		var (
+			gp      = new(core.GasPool).AddGas(block.GasLimit())
+			usedGas uint64
+			header  = block.Header()
+		)
+		for _, tx := range block.Transactions() {
+			_, err := core.ApplyTransaction(config, chain, &header.Coinbase, gp, statedb, header, tx, &usedGas, vm.Config{Debug: true, Tracer: logger})
+			require.NoError(t, err)
+		}
+
+		statedb, err = chain.StateAt(parent.Root())
+		require.NoError(t, err)
+		trace, err := tracing.NewTracerWrapper().CreateTraceEnvAndGetBlockTrace(config, chain, chain.Engine(), db, statedb, parent, block, true)
+		require.NoError(t, err)
+
+		result = append(result, calibrationBlock{name: blocks[i].name, trace: trace, logger: logger.RowConsumption()})
+	}
+	return result
+}
+
+// requireConservative checks that estimate is at least as large as reference for every
+// subcircuit that both of them track.
+func requireConservative(t *testing.T, name string, estimate, reference types.RowConsumption) {
+	estimated := make(map[string]uint64)
+	for _, usage := range estimate {
+		estimated[usage.Name] = usage.RowNumber
+	}
+	for _, usage := range reference {
+		if rows, ok := estimated[usage.Name]; ok {
+			require.GreaterOrEqual(t, rows, usage.RowNumber, "%s: %s subcircuit underestimated", name, usage.Name)
+		}
+	}
+}
+
+func TestGoCheckerMatchesLogger(t *testing.T) {
+	for _, block := range generateCalibrationBlocks(t) {
+		rc, err := NewGoChecker(false).ApplyBlock(block.trace)
+		require.NoError(t, err)
+		requireConservative(t, block.name, *rc, block.logger)
+	}
+}
+
+func TestGoCheckerApplyTransaction(t *testing.T) {
+	block := generateCalibrationBlocks(t)[5]
+	checker := NewGoChecker(false)
+
+	var last types.RowConsumption
+	for i := range block.trace.Transactions {
+		txTrace := *block.trace
+		txTrace.Transactions = block.trace.Transactions[i : i+1]
+		txTrace.ExecutionResults = block.trace.ExecutionResults[i : i+1]
+		rc, err := checker.ApplyTransaction(&txTrace)
+		require.NoError(t, err)
+		if last != nil {
+			requireConservative(t, block.name, *rc, last)
+		}
+		last = *rc
+	}
+
+	ok, txNum, err := checker.CheckTxNum(len(block.trace.Transactions))
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, uint64(len(block.trace.Transactions)), txNum)
+
+	blockRc, err := NewGoChecker(false).ApplyBlock(block.trace)
+	require.NoError(t, err)
+	require.Equal(t, last, *blockRc)
+
+	checker.Reset()
+	_, txNum, _ = checker.CheckTxNum(0)
+	require.Zero(t, txNum)
+}
+
+// calibrationCase is a block trace and the row consumption that libzkp computed for it.
+type calibrationCase struct {
+	Trace          *types.BlockTrace    `json:"trace"`
+	RowConsumption types.RowConsumption `json:"rowConsumption"`
+}
+
+// TestGoCheckerCalibration compares the Go checker against libzkp outputs recorded in testdata.
+// To record them, build libzkp (make -C libzkp libzkp) and run
+//
+//	go test -tags circuit_capacity_checker -run TestRecordCalibration -record-calibration
+//
+// with libzkp.so on the linker and loader paths.
+func TestGoCheckerCalibration(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join(calibrationDir, "*.json"))
+	require.NoError(t, err)
+	if len(files) == 0 {
+		t.Skipf("no recorded libzkp outputs in %s, run TestRecordCalibration with -tags circuit_capacity_checker", calibrationDir)
+	}
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		require.NoError(t, err)
+		var c calibrationCase
+		require.NoError(t, json.Unmarshal(data, &c))
+
+		rc, err := NewGoChecker(false).ApplyBlock(c.Trace)
+		require.NoError(t, err)
+		requireConservative(t, filepath.Base(file), *rc, c.RowConsumption)
+	}
+}
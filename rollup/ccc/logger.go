@@ -5,6 +5,8 @@ import (
 	"math/big"
 	"time"
 
+	"github.com/holiman/uint256"
+
 	"github.com/scroll-tech/go-ethereum/common"
 	"github.com/scroll-tech/go-ethereum/core/types"
 	"github.com/scroll-tech/go-ethereum/core/vm"
@@ -26,6 +28,11 @@ const (
 
 var _ vm.EVMLogger = (*Logger)(nil)
 
+// stackReader provides read access to the EVM stack when an opcode is executed
+type stackReader interface {
+	Back(n int) *uint256.Int
+}
+
 // Logger is a tracer that keeps track of resource usages of each subcircuit
 // that Scroll's halo2 based zkEVM has. Some subcircuits are not tracked
 // here for the following reasons.
@@ -188,7 +195,7 @@ func (l *Logger) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *
 	}
 
 	l.evmUsage += evmUsagePerOpCode[op]
-	l.stateUsage += stateUsagePerOpCode[op](scope, depth)
+	l.stateUsage += stateUsagePerOpCode[op](scope.Stack, depth)
 
 	getInputFn := func(inputOffset int64) func(int64, int64) ([]byte, error) {
 		return func(argOffset, argLen int64) ([]byte, error) {
@@ -575,20 +582,20 @@ var evmUsagePerOpCode = [256]uint64{
 	0,  // SELFDESTRUCT (255)
 }
 
-func constantStateUsage(usage uint64) func(*vm.ScopeContext, int) uint64 {
-	return func(_ *vm.ScopeContext, _ int) uint64 {
+func constantStateUsage(usage uint64) func(stackReader, int) uint64 {
+	return func(_ stackReader, _ int) uint64 {
 		return usage
 	}
 }
 
-func logStateUsage(size uint64) func(*vm.ScopeContext, int) uint64 {
-	return func(scope *vm.ScopeContext, _ int) uint64 {
-		return 2*(scope.Stack.Back(1).Uint64()/32) + 7 + 2*size
+func logStateUsage(size uint64) func(stackReader, int) uint64 {
+	return func(stack stackReader, _ int) uint64 {
+		return 2*(stack.Back(1).Uint64()/32) + 7 + 2*size
 	}
 }
 
 // state circuit resource usage per OpCode
-var stateUsagePerOpCode = [256]func(*vm.ScopeContext, int) uint64{
+var stateUsagePerOpCode = [256]func(stackReader, int) uint64{
 	constantStateUsage(13), // STOP (0)
 	constantStateUsage(3),  // ADD (1)
 	constantStateUsage(3),  // MUL (2)
@@ -621,9 +628,9 @@ var stateUsagePerOpCode = [256]func(*vm.ScopeContext, int) uint64{
 	constantStateUsage(3),  // SAR (29)
 	constantStateUsage(0),  // UNDEFINED (30)
 	constantStateUsage(0),  // UNDEFINED (31)
-	func(scope *vm.ScopeContext, _ int) uint64 {
+	func(stack stackReader, _ int) uint64 {
 		// let n = # bytes, then row_consumption = (n/32) + 3
-		return scope.Stack.Back(1).Uint64()/32 + 3
+		return stack.Back(1).Uint64()/32 + 3
 	}, // SHA3 (32)
 	constantStateUsage(0), // UNDEFINED (33)
 	constantStateUsage(0), // UNDEFINED (34)
@@ -647,29 +654,29 @@ var stateUsagePerOpCode = [256]func(*vm.ScopeContext, int) uint64{
 	constantStateUsage(2), // CALLVALUE (52)
 	constantStateUsage(7), // CALLDATALOAD (53)
 	constantStateUsage(2), // CALLDATASIZE (54)
-	func(scope *vm.ScopeContext, depth int) uint64 {
+	func(stack stackReader, depth int) uint64 {
 		// let n = # bytes in calldata, then row_consumption = (n/32)*2 + (is_root? 5 : 6)
 		constant := uint64(5)
 		if depth != 0 {
 			constant = 6
 		}
-		return 2*(scope.Stack.Back(2).Uint64()/32) + constant
+		return 2*(stack.Back(2).Uint64()/32) + constant
 	}, // CALLDATACOPY (55)
 	constantStateUsage(1), // CODESIZE (56)
-	func(scope *vm.ScopeContext, _ int) uint64 {
+	func(stack stackReader, _ int) uint64 {
 		// let n = # bytes in code, then row_consumption = (n/32) + 3
-		return scope.Stack.Back(2).Uint64()/32 + 3
+		return stack.Back(2).Uint64()/32 + 3
 	}, // CODECOPY (57)
 	constantStateUsage(2), // GASPRICE (58)
 	constantStateUsage(7), // EXTCODESIZE (59)
-	func(scope *vm.ScopeContext, _ int) uint64 {
+	func(stack stackReader, _ int) uint64 {
 		// let n = # bytes in code, then row_consumption = (n/32) + 9
-		return scope.Stack.Back(3).Uint64()/32 + 3
+		return stack.Back(3).Uint64()/32 + 3
 	}, // EXTCODECOPY (60)
 	constantStateUsage(2), // RETURNDATASIZE (61)
-	func(scope *vm.ScopeContext, _ int) uint64 {
+	func(stack stackReader, _ int) uint64 {
 		// let n = # of bytes to return, then row_consumption = (n/32)*2 + 6
-		return 2*(scope.Stack.Back(2).Uint64()/32) + 6
+		return 2*(stack.Back(2).Uint64()/32) + 6
 	}, // RETURNDATACOPY (62)
 	constantStateUsage(7),   // EXTCODEHASH (63)
 	constantStateUsage(2),   // BLOCKHASH (64)
@@ -1,4 +1,4 @@
-//go:build !circuit_capacity_checker
+//go:build !circuit_capacity_checker && !ccc_go
 
 package ccc
 
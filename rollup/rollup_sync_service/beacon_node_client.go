@@ -0,0 +1,124 @@
+package rollup_sync_service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/scroll-tech/go-ethereum/common"
+	"github.com/scroll-tech/go-ethereum/common/hexutil"
+	"github.com/scroll-tech/go-ethereum/crypto/kzg4844"
+)
+
+// BeaconNodeClient fetches blobs from the blob sidecar API of a beacon node.
+// Beacon nodes only keep blobs for a limited time (~18 days), older blobs have to be fetched from an archive.
+type BeaconNodeClient struct {
+	apiEndpoint    string
+	client         *http.Client
+	genesisTime    uint64
+	secondsPerSlot uint64
+}
+
+// NewBeaconNodeClient creates a BeaconNodeClient, it queries the beacon chain genesis time and slot
+// duration that are needed to map L1 block timestamps to slots.
+func NewBeaconNodeClient(ctx context.Context, apiEndpoint string, client *http.Client) (*BeaconNodeClient, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	c := &BeaconNodeClient{apiEndpoint: apiEndpoint, client: client}
+
+	var genesisResp struct {
+		Data struct {
+			GenesisTime string `json:"genesis_time"`
+		} `json:"data"`
+	}
+	if err := c.get(ctx, "eth/v1/beacon/genesis", &genesisResp); err != nil {
+		return nil, fmt.Errorf("failed to get beacon genesis: %w", err)
+	}
+	genesisTime, err := strconv.ParseUint(genesisResp.Data.GenesisTime, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse genesis time %q: %w", genesisResp.Data.GenesisTime, err)
+	}
+
+	var specResp struct {
+		Data struct {
+			SecondsPerSlot string `json:"SECONDS_PER_SLOT"`
+		} `json:"data"`
+	}
+	if err := c.get(ctx, "eth/v1/config/spec", &specResp); err != nil {
+		return nil, fmt.Errorf("failed to get beacon spec: %w", err)
+	}
+	secondsPerSlot, err := strconv.ParseUint(specResp.Data.SecondsPerSlot, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse seconds per slot %q: %w", specResp.Data.SecondsPerSlot, err)
+	}
+	if secondsPerSlot == 0 {
+		return nil, fmt.Errorf("invalid seconds per slot: %v", secondsPerSlot)
+	}
+
+	c.genesisTime = genesisTime
+	c.secondsPerSlot = secondsPerSlot
+	return c, nil
+}
+
+// GetBlobByVersionedHashAndBlockTime implements BlobClient.
+func (c *BeaconNodeClient) GetBlobByVersionedHashAndBlockTime(ctx context.Context, versionedHash common.Hash, blockTime uint64) (*kzg4844.Blob, error) {
+	if blockTime < c.genesisTime {
+		return nil, fmt.Errorf("block time %v is before beacon genesis time %v", blockTime, c.genesisTime)
+	}
+	slot := (blockTime - c.genesisTime) / c.secondsPerSlot
+
+	var sidecarsResp struct {
+		Data []struct {
+			Blob          hexutil.Bytes `json:"blob"`
+			KZGCommitment hexutil.Bytes `json:"kzg_commitment"`
+		} `json:"data"`
+	}
+	if err := c.get(ctx, fmt.Sprintf("eth/v1/beacon/blob_sidecars/%d", slot), &sidecarsResp); err != nil {
+		return nil, fmt.Errorf("failed to get blob sidecars, slot: %v, err: %w", slot, err)
+	}
+
+	for _, sidecar := range sidecarsResp.Data {
+		var commitment kzg4844.Commitment
+		if len(sidecar.KZGCommitment) != len(commitment) {
+			return nil, fmt.Errorf("invalid kzg commitment length: %v, slot: %v", len(sidecar.KZGCommitment), slot)
+		}
+		copy(commitment[:], sidecar.KZGCommitment)
+		if common.Hash(kzg4844.CalcBlobHashV1(sha256.New(), &commitment)) != versionedHash {
+			continue
+		}
+
+		return decodeAndVerifyBlob(sidecar.Blob, versionedHash)
+	}
+	return nil, fmt.Errorf("blob not found in slot %v, versioned hash: %v", slot, versionedHash.Hex())
+}
+
+// get queries the given path of the beacon API and decodes the JSON response into result.
+func (c *BeaconNodeClient) get(ctx context.Context, path string, result interface{}) error {
+	reqURL, err := url.JoinPath(c.apiEndpoint, path)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unexpected status code %v, body: %s", resp.StatusCode, body)
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
@@ -0,0 +1,72 @@
+package rollup_sync_service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/scroll-tech/go-ethereum/common"
+	"github.com/scroll-tech/go-ethereum/common/hexutil"
+	"github.com/scroll-tech/go-ethereum/crypto/kzg4844"
+)
+
+// BlobArchiveClient fetches blobs from an HTTP blob archive that serves blobs by versioned hash under
+// <apiEndpoint>/<versioned hash>. The response is either the raw blob or a JSON object with the
+// hex-encoded blob in its "data" field, as served by e.g. the blobscan API.
+type BlobArchiveClient struct {
+	apiEndpoint string
+	client      *http.Client
+}
+
+// NewBlobArchiveClient creates a BlobArchiveClient.
+func NewBlobArchiveClient(apiEndpoint string, client *http.Client) *BlobArchiveClient {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &BlobArchiveClient{apiEndpoint: apiEndpoint, client: client}
+}
+
+// GetBlobByVersionedHashAndBlockTime implements BlobClient.
+func (c *BlobArchiveClient) GetBlobByVersionedHashAndBlockTime(ctx context.Context, versionedHash common.Hash, blockTime uint64) (*kzg4844.Blob, error) {
+	reqURL, err := url.JoinPath(c.apiEndpoint, versionedHash.Hex())
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query blob archive: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// a hex-encoded blob in a JSON object is a bit more than twice the blob size
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 3*int64(len(kzg4844.Blob{}))))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob archive response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		if len(body) > 1024 {
+			body = body[:1024]
+		}
+		return nil, fmt.Errorf("unexpected status code %v, body: %s", resp.StatusCode, body)
+	}
+
+	data := body
+	if len(body) != len(kzg4844.Blob{}) {
+		var result struct {
+			Data hexutil.Bytes `json:"data"`
+		}
+		if err := json.Unmarshal(body, &result); err != nil {
+			return nil, fmt.Errorf("failed to decode blob archive response: %w", err)
+		}
+		data = result.Data
+	}
+	return decodeAndVerifyBlob(data, versionedHash)
+}
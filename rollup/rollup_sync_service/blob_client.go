@@ -0,0 +1,68 @@
+package rollup_sync_service
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"github.com/scroll-tech/go-ethereum/common"
+	"github.com/scroll-tech/go-ethereum/crypto/kzg4844"
+	"github.com/scroll-tech/go-ethereum/log"
+)
+
+// BlobClient retrieves the EIP-4844 blobs referenced by commit batch transactions.
+type BlobClient interface {
+	// GetBlobByVersionedHashAndBlockTime returns the blob with the given versioned hash.
+	// blockTime is the timestamp of the L1 block that included the blob.
+	GetBlobByVersionedHashAndBlockTime(ctx context.Context, versionedHash common.Hash, blockTime uint64) (*kzg4844.Blob, error)
+}
+
+// BlobClients queries a list of blob sources in order and returns the first blob found.
+type BlobClients []BlobClient
+
+// GetBlobByVersionedHashAndBlockTime implements BlobClient.
+func (c BlobClients) GetBlobByVersionedHashAndBlockTime(ctx context.Context, versionedHash common.Hash, blockTime uint64) (*kzg4844.Blob, error) {
+	if len(c) == 0 {
+		return nil, errors.New("no blob source configured")
+	}
+
+	var errs []error
+	for i, client := range c {
+		blob, err := client.GetBlobByVersionedHashAndBlockTime(ctx, versionedHash, blockTime)
+		if err == nil {
+			return blob, nil
+		}
+		log.Debug("Failed to get blob from source", "source", i, "versioned hash", versionedHash.Hex(), "err", err)
+		errs = append(errs, err)
+	}
+	return nil, fmt.Errorf("failed to get blob from all %d sources: %w", len(c), errors.Join(errs...))
+}
+
+// verifyBlob checks that the KZG commitment of the blob matches the versioned hash.
+func verifyBlob(blob *kzg4844.Blob, versionedHash common.Hash) error {
+	if blob == nil {
+		return errors.New("blob is nil")
+	}
+	commitment, err := kzg4844.BlobToCommitment(blob)
+	if err != nil {
+		return fmt.Errorf("failed to compute blob commitment: %w", err)
+	}
+	if hash := common.Hash(kzg4844.CalcBlobHashV1(sha256.New(), &commitment)); hash != versionedHash {
+		return fmt.Errorf("blob versioned hash mismatch, expected: %v, got: %v", versionedHash.Hex(), hash.Hex())
+	}
+	return nil
+}
+
+// decodeAndVerifyBlob converts raw blob bytes into a blob and checks it against the versioned hash.
+func decodeAndVerifyBlob(data []byte, versionedHash common.Hash) (*kzg4844.Blob, error) {
+	var blob kzg4844.Blob
+	if len(data) != len(blob) {
+		return nil, fmt.Errorf("invalid blob length, expected: %v, got: %v", len(blob), len(data))
+	}
+	copy(blob[:], data)
+	if err := verifyBlob(&blob, versionedHash); err != nil {
+		return nil, err
+	}
+	return &blob, nil
+}
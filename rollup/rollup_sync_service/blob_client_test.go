@@ -0,0 +1,165 @@
+package rollup_sync_service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/scroll-tech/da-codec/encoding"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/scroll-tech/go-ethereum/common"
+	"github.com/scroll-tech/go-ethereum/common/hexutil"
+	"github.com/scroll-tech/go-ethereum/crypto/kzg4844"
+)
+
+func newTestBlob(t *testing.T, data string) (*kzg4844.Blob, kzg4844.Commitment, common.Hash) {
+	blob, err := encoding.MakeBlobCanonical([]byte(data))
+	require.NoError(t, err)
+	commitment, err := kzg4844.BlobToCommitment(blob)
+	require.NoError(t, err)
+	return blob, commitment, kzg4844.CalcBlobHashV1(sha256.New(), &commitment)
+}
+
+func writeJSON(t *testing.T, w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	require.NoError(t, json.NewEncoder(w).Encode(v))
+}
+
+func TestBeaconNodeClient(t *testing.T) {
+	blob1, commitment1, hash1 := newTestBlob(t, "blob 1")
+	blob2, commitment2, hash2 := newTestBlob(t, "blob 2")
+	_, _, missingHash := newTestBlob(t, "missing blob")
+
+	type sidecar struct {
+		Index         string        `json:"index"`
+		Blob          hexutil.Bytes `json:"blob"`
+		KZGCommitment hexutil.Bytes `json:"kzg_commitment"`
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/eth/v1/beacon/genesis", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, map[string]interface{}{"data": map[string]string{"genesis_time": "1000"}})
+	})
+	mux.HandleFunc("/eth/v1/config/spec", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, map[string]interface{}{"data": map[string]string{"SECONDS_PER_SLOT": "12"}})
+	})
+	mux.HandleFunc("/eth/v1/beacon/blob_sidecars/10", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, map[string]interface{}{"data": []sidecar{
+			{Index: "0", Blob: blob1[:], KZGCommitment: commitment1[:]},
+			{Index: "1", Blob: blob2[:], KZGCommitment: commitment2[:]},
+		}})
+	})
+	// slot 11 serves a blob that does not match its commitment
+	mux.HandleFunc("/eth/v1/beacon/blob_sidecars/11", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, map[string]interface{}{"data": []sidecar{
+			{Index: "0", Blob: blob2[:], KZGCommitment: commitment1[:]},
+		}})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client, err := NewBeaconNodeClient(context.Background(), server.URL, nil)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1000), client.genesisTime)
+	assert.Equal(t, uint64(12), client.secondsPerSlot)
+
+	blockTime := uint64(1000 + 10*12 + 5) // slot 10
+	blob, err := client.GetBlobByVersionedHashAndBlockTime(context.Background(), hash2, blockTime)
+	require.NoError(t, err)
+	assert.Equal(t, blob2, blob)
+
+	_, err = client.GetBlobByVersionedHashAndBlockTime(context.Background(), missingHash, blockTime)
+	assert.ErrorContains(t, err, "blob not found")
+
+	_, err = client.GetBlobByVersionedHashAndBlockTime(context.Background(), hash1, blockTime+12)
+	assert.ErrorContains(t, err, "versioned hash mismatch")
+
+	_, err = client.GetBlobByVersionedHashAndBlockTime(context.Background(), hash1, blockTime+24)
+	assert.ErrorContains(t, err, "unexpected status code 404")
+}
+
+func TestBlobArchiveClient(t *testing.T) {
+	blob1, _, hash1 := newTestBlob(t, "blob 1")
+	blob2, _, hash2 := newTestBlob(t, "blob 2")
+	_, _, hash3 := newTestBlob(t, "blob 3")
+
+	mux := http.NewServeMux()
+	// raw blob
+	mux.HandleFunc("/blobs/"+hash1.Hex(), func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write(blob1[:])
+		require.NoError(t, err)
+	})
+	// JSON encoded blob
+	mux.HandleFunc("/blobs/"+hash2.Hex(), func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, map[string]interface{}{"versionedHash": hash2, "data": hexutil.Bytes(blob2[:])})
+	})
+	// wrong blob
+	mux.HandleFunc("/blobs/"+hash3.Hex(), func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write(blob1[:])
+		require.NoError(t, err)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := NewBlobArchiveClient(server.URL+"/blobs", nil)
+
+	blob, err := client.GetBlobByVersionedHashAndBlockTime(context.Background(), hash1, 0)
+	require.NoError(t, err)
+	assert.Equal(t, blob1, blob)
+
+	blob, err = client.GetBlobByVersionedHashAndBlockTime(context.Background(), hash2, 0)
+	require.NoError(t, err)
+	assert.Equal(t, blob2, blob)
+
+	_, err = client.GetBlobByVersionedHashAndBlockTime(context.Background(), hash3, 0)
+	assert.ErrorContains(t, err, "versioned hash mismatch")
+
+	_, err = client.GetBlobByVersionedHashAndBlockTime(context.Background(), common.Hash{}, 0)
+	assert.ErrorContains(t, err, "unexpected status code 404")
+}
+
+func TestBlobDirectoryClient(t *testing.T) {
+	blob1, _, hash1 := newTestBlob(t, "blob 1")
+	_, _, hash2 := newTestBlob(t, "blob 2")
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, hash1.Hex()), blob1[:], 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, hash2.Hex()), blob1[:], 0o644))
+
+	client := NewBlobDirectoryClient(dir)
+
+	blob, err := client.GetBlobByVersionedHashAndBlockTime(context.Background(), hash1, 0)
+	require.NoError(t, err)
+	assert.Equal(t, blob1, blob)
+
+	_, err = client.GetBlobByVersionedHashAndBlockTime(context.Background(), hash2, 0)
+	assert.ErrorContains(t, err, "versioned hash mismatch")
+
+	_, err = client.GetBlobByVersionedHashAndBlockTime(context.Background(), common.Hash{}, 0)
+	assert.Error(t, err)
+}
+
+func TestBlobClientsFallback(t *testing.T) {
+	blob1, _, hash1 := newTestBlob(t, "blob 1")
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, hash1.Hex()), blob1[:], 0o644))
+
+	clients := BlobClients{NewBlobDirectoryClient(t.TempDir()), NewBlobDirectoryClient(dir)}
+	blob, err := clients.GetBlobByVersionedHashAndBlockTime(context.Background(), hash1, 0)
+	require.NoError(t, err)
+	assert.Equal(t, blob1, blob)
+
+	_, err = clients.GetBlobByVersionedHashAndBlockTime(context.Background(), common.Hash{}, 0)
+	assert.ErrorContains(t, err, "failed to get blob from all 2 sources")
+
+	_, err = BlobClients{}.GetBlobByVersionedHashAndBlockTime(context.Background(), hash1, 0)
+	assert.Error(t, err)
+}
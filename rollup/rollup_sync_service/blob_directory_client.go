@@ -0,0 +1,31 @@
+package rollup_sync_service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/scroll-tech/go-ethereum/common"
+	"github.com/scroll-tech/go-ethereum/crypto/kzg4844"
+)
+
+// BlobDirectoryClient reads blobs from a local directory that contains one file per blob,
+// named after the blob's versioned hash and containing the raw blob.
+type BlobDirectoryClient struct {
+	dir string
+}
+
+// NewBlobDirectoryClient creates a BlobDirectoryClient.
+func NewBlobDirectoryClient(dir string) *BlobDirectoryClient {
+	return &BlobDirectoryClient{dir: dir}
+}
+
+// GetBlobByVersionedHashAndBlockTime implements BlobClient.
+func (c *BlobDirectoryClient) GetBlobByVersionedHashAndBlockTime(ctx context.Context, versionedHash common.Hash, blockTime uint64) (*kzg4844.Blob, error) {
+	data, err := os.ReadFile(filepath.Join(c.dir, versionedHash.Hex()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob file: %w", err)
+	}
+	return decodeAndVerifyBlob(data, versionedHash)
+}
@@ -0,0 +1,380 @@
+package rollup_sync_service
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/scroll-tech/da-codec/encoding"
+	"github.com/scroll-tech/da-codec/encoding/codecv0"
+	"github.com/scroll-tech/da-codec/encoding/codecv1"
+	"github.com/scroll-tech/da-codec/encoding/codecv2"
+	"github.com/scroll-tech/da-codec/encoding/codecv4"
+
+	"github.com/scroll-tech/go-ethereum/common"
+	"github.com/scroll-tech/go-ethereum/core/rawdb"
+	"github.com/scroll-tech/go-ethereum/core/types"
+	"github.com/scroll-tech/go-ethereum/crypto/kzg4844"
+	"github.com/scroll-tech/go-ethereum/log"
+	"github.com/scroll-tech/go-ethereum/rlp"
+	"github.com/scroll-tech/go-ethereum/trie"
+)
+
+const (
+	// blockContextByteSize is the size of an encoded block context in a chunk, it is the same for all codec versions.
+	blockContextByteSize = 60
+
+	// blobElementCount and blobElementByteSize describe the canonical blob layout,
+	// only the last 31 bytes of each field element carry payload data.
+	blobElementCount    = 4096
+	blobElementByteSize = 32
+)
+
+// derivedBlock is an L2 block decoded from the batch data committed on L1.
+type derivedBlock struct {
+	context *codecv0.DABlock
+	l2Txs   types.Transactions
+}
+
+// deriveBlocksFromBatch reconstructs the L2 blocks of a committed batch from the commit transaction's
+// calldata and blob, and executes them on top of the local chain. Blocks that already exist locally are
+// skipped, their consistency with L1 is checked once the batch is finalized.
+func (s *RollupSyncService) deriveBlocksFromBatch(batchIndex uint64, vLog *types.Log) error {
+	// the genesis batch only contains the genesis block
+	if batchIndex == 0 {
+		return nil
+	}
+
+	tx, err := s.getCommitBatchTransaction(vLog)
+	if err != nil {
+		return err
+	}
+
+	args, err := s.decodeCommitBatchArgs(tx.Data())
+	if err != nil {
+		return fmt.Errorf("failed to decode commit batch calldata, err: %w", err)
+	}
+
+	// the parent batch header has the same prefix in all codec versions:
+	// version (1 byte), batch index (8 bytes), l1 message popped (8 bytes), total l1 message popped (8 bytes), ...
+	if len(args.ParentBatchHeader) < 25 {
+		return fmt.Errorf("invalid parent batch header length: %v", len(args.ParentBatchHeader))
+	}
+	totalL1MessagePoppedBefore := binary.BigEndian.Uint64(args.ParentBatchHeader[17:25])
+
+	codecVersion := encoding.CodecVersion(args.Version)
+	var blob *kzg4844.Blob
+	if codecVersion != encoding.CodecV0 {
+		if blob, err = s.getBatchBlob(tx, vLog); err != nil {
+			return err
+		}
+	}
+
+	blocks, err := decodeBatchBlocks(codecVersion, args.Chunks, blob)
+	if err != nil {
+		return fmt.Errorf("failed to decode batch blocks, version: %v, err: %w", codecVersion, err)
+	}
+
+	return s.insertDerivedBlocks(blocks, totalL1MessagePoppedBefore, args.SkippedL1MessageBitmap)
+}
+
+// getBatchBlob fetches the blob that carries the L2 transactions of a codecv1+ batch.
+func (s *RollupSyncService) getBatchBlob(tx *types.Transaction, vLog *types.Log) (*kzg4844.Blob, error) {
+	if s.config.BlobClient == nil {
+		return nil, errors.New("no blob client configured, cannot derive blocks from blob batches")
+	}
+
+	blobVersionedHashes := tx.BlobHashes()
+	if len(blobVersionedHashes) != 1 {
+		return nil, fmt.Errorf("expected exactly one blob in commit batch transaction, got: %v, tx hash: %v", len(blobVersionedHashes), tx.Hash().Hex())
+	}
+
+	header, err := s.client.client.HeaderByNumber(s.ctx, new(big.Int).SetUint64(vLog.BlockNumber))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get L1 header, block number: %v, err: %w", vLog.BlockNumber, err)
+	}
+
+	blob, err := s.config.BlobClient.GetBlobByVersionedHashAndBlockTime(s.ctx, blobVersionedHashes[0], header.Time)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get blob, versioned hash: %v, err: %w", blobVersionedHashes[0].Hex(), err)
+	}
+	return blob, nil
+}
+
+// verifyBatchBlob checks the L2 transactions carried by the blob of a committed batch against the local chain.
+// Blocks that are not synced yet are skipped, the whole batch is validated again once it is finalized.
+func (s *RollupSyncService) verifyBatchBlob(batchIndex uint64, tx *types.Transaction, vLog *types.Log, chunkBlockRanges []*rawdb.ChunkBlockRange) error {
+	args, err := s.decodeCommitBatchArgs(tx.Data())
+	if err != nil {
+		return fmt.Errorf("failed to decode commit batch calldata, err: %w", err)
+	}
+
+	blob, err := s.getBatchBlob(tx, vLog)
+	if err != nil {
+		return err
+	}
+
+	codecVersion := encoding.CodecVersion(args.Version)
+	blocks, err := decodeBatchBlocks(codecVersion, args.Chunks, blob)
+	if err != nil {
+		return fmt.Errorf("failed to decode batch blocks, version: %v, err: %w", codecVersion, err)
+	}
+
+	for _, b := range blocks {
+		local := s.bc.GetBlockByNumber(b.context.BlockNumber)
+		if local == nil {
+			continue
+		}
+		var localL2Txs types.Transactions
+		for _, localTx := range local.Transactions() {
+			if !localTx.IsL1MessageTx() {
+				localL2Txs = append(localL2Txs, localTx)
+			}
+		}
+		if types.DeriveSha(localL2Txs, trie.NewStackTrie(nil)) != types.DeriveSha(b.l2Txs, trie.NewStackTrie(nil)) {
+			return s.handleValidationFailure(&rawdb.BatchValidationFailure{
+				BatchIndex:       batchIndex,
+				CodecVersion:     args.Version,
+				ChunkBlockRanges: chunkBlockRanges,
+				Reason:           fmt.Sprintf("L2 transactions of block %v do not match the committed blob", b.context.BlockNumber),
+			})
+		}
+	}
+	return nil
+}
+
+// insertDerivedBlocks completes the derived blocks with their L1 messages and writes them to the local chain.
+func (s *RollupSyncService) insertDerivedBlocks(blocks []*derivedBlock, totalL1MessagePoppedBefore uint64, skippedL1MessageBitmap []byte) error {
+	nextQueueIndex := totalL1MessagePoppedBefore
+	for _, b := range blocks {
+		var txs types.Transactions
+		for queueIndex := nextQueueIndex; queueIndex < nextQueueIndex+uint64(b.context.NumL1Messages); queueIndex++ {
+			skipped, err := isL1MessageSkipped(skippedL1MessageBitmap, queueIndex-totalL1MessagePoppedBefore)
+			if err != nil {
+				return err
+			}
+			if skipped {
+				continue
+			}
+			msg := rawdb.ReadL1Message(s.db, queueIndex)
+			if msg == nil {
+				return fmt.Errorf("L1 message not found, queue index: %v, L1 message sync is probably behind", queueIndex)
+			}
+			txs = append(txs, types.NewTx(msg))
+		}
+		nextQueueIndex += uint64(b.context.NumL1Messages)
+		txs = append(txs, b.l2Txs...)
+
+		parent := s.bc.CurrentBlock()
+		if b.context.BlockNumber <= parent.NumberU64() {
+			log.Trace("Skipping derived block that already exists", "number", b.context.BlockNumber)
+			continue
+		}
+		if b.context.BlockNumber != parent.NumberU64()+1 {
+			return fmt.Errorf("derived block is not contiguous with local chain, block number: %v, local head: %v", b.context.BlockNumber, parent.NumberU64())
+		}
+
+		header := &types.Header{
+			ParentHash: parent.Hash(),
+			Number:     new(big.Int).SetUint64(b.context.BlockNumber),
+			Time:       b.context.Timestamp,
+			GasLimit:   b.context.GasLimit,
+			Difficulty: common.Big1,
+		}
+		if s.bc.Config().IsCurie(header.Number) {
+			header.BaseFee = b.context.BaseFee
+		}
+
+		block, _, err := s.bc.BuildAndWriteBlock(parent, header, txs)
+		if err != nil {
+			return fmt.Errorf("failed to insert derived block %v: %w", b.context.BlockNumber, err)
+		}
+		// skipped messages at the end of the block are not covered by the queue index tracked by the chain
+		rawdb.WriteFirstQueueIndexNotInL2Block(s.db, block.Hash(), nextQueueIndex)
+
+		log.Debug("Inserted derived block", "number", block.Number(), "hash", block.Hash(), "txs", len(txs), "gas", block.GasUsed(), "root", block.Root())
+	}
+	return nil
+}
+
+// isL1MessageSkipped reports whether the L1 message at the given offset from the first
+// queue index of the batch is marked in the skipped L1 message bitmap.
+func isL1MessageSkipped(skippedL1MessageBitmap []byte, offset uint64) (bool, error) {
+	quo, rem := offset/256, offset%256
+	if uint64(len(skippedL1MessageBitmap)) < (quo+1)*32 {
+		return false, fmt.Errorf("skipped L1 message bitmap is too short, length: %v, offset: %v", len(skippedL1MessageBitmap), offset)
+	}
+	// each 256-bit bitmap is encoded in big-endian order
+	word := skippedL1MessageBitmap[quo*32 : (quo+1)*32]
+	return (word[31-rem/8]>>(rem%8))&1 == 1, nil
+}
+
+// decodeBatchBlocks decodes the blocks of a batch from its encoded chunks and, for codecv1+, its blob.
+func decodeBatchBlocks(codecVersion encoding.CodecVersion, chunks [][]byte, blob *kzg4844.Blob) ([]*derivedBlock, error) {
+	var chunkTxsBytes [][]byte
+	if codecVersion != encoding.CodecV0 {
+		if blob == nil {
+			return nil, fmt.Errorf("missing blob for codec version %v", codecVersion)
+		}
+		var err error
+		if chunkTxsBytes, err = decodeBlobPayload(codecVersion, blob); err != nil {
+			return nil, fmt.Errorf("failed to decode blob payload: %w", err)
+		}
+		if len(chunkTxsBytes) != len(chunks) {
+			return nil, fmt.Errorf("number of chunks in blob does not match calldata, blob: %v, calldata: %v", len(chunkTxsBytes), len(chunks))
+		}
+	}
+
+	var blocks []*derivedBlock
+	for i, chunk := range chunks {
+		if len(chunk) < 1 {
+			return nil, fmt.Errorf("invalid chunk, length is less than 1")
+		}
+		numBlocks := int(chunk[0])
+		contextsEnd := 1 + numBlocks*blockContextByteSize
+		if len(chunk) < contextsEnd || (codecVersion != encoding.CodecV0 && len(chunk) != contextsEnd) {
+			return nil, fmt.Errorf("invalid chunk byte length, number of blocks: %v, got: %v", numBlocks, len(chunk))
+		}
+
+		var txs types.Transactions
+		var err error
+		if codecVersion == encoding.CodecV0 {
+			txs, err = decodeLengthPrefixedTxs(chunk[contextsEnd:])
+		} else {
+			txs, err = decodeConcatenatedTxs(chunkTxsBytes[i])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode transactions of chunk %v: %w", i, err)
+		}
+
+		for j := 0; j < numBlocks; j++ {
+			blockContext := &codecv0.DABlock{}
+			if err := blockContext.Decode(chunk[1+j*blockContextByteSize : 1+(j+1)*blockContextByteSize]); err != nil {
+				return nil, err
+			}
+			if blockContext.NumTransactions < blockContext.NumL1Messages {
+				return nil, fmt.Errorf("invalid block context, block number: %v, number of transactions: %v, number of L1 messages: %v", blockContext.BlockNumber, blockContext.NumTransactions, blockContext.NumL1Messages)
+			}
+			numL2Txs := int(blockContext.NumTransactions - blockContext.NumL1Messages)
+			if numL2Txs > len(txs) {
+				return nil, fmt.Errorf("not enough transactions in chunk %v for block %v, expected: %v, remaining: %v", i, blockContext.BlockNumber, numL2Txs, len(txs))
+			}
+			blocks = append(blocks, &derivedBlock{context: blockContext, l2Txs: txs[:numL2Txs]})
+			txs = txs[numL2Txs:]
+		}
+		if len(txs) != 0 {
+			return nil, fmt.Errorf("unexpected %v trailing transactions in chunk %v", len(txs), i)
+		}
+	}
+	return blocks, nil
+}
+
+// decodeBlobPayload extracts the L2 transaction bytes of each chunk from a batch blob.
+func decodeBlobPayload(codecVersion encoding.CodecVersion, blob *kzg4844.Blob) ([][]byte, error) {
+	// strip the leading zero byte of each field element
+	blobBytes := make([]byte, 0, blobElementCount*(blobElementByteSize-1))
+	for i := 0; i < blobElementCount; i++ {
+		blobBytes = append(blobBytes, blob[i*blobElementByteSize+1:(i+1)*blobElementByteSize]...)
+	}
+
+	var batchBytes []byte
+	var maxNumChunks int
+	var err error
+	switch codecVersion {
+	case encoding.CodecV1:
+		maxNumChunks = codecv1.MaxNumChunks
+		batchBytes = blobBytes
+	case encoding.CodecV2, encoding.CodecV3:
+		maxNumChunks = codecv2.MaxNumChunks
+		if batchBytes, err = decompressScrollBlobToBatch(blobBytes); err != nil {
+			return nil, err
+		}
+	case encoding.CodecV4:
+		// codecv4 prefixes the payload with a flag that indicates whether it is compressed
+		maxNumChunks = codecv4.MaxNumChunks
+		switch blobBytes[0] {
+		case 0:
+			batchBytes = blobBytes[1:]
+		case 1:
+			if batchBytes, err = decompressScrollBlobToBatch(blobBytes[1:]); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("invalid compression flag: %v", blobBytes[0])
+		}
+	default:
+		return nil, fmt.Errorf("unexpected batch version %v", codecVersion)
+	}
+
+	// metadata consists of num_chunks (2 bytes) and chunki_size (4 bytes per chunk)
+	metadataLength := 2 + maxNumChunks*4
+	if len(batchBytes) < metadataLength {
+		return nil, fmt.Errorf("batch payload is too short, length: %v, metadata length: %v", len(batchBytes), metadataLength)
+	}
+	numChunks := int(binary.BigEndian.Uint16(batchBytes[0:2]))
+	if numChunks > maxNumChunks {
+		return nil, fmt.Errorf("number of chunks exceeds maximum, got: %v, max: %v", numChunks, maxNumChunks)
+	}
+
+	chunks := make([][]byte, numChunks)
+	offset := metadataLength
+	for i := 0; i < numChunks; i++ {
+		chunkSize := int(binary.BigEndian.Uint32(batchBytes[2+4*i:]))
+		if offset+chunkSize > len(batchBytes) {
+			return nil, fmt.Errorf("chunk %v exceeds batch payload, offset: %v, size: %v, payload length: %v", i, offset, chunkSize, len(batchBytes))
+		}
+		chunks[i] = batchBytes[offset : offset+chunkSize]
+		offset += chunkSize
+	}
+	return chunks, nil
+}
+
+// decodeLengthPrefixedTxs decodes transactions that are each prefixed with their 4-byte length, as used by codecv0 chunks.
+func decodeLengthPrefixedTxs(data []byte) (types.Transactions, error) {
+	var txs types.Transactions
+	for len(data) > 0 {
+		if len(data) < 4 {
+			return nil, fmt.Errorf("invalid transaction length prefix, remaining bytes: %v", len(data))
+		}
+		txLen := int(binary.BigEndian.Uint32(data[:4]))
+		if len(data) < 4+txLen {
+			return nil, fmt.Errorf("transaction exceeds chunk, length: %v, remaining bytes: %v", txLen, len(data)-4)
+		}
+		tx := new(types.Transaction)
+		if err := tx.UnmarshalBinary(data[4 : 4+txLen]); err != nil {
+			return nil, err
+		}
+		txs = append(txs, tx)
+		data = data[4+txLen:]
+	}
+	return txs, nil
+}
+
+// decodeConcatenatedTxs decodes back-to-back encoded transactions, as used by blob payloads.
+// Transaction boundaries are determined from the RLP structure of each transaction.
+func decodeConcatenatedTxs(data []byte) (types.Transactions, error) {
+	var txs types.Transactions
+	for len(data) > 0 {
+		// legacy transactions are RLP lists, typed transactions are a type byte followed by an RLP list
+		var envelope []byte
+		if data[0] >= 0xc0 {
+			envelope = data
+		} else {
+			envelope = data[1:]
+		}
+		_, _, rest, err := rlp.Split(envelope)
+		if err != nil {
+			return nil, err
+		}
+		txLen := len(data) - len(rest)
+
+		tx := new(types.Transaction)
+		if err := tx.UnmarshalBinary(data[:txLen]); err != nil {
+			return nil, err
+		}
+		txs = append(txs, tx)
+		data = rest
+	}
+	return txs, nil
+}
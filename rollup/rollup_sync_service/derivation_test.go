@@ -0,0 +1,420 @@
+package rollup_sync_service
+
+import (
+	"context"
+	"crypto/sha256"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/holiman/uint256"
+	"github.com/scroll-tech/da-codec/encoding"
+	"github.com/scroll-tech/da-codec/encoding/codecv0"
+	"github.com/scroll-tech/da-codec/encoding/codecv1"
+	"github.com/scroll-tech/da-codec/encoding/codecv2"
+	"github.com/scroll-tech/da-codec/encoding/codecv3"
+	"github.com/scroll-tech/da-codec/encoding/codecv4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/scroll-tech/go-ethereum/accounts/abi/bind/backends"
+	"github.com/scroll-tech/go-ethereum/common"
+	"github.com/scroll-tech/go-ethereum/consensus/ethash"
+	"github.com/scroll-tech/go-ethereum/core"
+	"github.com/scroll-tech/go-ethereum/core/rawdb"
+	"github.com/scroll-tech/go-ethereum/core/types"
+	"github.com/scroll-tech/go-ethereum/core/vm"
+	"github.com/scroll-tech/go-ethereum/crypto"
+	"github.com/scroll-tech/go-ethereum/crypto/kzg4844"
+	"github.com/scroll-tech/go-ethereum/node"
+	"github.com/scroll-tech/go-ethereum/params"
+	"github.com/scroll-tech/go-ethereum/rlp"
+)
+
+var (
+	testL2Key, _     = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	testL2Address    = crypto.PubkeyToAddress(testL2Key.PublicKey)
+	testL1Key, _     = crypto.HexToECDSA("8a1f9a8f95be41cd7ccb6168179afb4504aefe388d1e14474d32c45c72ce7b7a")
+	testL1Address    = crypto.PubkeyToAddress(testL1Key.PublicKey)
+	testL1MsgSender  = common.HexToAddress("0x0000000000000000000000000000000000001000")
+	testL2Recipient  = common.HexToAddress("0x0000000000000000000000000000000000002000")
+	testL1Messages   = []types.L1MessageTx{testL1Message(0), testL1Message(1), testL1Message(2), testL1Message(3)}
+	testL2Allocation = core.GenesisAlloc{testL2Address: {Balance: big.NewInt(params.Ether)}}
+)
+
+func testL1Message(queueIndex uint64) types.L1MessageTx {
+	return types.L1MessageTx{
+		QueueIndex: queueIndex,
+		Gas:        100000,
+		To:         &testL2Recipient,
+		Value:      big.NewInt(0),
+		Data:       []byte{byte(queueIndex)},
+		Sender:     testL1MsgSender,
+	}
+}
+
+// generateTestL2Blocks generates 3 L2 blocks with one transfer each. Block 1 includes the
+// L1 messages 0 and 2 (message 1 is skipped), block 3 includes L1 message 3.
+func generateTestL2Blocks(t *testing.T, config *params.ChainConfig) []*types.Block {
+	db := rawdb.NewMemoryDatabase()
+	genesis := (&core.Genesis{Config: config, Alloc: testL2Allocation}).MustCommit(db)
+	signer := types.LatestSigner(config)
+
+	blocks, _ := core.GenerateChain(config, genesis, ethash.NewFaker(), db, 3, func(i int, b *core.BlockGen) {
+		switch i {
+		case 0:
+			b.AddTx(types.NewTx(&testL1Messages[0]))
+			b.AddTx(types.NewTx(&testL1Messages[2]))
+		case 2:
+			b.AddTx(types.NewTx(&testL1Messages[3]))
+		}
+		tx, err := types.SignTx(types.NewTransaction(b.TxNonce(testL2Address), testL2Recipient, big.NewInt(1), params.TxGas, b.BaseFee(), nil), signer, testL2Key)
+		require.NoError(t, err)
+		b.AddTx(tx)
+	})
+	return blocks
+}
+
+func newTestL2BlockChain(t *testing.T, config *params.ChainConfig) *core.BlockChain {
+	db := rawdb.NewMemoryDatabase()
+	(&core.Genesis{Config: config, Alloc: testL2Allocation}).MustCommit(db)
+	bc, err := core.NewBlockChain(db, nil, config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	require.NoError(t, err)
+	return bc
+}
+
+func newTestChunk(blocks []*types.Block) *encoding.Chunk {
+	chunk := &encoding.Chunk{}
+	for _, block := range blocks {
+		chunk.Blocks = append(chunk.Blocks, &encoding.Block{
+			Header:       block.Header(),
+			Transactions: encoding.TxsToTxsData(block.Transactions()),
+		})
+	}
+	return chunk
+}
+
+func l2Transactions(block *types.Block) []common.Hash {
+	var hashes []common.Hash
+	for _, tx := range block.Transactions() {
+		if !tx.IsL1MessageTx() {
+			hashes = append(hashes, tx.Hash())
+		}
+	}
+	return hashes
+}
+
+func TestDecodeBatchBlocksFromBlob(t *testing.T) {
+	blocks := generateTestL2Blocks(t, params.TestChainConfig)
+	chunks := []*encoding.Chunk{newTestChunk(blocks[:2]), newTestChunk(blocks[2:])}
+	batch := &encoding.Batch{Index: 1, Chunks: chunks}
+
+	newBlob := map[encoding.CodecVersion]func() (*kzg4844.Blob, error){
+		encoding.CodecV1: func() (*kzg4844.Blob, error) {
+			b, err := codecv1.NewDABatch(batch)
+			if err != nil {
+				return nil, err
+			}
+			return b.Blob(), nil
+		},
+		encoding.CodecV2: func() (*kzg4844.Blob, error) {
+			b, err := codecv2.NewDABatch(batch)
+			if err != nil {
+				return nil, err
+			}
+			return b.Blob(), nil
+		},
+		encoding.CodecV3: func() (*kzg4844.Blob, error) {
+			b, err := codecv3.NewDABatch(batch)
+			if err != nil {
+				return nil, err
+			}
+			return b.Blob(), nil
+		},
+		encoding.CodecV4: func() (*kzg4844.Blob, error) {
+			b, err := codecv4.NewDABatch(batch, true)
+			if err != nil {
+				return nil, err
+			}
+			return b.Blob(), nil
+		},
+	}
+
+	// chunk encoding is the same for all blob codec versions
+	var encodedChunks [][]byte
+	var totalL1MessagePopped uint64
+	for _, chunk := range chunks {
+		daChunk, err := codecv1.NewDAChunk(chunk, totalL1MessagePopped)
+		require.NoError(t, err)
+		encodedChunks = append(encodedChunks, daChunk.Encode())
+		totalL1MessagePopped += chunk.NumL1Messages(totalL1MessagePopped)
+	}
+
+	for version, f := range newBlob {
+		blob, err := f()
+		require.NoError(t, err, "codec version %v", version)
+
+		derived, err := decodeBatchBlocks(version, encodedChunks, blob)
+		require.NoError(t, err, "codec version %v", version)
+		require.Len(t, derived, len(blocks), "codec version %v", version)
+
+		for i, block := range blocks {
+			assert.Equal(t, block.NumberU64(), derived[i].context.BlockNumber)
+			assert.Equal(t, block.Time(), derived[i].context.Timestamp)
+			assert.Equal(t, block.GasLimit(), derived[i].context.GasLimit)
+			var hashes []common.Hash
+			for _, tx := range derived[i].l2Txs {
+				hashes = append(hashes, tx.Hash())
+			}
+			assert.Equal(t, l2Transactions(block), hashes, "codec version %v, block %v", version, block.NumberU64())
+		}
+		assert.Equal(t, uint16(3), derived[0].context.NumL1Messages)
+		assert.Equal(t, uint16(1), derived[2].context.NumL1Messages)
+	}
+}
+
+func TestIsL1MessageSkipped(t *testing.T) {
+	bitmap := make([]byte, 64)
+	bitmap[31] = 0x02 // offset 1
+	bitmap[32] = 0x80 // offset 511
+
+	for offset, expected := range map[uint64]bool{0: false, 1: true, 2: false, 256: false, 511: true} {
+		skipped, err := isL1MessageSkipped(bitmap, offset)
+		require.NoError(t, err)
+		assert.Equal(t, expected, skipped, "offset %v", offset)
+	}
+
+	_, err := isL1MessageSkipped(bitmap, 512)
+	assert.Error(t, err)
+}
+
+// simulatedL1Client adapts a simulated backend to the sync_service.EthClient interface.
+type simulatedL1Client struct {
+	*backends.SimulatedBackend
+}
+
+func (c *simulatedL1Client) BlockNumber(ctx context.Context) (uint64, error) {
+	return c.Blockchain().CurrentBlock().NumberU64(), nil
+}
+
+func (c *simulatedL1Client) ChainID(ctx context.Context) (*big.Int, error) {
+	return c.Blockchain().Config().ChainID, nil
+}
+
+// HeaderByNumber treats the finalized and safe tags as the latest block, the simulated chain has no reorgs.
+func (c *simulatedL1Client) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	if number != nil && number.Sign() < 0 {
+		number = nil
+	}
+	return c.SimulatedBackend.HeaderByNumber(ctx, number)
+}
+
+// sendTransaction sends a transaction from testL1Address and mines it in a new block.
+func (c *simulatedL1Client) sendTransaction(t *testing.T, to *common.Address, data []byte) {
+	ctx := context.Background()
+	nonce, err := c.PendingNonceAt(ctx, testL1Address)
+	require.NoError(t, err)
+	gasPrice, err := c.SuggestGasPrice(ctx)
+	require.NoError(t, err)
+
+	var tx *types.Transaction
+	if to == nil {
+		tx = types.NewContractCreation(nonce, big.NewInt(0), 3000000, gasPrice, data)
+	} else {
+		tx = types.NewTransaction(nonce, *to, big.NewInt(0), 3000000, gasPrice, data)
+	}
+	tx, err = types.SignTx(tx, types.LatestSignerForChainID(c.Blockchain().Config().ChainID), testL1Key)
+	require.NoError(t, err)
+	require.NoError(t, c.SendTransaction(ctx, tx))
+	c.Commit()
+
+	receipt, err := c.TransactionReceipt(ctx, tx.Hash())
+	require.NoError(t, err)
+	require.Equal(t, types.ReceiptStatusSuccessful, receipt.Status)
+}
+
+// deployCommitBatchEmitter deploys a contract that emits CommitBatch(batchIndex, 0) with an
+// increasing batch index whenever it is called, standing in for the ScrollChain contract.
+func deployCommitBatchEmitter(t *testing.T, client *simulatedL1Client, eventID common.Hash) common.Address {
+	runtime := []byte{
+		0x60, 0x00, 0x54, // SLOAD(0)
+		0x60, 0x01, 0x01, // ADD 1
+		0x80, 0x60, 0x00, 0x55, // SSTORE(0, batchIndex)
+		0x60, 0x00, 0x90, // topic2 = 0, topic1 = batchIndex
+		0x7f, // PUSH32 topic0
+	}
+	runtime = append(runtime, eventID.Bytes()...)
+	runtime = append(runtime, 0x60, 0x00, 0x60, 0x00, 0xa3, 0x00) // LOG3(0, 0, ...), STOP
+
+	initCode := []byte{
+		0x60, byte(len(runtime)), 0x80, // size
+		0x60, 0x0b, 0x60, 0x00, 0x39, // CODECOPY(0, 11, size)
+		0x60, 0x00, 0xf3, // RETURN(0, size)
+	}
+	initCode = append(initCode, runtime...)
+
+	nonce, err := client.PendingNonceAt(context.Background(), testL1Address)
+	require.NoError(t, err)
+	client.sendTransaction(t, nil, initCode)
+	return crypto.CreateAddress(testL1Address, nonce)
+}
+
+func TestDeriveBlocksFromSimulatedL1(t *testing.T) {
+	l2Config := *params.TestChainConfig
+	blocks := generateTestL2Blocks(t, &l2Config)
+
+	// build two codecv0 batches: [block 1, block 2] and [block 3]
+	batch1 := &encoding.Batch{Index: 1, Chunks: []*encoding.Chunk{newTestChunk(blocks[:2])}}
+	daBatch1, err := codecv0.NewDABatch(batch1)
+	require.NoError(t, err)
+	batch2 := &encoding.Batch{Index: 2, TotalL1MessagePoppedBefore: daBatch1.TotalL1MessagePopped, ParentBatchHash: daBatch1.Hash(), Chunks: []*encoding.Chunk{newTestChunk(blocks[2:])}}
+
+	scrollChainABI, err := scrollChainMetaData.GetAbi()
+	require.NoError(t, err)
+	commitBatchCalldata := func(batch *encoding.Batch, parentBatchHeader []byte) []byte {
+		var chunks [][]byte
+		totalL1MessagePopped := batch.TotalL1MessagePoppedBefore
+		for _, chunk := range batch.Chunks {
+			daChunk, err := codecv0.NewDAChunk(chunk, totalL1MessagePopped)
+			require.NoError(t, err)
+			encoded, err := daChunk.Encode()
+			require.NoError(t, err)
+			chunks = append(chunks, encoded)
+			totalL1MessagePopped += chunk.NumL1Messages(totalL1MessagePopped)
+		}
+		bitmap, _, err := encoding.ConstructSkippedBitmap(batch.Index, batch.Chunks, batch.TotalL1MessagePoppedBefore)
+		require.NoError(t, err)
+		data, err := scrollChainABI.Pack("commitBatch", uint8(encoding.CodecV0), parentBatchHeader, chunks, bitmap)
+		require.NoError(t, err)
+		return data
+	}
+
+	// commit both batches on the simulated L1
+	l1 := &simulatedL1Client{backends.NewSimulatedBackend(core.GenesisAlloc{testL1Address: {Balance: big.NewInt(params.Ether)}}, 10000000)}
+	defer l1.Close()
+	scrollChainAddress := deployCommitBatchEmitter(t, l1, scrollChainABI.Events["CommitBatch"].ID)
+	l1.sendTransaction(t, &scrollChainAddress, commitBatchCalldata(batch1, make([]byte, 89)))
+	l1.sendTransaction(t, &scrollChainAddress, commitBatchCalldata(batch2, daBatch1.Encode()))
+
+	// the follower only knows the genesis block and the L1 messages synced by SyncService
+	bc := newTestL2BlockChain(t, &l2Config)
+	defer bc.Stop()
+	db := bc.Database()
+	rawdb.WriteL1Messages(db, testL1Messages)
+
+	genesisConfig := l2Config
+	l1Config := *l2Config.Scroll.L1Config
+	l1Config.L1ChainId = l1.Blockchain().Config().ChainID.Uint64()
+	l1Config.ScrollChainAddress = scrollChainAddress
+	genesisConfig.Scroll.L1Config = &l1Config
+
+	stack, err := node.New(&node.DefaultConfig)
+	require.NoError(t, err)
+	defer stack.Close()
+	service, err := NewRollupSyncService(context.Background(), &genesisConfig, db, l1, bc, stack, Config{ValidationFailurePolicy: HaltOnFailure, DeriveBlocks: true})
+	require.NoError(t, err)
+
+	service.fetchRollupEvents()
+
+	require.Equal(t, uint64(3), bc.CurrentBlock().NumberU64())
+	for _, expected := range blocks {
+		derived := bc.GetBlockByNumber(expected.NumberU64())
+		require.NotNil(t, derived)
+		assert.Equal(t, expected.Root(), derived.Root(), "block %v", expected.NumberU64())
+		assert.Equal(t, expected.GasUsed(), derived.GasUsed(), "block %v", expected.NumberU64())
+		assert.Equal(t, expected.TxHash(), derived.TxHash(), "block %v", expected.NumberU64())
+		assert.Equal(t, expected.ReceiptHash(), derived.ReceiptHash(), "block %v", expected.NumberU64())
+	}
+	assert.Equal(t, uint64(3), *rawdb.ReadFirstQueueIndexNotInL2Block(db, bc.GetBlockByNumber(1).Hash()))
+	assert.Equal(t, uint64(4), *rawdb.ReadFirstQueueIndexNotInL2Block(db, bc.CurrentBlock().Hash()))
+
+	committed := rawdb.ReadCommittedL2BlockNumber(db)
+	require.NotNil(t, committed)
+	assert.Equal(t, uint64(3), *committed)
+	assert.NotNil(t, rawdb.ReadCommittedBatchMeta(db, 2))
+}
+
+func TestVerifyBatchBlob(t *testing.T) {
+	l2Config := *params.TestChainConfig
+	blocks := generateTestL2Blocks(t, &l2Config)
+
+	bc := newTestL2BlockChain(t, &l2Config)
+	defer bc.Stop()
+	db := bc.Database()
+	rawdb.WriteL1Messages(db, testL1Messages)
+	_, err := bc.InsertChain(blocks)
+	require.NoError(t, err)
+
+	scrollChainABI, err := scrollChainMetaData.GetAbi()
+	require.NoError(t, err)
+	blobDir := t.TempDir()
+
+	// commitBatchTx stores the blob of a codecv1 batch with the given chunk in the blob directory
+	// and returns the RLP of the blob transaction that commits it
+	commitBatchTx := func(chunk *encoding.Chunk) []byte {
+		batch := &encoding.Batch{Index: 1, Chunks: []*encoding.Chunk{chunk}}
+		daBatch, err := codecv1.NewDABatch(batch)
+		require.NoError(t, err)
+		blob := daBatch.Blob()
+		commitment, err := kzg4844.BlobToCommitment(blob)
+		require.NoError(t, err)
+		versionedHash := common.Hash(kzg4844.CalcBlobHashV1(sha256.New(), &commitment))
+		require.NoError(t, os.WriteFile(filepath.Join(blobDir, versionedHash.Hex()), blob[:], 0o644))
+
+		daChunk, err := codecv1.NewDAChunk(chunk, 0)
+		require.NoError(t, err)
+		bitmap, _, err := encoding.ConstructSkippedBitmap(batch.Index, batch.Chunks, 0)
+		require.NoError(t, err)
+		data, err := scrollChainABI.Pack("commitBatch", uint8(encoding.CodecV1), make([]byte, 89), [][]byte{daChunk.Encode()}, bitmap)
+		require.NoError(t, err)
+
+		tx := types.NewTx(&types.BlobTx{
+			ChainID:    uint256.NewInt(1),
+			GasTipCap:  uint256.NewInt(1),
+			GasFeeCap:  uint256.NewInt(1),
+			Gas:        1000000,
+			Value:      uint256.NewInt(0),
+			Data:       data,
+			BlobFeeCap: uint256.NewInt(1),
+			BlobHashes: []common.Hash{versionedHash},
+			V:          uint256.NewInt(0),
+			R:          uint256.NewInt(0),
+			S:          uint256.NewInt(0),
+		})
+		txRLP, err := rlp.EncodeToBytes(tx)
+		require.NoError(t, err)
+		return txRLP
+	}
+
+	genesisConfig := l2Config
+	l1Config := *l2Config.Scroll.L1Config
+	l1Config.L1ChainId = 11155111
+	l1Config.ScrollChainAddress = common.HexToAddress("0x2D567EcE699Eabe5afCd141eDB7A4f2D0D6ce8a0")
+	genesisConfig.Scroll.L1Config = &l1Config
+
+	stack, err := node.New(&node.DefaultConfig)
+	require.NoError(t, err)
+	defer stack.Close()
+	l1Client := &mockEthClient{}
+	config := Config{ValidationFailurePolicy: DivergeOnFailure, BlobClient: NewBlobDirectoryClient(blobDir)}
+	service, err := NewRollupSyncService(context.Background(), &genesisConfig, db, l1Client, bc, stack, config)
+	require.NoError(t, err)
+
+	// the blob matches the local blocks
+	l1Client.txRLP = commitBatchTx(newTestChunk(blocks))
+	_, ranges, err := service.getCommittedBatchMeta(1, &types.Log{})
+	require.NoError(t, err)
+	assert.Equal(t, []*rawdb.ChunkBlockRange{{StartBlockNumber: 1, EndBlockNumber: 3}}, ranges)
+	assert.False(t, service.Diverged())
+
+	// the blob carries a different L2 transaction in block 2
+	tx, err := types.SignTx(types.NewTransaction(1, testL2Recipient, big.NewInt(2), params.TxGas, blocks[1].BaseFee(), nil), types.LatestSigner(&l2Config), testL2Key)
+	require.NoError(t, err)
+	chunk := newTestChunk(blocks)
+	chunk.Blocks[1].Transactions = encoding.TxsToTxsData(types.Transactions{tx})
+	l1Client.txRLP = commitBatchTx(chunk)
+	_, _, err = service.getCommittedBatchMeta(1, &types.Log{})
+	assert.ErrorContains(t, err, "L2 transactions of block 2 do not match the committed blob")
+	assert.True(t, service.Diverged())
+}
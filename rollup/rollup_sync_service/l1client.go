@@ -56,29 +56,34 @@ func newL1Client(ctx context.Context, l1Client sync_service.EthClient, l1ChainId
 }
 
 // fetcRollupEventsInRange retrieves and parses commit/revert/finalize rollup events between block numbers: [from, to].
-func (c *L1Client) fetchRollupEventsInRange(from, to uint64) ([]types.Log, error) {
+func (c *L1Client) fetchRollupEventsInRange(ctx context.Context, from, to uint64) ([]types.Log, error) {
 	log.Trace("L1Client fetchRollupEventsInRange", "fromBlock", from, "toBlock", to)
 
-	query := ethereum.FilterQuery{
-		FromBlock: big.NewInt(int64(from)), // inclusive
-		ToBlock:   big.NewInt(int64(to)),   // inclusive
-		Addresses: []common.Address{
-			c.scrollChainAddress,
-		},
-		Topics: make([][]common.Hash, 1),
-	}
-	query.Topics[0] = make([]common.Hash, 3)
-	query.Topics[0][0] = c.l1CommitBatchEventSignature
-	query.Topics[0][1] = c.l1RevertBatchEventSignature
-	query.Topics[0][2] = c.l1FinalizeBatchEventSignature
+	query := c.rollupEventsQuery()
+	query.FromBlock = big.NewInt(int64(from)) // inclusive
+	query.ToBlock = big.NewInt(int64(to))     // inclusive
 
-	logs, err := c.client.FilterLogs(c.ctx, query)
+	logs, err := c.client.FilterLogs(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to filter logs, err: %w", err)
 	}
 	return logs, nil
 }
 
+// rollupEventsQuery returns the filter query matching all commit/revert/finalize rollup events.
+func (c *L1Client) rollupEventsQuery() ethereum.FilterQuery {
+	return ethereum.FilterQuery{
+		Addresses: []common.Address{
+			c.scrollChainAddress,
+		},
+		Topics: [][]common.Hash{{
+			c.l1CommitBatchEventSignature,
+			c.l1RevertBatchEventSignature,
+			c.l1FinalizeBatchEventSignature,
+		}},
+	}
+}
+
 // getLatestFinalizedBlockNumber fetches the block number of the latest finalized block from the L1 chain.
 func (c *L1Client) getLatestFinalizedBlockNumber() (uint64, error) {
 	header, err := c.client.HeaderByNumber(c.ctx, big.NewInt(int64(rpc.FinalizedBlockNumber)))
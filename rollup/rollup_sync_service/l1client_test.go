@@ -30,7 +30,7 @@ func TestL1Client(t *testing.T) {
 	assert.NoError(t, err, "Error getting latest confirmed block number")
 	assert.Equal(t, uint64(36), blockNumber, "Unexpected block number")
 
-	logs, err := l1Client.fetchRollupEventsInRange(0, blockNumber)
+	logs, err := l1Client.fetchRollupEventsInRange(ctx, 0, blockNumber)
 	assert.NoError(t, err, "Error fetching rollup events in range")
 	assert.Empty(t, logs, "Expected no logs from fetchRollupEventsInRange")
 }
@@ -3,10 +3,12 @@ package rollup_sync_service
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math/big"
 	"os"
 	"reflect"
+	"strings"
 	"sync"
 	"time"
 
@@ -24,6 +26,7 @@ import (
 	"github.com/scroll-tech/go-ethereum/core/types"
 	"github.com/scroll-tech/go-ethereum/ethdb"
 	"github.com/scroll-tech/go-ethereum/log"
+	"github.com/scroll-tech/go-ethereum/metrics"
 	"github.com/scroll-tech/go-ethereum/node"
 	"github.com/scroll-tech/go-ethereum/params"
 
@@ -33,9 +36,6 @@ import (
 )
 
 const (
-	// defaultFetchBlockRange is the number of blocks that we collect in a single eth_getLogs query.
-	defaultFetchBlockRange = uint64(100)
-
 	// defaultSyncInterval is the frequency at which we query for new rollup event.
 	defaultSyncInterval = 60 * time.Second
 
@@ -51,11 +51,55 @@ const (
 	defaultLogInterval = 5 * time.Minute
 )
 
+var (
+	batchValidationFailureCounter = metrics.NewRegisteredCounter("rollup/sync/validation_failure", nil)
+	divergedGauge                 = metrics.NewRegisteredGauge("rollup/sync/diverged", nil)
+)
+
+// ValidationFailurePolicy determines how RollupSyncService reacts to a batch whose
+// locally computed data does not match the L1 FinalizeBatch event.
+type ValidationFailurePolicy string
+
+const (
+	// HaltOnFailure shuts down the node.
+	HaltOnFailure ValidationFailurePolicy = "halt"
+
+	// DivergeOnFailure keeps the node running in a diverged state, no further batches are finalized.
+	DivergeOnFailure ValidationFailurePolicy = "diverge"
+
+	// RollbackOnFailure rewinds the local chain to the last valid finalized batch and validates again
+	// once the chain is resynced. If the same batch fails again, the node enters the diverged state.
+	RollbackOnFailure ValidationFailurePolicy = "rollback"
+)
+
+// ParseValidationFailurePolicy parses a ValidationFailurePolicy from its string representation.
+func ParseValidationFailurePolicy(policy string) (ValidationFailurePolicy, error) {
+	switch p := ValidationFailurePolicy(policy); p {
+	case HaltOnFailure, DivergeOnFailure, RollbackOnFailure:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown batch validation failure policy: %q, expected one of %q, %q, %q", policy, HaltOnFailure, DivergeOnFailure, RollbackOnFailure)
+	}
+}
+
+// Config contains the configuration of RollupSyncService.
+type Config struct {
+	ValidationFailurePolicy ValidationFailurePolicy // How to react to batches that fail validation
+	DeriveBlocks            bool                    // Rebuild L2 blocks from the batch data committed on L1 instead of relying on block sync from peers
+	BlobClient              BlobClient              // Source of EIP-4844 blobs, required to derive blocks from codecv1+ batches and used to check their data otherwise
+}
+
+// DefaultConfig contains the default configuration of RollupSyncService.
+var DefaultConfig = Config{
+	ValidationFailurePolicy: HaltOnFailure,
+}
+
 // RollupSyncService collects ScrollChain batch commit/revert/finalize events and stores metadata into db.
 type RollupSyncService struct {
 	ctx                           context.Context
 	cancel                        context.CancelFunc
 	client                        *L1Client
+	fetcher                       *sync_service.RangeFetcher
 	db                            ethdb.Database
 	latestProcessedBlock          uint64
 	scrollChainABI                *abi.ABI
@@ -64,10 +108,14 @@ type RollupSyncService struct {
 	l1FinalizeBatchEventSignature common.Hash
 	bc                            *core.BlockChain
 	stack                         *node.Node
+	config                        Config
 	stateMu                       sync.Mutex
+
+	diverged             bool    // set after a validation failure, no further batches are finalized, persisted across restarts
+	rolledBackBatchIndex *uint64 // batch index that triggered the last rollback, persisted across restarts
 }
 
-func NewRollupSyncService(ctx context.Context, genesisConfig *params.ChainConfig, db ethdb.Database, l1Client sync_service.EthClient, bc *core.BlockChain, stack *node.Node) (*RollupSyncService, error) {
+func NewRollupSyncService(ctx context.Context, genesisConfig *params.ChainConfig, db ethdb.Database, l1Client sync_service.EthClient, bc *core.BlockChain, stack *node.Node, config Config) (*RollupSyncService, error) {
 	// terminate if the caller does not provide an L1 client (e.g. in tests)
 	if l1Client == nil || (reflect.ValueOf(l1Client).Kind() == reflect.Ptr && reflect.ValueOf(l1Client).IsNil()) {
 		log.Warn("No L1 client provided, L1 rollup sync service will not run")
@@ -88,6 +136,10 @@ func NewRollupSyncService(ctx context.Context, genesisConfig *params.ChainConfig
 		return nil, fmt.Errorf("failed to initialize l1 client: %w", err)
 	}
 
+	if config.ValidationFailurePolicy == "" {
+		config.ValidationFailurePolicy = DefaultConfig.ValidationFailurePolicy
+	}
+
 	// Initialize the latestProcessedBlock with the block just before the L1 deployment block.
 	// This serves as a default value when there's no L1 rollup events synced in the database.
 	var latestProcessedBlock uint64
@@ -107,6 +159,7 @@ func NewRollupSyncService(ctx context.Context, genesisConfig *params.ChainConfig
 		ctx:                           ctx,
 		cancel:                        cancel,
 		client:                        client,
+		fetcher:                       sync_service.NewRangeFetcher(sync_service.FetchConfigFromNodeConfig(stack.Config())),
 		db:                            db,
 		latestProcessedBlock:          latestProcessedBlock,
 		scrollChainABI:                scrollChainABI,
@@ -115,11 +168,24 @@ func NewRollupSyncService(ctx context.Context, genesisConfig *params.ChainConfig
 		l1FinalizeBatchEventSignature: scrollChainABI.Events["FinalizeBatch"].ID,
 		bc:                            bc,
 		stack:                         stack,
+		config:                        config,
 	}
+	service.restoreValidationState()
 
 	return &service, nil
 }
 
+// restoreValidationState loads the diverged state and the last rollback of a previous run from the database,
+// so that a restart neither resumes finalizing on a diverged chain nor rolls back the same batch twice.
+func (s *RollupSyncService) restoreValidationState() {
+	if batchIndex := rawdb.ReadDivergedBatchIndex(s.db); batchIndex != nil {
+		log.Error("Local chain diverged from L1 in a previous run, batch finalization stopped", "batch index", *batchIndex)
+		s.diverged = true
+		divergedGauge.Update(1)
+	}
+	s.rolledBackBatchIndex = rawdb.ReadRolledBackBatchIndex(s.db)
+}
+
 func (s *RollupSyncService) Start() {
 	if s == nil {
 		return
@@ -127,6 +193,9 @@ func (s *RollupSyncService) Start() {
 
 	log.Info("Starting rollup event sync background service", "latest processed block", s.latestProcessedBlock)
 
+	// new L1 heads and rollup events trigger a sync immediately, polling is the fallback
+	updates := sync_service.WatchL1(s.ctx, s.client.client, s.client.rollupEventsQuery(), sync_service.DefaultResubscribeInterval)
+
 	go func() {
 		syncTicker := time.NewTicker(defaultSyncInterval)
 		defer syncTicker.Stop()
@@ -140,6 +209,8 @@ func (s *RollupSyncService) Start() {
 				return
 			case <-syncTicker.C:
 				s.fetchRollupEvents()
+			case <-updates:
+				s.fetchRollupEvents()
 			case <-logTicker.C:
 				log.Info("Sync rollup events progress update", "latestProcessedBlock", s.latestProcessedBlock)
 			}
@@ -159,7 +230,9 @@ func (s *RollupSyncService) Stop() {
 	}
 }
 
-// ResetStartSyncHeight resets the RollupSyncService to a specific L1 block height
+// ResetStartSyncHeight resets the RollupSyncService to a specific L1 block height.
+// It also clears the diverged state and the last rolled back batch, so that the rollup
+// events are processed again and the validation failure policy starts over.
 func (s *RollupSyncService) ResetStartSyncHeight(height uint64) {
 	if s == nil {
 		return
@@ -169,13 +242,41 @@ func (s *RollupSyncService) ResetStartSyncHeight(height uint64) {
 	defer s.stateMu.Unlock()
 
 	s.latestProcessedBlock = height
+	if s.diverged {
+		log.Warn("Clearing diverged state of rollup sync service")
+		rawdb.DeleteDivergedBatchIndex(s.db)
+		s.diverged = false
+		divergedGauge.Update(0)
+	}
+	if s.rolledBackBatchIndex != nil {
+		log.Warn("Clearing rolled back batch of rollup sync service", "batch index", *s.rolledBackBatchIndex)
+		rawdb.DeleteRolledBackBatchIndex(s.db)
+		s.rolledBackBatchIndex = nil
+	}
 	log.Info("Reset sync service", "height", height)
 }
 
+// Diverged returns whether the local chain diverged from the batches finalized on L1.
+func (s *RollupSyncService) Diverged() bool {
+	if s == nil {
+		return false
+	}
+
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+
+	return s.diverged
+}
+
 func (s *RollupSyncService) fetchRollupEvents() {
 	s.stateMu.Lock()
 	defer s.stateMu.Unlock()
 
+	if s.diverged {
+		log.Warn("Local chain diverged from L1, not processing rollup events", "latest processed block", s.latestProcessedBlock)
+		return
+	}
+
 	latestConfirmed, err := s.client.getLatestFinalizedBlockNumber()
 	if err != nil {
 		log.Warn("failed to get latest confirmed block number", "err", err)
@@ -184,30 +285,25 @@ func (s *RollupSyncService) fetchRollupEvents() {
 
 	log.Trace("Sync service fetch rollup events", "latest processed block", s.latestProcessedBlock, "latest confirmed", latestConfirmed)
 
-	// query in batches
-	for from := s.latestProcessedBlock + 1; from <= latestConfirmed; from += defaultFetchBlockRange {
-		if s.ctx.Err() != nil {
-			log.Info("Context canceled", "reason", s.ctx.Err())
-			return
-		}
-
-		to := from + defaultFetchBlockRange - 1
-		if to > latestConfirmed {
-			to = latestConfirmed
-		}
+	query := func(ctx context.Context, from, to uint64) (interface{}, error) {
+		return s.client.fetchRollupEventsInRange(ctx, from, to)
+	}
 
-		logs, err := s.client.fetchRollupEventsInRange(from, to)
-		if err != nil {
-			log.Error("failed to fetch rollup events in range", "from block", from, "to block", to, "err", err)
-			return
+	// results are committed in order, even if they were queried concurrently
+	commit := func(from, to uint64, result interface{}) error {
+		if err := s.parseAndUpdateRollupEventLogs(result.([]types.Log), to); err != nil {
+			return fmt.Errorf("failed to parse and update rollup event logs, err: %w", err)
 		}
+		s.latestProcessedBlock = to
+		return nil
+	}
 
-		if err := s.parseAndUpdateRollupEventLogs(logs, to); err != nil {
-			log.Error("failed to parse and update rollup event logs", "err", err)
+	if err := s.fetcher.Fetch(s.ctx, s.latestProcessedBlock+1, latestConfirmed, query, commit); err != nil {
+		if s.ctx.Err() != nil {
+			log.Info("Context canceled", "reason", s.ctx.Err())
 			return
 		}
-
-		s.latestProcessedBlock = to
+		log.Error("failed to fetch rollup events", "latest processed block", s.latestProcessedBlock, "latest confirmed", latestConfirmed, "err", err)
 	}
 }
 
@@ -222,12 +318,21 @@ func (s *RollupSyncService) parseAndUpdateRollupEventLogs(logs []types.Log, endB
 			batchIndex := event.BatchIndex.Uint64()
 			log.Trace("found new CommitBatch event", "batch index", batchIndex)
 
+			if s.config.DeriveBlocks {
+				if err := s.deriveBlocksFromBatch(batchIndex, &vLog); err != nil {
+					return fmt.Errorf("failed to derive blocks from batch, batch index: %v, err: %w", batchIndex, err)
+				}
+			}
+
 			committedBatchMeta, chunkBlockRanges, err := s.getCommittedBatchMeta(batchIndex, &vLog)
 			if err != nil {
 				return fmt.Errorf("failed to get chunk ranges, batch index: %v, err: %w", batchIndex, err)
 			}
+			committedBatchMeta.BatchHash = event.BatchHash
 			rawdb.WriteCommittedBatchMeta(s.db, batchIndex, committedBatchMeta)
 			rawdb.WriteBatchChunkRanges(s.db, batchIndex, chunkBlockRanges)
+			rawdb.WriteL2BlockBatchIndex(s.db, batchIndex, chunkBlockRanges)
+			rawdb.WriteCommittedL2BlockNumber(s.db, chunkBlockRanges[len(chunkBlockRanges)-1].EndBlockNumber)
 
 		case s.l1RevertBatchEventSignature:
 			event := &L1RevertBatchEvent{}
@@ -237,6 +342,9 @@ func (s *RollupSyncService) parseAndUpdateRollupEventLogs(logs []types.Log, endB
 			batchIndex := event.BatchIndex.Uint64()
 			log.Trace("found new RevertBatch event", "batch index", batchIndex)
 
+			chunkBlockRanges := rawdb.ReadBatchChunkRanges(s.db, batchIndex)
+			s.resetCommittedL2BlockNumber(chunkBlockRanges)
+			rawdb.DeleteL2BlockBatchIndex(s.db, chunkBlockRanges)
 			rawdb.DeleteCommittedBatchMeta(s.db, batchIndex)
 			rawdb.DeleteBatchChunkRanges(s.db, batchIndex)
 
@@ -278,7 +386,11 @@ func (s *RollupSyncService) parseAndUpdateRollupEventLogs(logs []types.Log, endB
 					return fmt.Errorf("failed to get local node info, batch index: %v, err: %w", index, err)
 				}
 
-				endBlock, finalizedBatchMeta, err := validateBatch(index, event, parentFinalizedBatchMeta, committedBatchMeta, chunks, s.bc.Config(), s.stack)
+				endBlock, finalizedBatchMeta, err := validateBatch(index, event, parentFinalizedBatchMeta, committedBatchMeta, chunks, s.bc.Config())
+				var validationErr *batchValidationError
+				if errors.As(err, &validationErr) {
+					return s.handleValidationFailure(validationErr.failure)
+				}
 				if err != nil {
 					return fmt.Errorf("fatal: validateBatch failed: finalize event: %v, err: %w", event, err)
 				}
@@ -313,6 +425,73 @@ func (s *RollupSyncService) parseAndUpdateRollupEventLogs(logs []types.Log, endB
 	return nil
 }
 
+// handleValidationFailure records a batch validation failure and reacts to it according to the configured policy.
+// It returns an error so that the corresponding FinalizeBatch event is not marked as processed.
+func (s *RollupSyncService) handleValidationFailure(failure *rawdb.BatchValidationFailure) error {
+	failure.DetectedAt = uint64(time.Now().Unix())
+	rawdb.WriteBatchValidationFailure(s.db, failure)
+	batchValidationFailureCounter.Inc(1)
+
+	switch s.config.ValidationFailurePolicy {
+	case DivergeOnFailure:
+		s.setDiverged(failure)
+
+	case RollbackOnFailure:
+		if s.rolledBackBatchIndex != nil && *s.rolledBackBatchIndex == failure.BatchIndex {
+			log.Error("Batch validation failed again after rollback", "batch index", failure.BatchIndex)
+			s.setDiverged(failure)
+			break
+		}
+
+		// rewind to the end of the last valid finalized batch, the chain will be resynced from there
+		var lastValidBlock uint64
+		if finalized := rawdb.ReadFinalizedL2BlockNumber(s.db); finalized != nil {
+			lastValidBlock = *finalized
+		}
+		log.Warn("Rolling back local chain to the last valid finalized batch", "batch index", failure.BatchIndex, "block", lastValidBlock)
+		if err := s.bc.SetHead(lastValidBlock); err != nil {
+			return fmt.Errorf("failed to roll back local chain to block %v: %w", lastValidBlock, err)
+		}
+		batchIndex := failure.BatchIndex
+		rawdb.WriteRolledBackBatchIndex(s.db, batchIndex)
+		s.rolledBackBatchIndex = &batchIndex
+
+	default:
+		log.Error("Batch validation failed, shutting down node", "batch index", failure.BatchIndex, "reason", failure.Reason)
+		s.stack.Close()
+		os.Exit(1)
+	}
+
+	return fmt.Errorf("batch validation failed, batch index: %v, reason: %v", failure.BatchIndex, failure.Reason)
+}
+
+func (s *RollupSyncService) setDiverged(failure *rawdb.BatchValidationFailure) {
+	log.Error("Local chain diverged from L1, batch finalization stopped", "batch index", failure.BatchIndex, "reason", failure.Reason)
+	rawdb.WriteDivergedBatchIndex(s.db, failure.BatchIndex)
+	s.diverged = true
+	divergedGauge.Update(1)
+}
+
+// resetCommittedL2BlockNumber moves the highest committed L2 block number back to the block preceding a
+// reverted batch, given the chunk block ranges of that batch. It must be called before the batch is deleted.
+// Reverted batches are consecutive, so the committed block number never increases here.
+func (s *RollupSyncService) resetCommittedL2BlockNumber(revertedChunkBlockRanges []*rawdb.ChunkBlockRange) {
+	var newCommitted uint64
+	if len(revertedChunkBlockRanges) > 0 && revertedChunkBlockRanges[0].StartBlockNumber > 0 {
+		newCommitted = revertedChunkBlockRanges[0].StartBlockNumber - 1
+	} else if finalized := rawdb.ReadFinalizedL2BlockNumber(s.db); finalized != nil {
+		// the reverted batch was committed before the rollup sync service started, fall back to the finalized L2 block
+		newCommitted = *finalized
+	} else {
+		rawdb.DeleteCommittedL2BlockNumber(s.db)
+		return
+	}
+	if committed := rawdb.ReadCommittedL2BlockNumber(s.db); committed != nil && *committed < newCommitted {
+		newCommitted = *committed
+	}
+	rawdb.WriteCommittedL2BlockNumber(s.db, newCommitted)
+}
+
 func (s *RollupSyncService) getLocalChunksForBatch(batchIndex uint64) ([]*encoding.Chunk, error) {
 	chunkBlockRanges := rawdb.ReadBatchChunkRanges(s.db, batchIndex)
 	if len(chunkBlockRanges) == 0 {
@@ -375,17 +554,51 @@ func (s *RollupSyncService) getCommittedBatchMeta(batchIndex uint64, vLog *types
 		}, []*rawdb.ChunkBlockRange{{StartBlockNumber: 0, EndBlockNumber: 0}}, nil
 	}
 
+	tx, err := s.getCommitBatchTransaction(vLog)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var commitBatchMeta rawdb.CommittedBatchMeta
+
+	if tx.Type() == types.BlobTxType {
+		blobVersionedHashes := tx.BlobHashes()
+		if blobVersionedHashes == nil {
+			return nil, nil, fmt.Errorf("invalid blob transaction, blob hashes is nil, tx hash: %v", tx.Hash().Hex())
+		}
+		commitBatchMeta.BlobVersionedHashes = blobVersionedHashes
+	}
+
+	version, ranges, err := s.decodeBatchVersionAndChunkBlockRanges(tx.Data())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decode chunk block ranges, batch index: %v, err: %w", batchIndex, err)
+	}
+
+	// when blocks are derived, the blob has already been used to build them
+	if tx.Type() == types.BlobTxType && s.config.BlobClient != nil && !s.config.DeriveBlocks {
+		if err := s.verifyBatchBlob(batchIndex, tx, vLog, ranges); err != nil {
+			return nil, nil, fmt.Errorf("failed to verify batch blob, batch index: %v, err: %w", batchIndex, err)
+		}
+	}
+
+	commitBatchMeta.Version = version
+	commitBatchMeta.ChunkBlockRanges = ranges
+	return &commitBatchMeta, ranges, nil
+}
+
+// getCommitBatchTransaction fetches the L1 transaction that emitted the given CommitBatch event.
+func (s *RollupSyncService) getCommitBatchTransaction(vLog *types.Log) (*types.Transaction, error) {
 	tx, _, err := s.client.client.TransactionByHash(s.ctx, vLog.TxHash)
 	if err != nil {
 		log.Debug("failed to get transaction by hash, probably an unindexed transaction, fetching the whole block to get the transaction",
 			"tx hash", vLog.TxHash.Hex(), "block number", vLog.BlockNumber, "block hash", vLog.BlockHash.Hex(), "err", err)
 		block, err := s.client.client.BlockByHash(s.ctx, vLog.BlockHash)
 		if err != nil {
-			return nil, nil, fmt.Errorf("failed to get block by hash, block number: %v, block hash: %v, err: %w", vLog.BlockNumber, vLog.BlockHash.Hex(), err)
+			return nil, fmt.Errorf("failed to get block by hash, block number: %v, block hash: %v, err: %w", vLog.BlockNumber, vLog.BlockHash.Hex(), err)
 		}
 
 		if block == nil {
-			return nil, nil, fmt.Errorf("failed to get block by hash, block not found, block number: %v, block hash: %v", vLog.BlockNumber, vLog.BlockHash.Hex())
+			return nil, fmt.Errorf("failed to get block by hash, block not found, block number: %v, block hash: %v", vLog.BlockNumber, vLog.BlockHash.Hex())
 		}
 
 		found := false
@@ -397,66 +610,60 @@ func (s *RollupSyncService) getCommittedBatchMeta(batchIndex uint64, vLog *types
 			}
 		}
 		if !found {
-			return nil, nil, fmt.Errorf("transaction not found in the block, tx hash: %v, block number: %v, block hash: %v", vLog.TxHash.Hex(), vLog.BlockNumber, vLog.BlockHash.Hex())
+			return nil, fmt.Errorf("transaction not found in the block, tx hash: %v, block number: %v, block hash: %v", vLog.TxHash.Hex(), vLog.BlockNumber, vLog.BlockHash.Hex())
 		}
 	}
 
-	var commitBatchMeta rawdb.CommittedBatchMeta
+	return tx, nil
+}
 
-	if tx.Type() == types.BlobTxType {
-		blobVersionedHashes := tx.BlobHashes()
-		if blobVersionedHashes == nil {
-			return nil, nil, fmt.Errorf("invalid blob transaction, blob hashes is nil, tx hash: %v", tx.Hash().Hex())
-		}
-		commitBatchMeta.BlobVersionedHashes = blobVersionedHashes
+// decodeBatchVersionAndChunkBlockRanges decodes version and chunks' block ranges in a batch based on the commit batch transaction's calldata.
+func (s *RollupSyncService) decodeBatchVersionAndChunkBlockRanges(txData []byte) (uint8, []*rawdb.ChunkBlockRange, error) {
+	args, err := s.decodeCommitBatchArgs(txData)
+	if err != nil {
+		return 0, nil, err
 	}
 
-	version, ranges, err := s.decodeBatchVersionAndChunkBlockRanges(tx.Data())
+	chunkRanges, err := decodeBlockRangesFromEncodedChunks(encoding.CodecVersion(args.Version), args.Chunks)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to decode chunk block ranges, batch index: %v, err: %w", batchIndex, err)
+		return 0, nil, fmt.Errorf("failed to decode block ranges from encoded chunks, version: %v, chunks: %+v, err: %w", args.Version, args.Chunks, err)
 	}
 
-	commitBatchMeta.Version = version
-	commitBatchMeta.ChunkBlockRanges = ranges
-	return &commitBatchMeta, ranges, nil
+	return args.Version, chunkRanges, nil
 }
 
-// decodeBatchVersionAndChunkBlockRanges decodes version and chunks' block ranges in a batch based on the commit batch transaction's calldata.
-func (s *RollupSyncService) decodeBatchVersionAndChunkBlockRanges(txData []byte) (uint8, []*rawdb.ChunkBlockRange, error) {
+// commitBatchArgs contains the arguments of a commitBatch or commitBatchWithBlobProof call.
+type commitBatchArgs struct {
+	Version                uint8
+	ParentBatchHeader      []byte
+	Chunks                 [][]byte
+	SkippedL1MessageBitmap []byte
+}
+
+// decodeCommitBatchArgs decodes the calldata of a commit batch transaction.
+func (s *RollupSyncService) decodeCommitBatchArgs(txData []byte) (*commitBatchArgs, error) {
 	const methodIDLength = 4
 	if len(txData) < methodIDLength {
-		return 0, nil, fmt.Errorf("transaction data is too short, length of tx data: %v, minimum length required: %v", len(txData), methodIDLength)
+		return nil, fmt.Errorf("transaction data is too short, length of tx data: %v, minimum length required: %v", len(txData), methodIDLength)
 	}
 
 	method, err := s.scrollChainABI.MethodById(txData[:methodIDLength])
 	if err != nil {
-		return 0, nil, fmt.Errorf("failed to get method by ID, ID: %v, err: %w", txData[:methodIDLength], err)
+		return nil, fmt.Errorf("failed to get method by ID, ID: %v, err: %w", txData[:methodIDLength], err)
 	}
 
 	values, err := method.Inputs.Unpack(txData[methodIDLength:])
 	if err != nil {
-		return 0, nil, fmt.Errorf("failed to unpack transaction data using ABI, tx data: %v, err: %w", txData, err)
+		return nil, fmt.Errorf("failed to unpack transaction data using ABI, tx data: %v, err: %w", txData, err)
 	}
 
 	if method.Name == "commitBatch" {
-		type commitBatchArgs struct {
-			Version                uint8
-			ParentBatchHeader      []byte
-			Chunks                 [][]byte
-			SkippedL1MessageBitmap []byte
-		}
-
 		var args commitBatchArgs
 		if err = method.Inputs.Copy(&args, values); err != nil {
-			return 0, nil, fmt.Errorf("failed to decode calldata into commitBatch args, values: %+v, err: %w", values, err)
+			return nil, fmt.Errorf("failed to decode calldata into commitBatch args, values: %+v, err: %w", values, err)
 		}
 
-		chunkRanges, err := decodeBlockRangesFromEncodedChunks(encoding.CodecVersion(args.Version), args.Chunks)
-		if err != nil {
-			return 0, nil, fmt.Errorf("failed to decode block ranges from encoded chunks, version: %v, chunks: %+v, err: %w", args.Version, args.Chunks, err)
-		}
-
-		return args.Version, chunkRanges, nil
+		return &args, nil
 	} else if method.Name == "commitBatchWithBlobProof" {
 		type commitBatchWithBlobProofArgs struct {
 			Version                uint8
@@ -468,18 +675,18 @@ func (s *RollupSyncService) decodeBatchVersionAndChunkBlockRanges(txData []byte)
 
 		var args commitBatchWithBlobProofArgs
 		if err = method.Inputs.Copy(&args, values); err != nil {
-			return 0, nil, fmt.Errorf("failed to decode calldata into commitBatchWithBlobProofArgs args, values: %+v, err: %w", values, err)
-		}
-
-		chunkRanges, err := decodeBlockRangesFromEncodedChunks(encoding.CodecVersion(args.Version), args.Chunks)
-		if err != nil {
-			return 0, nil, fmt.Errorf("failed to decode block ranges from encoded chunks, version: %v, chunks: %+v, err: %w", args.Version, args.Chunks, err)
+			return nil, fmt.Errorf("failed to decode calldata into commitBatchWithBlobProofArgs args, values: %+v, err: %w", values, err)
 		}
 
-		return args.Version, chunkRanges, nil
+		return &commitBatchArgs{
+			Version:                args.Version,
+			ParentBatchHeader:      args.ParentBatchHeader,
+			Chunks:                 args.Chunks,
+			SkippedL1MessageBitmap: args.SkippedL1MessageBitmap,
+		}, nil
 	}
 
-	return 0, nil, fmt.Errorf("unexpected method name: %v", method.Name)
+	return nil, fmt.Errorf("unexpected method name: %v", method.Name)
 }
 
 // validateBatch verifies the consistency between the L1 contract and L2 node data.
@@ -487,7 +694,7 @@ func (s *RollupSyncService) decodeBatchVersionAndChunkBlockRanges(txData []byte)
 // 1. Recalculates the batch hash locally
 // 2. Compares local state root, local withdraw root, and locally calculated batch hash with L1 data (for the last batch only when "finalize by bundle")
 //
-// The function will terminate the node and exit if any consistency check fails.
+// The function returns a *batchValidationError if any consistency check fails.
 //
 // Parameters:
 //   - batchIndex: batch index of the validated batch
@@ -497,7 +704,6 @@ func (s *RollupSyncService) decodeBatchVersionAndChunkBlockRanges(txData []byte)
 //     Can be nil for older client versions that don't store this information.
 //   - chunks: slice of chunk data for the current batch
 //   - chainCfg: chain configuration to identify the codec version when committedBatchMeta is nil
-//   - stack: node stack to terminate the node in case of inconsistency
 //
 // Returns:
 // - uint64: the end block height of the batch
@@ -507,7 +713,7 @@ func (s *RollupSyncService) decodeBatchVersionAndChunkBlockRanges(txData []byte)
 // Note: This function is compatible with both "finalize by batch" and "finalize by bundle" methods.
 // In "finalize by bundle", only the last batch of each bundle is fully verified.
 // This check still ensures the correctness of all batch hashes in the bundle due to the parent-child relationship between batch hashes.
-func validateBatch(batchIndex uint64, event *L1FinalizeBatchEvent, parentFinalizedBatchMeta *rawdb.FinalizedBatchMeta, committedBatchMeta *rawdb.CommittedBatchMeta, chunks []*encoding.Chunk, chainCfg *params.ChainConfig, stack *node.Node) (uint64, *rawdb.FinalizedBatchMeta, error) {
+func validateBatch(batchIndex uint64, event *L1FinalizeBatchEvent, parentFinalizedBatchMeta *rawdb.FinalizedBatchMeta, committedBatchMeta *rawdb.CommittedBatchMeta, chunks []*encoding.Chunk, chainCfg *params.ChainConfig) (uint64, *rawdb.FinalizedBatchMeta, error) {
 	if len(chunks) == 0 {
 		return 0, nil, fmt.Errorf("invalid argument: length of chunks is 0, batch index: %v", batchIndex)
 	}
@@ -608,16 +814,16 @@ func validateBatch(batchIndex uint64, event *L1FinalizeBatchEvent, parentFinaliz
 	// - finalize by batch: check all batches
 	// - finalize by bundle: check the last batch, because only one event (containing the info of the last batch) is emitted per bundle
 	if batchIndex == event.BatchIndex.Uint64() {
+		var reasons []string
+
 		if localStateRoot != event.StateRoot {
 			log.Error("State root mismatch", "batch index", event.BatchIndex.Uint64(), "start block", startBlock.Header.Number.Uint64(), "end block", endBlock.Header.Number.Uint64(), "parent batch hash", parentFinalizedBatchMeta.BatchHash.Hex(), "l1 finalized state root", event.StateRoot.Hex(), "l2 state root", localStateRoot.Hex())
-			stack.Close()
-			os.Exit(1)
+			reasons = append(reasons, "state root mismatch")
 		}
 
 		if localWithdrawRoot != event.WithdrawRoot {
 			log.Error("Withdraw root mismatch", "batch index", event.BatchIndex.Uint64(), "start block", startBlock.Header.Number.Uint64(), "end block", endBlock.Header.Number.Uint64(), "parent batch hash", parentFinalizedBatchMeta.BatchHash.Hex(), "l1 finalized withdraw root", event.WithdrawRoot.Hex(), "l2 withdraw root", localWithdrawRoot.Hex())
-			stack.Close()
-			os.Exit(1)
+			reasons = append(reasons, "withdraw root mismatch")
 		}
 
 		// Verify batch hash
@@ -630,8 +836,29 @@ func validateBatch(batchIndex uint64, event *L1FinalizeBatchEvent, parentFinaliz
 				log.Error("marshal chunks failed", "err", err)
 			}
 			log.Error("Chunks", "chunks", string(chunksJson))
-			stack.Close()
-			os.Exit(1)
+			reasons = append(reasons, "batch hash mismatch")
+		}
+
+		if len(reasons) > 0 {
+			chunkBlockRanges := make([]*rawdb.ChunkBlockRange, len(chunks))
+			for i, chunk := range chunks {
+				chunkBlockRanges[i] = &rawdb.ChunkBlockRange{
+					StartBlockNumber: chunk.Blocks[0].Header.Number.Uint64(),
+					EndBlockNumber:   chunk.Blocks[len(chunk.Blocks)-1].Header.Number.Uint64(),
+				}
+			}
+			return 0, nil, &batchValidationError{failure: &rawdb.BatchValidationFailure{
+				BatchIndex:        batchIndex,
+				CodecVersion:      uint8(codecVersion),
+				ChunkBlockRanges:  chunkBlockRanges,
+				Reason:            strings.Join(reasons, ", "),
+				L1StateRoot:       event.StateRoot,
+				LocalStateRoot:    localStateRoot,
+				L1WithdrawRoot:    event.WithdrawRoot,
+				LocalWithdrawRoot: localWithdrawRoot,
+				L1BatchHash:       event.BatchHash,
+				LocalBatchHash:    localBatchHash,
+			}}
 		}
 	}
 
@@ -648,6 +875,15 @@ func validateBatch(batchIndex uint64, event *L1FinalizeBatchEvent, parentFinaliz
 	return endBlock.Header.Number.Uint64(), finalizedBatchMeta, nil
 }
 
+// batchValidationError is returned by validateBatch if the local batch data does not match the L1 FinalizeBatch event.
+type batchValidationError struct {
+	failure *rawdb.BatchValidationFailure
+}
+
+func (e *batchValidationError) Error() string {
+	return fmt.Sprintf("batch validation failed, batch index: %v, reason: %v", e.failure.BatchIndex, e.failure.Reason)
+}
+
 // determineCodecVersion determines the codec version based on the block number and chain configuration.
 func determineCodecVersion(startBlockNumber *big.Int, startBlockTimestamp uint64, chainCfg *params.ChainConfig) encoding.CodecVersion {
 	switch {
@@ -14,9 +14,11 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/scroll-tech/go-ethereum/common"
+	"github.com/scroll-tech/go-ethereum/consensus/ethash"
 	"github.com/scroll-tech/go-ethereum/core"
 	"github.com/scroll-tech/go-ethereum/core/rawdb"
 	"github.com/scroll-tech/go-ethereum/core/types"
+	"github.com/scroll-tech/go-ethereum/core/vm"
 	"github.com/scroll-tech/go-ethereum/ethdb/memorydb"
 	"github.com/scroll-tech/go-ethereum/node"
 	"github.com/scroll-tech/go-ethereum/params"
@@ -39,7 +41,7 @@ func TestRollupSyncServiceStartAndStop(t *testing.T) {
 		t.Fatalf("Failed to new P2P node: %v", err)
 	}
 	defer stack.Close()
-	service, err := NewRollupSyncService(context.Background(), genesisConfig, db, l1Client, bc, stack)
+	service, err := NewRollupSyncService(context.Background(), genesisConfig, db, l1Client, bc, stack, DefaultConfig)
 	if err != nil {
 		t.Fatalf("Failed to new rollup sync service: %v", err)
 	}
@@ -305,7 +307,7 @@ func TestGetCommittedBatchMetaCodecv0(t *testing.T) {
 		t.Fatalf("Failed to new P2P node: %v", err)
 	}
 	defer stack.Close()
-	service, err := NewRollupSyncService(context.Background(), genesisConfig, db, l1Client, bc, stack)
+	service, err := NewRollupSyncService(context.Background(), genesisConfig, db, l1Client, bc, stack, DefaultConfig)
 	if err != nil {
 		t.Fatalf("Failed to new rollup sync service: %v", err)
 	}
@@ -359,7 +361,7 @@ func TestGetCommittedBatchMetaCodecv1(t *testing.T) {
 		t.Fatalf("Failed to new P2P node: %v", err)
 	}
 	defer stack.Close()
-	service, err := NewRollupSyncService(context.Background(), genesisConfig, db, l1Client, bc, stack)
+	service, err := NewRollupSyncService(context.Background(), genesisConfig, db, l1Client, bc, stack, DefaultConfig)
 	if err != nil {
 		t.Fatalf("Failed to new rollup sync service: %v", err)
 	}
@@ -411,7 +413,7 @@ func TestGetCommittedBatchMetaCodecv2(t *testing.T) {
 		t.Fatalf("Failed to new P2P node: %v", err)
 	}
 	defer stack.Close()
-	service, err := NewRollupSyncService(context.Background(), genesisConfig, db, l1Client, bc, stack)
+	service, err := NewRollupSyncService(context.Background(), genesisConfig, db, l1Client, bc, stack, DefaultConfig)
 	if err != nil {
 		t.Fatalf("Failed to new rollup sync service: %v", err)
 	}
@@ -491,7 +493,7 @@ func TestGetCommittedBatchMetaCodecv3(t *testing.T) {
 		t.Fatalf("Failed to new P2P node: %v", err)
 	}
 	defer stack.Close()
-	service, err := NewRollupSyncService(context.Background(), genesisConfig, db, l1Client, bc, stack)
+	service, err := NewRollupSyncService(context.Background(), genesisConfig, db, l1Client, bc, stack, DefaultConfig)
 	if err != nil {
 		t.Fatalf("Failed to new rollup sync service: %v", err)
 	}
@@ -568,7 +570,7 @@ func TestValidateBatchCodecv0(t *testing.T) {
 		WithdrawRoot: chunk3.Blocks[len(chunk3.Blocks)-1].WithdrawRoot,
 	}
 
-	endBlock1, finalizedBatchMeta1, err := validateBatch(event1.BatchIndex.Uint64(), event1, parentBatchMeta1, nil, []*encoding.Chunk{chunk1, chunk2, chunk3}, chainConfig, nil)
+	endBlock1, finalizedBatchMeta1, err := validateBatch(event1.BatchIndex.Uint64(), event1, parentBatchMeta1, nil, []*encoding.Chunk{chunk1, chunk2, chunk3}, chainConfig)
 	assert.NoError(t, err)
 	assert.Equal(t, uint64(13), endBlock1)
 
@@ -588,7 +590,7 @@ func TestValidateBatchCodecv0(t *testing.T) {
 		StateRoot:    chunk4.Blocks[len(chunk4.Blocks)-1].Header.Root,
 		WithdrawRoot: chunk4.Blocks[len(chunk4.Blocks)-1].WithdrawRoot,
 	}
-	endBlock2, finalizedBatchMeta2, err := validateBatch(event2.BatchIndex.Uint64(), event2, parentBatchMeta2, nil, []*encoding.Chunk{chunk4}, chainConfig, nil)
+	endBlock2, finalizedBatchMeta2, err := validateBatch(event2.BatchIndex.Uint64(), event2, parentBatchMeta2, nil, []*encoding.Chunk{chunk4}, chainConfig)
 	assert.NoError(t, err)
 	assert.Equal(t, uint64(17), endBlock2)
 
@@ -621,7 +623,7 @@ func TestValidateBatchCodecv1(t *testing.T) {
 		WithdrawRoot: chunk3.Blocks[len(chunk3.Blocks)-1].WithdrawRoot,
 	}
 
-	endBlock1, finalizedBatchMeta1, err := validateBatch(event1.BatchIndex.Uint64(), event1, parentBatchMeta1, nil, []*encoding.Chunk{chunk1, chunk2, chunk3}, chainConfig, nil)
+	endBlock1, finalizedBatchMeta1, err := validateBatch(event1.BatchIndex.Uint64(), event1, parentBatchMeta1, nil, []*encoding.Chunk{chunk1, chunk2, chunk3}, chainConfig)
 	assert.NoError(t, err)
 	assert.Equal(t, uint64(13), endBlock1)
 
@@ -641,7 +643,7 @@ func TestValidateBatchCodecv1(t *testing.T) {
 		StateRoot:    chunk4.Blocks[len(chunk4.Blocks)-1].Header.Root,
 		WithdrawRoot: chunk4.Blocks[len(chunk4.Blocks)-1].WithdrawRoot,
 	}
-	endBlock2, finalizedBatchMeta2, err := validateBatch(event2.BatchIndex.Uint64(), event2, parentBatchMeta2, nil, []*encoding.Chunk{chunk4}, chainConfig, nil)
+	endBlock2, finalizedBatchMeta2, err := validateBatch(event2.BatchIndex.Uint64(), event2, parentBatchMeta2, nil, []*encoding.Chunk{chunk4}, chainConfig)
 	assert.NoError(t, err)
 	assert.Equal(t, uint64(17), endBlock2)
 
@@ -674,7 +676,7 @@ func TestValidateBatchCodecv2(t *testing.T) {
 		WithdrawRoot: chunk3.Blocks[len(chunk3.Blocks)-1].WithdrawRoot,
 	}
 
-	endBlock1, finalizedBatchMeta1, err := validateBatch(event1.BatchIndex.Uint64(), event1, parentBatchMeta1, nil, []*encoding.Chunk{chunk1, chunk2, chunk3}, chainConfig, nil)
+	endBlock1, finalizedBatchMeta1, err := validateBatch(event1.BatchIndex.Uint64(), event1, parentBatchMeta1, nil, []*encoding.Chunk{chunk1, chunk2, chunk3}, chainConfig)
 	assert.NoError(t, err)
 	assert.Equal(t, uint64(13), endBlock1)
 
@@ -694,7 +696,7 @@ func TestValidateBatchCodecv2(t *testing.T) {
 		StateRoot:    chunk4.Blocks[len(chunk4.Blocks)-1].Header.Root,
 		WithdrawRoot: chunk4.Blocks[len(chunk4.Blocks)-1].WithdrawRoot,
 	}
-	endBlock2, finalizedBatchMeta2, err := validateBatch(event2.BatchIndex.Uint64(), event2, parentBatchMeta2, nil, []*encoding.Chunk{chunk4}, chainConfig, nil)
+	endBlock2, finalizedBatchMeta2, err := validateBatch(event2.BatchIndex.Uint64(), event2, parentBatchMeta2, nil, []*encoding.Chunk{chunk4}, chainConfig)
 	assert.NoError(t, err)
 	assert.Equal(t, uint64(17), endBlock2)
 
@@ -727,7 +729,7 @@ func TestValidateBatchCodecv3(t *testing.T) {
 		WithdrawRoot: chunk3.Blocks[len(chunk3.Blocks)-1].WithdrawRoot,
 	}
 
-	endBlock1, finalizedBatchMeta1, err := validateBatch(event1.BatchIndex.Uint64(), event1, parentBatchMeta1, nil, []*encoding.Chunk{chunk1, chunk2, chunk3}, chainConfig, nil)
+	endBlock1, finalizedBatchMeta1, err := validateBatch(event1.BatchIndex.Uint64(), event1, parentBatchMeta1, nil, []*encoding.Chunk{chunk1, chunk2, chunk3}, chainConfig)
 	assert.NoError(t, err)
 	assert.Equal(t, uint64(13), endBlock1)
 
@@ -747,7 +749,7 @@ func TestValidateBatchCodecv3(t *testing.T) {
 		StateRoot:    chunk4.Blocks[len(chunk4.Blocks)-1].Header.Root,
 		WithdrawRoot: chunk4.Blocks[len(chunk4.Blocks)-1].WithdrawRoot,
 	}
-	endBlock2, finalizedBatchMeta2, err := validateBatch(event2.BatchIndex.Uint64(), event2, parentBatchMeta2, nil, []*encoding.Chunk{chunk4}, chainConfig, nil)
+	endBlock2, finalizedBatchMeta2, err := validateBatch(event2.BatchIndex.Uint64(), event2, parentBatchMeta2, nil, []*encoding.Chunk{chunk4}, chainConfig)
 	assert.NoError(t, err)
 	assert.Equal(t, uint64(17), endBlock2)
 
@@ -774,7 +776,7 @@ func TestValidateBatchUpgrades(t *testing.T) {
 		WithdrawRoot: chunk1.Blocks[len(chunk1.Blocks)-1].WithdrawRoot,
 	}
 
-	endBlock1, finalizedBatchMeta1, err := validateBatch(event1.BatchIndex.Uint64(), event1, parentBatchMeta1, nil, []*encoding.Chunk{chunk1}, chainConfig, nil)
+	endBlock1, finalizedBatchMeta1, err := validateBatch(event1.BatchIndex.Uint64(), event1, parentBatchMeta1, nil, []*encoding.Chunk{chunk1}, chainConfig)
 	assert.NoError(t, err)
 	assert.Equal(t, uint64(2), endBlock1)
 
@@ -794,7 +796,7 @@ func TestValidateBatchUpgrades(t *testing.T) {
 		StateRoot:    chunk2.Blocks[len(chunk2.Blocks)-1].Header.Root,
 		WithdrawRoot: chunk2.Blocks[len(chunk2.Blocks)-1].WithdrawRoot,
 	}
-	endBlock2, finalizedBatchMeta2, err := validateBatch(event2.BatchIndex.Uint64(), event2, parentBatchMeta2, nil, []*encoding.Chunk{chunk2}, chainConfig, nil)
+	endBlock2, finalizedBatchMeta2, err := validateBatch(event2.BatchIndex.Uint64(), event2, parentBatchMeta2, nil, []*encoding.Chunk{chunk2}, chainConfig)
 	assert.NoError(t, err)
 	assert.Equal(t, uint64(3), endBlock2)
 
@@ -814,7 +816,7 @@ func TestValidateBatchUpgrades(t *testing.T) {
 		StateRoot:    chunk3.Blocks[len(chunk3.Blocks)-1].Header.Root,
 		WithdrawRoot: chunk3.Blocks[len(chunk3.Blocks)-1].WithdrawRoot,
 	}
-	endBlock3, finalizedBatchMeta3, err := validateBatch(event3.BatchIndex.Uint64(), event3, parentBatchMeta3, nil, []*encoding.Chunk{chunk3}, chainConfig, nil)
+	endBlock3, finalizedBatchMeta3, err := validateBatch(event3.BatchIndex.Uint64(), event3, parentBatchMeta3, nil, []*encoding.Chunk{chunk3}, chainConfig)
 	assert.NoError(t, err)
 	assert.Equal(t, uint64(13), endBlock3)
 
@@ -834,7 +836,7 @@ func TestValidateBatchUpgrades(t *testing.T) {
 		StateRoot:    chunk4.Blocks[len(chunk4.Blocks)-1].Header.Root,
 		WithdrawRoot: chunk4.Blocks[len(chunk4.Blocks)-1].WithdrawRoot,
 	}
-	endBlock4, finalizedBatchMeta4, err := validateBatch(event4.BatchIndex.Uint64(), event4, parentBatchMeta4, nil, []*encoding.Chunk{chunk4}, chainConfig, nil)
+	endBlock4, finalizedBatchMeta4, err := validateBatch(event4.BatchIndex.Uint64(), event4, parentBatchMeta4, nil, []*encoding.Chunk{chunk4}, chainConfig)
 	assert.NoError(t, err)
 	assert.Equal(t, uint64(17), endBlock4)
 
@@ -867,19 +869,19 @@ func TestValidateBatchInFinalizeByBundle(t *testing.T) {
 		WithdrawRoot: chunk4.Blocks[len(chunk4.Blocks)-1].WithdrawRoot,
 	}
 
-	endBlock1, finalizedBatchMeta1, err := validateBatch(0, event, &rawdb.FinalizedBatchMeta{}, nil, []*encoding.Chunk{chunk1}, chainConfig, nil)
+	endBlock1, finalizedBatchMeta1, err := validateBatch(0, event, &rawdb.FinalizedBatchMeta{}, nil, []*encoding.Chunk{chunk1}, chainConfig)
 	assert.NoError(t, err)
 	assert.Equal(t, uint64(2), endBlock1)
 
-	endBlock2, finalizedBatchMeta2, err := validateBatch(1, event, finalizedBatchMeta1, nil, []*encoding.Chunk{chunk2}, chainConfig, nil)
+	endBlock2, finalizedBatchMeta2, err := validateBatch(1, event, finalizedBatchMeta1, nil, []*encoding.Chunk{chunk2}, chainConfig)
 	assert.NoError(t, err)
 	assert.Equal(t, uint64(3), endBlock2)
 
-	endBlock3, finalizedBatchMeta3, err := validateBatch(2, event, finalizedBatchMeta2, nil, []*encoding.Chunk{chunk3}, chainConfig, nil)
+	endBlock3, finalizedBatchMeta3, err := validateBatch(2, event, finalizedBatchMeta2, nil, []*encoding.Chunk{chunk3}, chainConfig)
 	assert.NoError(t, err)
 	assert.Equal(t, uint64(13), endBlock3)
 
-	endBlock4, finalizedBatchMeta4, err := validateBatch(3, event, finalizedBatchMeta3, nil, []*encoding.Chunk{chunk4}, chainConfig, nil)
+	endBlock4, finalizedBatchMeta4, err := validateBatch(3, event, finalizedBatchMeta3, nil, []*encoding.Chunk{chunk4}, chainConfig)
 	assert.NoError(t, err)
 	assert.Equal(t, uint64(17), endBlock4)
 
@@ -892,6 +894,184 @@ func TestValidateBatchInFinalizeByBundle(t *testing.T) {
 	assert.Equal(t, parentBatchMeta5, finalizedBatchMeta4)
 }
 
+func TestValidateBatchMismatch(t *testing.T) {
+	chainConfig := &params.ChainConfig{}
+
+	block1 := readBlockFromJSON(t, "./testdata/blockTrace_02.json")
+	chunk1 := &encoding.Chunk{Blocks: []*encoding.Block{block1}}
+
+	block2 := readBlockFromJSON(t, "./testdata/blockTrace_03.json")
+	chunk2 := &encoding.Chunk{Blocks: []*encoding.Block{block2}}
+
+	event := &L1FinalizeBatchEvent{
+		BatchIndex:   big.NewInt(0),
+		BatchHash:    common.HexToHash("0x01"),
+		StateRoot:    common.HexToHash("0x02"),
+		WithdrawRoot: chunk2.Blocks[len(chunk2.Blocks)-1].WithdrawRoot,
+	}
+
+	_, _, err := validateBatch(0, event, &rawdb.FinalizedBatchMeta{}, nil, []*encoding.Chunk{chunk1, chunk2}, chainConfig)
+	var validationErr *batchValidationError
+	require.ErrorAs(t, err, &validationErr)
+
+	failure := validationErr.failure
+	assert.Equal(t, uint64(0), failure.BatchIndex)
+	assert.Equal(t, uint8(encoding.CodecV0), failure.CodecVersion)
+	assert.Equal(t, "state root mismatch, batch hash mismatch", failure.Reason)
+	assert.Equal(t, event.StateRoot, failure.L1StateRoot)
+	assert.Equal(t, block2.Header.Root, failure.LocalStateRoot)
+	assert.Equal(t, event.BatchHash, failure.L1BatchHash)
+	assert.NotEqual(t, event.BatchHash, failure.LocalBatchHash)
+	assert.Equal(t, []*rawdb.ChunkBlockRange{
+		{StartBlockNumber: block1.Header.Number.Uint64(), EndBlockNumber: block1.Header.Number.Uint64()},
+		{StartBlockNumber: block2.Header.Number.Uint64(), EndBlockNumber: block2.Header.Number.Uint64()},
+	}, failure.ChunkBlockRanges)
+}
+
+func TestHandleValidationFailureDiverge(t *testing.T) {
+	db := rawdb.NewDatabase(memorydb.New())
+	service := &RollupSyncService{
+		db:     db,
+		config: Config{ValidationFailurePolicy: DivergeOnFailure},
+	}
+
+	failure := &rawdb.BatchValidationFailure{BatchIndex: 7, Reason: "state root mismatch"}
+	assert.Error(t, service.handleValidationFailure(failure))
+	assert.True(t, service.Diverged())
+
+	stored := rawdb.ReadBatchValidationFailure(db, 7)
+	require.NotNil(t, stored)
+	assert.Equal(t, "state root mismatch", stored.Reason)
+	assert.NotZero(t, stored.DetectedAt)
+
+	// no further rollup events are processed once diverged
+	service.latestProcessedBlock = 10
+	service.fetchRollupEvents()
+	assert.Equal(t, uint64(10), service.latestProcessedBlock)
+}
+
+func TestHandleValidationFailureRollback(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	genesis := (&core.Genesis{Config: params.TestChainConfig}).MustCommit(db)
+	bc, err := core.NewBlockChain(db, nil, params.TestChainConfig, ethash.NewFaker(), vm.Config{}, nil, nil)
+	require.NoError(t, err)
+	defer bc.Stop()
+	blocks, _ := core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), db, 10, nil)
+	_, err = bc.InsertChain(blocks)
+	require.NoError(t, err)
+	rawdb.WriteFinalizedL2BlockNumber(db, 4)
+
+	service := &RollupSyncService{
+		db:     db,
+		bc:     bc,
+		config: Config{ValidationFailurePolicy: RollbackOnFailure},
+	}
+
+	// the first failure rolls the chain back to the last finalized block
+	failure := &rawdb.BatchValidationFailure{BatchIndex: 7, Reason: "state root mismatch"}
+	assert.Error(t, service.handleValidationFailure(failure))
+	assert.False(t, service.Diverged())
+	assert.Equal(t, uint64(4), bc.CurrentBlock().NumberU64())
+	require.NotNil(t, rawdb.ReadRolledBackBatchIndex(db))
+	assert.Equal(t, uint64(7), *rawdb.ReadRolledBackBatchIndex(db))
+
+	// after a restart, the same batch failing again makes the chain diverge instead of rolling back again
+	_, err = bc.InsertChain(blocks[4:])
+	require.NoError(t, err)
+	service = &RollupSyncService{
+		db:     db,
+		bc:     bc,
+		config: Config{ValidationFailurePolicy: RollbackOnFailure},
+	}
+	service.restoreValidationState()
+	assert.Error(t, service.handleValidationFailure(failure))
+	assert.True(t, service.Diverged())
+	assert.Equal(t, uint64(10), bc.CurrentBlock().NumberU64())
+
+	// the diverged state survives a restart as well, until the sync height is reset
+	service = &RollupSyncService{db: db, bc: bc}
+	service.restoreValidationState()
+	assert.True(t, service.Diverged())
+	service.ResetStartSyncHeight(100)
+	assert.False(t, service.Diverged())
+	assert.Nil(t, rawdb.ReadDivergedBatchIndex(db))
+	assert.Nil(t, rawdb.ReadRolledBackBatchIndex(db))
+
+	// after the reset, the same batch failing again rolls the chain back instead of diverging
+	service.config.ValidationFailurePolicy = RollbackOnFailure
+	assert.Error(t, service.handleValidationFailure(failure))
+	assert.False(t, service.Diverged())
+	assert.Equal(t, uint64(4), bc.CurrentBlock().NumberU64())
+}
+
+func TestResetCommittedL2BlockNumber(t *testing.T) {
+	db := rawdb.NewDatabase(memorydb.New())
+	service := &RollupSyncService{db: db}
+
+	rawdb.WriteCommittedL2BlockNumber(db, 60)
+
+	// reverted batch is known
+	service.resetCommittedL2BlockNumber([]*rawdb.ChunkBlockRange{{StartBlockNumber: 51, EndBlockNumber: 55}, {StartBlockNumber: 56, EndBlockNumber: 60}})
+	assert.Equal(t, uint64(50), *rawdb.ReadCommittedL2BlockNumber(db))
+
+	// the committed block number never increases
+	service.resetCommittedL2BlockNumber([]*rawdb.ChunkBlockRange{{StartBlockNumber: 61, EndBlockNumber: 70}})
+	assert.Equal(t, uint64(50), *rawdb.ReadCommittedL2BlockNumber(db))
+
+	// reverted batch is unknown, fall back to the finalized L2 block
+	rawdb.WriteFinalizedL2BlockNumber(db, 30)
+	service.resetCommittedL2BlockNumber(nil)
+	assert.Equal(t, uint64(30), *rawdb.ReadCommittedL2BlockNumber(db))
+}
+
+func TestRevertMultipleBatches(t *testing.T) {
+	scrollChainABI, err := scrollChainMetaData.GetAbi()
+	require.NoError(t, err)
+
+	db := rawdb.NewDatabase(memorydb.New())
+	service := &RollupSyncService{
+		db:                          db,
+		scrollChainABI:              scrollChainABI,
+		l1RevertBatchEventSignature: scrollChainABI.Events["RevertBatch"].ID,
+	}
+
+	// batches 1 to 7 are committed, batch 2 is finalized
+	for batchIndex := uint64(1); batchIndex <= 7; batchIndex++ {
+		chunkBlockRanges := []*rawdb.ChunkBlockRange{{StartBlockNumber: batchIndex*10 + 1, EndBlockNumber: batchIndex*10 + 10}}
+		rawdb.WriteBatchChunkRanges(db, batchIndex, chunkBlockRanges)
+		rawdb.WriteL2BlockBatchIndex(db, batchIndex, chunkBlockRanges)
+	}
+	rawdb.WriteFinalizedL2BlockNumber(db, 30)
+	rawdb.WriteCommittedL2BlockNumber(db, 80)
+
+	// batches 5, 6 and 7 are reverted in ascending order
+	var logs []types.Log
+	for batchIndex := int64(5); batchIndex <= 7; batchIndex++ {
+		logs = append(logs, types.Log{
+			Topics: []common.Hash{scrollChainABI.Events["RevertBatch"].ID, common.BigToHash(big.NewInt(batchIndex)), {}},
+		})
+	}
+	require.NoError(t, service.parseAndUpdateRollupEventLogs(logs, 100))
+
+	// the committed L2 block number is the end of batch 4
+	assert.Equal(t, uint64(50), *rawdb.ReadCommittedL2BlockNumber(db))
+	for batchIndex := uint64(5); batchIndex <= 7; batchIndex++ {
+		assert.Nil(t, rawdb.ReadBatchChunkRanges(db, batchIndex))
+	}
+	assert.NotNil(t, rawdb.ReadBatchChunkRanges(db, 4))
+}
+
+func TestParseValidationFailurePolicy(t *testing.T) {
+	for _, policy := range []ValidationFailurePolicy{HaltOnFailure, DivergeOnFailure, RollbackOnFailure} {
+		got, err := ParseValidationFailurePolicy(string(policy))
+		assert.NoError(t, err)
+		assert.Equal(t, policy, got)
+	}
+
+	_, err := ParseValidationFailurePolicy("ignore")
+	assert.Error(t, err)
+}
+
 func readBlockFromJSON(t *testing.T, filename string) *encoding.Block {
 	data, err := os.ReadFile(filename)
 	assert.NoError(t, err)
@@ -0,0 +1,43 @@
+package rollup_sync_service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// maxDecompressedBatchSize bounds the memory used to decompress a batch payload.
+	maxDecompressedBatchSize = 16 * 1024 * 1024
+)
+
+// zstdMagicNumber is stripped from the frame by the scroll zstd compressor to save blob space.
+var zstdMagicNumber = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// decompressScrollBlobToBatch decompresses a zstd compressed batch payload produced by
+// zstd.CompressScrollBatchBytes. Any bytes following the compressed frame (e.g. blob padding) are ignored.
+func decompressScrollBlobToBatch(compressedBytes []byte) ([]byte, error) {
+	src := make([]byte, 0, len(zstdMagicNumber)+len(compressedBytes))
+	src = append(src, zstdMagicNumber...)
+	src = append(src, compressedBytes...)
+
+	zr, err := zstd.NewReader(bytes.NewReader(src), zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxMemory(maxDecompressedBatchSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create zstd decoder: %w", err)
+	}
+	defer zr.Close()
+
+	// the payload is a single frame, the padding that follows it is not a valid frame header
+	var batchBytes bytes.Buffer
+	n, err := io.Copy(&batchBytes, io.LimitReader(zr, maxDecompressedBatchSize+1))
+	switch {
+	case n > maxDecompressedBatchSize:
+		return nil, fmt.Errorf("decompressed batch payload exceeds %d bytes", maxDecompressedBatchSize)
+	case err != nil && (n == 0 || !errors.Is(err, zstd.ErrMagicMismatch)):
+		return nil, fmt.Errorf("failed to decompress batch payload: %w", err)
+	}
+	return batchBytes.Bytes(), nil
+}
@@ -0,0 +1,30 @@
+package rollup_sync_service
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/scroll-tech/da-codec/encoding/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecompressScrollBlobToBatch(t *testing.T) {
+	batchBytes := bytes.Repeat([]byte("scroll batch payload "), 1000)
+	compressed, err := zstd.CompressScrollBatchBytes(batchBytes)
+	require.NoError(t, err)
+
+	// the blob padding after the frame is ignored
+	padded := append(compressed, make([]byte, 1024)...)
+	decompressed, err := decompressScrollBlobToBatch(padded)
+	require.NoError(t, err)
+	assert.Equal(t, batchBytes, decompressed)
+
+	// a truncated frame is rejected
+	_, err = decompressScrollBlobToBatch(compressed[:len(compressed)/2])
+	assert.Error(t, err)
+
+	// so is data that is not a frame at all
+	_, err = decompressScrollBlobToBatch(make([]byte, 1024))
+	assert.Error(t, err)
+}
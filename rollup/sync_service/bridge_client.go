@@ -6,8 +6,10 @@ import (
 	"fmt"
 	"math/big"
 
+	"github.com/scroll-tech/go-ethereum"
 	"github.com/scroll-tech/go-ethereum/accounts/abi/bind"
 	"github.com/scroll-tech/go-ethereum/common"
+	"github.com/scroll-tech/go-ethereum/core/rawdb"
 	"github.com/scroll-tech/go-ethereum/core/types"
 	"github.com/scroll-tech/go-ethereum/log"
 	"github.com/scroll-tech/go-ethereum/rpc"
@@ -20,6 +22,8 @@ type BridgeClient struct {
 	confirmations         rpc.BlockNumber
 	l1MessageQueueAddress common.Address
 	filterer              *L1MessageQueueFilterer
+
+	queueTransactionEventID common.Hash
 }
 
 func newBridgeClient(ctx context.Context, l1Client EthClient, l1ChainId uint64, confirmations rpc.BlockNumber, l1MessageQueueAddress common.Address) (*BridgeClient, error) {
@@ -41,19 +45,27 @@ func newBridgeClient(ctx context.Context, l1Client EthClient, l1ChainId uint64,
 		return nil, fmt.Errorf("failed to initialize L1MessageQueueFilterer, err = %w", err)
 	}
 
+	l1MessageQueueABI, err := L1MessageQueueMetaData.GetAbi()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse L1MessageQueue ABI, err = %w", err)
+	}
+
 	client := BridgeClient{
 		client:                l1Client,
 		confirmations:         confirmations,
 		l1MessageQueueAddress: l1MessageQueueAddress,
 		filterer:              filterer,
+
+		queueTransactionEventID: l1MessageQueueABI.Events["QueueTransaction"].ID,
 	}
 
 	return &client, nil
 }
 
 // fetchMessagesInRange retrieves and parses all L1 messages between the
-// provided from and to L1 block numbers (inclusive).
-func (c *BridgeClient) fetchMessagesInRange(ctx context.Context, from, to uint64) ([]types.L1MessageTx, error) {
+// provided from and to L1 block numbers (inclusive). It also returns a
+// checkpoint for each L1 block that emitted at least one message.
+func (c *BridgeClient) fetchMessagesInRange(ctx context.Context, from, to uint64) ([]types.L1MessageTx, []rawdb.L1BlockCheckpoint, error) {
 	log.Trace("BridgeClient fetchMessagesInRange", "fromBlock", from, "toBlock", to)
 
 	opts := bind.FilterOpts{
@@ -63,17 +75,18 @@ func (c *BridgeClient) fetchMessagesInRange(ctx context.Context, from, to uint64
 	}
 	it, err := c.filterer.FilterQueueTransaction(&opts, nil, nil)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	var msgs []types.L1MessageTx
+	var checkpoints []rawdb.L1BlockCheckpoint
 
 	for it.Next() {
 		event := it.Event
 		log.Trace("Received new L1 QueueTransaction event", "event", event)
 
 		if !event.GasLimit.IsUint64() {
-			return nil, fmt.Errorf("invalid QueueTransaction event: QueueIndex = %v, GasLimit = %v", event.QueueIndex, event.GasLimit)
+			return nil, nil, fmt.Errorf("invalid QueueTransaction event: QueueIndex = %v, GasLimit = %v", event.QueueIndex, event.GasLimit)
 		}
 
 		msgs = append(msgs, types.L1MessageTx{
@@ -84,13 +97,44 @@ func (c *BridgeClient) fetchMessagesInRange(ctx context.Context, from, to uint64
 			Data:       event.Data,
 			Sender:     event.Sender,
 		})
+
+		checkpoint := rawdb.L1BlockCheckpoint{
+			Number:         event.Raw.BlockNumber,
+			Hash:           event.Raw.BlockHash,
+			NextQueueIndex: event.QueueIndex + 1,
+		}
+		if n := len(checkpoints); n > 0 && checkpoints[n-1].Number == checkpoint.Number {
+			checkpoints[n-1] = checkpoint
+		} else {
+			checkpoints = append(checkpoints, checkpoint)
+		}
 	}
 
 	if err := it.Error(); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	return msgs, nil
+	return msgs, checkpoints, nil
+}
+
+// messagesQuery returns the filter query matching all QueueTransaction events.
+func (c *BridgeClient) messagesQuery() ethereum.FilterQuery {
+	return ethereum.FilterQuery{
+		Addresses: []common.Address{c.l1MessageQueueAddress},
+		Topics:    [][]common.Hash{{c.queueTransactionEventID}},
+	}
+}
+
+// getHeaderByNumber retrieves the header of the provided L1 block.
+func (c *BridgeClient) getHeaderByNumber(ctx context.Context, number uint64) (*types.Header, error) {
+	header, err := c.client.HeaderByNumber(ctx, new(big.Int).SetUint64(number))
+	if err != nil {
+		return nil, err
+	}
+	if header == nil {
+		return nil, fmt.Errorf("L1 block %d not found", number)
+	}
+	return header, nil
 }
 
 func (c *BridgeClient) getLatestConfirmedBlockNumber(ctx context.Context) (uint64, error) {
@@ -0,0 +1,108 @@
+package sync_service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/scroll-tech/go-ethereum"
+	"github.com/scroll-tech/go-ethereum/core/types"
+	"github.com/scroll-tech/go-ethereum/log"
+	"github.com/scroll-tech/go-ethereum/rpc"
+)
+
+// DefaultResubscribeInterval is the time we wait before resubscribing to L1 after a subscription failed.
+const DefaultResubscribeInterval = 10 * time.Second
+
+// HeadSubscriber is implemented by L1 clients that support eth_subscribe("newHeads"),
+// such as ethclient.Client connected to a websocket or IPC endpoint.
+type HeadSubscriber interface {
+	SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (ethereum.Subscription, error)
+}
+
+// WatchL1 subscribes to new L1 heads and to logs matching query, and signals on the returned channel
+// whenever either of them arrives, so that the caller can sync without waiting for its next poll.
+// Signals are coalesced, the caller is expected to fetch everything up to the latest L1 block.
+// Failed subscriptions are re-established after resubscribeInterval, and a signal is sent after
+// every successful (re)subscription so that the caller fills the gap. If the L1 endpoint does not
+// support subscriptions (e.g. HTTP), no signals are sent and callers rely on polling alone.
+func WatchL1(ctx context.Context, client EthClient, query ethereum.FilterQuery, resubscribeInterval time.Duration) <-chan struct{} {
+	updates := make(chan struct{}, 1)
+	notify := func() {
+		select {
+		case updates <- struct{}{}:
+		default:
+		}
+	}
+
+	headSubscriber, ok := client.(HeadSubscriber)
+	if !ok {
+		log.Info("L1 client does not support subscriptions, using polling only")
+		return updates
+	}
+
+	go func() {
+		for {
+			err := watchL1(ctx, client, headSubscriber, query, notify)
+			if ctx.Err() != nil {
+				return
+			}
+			if errors.Is(err, rpc.ErrNotificationsUnsupported) {
+				log.Info("L1 endpoint does not support subscriptions, using polling only")
+				return
+			}
+			log.Warn("L1 subscription failed, falling back to polling until resubscribed", "err", err)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(resubscribeInterval):
+			}
+		}
+	}()
+	return updates
+}
+
+// watchL1 subscribes to new L1 heads and logs and forwards them to notify until a subscription fails.
+func watchL1(ctx context.Context, client EthClient, headSubscriber HeadSubscriber, query ethereum.FilterQuery, notify func()) error {
+	headCh := make(chan *types.Header, 16)
+	headSub, err := headSubscriber.SubscribeNewHead(ctx, headCh)
+	if err != nil {
+		return err
+	}
+	defer headSub.Unsubscribe()
+
+	logCh := make(chan types.Log, 16)
+	logSub, err := client.SubscribeFilterLogs(ctx, query, logCh)
+	if err != nil {
+		return err
+	}
+	defer logSub.Unsubscribe()
+
+	log.Debug("Subscribed to L1 heads and logs")
+
+	// fill the gap since the last subscription
+	notify()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-headCh:
+			notify()
+		case <-logCh:
+			notify()
+		case err := <-headSub.Err():
+			return subscriptionError(err)
+		case err := <-logSub.Err():
+			return subscriptionError(err)
+		}
+	}
+}
+
+func subscriptionError(err error) error {
+	if err == nil {
+		return errors.New("subscription closed")
+	}
+	return err
+}
@@ -0,0 +1,134 @@
+package sync_service
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/scroll-tech/go-ethereum"
+	"github.com/scroll-tech/go-ethereum/core/rawdb"
+	"github.com/scroll-tech/go-ethereum/core/types"
+	"github.com/scroll-tech/go-ethereum/event"
+	"github.com/scroll-tech/go-ethereum/rpc"
+)
+
+// subscribingL1Chain is a mockL1Chain that supports head and log subscriptions.
+type subscribingL1Chain struct {
+	*mockL1Chain
+	heads         chan *types.Header
+	fail          chan error
+	subscribeErr  error
+	subscriptions int32
+}
+
+func newSubscribingL1Chain(chain *mockL1Chain) *subscribingL1Chain {
+	return &subscribingL1Chain{mockL1Chain: chain, heads: make(chan *types.Header), fail: make(chan error)}
+}
+
+func (c *subscribingL1Chain) SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (ethereum.Subscription, error) {
+	atomic.AddInt32(&c.subscriptions, 1)
+	if c.subscribeErr != nil {
+		return nil, c.subscribeErr
+	}
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		for {
+			select {
+			case header := <-c.heads:
+				select {
+				case ch <- header:
+				case <-quit:
+					return nil
+				}
+			case err := <-c.fail:
+				return err
+			case <-quit:
+				return nil
+			}
+		}
+	}), nil
+}
+
+func (c *subscribingL1Chain) SubscribeFilterLogs(ctx context.Context, query ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		<-quit
+		return nil
+	}), nil
+}
+
+func waitForUpdate(t *testing.T, updates <-chan struct{}) {
+	select {
+	case <-updates:
+	case <-time.After(time.Second):
+		t.Fatal("L1 update not received")
+	}
+}
+
+func TestWatchL1(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	chain := newSubscribingL1Chain(newMockL1Chain(10))
+	updates := WatchL1(ctx, chain, ethereum.FilterQuery{}, 10*time.Millisecond)
+
+	// initial signal after subscribing
+	waitForUpdate(t, updates)
+
+	// new heads are signalled
+	chain.heads <- chain.headers[9]
+	waitForUpdate(t, updates)
+
+	// failed subscriptions are re-established and the gap is signalled
+	chain.fail <- errors.New("connection lost")
+	waitForUpdate(t, updates)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&chain.subscriptions))
+}
+
+func TestWatchL1Unsupported(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// clients without subscription support never signal
+	updates := WatchL1(ctx, newMockL1Chain(10), ethereum.FilterQuery{}, time.Millisecond)
+	select {
+	case <-updates:
+		t.Fatal("unexpected L1 update")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// endpoints without subscription support are not resubscribed
+	chain := newSubscribingL1Chain(newMockL1Chain(10))
+	chain.subscribeErr = rpc.ErrNotificationsUnsupported
+	updates = WatchL1(ctx, chain, ethereum.FilterQuery{}, time.Millisecond)
+	select {
+	case <-updates:
+		t.Fatal("unexpected L1 update")
+	case <-time.After(50 * time.Millisecond):
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&chain.subscriptions))
+}
+
+func TestSyncServiceSubscription(t *testing.T) {
+	chain := newSubscribingL1Chain(newMockL1Chain(11))
+	chain.addMessage(t, 5, 0, []byte{0})
+
+	service := newTestSyncService(t, chain)
+	service.pollInterval = time.Hour
+	service.Start()
+	defer service.Stop()
+
+	require.Eventually(t, func() bool { return rawdb.ReadL1Message(service.db, 0) != nil }, time.Second, 10*time.Millisecond)
+
+	// a new L1 head triggers a sync without waiting for the next poll
+	chain.mu.Lock()
+	chain.extend(5, 0)
+	chain.mu.Unlock()
+	chain.addMessage(t, 13, 1, []byte{1})
+	chain.heads <- chain.headers[15]
+
+	require.Eventually(t, func() bool { return rawdb.ReadL1Message(service.db, 1) != nil }, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,410 @@
+package sync_service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/scroll-tech/go-ethereum"
+	"github.com/scroll-tech/go-ethereum/common"
+	"github.com/scroll-tech/go-ethereum/core/types"
+	"github.com/scroll-tech/go-ethereum/crypto"
+	"github.com/scroll-tech/go-ethereum/log"
+	"github.com/scroll-tech/go-ethereum/metrics"
+	"github.com/scroll-tech/go-ethereum/rpc"
+)
+
+const (
+	// DefaultHealthCheckInterval is the frequency at which MultiClient checks the health of its endpoints.
+	DefaultHealthCheckInterval = 30 * time.Second
+
+	// DefaultMaxEndpointBlockLag is the number of blocks an endpoint can fall behind
+	// the most advanced endpoint before it is considered unhealthy.
+	DefaultMaxEndpointBlockLag = uint64(32)
+)
+
+var (
+	errNoQuorum = errors.New("L1 endpoints did not reach quorum")
+
+	l1EndpointFailoverCounter  = metrics.NewRegisteredCounter("rollup/l1/endpoint/failover", nil)
+	l1EndpointRateLimitCounter = metrics.NewRegisteredCounter("rollup/l1/endpoint/ratelimit", nil)
+	l1EndpointHealthyGauge     = metrics.NewRegisteredGauge("rollup/l1/endpoint/healthy", nil)
+	l1QuorumFailureCounter     = metrics.NewRegisteredCounter("rollup/l1/quorum/failure", nil)
+)
+
+// MultiClientConfig configures a MultiClient.
+type MultiClientConfig struct {
+	// Quorum is the number of endpoints that must return identical FilterLogs results and
+	// block headers before they are accepted. Values <= 1 disable quorum checks.
+	Quorum              int
+	HealthCheckInterval time.Duration // Frequency of endpoint health checks
+	MaxBlockLag         uint64        // Maximum number of blocks a healthy endpoint can lag behind the others
+}
+
+// l1Endpoint is a single L1 endpoint of a MultiClient.
+type l1Endpoint struct {
+	name   string
+	client EthClient
+
+	mu      sync.Mutex
+	healthy bool
+}
+
+func (e *l1Endpoint) isHealthy() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.healthy
+}
+
+func (e *l1Endpoint) setHealthy(healthy bool, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.healthy && !healthy {
+		log.Warn("L1 endpoint is unhealthy", "endpoint", e.name, "err", err)
+	} else if !e.healthy && healthy {
+		log.Info("L1 endpoint is healthy again", "endpoint", e.name)
+	}
+	e.healthy = healthy
+}
+
+// MultiClient is an EthClient backed by several L1 endpoints. Requests are sent to the first healthy
+// endpoint and fail over to the next one on error. If a quorum is configured, FilterLogs and
+// HeaderByNumber for explicit block numbers are sent to all endpoints and only succeed if enough
+// endpoints return identical results, so that a single faulty provider cannot inject L1 messages
+// or block hashes. Block tags such as "latest" or "finalized" legitimately differ between endpoints
+// and are not subject to the quorum.
+type MultiClient struct {
+	endpoints []*l1Endpoint
+	config    MultiClientConfig
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewMultiClient creates a MultiClient from the given clients, names are only used for logging.
+func NewMultiClient(names []string, clients []EthClient, config MultiClientConfig) (*MultiClient, error) {
+	if len(clients) == 0 {
+		return nil, errors.New("no L1 endpoint provided")
+	}
+	if len(names) != len(clients) {
+		return nil, fmt.Errorf("mismatched number of L1 endpoint names and clients: %d != %d", len(names), len(clients))
+	}
+	if config.Quorum > len(clients) {
+		return nil, fmt.Errorf("L1 quorum %d exceeds the number of L1 endpoints %d", config.Quorum, len(clients))
+	}
+	if config.HealthCheckInterval == 0 {
+		config.HealthCheckInterval = DefaultHealthCheckInterval
+	}
+	if config.MaxBlockLag == 0 {
+		config.MaxBlockLag = DefaultMaxEndpointBlockLag
+	}
+
+	c := &MultiClient{config: config, quit: make(chan struct{})}
+	for i, client := range clients {
+		c.endpoints = append(c.endpoints, &l1Endpoint{name: names[i], client: client, healthy: true})
+	}
+	l1EndpointHealthyGauge.Update(int64(len(c.endpoints)))
+	return c, nil
+}
+
+// Start implements node.Lifecycle, starting the background health checks.
+func (c *MultiClient) Start() error {
+	c.wg.Add(1)
+	go c.healthCheckLoop()
+	return nil
+}
+
+// Stop implements node.Lifecycle, terminating the background health checks.
+func (c *MultiClient) Stop() error {
+	close(c.quit)
+	c.wg.Wait()
+	return nil
+}
+
+func (c *MultiClient) healthCheckLoop() {
+	defer c.wg.Done()
+
+	t := time.NewTicker(c.config.HealthCheckInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			ctx, cancel := context.WithTimeout(context.Background(), c.config.HealthCheckInterval)
+			c.checkHealth(ctx)
+			cancel()
+		case <-c.quit:
+			return
+		}
+	}
+}
+
+// checkHealth queries the block number of all endpoints. Endpoints that fail or lag behind
+// the most advanced endpoint by more than MaxBlockLag blocks are marked unhealthy.
+func (c *MultiClient) checkHealth(ctx context.Context) {
+	numbers := make([]uint64, len(c.endpoints))
+	errs := make([]error, len(c.endpoints))
+
+	var wg sync.WaitGroup
+	for i, e := range c.endpoints {
+		wg.Add(1)
+		go func(i int, e *l1Endpoint) {
+			defer wg.Done()
+			numbers[i], errs[i] = e.client.BlockNumber(ctx)
+		}(i, e)
+	}
+	wg.Wait()
+
+	var highest uint64
+	for i := range c.endpoints {
+		if errs[i] == nil && numbers[i] > highest {
+			highest = numbers[i]
+		}
+	}
+
+	numHealthy := 0
+	for i, e := range c.endpoints {
+		switch {
+		case errs[i] != nil:
+			e.setHealthy(false, errs[i])
+		case numbers[i]+c.config.MaxBlockLag < highest:
+			e.setHealthy(false, fmt.Errorf("endpoint lags behind, block number: %d, highest: %d", numbers[i], highest))
+		default:
+			e.setHealthy(true, nil)
+			numHealthy++
+		}
+	}
+	l1EndpointHealthyGauge.Update(int64(numHealthy))
+}
+
+// orderedEndpoints returns the healthy endpoints followed by the unhealthy ones, both in configured order.
+func (c *MultiClient) orderedEndpoints() []*l1Endpoint {
+	endpoints := make([]*l1Endpoint, 0, len(c.endpoints))
+	var unhealthy []*l1Endpoint
+	for _, e := range c.endpoints {
+		if e.isHealthy() {
+			endpoints = append(endpoints, e)
+		} else {
+			unhealthy = append(unhealthy, e)
+		}
+	}
+	return append(endpoints, unhealthy...)
+}
+
+// failover calls fn on one endpoint after another until it succeeds.
+func (c *MultiClient) failover(ctx context.Context, method string, fn func(client EthClient) error) error {
+	var errs []error
+	for i, e := range c.orderedEndpoints() {
+		if i > 0 {
+			l1EndpointFailoverCounter.Inc(1)
+			log.Debug("Failing over to next L1 endpoint", "method", method, "endpoint", e.name)
+		}
+		err := fn(e.client)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if isRequestError(err) {
+			// not an endpoint failure, other endpoints will return the same error
+			return err
+		}
+		if isRateLimitError(err) {
+			// the endpoint is healthy but overloaded, move it to the back until the next health check
+			l1EndpointRateLimitCounter.Inc(1)
+			log.Debug("L1 endpoint is rate limited", "method", method, "endpoint", e.name, "err", err)
+		}
+		if errors.Is(err, rpc.ErrNotificationsUnsupported) {
+			// not an endpoint failure, but other endpoints might support subscriptions
+			errs = append(errs, fmt.Errorf("%s: %w", e.name, err))
+			continue
+		}
+		e.setHealthy(false, err)
+		errs = append(errs, fmt.Errorf("%s: %w", e.name, err))
+	}
+	return fmt.Errorf("%s failed on all %d L1 endpoints: %w", method, len(c.endpoints), errors.Join(errs...))
+}
+
+type quorumResult struct {
+	endpoint *l1Endpoint
+	digest   common.Hash
+	result   interface{}
+	err      error
+}
+
+// quorum calls fn on all endpoints in parallel and returns the first result that
+// c.config.Quorum endpoints agree on, results are compared by the digest returned by fn.
+func (c *MultiClient) quorum(ctx context.Context, method string, fn func(ctx context.Context, client EthClient) (interface{}, common.Hash, error)) (interface{}, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan quorumResult, len(c.endpoints))
+	for _, e := range c.endpoints {
+		go func(e *l1Endpoint) {
+			result, digest, err := fn(ctx, e.client)
+			results <- quorumResult{endpoint: e, digest: digest, result: result, err: err}
+		}(e)
+	}
+
+	var (
+		votes    = make(map[common.Hash]int)
+		errs     []error
+		maxVotes int
+	)
+	for received := 0; received < len(c.endpoints); received++ {
+		r := <-results
+		if r.err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			if !isRequestError(r.err) {
+				r.endpoint.setHealthy(false, r.err)
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", r.endpoint.name, r.err))
+		} else {
+			votes[r.digest]++
+			if votes[r.digest] >= c.config.Quorum {
+				return r.result, nil
+			}
+			if votes[r.digest] > maxVotes {
+				maxVotes = votes[r.digest]
+			}
+		}
+		// stop early if the remaining endpoints cannot form a quorum anymore
+		if maxVotes+len(c.endpoints)-received-1 < c.config.Quorum {
+			break
+		}
+	}
+
+	l1QuorumFailureCounter.Inc(1)
+	log.Warn("L1 endpoints did not reach quorum", "method", method, "quorum", c.config.Quorum, "distinct results", len(votes), "errors", len(errs))
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("%w on %s, votes: %d/%d: %w", errNoQuorum, method, maxVotes, c.config.Quorum, errors.Join(errs...))
+	}
+	return nil, fmt.Errorf("%w on %s, votes: %d/%d", errNoQuorum, method, maxVotes, c.config.Quorum)
+}
+
+// isRequestError reports whether err is caused by the request rather than by the endpoint,
+// e.g. because the block is not known yet or the queried range is too large. Rate limits are
+// specific to an endpoint and are not request errors, the request is retried on other endpoints.
+func isRequestError(err error) bool {
+	return errors.Is(err, ethereum.NotFound) || isRangeTooLargeError(err)
+}
+
+func (c *MultiClient) quorumEnabled() bool {
+	return c.config.Quorum > 1
+}
+
+// BlockNumber implements EthClient.
+func (c *MultiClient) BlockNumber(ctx context.Context) (number uint64, err error) {
+	err = c.failover(ctx, "BlockNumber", func(client EthClient) error {
+		number, err = client.BlockNumber(ctx)
+		return err
+	})
+	return number, err
+}
+
+// ChainID implements EthClient.
+func (c *MultiClient) ChainID(ctx context.Context) (chainID *big.Int, err error) {
+	err = c.failover(ctx, "ChainID", func(client EthClient) error {
+		chainID, err = client.ChainID(ctx)
+		return err
+	})
+	return chainID, err
+}
+
+// FilterLogs implements EthClient.
+func (c *MultiClient) FilterLogs(ctx context.Context, q ethereum.FilterQuery) (logs []types.Log, err error) {
+	if !c.quorumEnabled() {
+		err = c.failover(ctx, "FilterLogs", func(client EthClient) error {
+			logs, err = client.FilterLogs(ctx, q)
+			return err
+		})
+		return logs, err
+	}
+
+	result, err := c.quorum(ctx, "FilterLogs", func(ctx context.Context, client EthClient) (interface{}, common.Hash, error) {
+		logs, err := client.FilterLogs(ctx, q)
+		if err != nil {
+			return nil, common.Hash{}, err
+		}
+		encoded, err := json.Marshal(logs)
+		if err != nil {
+			return nil, common.Hash{}, err
+		}
+		return logs, crypto.Keccak256Hash(encoded), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.([]types.Log), nil
+}
+
+// HeaderByNumber implements EthClient.
+func (c *MultiClient) HeaderByNumber(ctx context.Context, number *big.Int) (header *types.Header, err error) {
+	if !c.quorumEnabled() || number == nil || number.Sign() < 0 {
+		err = c.failover(ctx, "HeaderByNumber", func(client EthClient) error {
+			header, err = client.HeaderByNumber(ctx, number)
+			return err
+		})
+		return header, err
+	}
+
+	result, err := c.quorum(ctx, "HeaderByNumber", func(ctx context.Context, client EthClient) (interface{}, common.Hash, error) {
+		header, err := client.HeaderByNumber(ctx, number)
+		if err != nil {
+			return nil, common.Hash{}, err
+		}
+		return header, header.Hash(), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*types.Header), nil
+}
+
+// SubscribeFilterLogs implements EthClient.
+func (c *MultiClient) SubscribeFilterLogs(ctx context.Context, q ethereum.FilterQuery, ch chan<- types.Log) (sub ethereum.Subscription, err error) {
+	err = c.failover(ctx, "SubscribeFilterLogs", func(client EthClient) error {
+		sub, err = client.SubscribeFilterLogs(ctx, q, ch)
+		return err
+	})
+	return sub, err
+}
+
+// SubscribeNewHead implements HeadSubscriber.
+func (c *MultiClient) SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (sub ethereum.Subscription, err error) {
+	err = c.failover(ctx, "SubscribeNewHead", func(client EthClient) error {
+		headSubscriber, ok := client.(HeadSubscriber)
+		if !ok {
+			return rpc.ErrNotificationsUnsupported
+		}
+		sub, err = headSubscriber.SubscribeNewHead(ctx, ch)
+		return err
+	})
+	return sub, err
+}
+
+// TransactionByHash implements EthClient.
+func (c *MultiClient) TransactionByHash(ctx context.Context, txHash common.Hash) (tx *types.Transaction, isPending bool, err error) {
+	err = c.failover(ctx, "TransactionByHash", func(client EthClient) error {
+		tx, isPending, err = client.TransactionByHash(ctx, txHash)
+		return err
+	})
+	return tx, isPending, err
+}
+
+// BlockByHash implements EthClient.
+func (c *MultiClient) BlockByHash(ctx context.Context, hash common.Hash) (block *types.Block, err error) {
+	err = c.failover(ctx, "BlockByHash", func(client EthClient) error {
+		block, err = client.BlockByHash(ctx, hash)
+		return err
+	})
+	return block, err
+}
@@ -0,0 +1,192 @@
+package sync_service
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/scroll-tech/go-ethereum"
+	"github.com/scroll-tech/go-ethereum/core/rawdb"
+	"github.com/scroll-tech/go-ethereum/core/types"
+)
+
+// faultyL1Client wraps an EthClient and fails or tampers with its responses.
+type faultyL1Client struct {
+	EthClient
+	err         error
+	tamperLogs  bool
+	blockNumber uint64
+	calls       int32
+}
+
+func (c *faultyL1Client) BlockNumber(ctx context.Context) (uint64, error) {
+	atomic.AddInt32(&c.calls, 1)
+	if c.err != nil {
+		return 0, c.err
+	}
+	if c.blockNumber != 0 {
+		return c.blockNumber, nil
+	}
+	return c.EthClient.BlockNumber(ctx)
+}
+
+func (c *faultyL1Client) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	atomic.AddInt32(&c.calls, 1)
+	if c.err != nil {
+		return nil, c.err
+	}
+	logs, err := c.EthClient.FilterLogs(ctx, q)
+	if err != nil || !c.tamperLogs {
+		return logs, err
+	}
+	for i := range logs {
+		logs[i].Data = append([]byte{}, logs[i].Data...)
+		logs[i].Data[len(logs[i].Data)-1] ^= 0xff
+	}
+	return logs, nil
+}
+
+func (c *faultyL1Client) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	atomic.AddInt32(&c.calls, 1)
+	if c.err != nil {
+		return nil, c.err
+	}
+	header, err := c.EthClient.HeaderByNumber(ctx, number)
+	if err != nil || !c.tamperLogs {
+		return header, err
+	}
+	header = types.CopyHeader(header)
+	header.Extra = []byte("tampered")
+	return header, nil
+}
+
+func newTestMultiClient(t *testing.T, quorum int, clients ...EthClient) *MultiClient {
+	names := make([]string, len(clients))
+	for i := range clients {
+		names[i] = string(rune('a' + i))
+	}
+	client, err := NewMultiClient(names, clients, MultiClientConfig{Quorum: quorum})
+	require.NoError(t, err)
+	return client
+}
+
+func TestMultiClientFailover(t *testing.T) {
+	chain := newMockL1Chain(10)
+	broken := &faultyL1Client{EthClient: chain, err: errors.New("connection refused")}
+	healthy := &faultyL1Client{EthClient: chain}
+	client := newTestMultiClient(t, 0, broken, healthy)
+
+	number, err := client.BlockNumber(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, uint64(9), number)
+	assert.False(t, client.endpoints[0].isHealthy())
+
+	// unhealthy endpoints are tried last
+	_, err = client.BlockNumber(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&broken.calls))
+
+	// the health check restores recovered endpoints
+	broken.err = nil
+	client.checkHealth(context.Background())
+	assert.True(t, client.endpoints[0].isHealthy())
+
+	// endpoints that lag behind are unhealthy
+	broken.blockNumber = 1
+	healthy.blockNumber = 100
+	client.checkHealth(context.Background())
+	assert.False(t, client.endpoints[0].isHealthy())
+	assert.True(t, client.endpoints[1].isHealthy())
+
+	// requests fail if all endpoints fail
+	healthy.err = errors.New("timeout")
+	broken.err = errors.New("connection refused")
+	_, err = client.BlockNumber(context.Background())
+	assert.ErrorContains(t, err, "BlockNumber failed on all 2 L1 endpoints")
+
+	// errors caused by the request do not affect endpoint health
+	chain.maxQueryRange = 1
+	healthy.err, broken.err = nil, nil
+	client.checkHealth(context.Background())
+	_, err = client.FilterLogs(context.Background(), ethereum.FilterQuery{FromBlock: big.NewInt(0), ToBlock: big.NewInt(5)})
+	assert.True(t, isRangeTooLargeError(err))
+	assert.True(t, client.endpoints[1].isHealthy())
+}
+
+func TestMultiClientFailoverOnRateLimit(t *testing.T) {
+	chain := newMockL1Chain(10)
+	rateLimited := &faultyL1Client{EthClient: chain, err: limitExceededError{"limit exceeded"}}
+	healthy := &faultyL1Client{EthClient: chain}
+	client := newTestMultiClient(t, 0, rateLimited, healthy)
+
+	// rate limits are specific to the endpoint, the request is sent to the next one
+	number, err := client.BlockNumber(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, uint64(9), number)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&rateLimited.calls))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&healthy.calls))
+	assert.False(t, client.endpoints[0].isHealthy())
+
+	// if all endpoints are rate limited, the error is still recognized as a rate limit
+	healthy.err = limitExceededError{"limit exceeded"}
+	_, err = client.BlockNumber(context.Background())
+	assert.ErrorContains(t, err, "BlockNumber failed on all 2 L1 endpoints")
+	assert.True(t, isRateLimitError(err))
+}
+
+func TestMultiClientQuorum(t *testing.T) {
+	chain := newMockL1Chain(10)
+	chain.addMessage(t, 5, 0, []byte{0})
+	tampered := &faultyL1Client{EthClient: chain, tamperLogs: true}
+	query := ethereum.FilterQuery{FromBlock: big.NewInt(0), ToBlock: big.NewInt(9)}
+
+	expected, err := chain.FilterLogs(context.Background(), query)
+	require.NoError(t, err)
+
+	// 2-of-3 endpoints agree
+	client := newTestMultiClient(t, 2, tampered, chain, chain)
+	logs, err := client.FilterLogs(context.Background(), query)
+	require.NoError(t, err)
+	assert.Equal(t, expected, logs)
+
+	header, err := client.HeaderByNumber(context.Background(), big.NewInt(5))
+	require.NoError(t, err)
+	assert.Equal(t, chain.headers[5].Hash(), header.Hash())
+
+	// no quorum
+	client = newTestMultiClient(t, 2, tampered, chain, &faultyL1Client{EthClient: chain, err: errors.New("timeout")})
+	_, err = client.FilterLogs(context.Background(), query)
+	assert.ErrorIs(t, err, errNoQuorum)
+	_, err = client.HeaderByNumber(context.Background(), big.NewInt(5))
+	assert.ErrorIs(t, err, errNoQuorum)
+
+	// block tags are not subject to the quorum
+	header, err = client.HeaderByNumber(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(9), header.Number.Uint64())
+
+	_, err = NewMultiClient([]string{"a"}, []EthClient{chain}, MultiClientConfig{Quorum: 2})
+	assert.Error(t, err)
+}
+
+func TestSyncServiceMultiClientQuorum(t *testing.T) {
+	chain := newMockL1Chain(21)
+	chain.addMessage(t, 5, 0, []byte{0})
+	chain.addMessage(t, 12, 1, []byte{1})
+
+	client := newTestMultiClient(t, 2, &faultyL1Client{EthClient: chain, tamperLogs: true}, chain, chain)
+	service := newTestSyncService(t, client)
+	service.fetchMessages()
+
+	assert.Equal(t, uint64(20), service.latestProcessedBlock)
+	for ii := uint64(0); ii < 2; ii++ {
+		msg := rawdb.ReadL1Message(service.db, ii)
+		require.NotNil(t, msg)
+		assert.Equal(t, []byte{byte(ii)}, msg.Data)
+	}
+}
@@ -0,0 +1,262 @@
+package sync_service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/scroll-tech/go-ethereum/log"
+	"github.com/scroll-tech/go-ethereum/metrics"
+	"github.com/scroll-tech/go-ethereum/node"
+	"github.com/scroll-tech/go-ethereum/rpc"
+)
+
+const (
+	// DefaultMaxFetchBlockRange is the upper bound the query range can grow to after successful queries.
+	DefaultMaxFetchBlockRange = uint64(1000)
+
+	// DefaultFetchConcurrency is the number of eth_getLogs queries that are executed in parallel.
+	DefaultFetchConcurrency = 1
+
+	// limitExceededErrorCode is the JSON-RPC error code used by providers to signal that a request exceeded a limit.
+	// Most providers use it for rate limits, so it only means that the range is too large together with a size message.
+	limitExceededErrorCode = -32005
+
+	// rateLimitBackoff is the delay before retrying a rate limited query, it is doubled on every further retry.
+	rateLimitBackoff = time.Second
+
+	// maxRateLimitRetries is the number of times a rate limited query is retried before giving up.
+	maxRateLimitRetries = 5
+)
+
+var (
+	fetchBlockRangeGauge = metrics.NewRegisteredGauge("rollup/l1/fetch/range", nil)
+
+	// rangeTooLargeErrorMessages are error message fragments returned by common L1 providers
+	// when an eth_getLogs query covers too many blocks or returns too many logs.
+	rangeTooLargeErrorMessages = []string{
+		"too many results",
+		"query returned more than",
+		"response size",
+		"response is too big",
+		"block range is too wide",
+		"maximum block range",
+		"range too large",
+		"query timeout exceeded",
+	}
+
+	// rateLimitErrorMessages are error message fragments returned by common L1 providers
+	// when a client sends too many requests.
+	rateLimitErrorMessages = []string{
+		"rate limit",
+		"too many requests",
+		"limit exceeded",
+		"exceeded its compute units",
+	}
+)
+
+// FetchConfig configures how a RangeFetcher splits a block range into queries.
+type FetchConfig struct {
+	BlockRange    uint64 // Number of blocks in the first query
+	MaxBlockRange uint64 // Upper bound for the number of blocks in a single query
+	Concurrency   int    // Maximum number of parallel queries
+}
+
+// DefaultFetchConfig contains the default settings for fetching L1 logs.
+var DefaultFetchConfig = FetchConfig{
+	BlockRange:    DefaultFetchBlockRange,
+	MaxBlockRange: DefaultMaxFetchBlockRange,
+	Concurrency:   DefaultFetchConcurrency,
+}
+
+// FetchConfigFromNodeConfig returns the fetch settings configured in the node config,
+// unset values are taken from DefaultFetchConfig.
+func FetchConfigFromNodeConfig(nodeConfig *node.Config) FetchConfig {
+	config := DefaultFetchConfig
+	if nodeConfig.L1FetchBlockRange > 0 {
+		config.BlockRange = nodeConfig.L1FetchBlockRange
+	}
+	if nodeConfig.L1MaxFetchBlockRange > 0 {
+		config.MaxBlockRange = nodeConfig.L1MaxFetchBlockRange
+	}
+	if config.MaxBlockRange < config.BlockRange {
+		config.MaxBlockRange = config.BlockRange
+	}
+	if nodeConfig.L1FetchConcurrency > 0 {
+		config.Concurrency = nodeConfig.L1FetchConcurrency
+	}
+	return config
+}
+
+// QueryFunc fetches the data of the L1 blocks [from, to].
+type QueryFunc func(ctx context.Context, from, to uint64) (interface{}, error)
+
+// CommitFunc processes the result of a query. It is called for consecutive block ranges in ascending order.
+type CommitFunc func(from, to uint64, result interface{}) error
+
+// RangeFetcher splits a block range into queries and runs up to Concurrency of them in parallel,
+// while results are committed strictly in block order. The number of blocks per query is halved
+// when the provider rejects a query as too large, and doubled again after successful rounds.
+// Rate limited queries are retried with the same range after an exponential backoff.
+type RangeFetcher struct {
+	config           FetchConfig
+	blockRange       uint64
+	rateLimitBackoff time.Duration
+	mu               sync.Mutex
+}
+
+// NewRangeFetcher creates a new RangeFetcher.
+func NewRangeFetcher(config FetchConfig) *RangeFetcher {
+	if config.BlockRange == 0 {
+		config.BlockRange = DefaultFetchBlockRange
+	}
+	if config.MaxBlockRange < config.BlockRange {
+		config.MaxBlockRange = config.BlockRange
+	}
+	if config.Concurrency < 1 {
+		config.Concurrency = 1
+	}
+	return &RangeFetcher{config: config, blockRange: config.BlockRange, rateLimitBackoff: rateLimitBackoff}
+}
+
+// BlockRange returns the current number of blocks per query.
+func (f *RangeFetcher) BlockRange() uint64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.blockRange
+}
+
+type queryResult struct {
+	from, to uint64
+	result   interface{}
+	err      error
+}
+
+// Fetch queries the blocks [from, to] and commits the results in order. It returns the first error
+// that is not resolved by shrinking the query range or by backing off, or the first error returned by commit.
+// Ranges committed before the error are not rolled back.
+func (f *RangeFetcher) Fetch(ctx context.Context, from, to uint64, query QueryFunc, commit CommitFunc) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	rateLimitRetries := 0
+	for next := from; next <= to; {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// split the next section into up to Concurrency queries of the current range size
+		var results []*queryResult
+		for start := next; start <= to && len(results) < f.config.Concurrency; start += f.blockRange {
+			end := start + f.blockRange - 1
+			if end > to || end < start {
+				end = to
+			}
+			results = append(results, &queryResult{from: start, to: end})
+		}
+
+		queryCtx, cancel := context.WithCancel(ctx)
+		var wg sync.WaitGroup
+		for _, r := range results {
+			wg.Add(1)
+			go func(r *queryResult) {
+				defer wg.Done()
+				r.result, r.err = query(queryCtx, r.from, r.to)
+			}(r)
+		}
+		wg.Wait()
+		cancel()
+
+		// commit in order until the first failed query
+		shrunk := false
+		var backoff time.Duration
+		for _, r := range results {
+			if r.err != nil {
+				if isRangeTooLargeError(r.err) && r.to > r.from {
+					f.shrink(r.to - r.from + 1)
+					log.Debug("L1 query range too large, retrying with a smaller range", "from", r.from, "to", r.to, "range", f.blockRange, "err", r.err)
+					shrunk = true
+				} else if isRateLimitError(r.err) && rateLimitRetries < maxRateLimitRetries {
+					backoff = f.rateLimitBackoff << rateLimitRetries
+					rateLimitRetries++
+					log.Debug("L1 provider rate limit reached, backing off", "from", r.from, "to", r.to, "backoff", backoff, "err", r.err)
+				} else {
+					return fmt.Errorf("failed to fetch L1 blocks [%d, %d]: %w", r.from, r.to, r.err)
+				}
+				break
+			}
+			if err := commit(r.from, r.to, r.result); err != nil {
+				return err
+			}
+			next = r.to + 1
+		}
+		if backoff > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			continue
+		}
+		rateLimitRetries = 0
+		if !shrunk && next <= to {
+			f.grow()
+		}
+	}
+	return nil
+}
+
+// shrink halves the query range based on the size of the failed query.
+func (f *RangeFetcher) shrink(failedRange uint64) {
+	f.blockRange = failedRange / 2
+	if f.blockRange < 1 {
+		f.blockRange = 1
+	}
+	fetchBlockRangeGauge.Update(int64(f.blockRange))
+}
+
+// grow doubles the query range up to the configured maximum.
+func (f *RangeFetcher) grow() {
+	if f.blockRange >= f.config.MaxBlockRange {
+		return
+	}
+	f.blockRange *= 2
+	if f.blockRange > f.config.MaxBlockRange {
+		f.blockRange = f.config.MaxBlockRange
+	}
+	fetchBlockRangeGauge.Update(int64(f.blockRange))
+}
+
+// isRangeTooLargeError reports whether err indicates that an eth_getLogs query should be split into smaller ranges.
+func isRangeTooLargeError(err error) bool {
+	return containsAny(strings.ToLower(err.Error()), rangeTooLargeErrorMessages)
+}
+
+// isRateLimitError reports whether err indicates that the provider rejected a request because of its rate limit.
+func isRateLimitError(err error) bool {
+	if isRangeTooLargeError(err) {
+		return false
+	}
+	var httpErr rpc.HTTPError
+	if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) && rpcErr.ErrorCode() == limitExceededErrorCode {
+		return true
+	}
+	return containsAny(strings.ToLower(err.Error()), rateLimitErrorMessages)
+}
+
+func containsAny(msg string, fragments []string) bool {
+	for _, m := range fragments {
+		if strings.Contains(msg, m) {
+			return true
+		}
+	}
+	return false
+}
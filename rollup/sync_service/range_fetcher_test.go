@@ -0,0 +1,199 @@
+package sync_service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/scroll-tech/go-ethereum/rpc"
+)
+
+type committedRange struct {
+	from, to uint64
+}
+
+type limitExceededError struct {
+	msg string
+}
+
+func (e limitExceededError) Error() string { return e.msg }
+func (limitExceededError) ErrorCode() int  { return limitExceededErrorCode }
+
+var _ rpc.Error = limitExceededError{}
+
+func TestRangeFetcherShrinksAndGrows(t *testing.T) {
+	fetcher := NewRangeFetcher(FetchConfig{BlockRange: 64, MaxBlockRange: 64, Concurrency: 1})
+
+	var queried []committedRange
+	query := func(ctx context.Context, from, to uint64) (interface{}, error) {
+		queried = append(queried, committedRange{from, to})
+		if to-from+1 > 20 {
+			return nil, errors.New("query returned more than 10000 results")
+		}
+		return to - from + 1, nil
+	}
+	var committed []committedRange
+	commit := func(from, to uint64, result interface{}) error {
+		assert.Equal(t, to-from+1, result)
+		committed = append(committed, committedRange{from, to})
+		return nil
+	}
+
+	require.NoError(t, fetcher.Fetch(context.Background(), 1, 100, query, commit))
+
+	// 64 -> 32 -> 16 blocks, then grows to 32 which is rejected again
+	assert.Equal(t, []committedRange{{1, 64}, {1, 32}, {1, 16}, {17, 48}, {17, 32}, {33, 64}, {33, 48}}, queried[:7])
+
+	// all blocks are committed exactly once, in order
+	next := uint64(1)
+	for _, r := range committed {
+		assert.Equal(t, next, r.from)
+		assert.LessOrEqual(t, r.to-r.from+1, uint64(20))
+		next = r.to + 1
+	}
+	assert.Equal(t, uint64(101), next)
+}
+
+func TestRangeFetcherConcurrentInOrder(t *testing.T) {
+	fetcher := NewRangeFetcher(FetchConfig{BlockRange: 10, MaxBlockRange: 10, Concurrency: 4})
+
+	var (
+		mu          sync.Mutex
+		inFlight    int
+		maxInFlight int
+	)
+	query := func(ctx context.Context, from, to uint64) (interface{}, error) {
+		mu.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mu.Unlock()
+
+		// later ranges finish first
+		time.Sleep(time.Duration(100-from%40) * time.Microsecond * 50)
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		return from, nil
+	}
+	var committed []committedRange
+	commit := func(from, to uint64, result interface{}) error {
+		assert.Equal(t, from, result)
+		committed = append(committed, committedRange{from, to})
+		return nil
+	}
+
+	require.NoError(t, fetcher.Fetch(context.Background(), 0, 95, query, commit))
+
+	assert.Equal(t, 4, maxInFlight)
+	require.Len(t, committed, 10)
+	for ii, r := range committed {
+		assert.Equal(t, uint64(ii*10), r.from)
+	}
+	assert.Equal(t, uint64(95), committed[9].to)
+}
+
+func TestRangeFetcherErrors(t *testing.T) {
+	fetcher := NewRangeFetcher(FetchConfig{BlockRange: 10, Concurrency: 2})
+
+	// other errors are returned after committing the preceding ranges
+	var committed []committedRange
+	err := fetcher.Fetch(context.Background(), 0, 49,
+		func(ctx context.Context, from, to uint64) (interface{}, error) {
+			if from == 30 {
+				return nil, errors.New("connection refused")
+			}
+			return nil, nil
+		},
+		func(from, to uint64, result interface{}) error {
+			committed = append(committed, committedRange{from, to})
+			return nil
+		})
+	assert.ErrorContains(t, err, "connection refused")
+	assert.Equal(t, []committedRange{{0, 9}, {10, 19}, {20, 29}}, committed)
+
+	// commit errors stop fetching
+	commitErr := errors.New("commit failed")
+	err = fetcher.Fetch(context.Background(), 0, 49,
+		func(ctx context.Context, from, to uint64) (interface{}, error) { return nil, nil },
+		func(from, to uint64, result interface{}) error { return commitErr })
+	assert.ErrorIs(t, err, commitErr)
+
+	// a single block that is too large cannot be split further
+	err = fetcher.Fetch(context.Background(), 0, 49,
+		func(ctx context.Context, from, to uint64) (interface{}, error) {
+			return nil, limitExceededError{"query returned more than 10000 results"}
+		},
+		func(from, to uint64, result interface{}) error { return nil })
+	assert.ErrorContains(t, err, "query returned more than 10000 results")
+	assert.Equal(t, uint64(1), fetcher.BlockRange())
+}
+
+func TestRangeFetcherBacksOffOnRateLimit(t *testing.T) {
+	fetcher := NewRangeFetcher(FetchConfig{BlockRange: 10, MaxBlockRange: 10, Concurrency: 1})
+	fetcher.rateLimitBackoff = time.Millisecond
+
+	// the provider rate limits the first two attempts to query [10, 19]
+	var queried []committedRange
+	rateLimited := 0
+	query := func(ctx context.Context, from, to uint64) (interface{}, error) {
+		queried = append(queried, committedRange{from, to})
+		if from == 10 && rateLimited < 2 {
+			rateLimited++
+			return nil, limitExceededError{"limit exceeded"}
+		}
+		return nil, nil
+	}
+	var committed []committedRange
+	commit := func(from, to uint64, result interface{}) error {
+		committed = append(committed, committedRange{from, to})
+		return nil
+	}
+
+	require.NoError(t, fetcher.Fetch(context.Background(), 0, 29, query, commit))
+	assert.Equal(t, []committedRange{{0, 9}, {10, 19}, {10, 19}, {10, 19}, {20, 29}}, queried)
+	assert.Equal(t, []committedRange{{0, 9}, {10, 19}, {20, 29}}, committed)
+	assert.Equal(t, uint64(10), fetcher.BlockRange())
+
+	// a provider that keeps rate limiting fails the fetch after the maximum number of retries
+	attempts := 0
+	err := fetcher.Fetch(context.Background(), 0, 29,
+		func(ctx context.Context, from, to uint64) (interface{}, error) {
+			attempts++
+			return nil, rpc.HTTPError{StatusCode: http.StatusTooManyRequests, Status: "429 Too Many Requests"}
+		},
+		func(from, to uint64, result interface{}) error { return nil })
+	assert.ErrorContains(t, err, "429 Too Many Requests")
+	assert.Equal(t, maxRateLimitRetries+1, attempts)
+	assert.Equal(t, uint64(10), fetcher.BlockRange())
+}
+
+func TestIsRangeTooLargeError(t *testing.T) {
+	assert.True(t, isRangeTooLargeError(errors.New("query returned more than 10000 results")))
+	assert.True(t, isRangeTooLargeError(errors.New("Log response size exceeded. You can make eth_getLogs requests with up to a 2K block range")))
+	assert.True(t, isRangeTooLargeError(errors.New("exceed maximum block range: 5000")))
+	assert.True(t, isRangeTooLargeError(errors.New("block range is too wide")))
+	assert.False(t, isRangeTooLargeError(errors.New("invalid block range params")))
+	assert.False(t, isRangeTooLargeError(errors.New("block range extends beyond current head block")))
+	assert.True(t, isRangeTooLargeError(limitExceededError{"query returned more than 10000 results"}))
+	assert.False(t, isRangeTooLargeError(limitExceededError{"limit exceeded"}))
+	assert.False(t, isRangeTooLargeError(errors.New("connection refused")))
+	assert.False(t, isRangeTooLargeError(context.DeadlineExceeded))
+}
+
+func TestIsRateLimitError(t *testing.T) {
+	assert.True(t, isRateLimitError(limitExceededError{"limit exceeded"}))
+	assert.True(t, isRateLimitError(limitExceededError{"request rate exceeded"}))
+	assert.True(t, isRateLimitError(errors.New("Your app has exceeded its compute units per second capacity")))
+	assert.True(t, isRateLimitError(rpc.HTTPError{StatusCode: http.StatusTooManyRequests, Status: "429 Too Many Requests"}))
+	assert.False(t, isRateLimitError(limitExceededError{"query returned more than 10000 results"}))
+	assert.False(t, isRateLimitError(errors.New("connection refused")))
+}
@@ -2,13 +2,16 @@ package sync_service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"reflect"
 	"sync"
 	"time"
 
+	"github.com/scroll-tech/go-ethereum/common"
 	"github.com/scroll-tech/go-ethereum/core"
 	"github.com/scroll-tech/go-ethereum/core/rawdb"
+	"github.com/scroll-tech/go-ethereum/core/types"
 	"github.com/scroll-tech/go-ethereum/ethdb"
 	"github.com/scroll-tech/go-ethereum/event"
 	"github.com/scroll-tech/go-ethereum/log"
@@ -35,10 +38,18 @@ const (
 	// a long section of L1 blocks with no messages and we stop or crash, we will not need to re-scan
 	// this secion.
 	DbWriteThresholdBlocks = 1000
+
+	// MaxL1ReorgDepth is the maximum depth of L1 reorgs that we can recover from.
+	// L1 block checkpoints older than this are pruned from the database.
+	MaxL1ReorgDepth = uint64(256)
 )
 
 var (
+	errUnexpectedQueueIndex = errors.New("unexpected L1 message queue index")
+	errL1ReorgDuringFetch   = errors.New("L1 reorg during query")
+
 	l1MessageTotalCounter = metrics.NewRegisteredCounter("rollup/l1/message", nil)
+	l1ReorgCounter        = metrics.NewRegisteredCounter("rollup/l1/reorg", nil)
 )
 
 // SyncService collects all L1 messages and stores them in a local database.
@@ -46,8 +57,10 @@ type SyncService struct {
 	ctx                  context.Context
 	cancel               context.CancelFunc
 	client               *BridgeClient
+	fetcher              *RangeFetcher
 	db                   ethdb.Database
 	msgCountFeed         event.Feed
+	reorgFeed            event.Feed
 	pollInterval         time.Duration
 	latestProcessedBlock uint64
 	scope                event.SubscriptionScope
@@ -84,6 +97,7 @@ func NewSyncService(ctx context.Context, genesisConfig *params.ChainConfig, node
 		ctx:                  ctx,
 		cancel:               cancel,
 		client:               client,
+		fetcher:              NewRangeFetcher(FetchConfigFromNodeConfig(nodeConfig)),
 		db:                   db,
 		pollInterval:         DefaultPollInterval,
 		latestProcessedBlock: latestProcessedBlock,
@@ -108,6 +122,9 @@ func (s *SyncService) Start() {
 		log.Info("L1 message initial sync completed", "latestProcessedBlock", s.latestProcessedBlock)
 	}
 
+	// new L1 heads and messages trigger a sync immediately, polling is the fallback
+	updates := WatchL1(s.ctx, s.client.client, s.client.messagesQuery(), DefaultResubscribeInterval)
+
 	go func() {
 		t := time.NewTicker(s.pollInterval)
 		defer t.Stop()
@@ -121,6 +138,8 @@ func (s *SyncService) Start() {
 				return
 			case <-t.C:
 				continue
+			case <-updates:
+				continue
 			}
 		}
 	}()
@@ -160,6 +179,12 @@ func (s *SyncService) SubscribeNewL1MsgsEvent(ch chan<- core.NewL1MsgsEvent) eve
 	return s.scope.Track(s.msgCountFeed.Subscribe(ch))
 }
 
+// SubscribeL1ReorgEvent registers a subscription of L1ReorgEvent and
+// starts sending event to the given channel.
+func (s *SyncService) SubscribeL1ReorgEvent(ch chan<- core.L1ReorgEvent) event.Subscription {
+	return s.scope.Track(s.reorgFeed.Subscribe(ch))
+}
+
 func (s *SyncService) fetchMessages() {
 	s.stateMu.Lock()
 	defer s.stateMu.Unlock()
@@ -172,21 +197,38 @@ func (s *SyncService) fetchMessages() {
 
 	log.Trace("Sync service fetchMessages", "latestProcessedBlock", s.latestProcessedBlock, "latestConfirmed", latestConfirmed)
 
+	// make sure that the blocks we processed so far are still part of the canonical L1 chain
+	if s.latestProcessedBlock < latestConfirmed {
+		if err := s.detectReorg(); err != nil {
+			log.Warn("Failed to check for L1 reorg", "err", err)
+			return
+		}
+	}
+
 	// keep track of next queue index we're expecting to see
 	queueIndex := rawdb.ReadHighestSyncedQueueIndex(s.db)
+	nextIndex := nextQueueIndex(s.db)
 
 	batchWriter := s.db.NewBatch()
 	numBlocksPendingDbWrite := uint64(0)
 	numMessagesPendingDbWrite := 0
 
 	// helper function to flush database writes cached in memory
-	flush := func(lastBlock uint64) {
+	flush := func(lastBlock uint64, lastHash common.Hash) {
+		// record the hash of the last processed block so that we can detect L1 reorgs later
+		rawdb.WriteL1BlockCheckpoint(batchWriter, rawdb.L1BlockCheckpoint{
+			Number:         lastBlock,
+			Hash:           lastHash,
+			NextQueueIndex: nextIndex,
+		})
+		s.pruneCheckpoints(batchWriter, lastBlock)
+		s.pruneSyncTimes(batchWriter)
+
 		// update sync progress
 		rawdb.WriteSyncedL1BlockNumber(batchWriter, lastBlock)
 
 		// write batch in a single transaction
-		err := batchWriter.Write()
-		if err != nil {
+		if err := batchWriter.Write(); err != nil {
 			// crash on database error, no risk of inconsistency here
 			log.Crit("Failed to write L1 messages to database", "err", err)
 		}
@@ -205,42 +247,54 @@ func (s *SyncService) fetchMessages() {
 
 	// ticker for logging progress
 	t := time.NewTicker(LogProgressInterval)
+	defer t.Stop()
 	numMsgsCollected := 0
+	lastCommitted := s.latestProcessedBlock
+	var lastCommittedHash common.Hash
+
+	query := func(ctx context.Context, from, to uint64) (interface{}, error) {
+		// the last block of the range is fetched before and after the logs, so that the
+		// recorded hash belongs to the chain the logs were fetched from
+		header, err := s.client.getHeaderByNumber(ctx, to)
+		if err != nil {
+			return nil, err
+		}
+		msgs, checkpoints, err := s.client.fetchMessagesInRange(ctx, from, to)
+		if err != nil {
+			return nil, err
+		}
+		latest, err := s.client.getHeaderByNumber(ctx, to)
+		if err != nil {
+			return nil, err
+		}
+		if latest.Hash() != header.Hash() {
+			return nil, fmt.Errorf("%w: block %d changed from %s to %s", errL1ReorgDuringFetch, to, header.Hash().Hex(), latest.Hash().Hex())
+		}
+		return &messagesInRange{msgs: msgs, checkpoints: checkpoints, lastHash: header.Hash()}, nil
+	}
 
-	// query in batches
-	for from := s.latestProcessedBlock + 1; from <= latestConfirmed; from += DefaultFetchBlockRange {
+	// results are committed in order, even if they were queried concurrently
+	commit := func(from, to uint64, result interface{}) error {
 		select {
-		case <-s.ctx.Done():
-			// flush pending writes to database
-			if from > 0 {
-				flush(from - 1)
-			}
-			return
 		case <-t.C:
 			progress := 100 * float64(s.latestProcessedBlock) / float64(latestConfirmed)
 			log.Info("Syncing L1 messages", "processed", s.latestProcessedBlock, "confirmed", latestConfirmed, "collected", numMsgsCollected, "progress(%)", progress)
 		default:
 		}
 
-		to := from + DefaultFetchBlockRange - 1
-		if to > latestConfirmed {
-			to = latestConfirmed
-		}
-
-		msgs, err := s.client.fetchMessagesInRange(s.ctx, from, to)
-		if err != nil {
-			// flush pending writes to database
-			if from > 0 {
-				flush(from - 1)
-			}
-			log.Warn("Failed to fetch L1 messages in range", "fromBlock", from, "toBlock", to, "err", err)
-			return
-		}
+		res := result.(*messagesInRange)
+		msgs, checkpoints := res.msgs, res.checkpoints
 
 		if len(msgs) > 0 {
 			log.Debug("Received new L1 events", "fromBlock", from, "toBlock", to, "count", len(msgs))
 			rawdb.WriteL1Messages(batchWriter, msgs) // collect messages in memory
 			numMsgsCollected += len(msgs)
+
+			// record when messages were synced so that the sequencer can track their age
+			syncTime := uint64(time.Now().Unix())
+			for _, msg := range msgs {
+				rawdb.WriteL1MessageSyncTime(batchWriter, msg.QueueIndex, syncTime)
+			}
 		}
 
 		for _, msg := range msgs {
@@ -250,16 +304,185 @@ func (s *SyncService) fetchMessages() {
 			// check if received queue index matches expected queue index
 			if msg.QueueIndex != queueIndex {
 				log.Error("Unexpected queue index in SyncService", "expected", queueIndex, "got", msg.QueueIndex, "msg", msg)
-				return // do not flush inconsistent data to disk
+				return errUnexpectedQueueIndex
+			}
+			nextIndex = msg.QueueIndex + 1
+		}
+
+		for _, checkpoint := range checkpoints {
+			// checkpoints this far behind the L1 head would be pruned right away
+			if checkpoint.Number+MaxL1ReorgDepth >= latestConfirmed {
+				rawdb.WriteL1BlockCheckpoint(batchWriter, checkpoint)
 			}
 		}
 
 		numBlocksPendingDbWrite += to - from + 1
 		numMessagesPendingDbWrite += len(msgs)
+		lastCommitted, lastCommittedHash = to, res.lastHash
 
 		// flush new messages to database periodically
 		if to == latestConfirmed || batchWriter.ValueSize() >= DbWriteThresholdBytes || numBlocksPendingDbWrite >= DbWriteThresholdBlocks {
-			flush(to)
+			flush(to, res.lastHash)
+		}
+		return nil
+	}
+
+	err = s.fetcher.Fetch(s.ctx, s.latestProcessedBlock+1, latestConfirmed, query, commit)
+	switch {
+	case err == nil:
+	case s.ctx.Err() != nil:
+		// pending writes are discarded, we will fetch them again after restart
+	case errors.Is(err, errUnexpectedQueueIndex):
+		// do not flush inconsistent data to disk
+	default:
+		// flush pending writes to database
+		if numBlocksPendingDbWrite > 0 {
+			flush(lastCommitted, lastCommittedHash)
 		}
+		log.Warn("Failed to fetch L1 messages", "fromBlock", lastCommitted+1, "toBlock", latestConfirmed, "err", err)
+	}
+}
+
+// detectReorg checks whether the latest processed L1 block is still part of the
+// canonical L1 chain. If not, it rolls back the L1 messages synced from reorged blocks.
+func (s *SyncService) detectReorg() error {
+	checkpoint := rawdb.ReadL1BlockCheckpoint(s.db, s.latestProcessedBlock)
+	if checkpoint == nil {
+		// no checkpoint recorded for this block (e.g. after a reset), nothing to compare against
+		return nil
+	}
+
+	header, err := s.client.getHeaderByNumber(s.ctx, s.latestProcessedBlock+1)
+	if err != nil {
+		return err
+	}
+	if header.ParentHash == checkpoint.Hash {
+		return nil
+	}
+
+	log.Warn("L1 reorg detected", "number", s.latestProcessedBlock, "expected", checkpoint.Hash.Hex(), "got", header.ParentHash.Hex())
+	return s.rollback()
+}
+
+// rollback finds the latest checkpoint that is still part of the canonical L1 chain,
+// and removes all L1 messages and checkpoints recorded after it from the database.
+// If the reorg is deeper than all retained checkpoints, it rolls back to the oldest
+// of them, whose L1 messages cannot be verified anymore.
+func (s *SyncService) rollback() error {
+	from := uint64(0)
+	if s.latestProcessedBlock > MaxL1ReorgDepth {
+		from = s.latestProcessedBlock - MaxL1ReorgDepth
+	}
+	checkpoints := rawdb.ReadL1BlockCheckpointsFrom(s.db, from)
+
+	var ancestor *rawdb.L1BlockCheckpoint
+	for ii := len(checkpoints) - 1; ii >= 0; ii-- {
+		if checkpoints[ii].Number > s.latestProcessedBlock {
+			continue
+		}
+		header, err := s.client.getHeaderByNumber(s.ctx, checkpoints[ii].Number)
+		if err != nil {
+			return err
+		}
+		if header.Hash() == checkpoints[ii].Hash {
+			ancestor = &checkpoints[ii]
+			break
+		}
+	}
+	batchWriter := s.db.NewBatch()
+
+	if ancestor == nil {
+		if len(checkpoints) == 0 || checkpoints[0].Number > s.latestProcessedBlock {
+			return fmt.Errorf("no L1 block checkpoint found within the last %d L1 blocks", MaxL1ReorgDepth)
+		}
+		// resync from the oldest checkpoint, and record its canonical hash so that
+		// the reorg is not detected again
+		header, err := s.client.getHeaderByNumber(s.ctx, checkpoints[0].Number)
+		if err != nil {
+			return err
+		}
+		ancestor = &rawdb.L1BlockCheckpoint{
+			Number:         checkpoints[0].Number,
+			Hash:           header.Hash(),
+			NextQueueIndex: checkpoints[0].NextQueueIndex,
+		}
+		rawdb.WriteL1BlockCheckpoint(batchWriter, *ancestor)
+		log.Error("L1 reorg deeper than the retained checkpoints, L1 messages up to the oldest checkpoint are not verified", "number", ancestor.Number, "nextQueueIndex", ancestor.NextQueueIndex)
+	}
+
+	// remove L1 messages emitted in reorged blocks
+	nextIndex := nextQueueIndex(s.db)
+	for index := ancestor.NextQueueIndex; index < nextIndex; index++ {
+		rawdb.DeleteL1Message(batchWriter, index)
+		rawdb.DeleteL1MessageSyncTime(batchWriter, index)
+	}
+	if ancestor.NextQueueIndex > 0 {
+		rawdb.WriteHighestSyncedQueueIndex(batchWriter, ancestor.NextQueueIndex-1)
+	} else {
+		rawdb.WriteHighestSyncedQueueIndex(batchWriter, 0)
+	}
+
+	// remove checkpoints of reorged blocks
+	for _, checkpoint := range checkpoints {
+		if checkpoint.Number > ancestor.Number {
+			rawdb.DeleteL1BlockCheckpoint(batchWriter, checkpoint.Number)
+		}
+	}
+
+	rawdb.WriteSyncedL1BlockNumber(batchWriter, ancestor.Number)
+
+	if err := batchWriter.Write(); err != nil {
+		// crash on database error, no risk of inconsistency here
+		log.Crit("Failed to roll back L1 messages", "err", err)
+	}
+
+	numRemoved := 0
+	if nextIndex > ancestor.NextQueueIndex {
+		numRemoved = int(nextIndex - ancestor.NextQueueIndex)
+	}
+	log.Warn("Rolled back L1 messages due to L1 reorg", "from", s.latestProcessedBlock, "to", ancestor.Number, "firstRemovedQueueIndex", ancestor.NextQueueIndex, "removed", numRemoved)
+
+	s.latestProcessedBlock = ancestor.Number
+	l1ReorgCounter.Inc(1)
+	s.reorgFeed.Send(core.L1ReorgEvent{
+		CommonAncestor:         ancestor.Number,
+		FirstRemovedQueueIndex: ancestor.NextQueueIndex,
+		NumRemovedMessages:     numRemoved,
+	})
+	return nil
+}
+
+// pruneCheckpoints removes checkpoints that are more than MaxL1ReorgDepth blocks behind latestBlock.
+func (s *SyncService) pruneCheckpoints(db ethdb.KeyValueWriter, latestBlock uint64) {
+	if latestBlock > MaxL1ReorgDepth {
+		rawdb.DeleteL1BlockCheckpointsBelow(s.db, db, latestBlock-MaxL1ReorgDepth)
+	}
+}
+
+// pruneSyncTimes removes the sync times of L1 messages included in the finalized L2 chain, or in the
+// L2 chain head if no L2 block is finalized. Sync times are only used for the age of pending messages.
+func (s *SyncService) pruneSyncTimes(db ethdb.KeyValueWriter) {
+	hash := rawdb.ReadHeadBlockHash(s.db)
+	if finalized := rawdb.ReadFinalizedL2BlockNumber(s.db); finalized != nil {
+		hash = rawdb.ReadCanonicalHash(s.db, *finalized)
+	}
+	if queueIndex := rawdb.ReadFirstQueueIndexNotInL2Block(s.db, hash); queueIndex != nil {
+		rawdb.DeleteL1MessageSyncTimesBelow(s.db, db, *queueIndex)
+	}
+}
+
+// messagesInRange holds the result of a single L1 message query.
+type messagesInRange struct {
+	msgs        []types.L1MessageTx
+	checkpoints []rawdb.L1BlockCheckpoint
+	lastHash    common.Hash // hash of the last block of the range
+}
+
+// nextQueueIndex returns the queue index of the first L1 message not yet stored in the database.
+func nextQueueIndex(db ethdb.Reader) uint64 {
+	highest := rawdb.ReadHighestSyncedQueueIndex(db)
+	if highest == 0 && rawdb.ReadL1Message(db, 0) == nil {
+		return 0
 	}
+	return highest + 1
 }
@@ -0,0 +1,358 @@
+package sync_service
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/scroll-tech/go-ethereum"
+	"github.com/scroll-tech/go-ethereum/accounts/abi"
+	"github.com/scroll-tech/go-ethereum/common"
+	"github.com/scroll-tech/go-ethereum/core"
+	"github.com/scroll-tech/go-ethereum/core/rawdb"
+	"github.com/scroll-tech/go-ethereum/core/types"
+	"github.com/scroll-tech/go-ethereum/node"
+	"github.com/scroll-tech/go-ethereum/params"
+	"github.com/scroll-tech/go-ethereum/rpc"
+)
+
+var (
+	testL1ChainId             = uint64(1)
+	testL1MessageQueueAddress = common.HexToAddress("0x1000000000000000000000000000000000000001")
+)
+
+// mockL1Chain is a minimal in-memory L1 chain that serves headers and
+// QueueTransaction logs through the EthClient interface.
+type mockL1Chain struct {
+	mu      sync.Mutex
+	headers []*types.Header
+	logs    map[uint64][]types.Log // block number -> logs
+
+	maxQueryRange uint64 // if set, FilterLogs rejects queries covering more blocks
+	onFilterLogs  func() // if set, called by FilterLogs before the logs are collected
+}
+
+func newMockL1Chain(numBlocks uint64) *mockL1Chain {
+	c := &mockL1Chain{logs: make(map[uint64][]types.Log)}
+	c.extend(numBlocks, 0)
+	return c
+}
+
+// extend appends numBlocks blocks to the chain. fork is used to
+// distinguish blocks on different forks at the same height.
+func (c *mockL1Chain) extend(numBlocks uint64, fork byte) {
+	for ii := uint64(0); ii < numBlocks; ii++ {
+		header := &types.Header{
+			Number:     new(big.Int).SetUint64(uint64(len(c.headers))),
+			Difficulty: common.Big0,
+			Extra:      []byte{fork},
+		}
+		if len(c.headers) > 0 {
+			header.ParentHash = c.headers[len(c.headers)-1].Hash()
+		}
+		c.headers = append(c.headers, header)
+	}
+}
+
+// reorg replaces all blocks after number with numBlocks new blocks.
+func (c *mockL1Chain) reorg(number uint64, numBlocks uint64, fork byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for n := range c.logs {
+		if n > number {
+			delete(c.logs, n)
+		}
+	}
+	c.headers = c.headers[:number+1]
+	c.extend(numBlocks, fork)
+}
+
+func (c *mockL1Chain) addMessage(t *testing.T, number uint64, queueIndex uint64, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	l1MessageQueueABI, err := abi.JSON(strings.NewReader(L1MessageQueueMetaData.ABI))
+	require.NoError(t, err)
+	event := l1MessageQueueABI.Events["QueueTransaction"]
+	packed, err := event.Inputs.NonIndexed().Pack(big.NewInt(0), queueIndex, big.NewInt(100000), data)
+	require.NoError(t, err)
+
+	header := c.headers[number]
+	c.logs[number] = append(c.logs[number], types.Log{
+		Address:     testL1MessageQueueAddress,
+		Topics:      []common.Hash{event.ID, {}, {}},
+		Data:        packed,
+		BlockNumber: number,
+		BlockHash:   header.Hash(),
+	})
+}
+
+func (c *mockL1Chain) BlockNumber(ctx context.Context) (uint64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return uint64(len(c.headers) - 1), nil
+}
+
+func (c *mockL1Chain) ChainID(ctx context.Context) (*big.Int, error) {
+	return new(big.Int).SetUint64(testL1ChainId), nil
+}
+
+func (c *mockL1Chain) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	if c.onFilterLogs != nil {
+		c.onFilterLogs()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.maxQueryRange > 0 && q.ToBlock.Uint64()-q.FromBlock.Uint64()+1 > c.maxQueryRange {
+		return nil, errors.New("query returned more than 10000 results")
+	}
+
+	var logs []types.Log
+	for n := q.FromBlock.Uint64(); n <= q.ToBlock.Uint64() && n < uint64(len(c.headers)); n++ {
+		logs = append(logs, c.logs[n]...)
+	}
+	return logs, nil
+}
+
+func (c *mockL1Chain) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if number == nil || number.Sign() < 0 {
+		return c.headers[len(c.headers)-1], nil
+	}
+	if number.Uint64() >= uint64(len(c.headers)) {
+		return nil, errors.New("not found")
+	}
+	return c.headers[number.Uint64()], nil
+}
+
+func (c *mockL1Chain) SubscribeFilterLogs(ctx context.Context, query ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	return nil, errors.New("not supported")
+}
+
+func (c *mockL1Chain) TransactionByHash(ctx context.Context, txHash common.Hash) (*types.Transaction, bool, error) {
+	return nil, false, errors.New("not supported")
+}
+
+func (c *mockL1Chain) BlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error) {
+	return nil, errors.New("not supported")
+}
+
+func newTestSyncService(t *testing.T, l1Client EthClient) *SyncService {
+	genesisConfig := &params.ChainConfig{
+		Scroll: params.ScrollConfig{
+			L1Config: &params.L1Config{
+				L1ChainId:             testL1ChainId,
+				L1MessageQueueAddress: testL1MessageQueueAddress,
+				NumL1MessagesPerBlock: 10,
+			},
+		},
+	}
+	nodeConfig := &node.Config{L1Confirmations: rpc.LatestBlockNumber}
+
+	service, err := NewSyncService(context.Background(), genesisConfig, nodeConfig, rawdb.NewMemoryDatabase(), l1Client)
+	require.NoError(t, err)
+	return service
+}
+
+func TestSyncServiceL1Reorg(t *testing.T) {
+	chain := newMockL1Chain(21)
+	chain.addMessage(t, 5, 0, []byte{0})
+	chain.addMessage(t, 12, 1, []byte{1})
+	chain.addMessage(t, 18, 2, []byte{2})
+
+	service := newTestSyncService(t, chain)
+	reorgCh := make(chan core.L1ReorgEvent, 1)
+	sub := service.SubscribeL1ReorgEvent(reorgCh)
+	defer sub.Unsubscribe()
+
+	service.fetchMessages()
+	assert.Equal(t, uint64(20), service.latestProcessedBlock)
+	assert.Equal(t, uint64(2), rawdb.ReadHighestSyncedQueueIndex(service.db))
+	assert.NotNil(t, rawdb.ReadL1MessageSyncTime(service.db, 2))
+
+	// replace blocks after 10, message 1 is now emitted in block 15 and message 2 is gone
+	chain.reorg(10, 12, 1)
+	chain.addMessage(t, 15, 1, []byte{11})
+
+	service.fetchMessages()
+
+	select {
+	case ev := <-reorgCh:
+		assert.Equal(t, uint64(5), ev.CommonAncestor)
+		assert.Equal(t, uint64(1), ev.FirstRemovedQueueIndex)
+		assert.Equal(t, 2, ev.NumRemovedMessages)
+	case <-time.After(time.Second):
+		t.Fatal("L1ReorgEvent not received")
+	}
+
+	assert.Equal(t, uint64(22), service.latestProcessedBlock)
+	assert.Equal(t, uint64(1), rawdb.ReadHighestSyncedQueueIndex(service.db))
+	msg := rawdb.ReadL1Message(service.db, 1)
+	require.NotNil(t, msg)
+	assert.Equal(t, []byte{11}, msg.Data)
+	assert.Nil(t, rawdb.ReadL1Message(service.db, 2))
+	assert.NotNil(t, rawdb.ReadL1MessageSyncTime(service.db, 1))
+	assert.Nil(t, rawdb.ReadL1MessageSyncTime(service.db, 2))
+
+	checkpoint := rawdb.ReadL1BlockCheckpoint(service.db, 22)
+	require.NotNil(t, checkpoint)
+	assert.Equal(t, chain.headers[22].Hash(), checkpoint.Hash)
+	assert.Equal(t, uint64(2), checkpoint.NextQueueIndex)
+}
+
+func TestSyncServicePruneSyncTimes(t *testing.T) {
+	chain := newMockL1Chain(11)
+	chain.addMessage(t, 3, 0, []byte{0})
+	chain.addMessage(t, 5, 1, []byte{1})
+	chain.addMessage(t, 7, 2, []byte{2})
+
+	service := newTestSyncService(t, chain)
+	service.fetchMessages()
+	for queueIndex := uint64(0); queueIndex <= 2; queueIndex++ {
+		assert.NotNil(t, rawdb.ReadL1MessageSyncTime(service.db, queueIndex))
+	}
+
+	// messages 0 and 1 are included in the finalized L2 chain, all messages in the L2 head
+	finalized, head := common.Hash{1}, common.Hash{2}
+	rawdb.WriteCanonicalHash(service.db, finalized, 5)
+	rawdb.WriteFinalizedL2BlockNumber(service.db, 5)
+	rawdb.WriteFirstQueueIndexNotInL2Block(service.db, finalized, 2)
+	rawdb.WriteHeadBlockHash(service.db, head)
+	rawdb.WriteFirstQueueIndexNotInL2Block(service.db, head, 3)
+
+	chain.extend(1, 0)
+	service.fetchMessages()
+	assert.Nil(t, rawdb.ReadL1MessageSyncTime(service.db, 0))
+	assert.Nil(t, rawdb.ReadL1MessageSyncTime(service.db, 1))
+	assert.NotNil(t, rawdb.ReadL1MessageSyncTime(service.db, 2))
+}
+
+func TestSyncServiceL1ReorgWithoutMessages(t *testing.T) {
+	chain := newMockL1Chain(11)
+	chain.addMessage(t, 3, 0, []byte{0})
+
+	service := newTestSyncService(t, chain)
+	service.fetchMessages()
+	assert.Equal(t, uint64(10), service.latestProcessedBlock)
+
+	// reorg only affects blocks without messages
+	chain.reorg(8, 4, 1)
+	service.fetchMessages()
+
+	assert.Equal(t, uint64(12), service.latestProcessedBlock)
+	assert.Equal(t, uint64(0), rawdb.ReadHighestSyncedQueueIndex(service.db))
+	assert.NotNil(t, rawdb.ReadL1Message(service.db, 0))
+}
+
+func TestSyncServiceL1ReorgBeyondCheckpoints(t *testing.T) {
+	chain := newMockL1Chain(400)
+	chain.addMessage(t, 10, 0, []byte{0})
+	chain.addMessage(t, 100, 1, []byte{1})
+	chain.addMessage(t, 390, 2, []byte{2})
+
+	service := newTestSyncService(t, chain)
+	reorgCh := make(chan core.L1ReorgEvent, 2)
+	sub := service.SubscribeL1ReorgEvent(reorgCh)
+	defer sub.Unsubscribe()
+
+	service.fetchMessages()
+	assert.Equal(t, uint64(399), service.latestProcessedBlock)
+	assert.Nil(t, rawdb.ReadL1BlockCheckpoint(service.db, 100))
+
+	// replace all blocks after 50, none of the retained checkpoints is canonical anymore
+	chain.reorg(50, 360, 1)
+	chain.addMessage(t, 400, 3, []byte{3})
+
+	service.fetchMessages()
+
+	select {
+	case ev := <-reorgCh:
+		assert.Equal(t, uint64(390), ev.CommonAncestor)
+		assert.Equal(t, uint64(3), ev.FirstRemovedQueueIndex)
+		assert.Equal(t, 0, ev.NumRemovedMessages)
+	case <-time.After(time.Second):
+		t.Fatal("L1ReorgEvent not received")
+	}
+
+	// sync resumes from the oldest checkpoint
+	assert.Equal(t, uint64(410), service.latestProcessedBlock)
+	assert.Equal(t, uint64(3), rawdb.ReadHighestSyncedQueueIndex(service.db))
+	msg := rawdb.ReadL1Message(service.db, 3)
+	require.NotNil(t, msg)
+	assert.Equal(t, []byte{3}, msg.Data)
+
+	// the reorg is not detected again
+	chain.mu.Lock()
+	chain.extend(5, 1)
+	chain.mu.Unlock()
+	service.fetchMessages()
+
+	assert.Equal(t, uint64(415), service.latestProcessedBlock)
+	select {
+	case ev := <-reorgCh:
+		t.Fatalf("unexpected L1ReorgEvent: %+v", ev)
+	default:
+	}
+}
+
+func TestSyncServiceL1ReorgDuringFetch(t *testing.T) {
+	chain := newMockL1Chain(11)
+	chain.addMessage(t, 8, 0, []byte{0})
+
+	// the chain reorgs between fetching the header and the logs of the range
+	var once sync.Once
+	chain.onFilterLogs = func() {
+		once.Do(func() {
+			chain.reorg(5, 6, 1)
+			chain.addMessage(t, 9, 0, []byte{1})
+		})
+	}
+
+	service := newTestSyncService(t, chain)
+	service.fetchMessages()
+	assert.Equal(t, uint64(0), service.latestProcessedBlock)
+	assert.Nil(t, rawdb.ReadL1Message(service.db, 0))
+
+	service.fetchMessages()
+	assert.Equal(t, uint64(11), service.latestProcessedBlock)
+	msg := rawdb.ReadL1Message(service.db, 0)
+	require.NotNil(t, msg)
+	assert.Equal(t, []byte{1}, msg.Data)
+
+	checkpoint := rawdb.ReadL1BlockCheckpoint(service.db, 11)
+	require.NotNil(t, checkpoint)
+	assert.Equal(t, chain.headers[11].Hash(), checkpoint.Hash)
+}
+
+func TestSyncServiceAdaptiveFetchRange(t *testing.T) {
+	chain := newMockL1Chain(301)
+	chain.maxQueryRange = 30
+	for ii := uint64(0); ii < 20; ii++ {
+		chain.addMessage(t, 10+ii*13, ii, []byte{byte(ii)})
+	}
+
+	service := newTestSyncService(t, chain)
+	service.fetcher = NewRangeFetcher(FetchConfig{BlockRange: 100, MaxBlockRange: 200, Concurrency: 4})
+	service.fetchMessages()
+
+	assert.Equal(t, uint64(300), service.latestProcessedBlock)
+	assert.Equal(t, uint64(19), rawdb.ReadHighestSyncedQueueIndex(service.db))
+	for ii := uint64(0); ii < 20; ii++ {
+		msg := rawdb.ReadL1Message(service.db, ii)
+		require.NotNil(t, msg)
+		assert.Equal(t, []byte{byte(ii)}, msg.Data)
+	}
+	assert.LessOrEqual(t, service.fetcher.BlockRange(), uint64(30)*2)
+}
@@ -20,6 +20,9 @@ import (
 	"errors"
 	"fmt"
 
+	zktrie "github.com/scroll-tech/zktrie/trie"
+	zkt "github.com/scroll-tech/zktrie/types"
+
 	"github.com/scroll-tech/go-ethereum/common"
 	"github.com/scroll-tech/go-ethereum/common/prque"
 	"github.com/scroll-tech/go-ethereum/core/rawdb"
@@ -86,6 +89,16 @@ func newSyncPath(path []byte) SyncPath {
 	return SyncPath{hexToKeybytes(path[:64]), hexToCompact(path[64:])}
 }
 
+// newZkSyncPath converts a zktrie path from bit form into a compact version that
+// can be sent over the network. The path of a storage trie node starts with all
+// the bits of the path key of its account, which is sent as is.
+func newZkSyncPath(path []byte) SyncPath {
+	if len(path) < 8*common.HashLength {
+		return SyncPath{zkPathToCompact(path)}
+	}
+	return SyncPath{zkPathBitsKey(path[:8*common.HashLength]), zkPathToCompact(path[8*common.HashLength:])}
+}
+
 // SyncResult is a response with requested data along with it's hash.
 type SyncResult struct {
 	Hash common.Hash // Hash of the originally unknown trie node
@@ -130,10 +143,25 @@ type Sync struct {
 	queue    *prque.Prque             // Priority queue with the pending requests
 	fetches  map[int]int              // Number of active fetches per trie node depth
 	bloom    *SyncBloom               // Bloom filter for fast state existence checks
+	zktrie   bool                     // Whether the synced tries are zktries
 }
 
 // NewSync creates a new trie data download scheduler.
 func NewSync(root common.Hash, database ethdb.KeyValueReader, callback LeafCallback, bloom *SyncBloom) *Sync {
+	return newSync(root, database, callback, bloom, false)
+}
+
+// NewZktrieSync creates a new trie data download scheduler for zktries.
+//
+// The paths of the zktrie nodes are made up of bits instead of nibbles. The leaves
+// are separate nodes, the callback is invoked with the full path of their node key
+// when they are processed, and the storage tries of the accounts should be added
+// as the sub-tries of the leaves.
+func NewZktrieSync(root common.Hash, database ethdb.KeyValueReader, callback LeafCallback, bloom *SyncBloom) *Sync {
+	return newSync(root, database, callback, bloom, true)
+}
+
+func newSync(root common.Hash, database ethdb.KeyValueReader, callback LeafCallback, bloom *SyncBloom, zktrie bool) *Sync {
 	ts := &Sync{
 		database: database,
 		membatch: newSyncMemBatch(),
@@ -142,6 +170,7 @@ func NewSync(root common.Hash, database ethdb.KeyValueReader, callback LeafCallb
 		queue:    prque.New(nil),
 		fetches:  make(map[int]int),
 		bloom:    bloom,
+		zktrie:   zktrie,
 	}
 	ts.AddSubTrie(root, nil, common.Hash{}, callback)
 	return ts
@@ -150,7 +179,7 @@ func NewSync(root common.Hash, database ethdb.KeyValueReader, callback LeafCallb
 // AddSubTrie registers a new trie to the sync code, rooted at the designated parent.
 func (s *Sync) AddSubTrie(root common.Hash, path []byte, parent common.Hash, callback LeafCallback) {
 	// Short circuit if the trie is empty or already known
-	if root == emptyRoot {
+	if root == emptyRoot || (s.zktrie && root == common.Hash{}) {
 		return
 	}
 	if s.membatch.hasNode(root) {
@@ -160,7 +189,7 @@ func (s *Sync) AddSubTrie(root common.Hash, path []byte, parent common.Hash, cal
 		// Bloom filter says this might be a duplicate, double check.
 		// If database says yes, then at least the trie node is present
 		// and we hold the assumption that it's NOT legacy contract code.
-		blob := rawdb.ReadTrieNode(s.database, root)
+		blob := s.readNode(root)
 		if len(blob) > 0 {
 			return
 		}
@@ -252,7 +281,11 @@ func (s *Sync) Missing(max int) (nodes []common.Hash, paths []SyncPath, codes []
 		hash := item.(common.Hash)
 		if req, ok := s.nodeReqs[hash]; ok {
 			nodeHashes = append(nodeHashes, hash)
-			nodePaths = append(nodePaths, newSyncPath(req.path))
+			if s.zktrie {
+				nodePaths = append(nodePaths, newZkSyncPath(req.path))
+			} else {
+				nodePaths = append(nodePaths, newSyncPath(req.path))
+			}
 		} else {
 			codeHashes = append(codeHashes, hash)
 		}
@@ -282,16 +315,26 @@ func (s *Sync) Process(result SyncResult) error {
 	if req := s.nodeReqs[result.Hash]; req != nil && req.data == nil {
 		filled = true
 		// Decode the node data content and update the request
-		node, err := decodeNode(result.Hash[:], result.Data)
-		if err != nil {
-			return err
-		}
-		req.data = result.Data
+		var requests []*request
+		if s.zktrie {
+			req.data = result.Data
+
+			// Create and schedule a request for all the children nodes
+			var err error
+			if requests, err = s.zkChildren(req, result.Data); err != nil {
+				return err
+			}
+		} else {
+			node, err := decodeNode(result.Hash[:], result.Data)
+			if err != nil {
+				return err
+			}
+			req.data = result.Data
 
-		// Create and schedule a request for all the children nodes
-		requests, err := s.children(req, node)
-		if err != nil {
-			return err
+			// Create and schedule a request for all the children nodes
+			if requests, err = s.children(req, node); err != nil {
+				return err
+			}
 		}
 		if len(requests) == 0 && req.deps == 0 {
 			s.commit(req)
@@ -313,7 +356,13 @@ func (s *Sync) Process(result SyncResult) error {
 func (s *Sync) Commit(dbw ethdb.Batch) error {
 	// Dump the membatch into a database dbw
 	for key, value := range s.membatch.nodes {
-		rawdb.WriteTrieNode(dbw, key, value)
+		if s.zktrie {
+			if err := dbw.Put(ZktrieNodeKey(key), value); err != nil {
+				return err
+			}
+		} else {
+			rawdb.WriteTrieNode(dbw, key, value)
+		}
 		if s.bloom != nil {
 			s.bloom.Add(key[:])
 		}
@@ -354,7 +403,7 @@ func (s *Sync) schedule(req *request) {
 	// is a trie node and code has same hash. In this case two elements
 	// with same hash and same or different depth will be pushed. But it's
 	// ok the worst case is the second response will be treated as duplicated.
-	prio := int64(len(req.path)) << 56 // depth >= 128 will never happen, storage leaves will be included in their parents
+	prio := int64(s.depth(req.path)) << 56 // depth >= 128 will never happen, storage leaves will be included in their parents
 	for i := 0; i < 14 && i < len(req.path); i++ {
 		prio |= int64(15-req.path[i]) << (52 - i*4) // 15-nibble => lexicographic order
 	}
@@ -422,7 +471,7 @@ func (s *Sync) children(req *request, object node) ([]*request, error) {
 				// Bloom filter says this might be a duplicate, double check.
 				// If database says yes, then at least the trie node is present
 				// and we hold the assumption that it's NOT legacy contract code.
-				if blob := rawdb.ReadTrieNode(s.database, hash); len(blob) > 0 {
+				if blob := s.readNode(hash); len(blob) > 0 {
 					continue
 				}
 				// False positive, bump fault meter
@@ -448,11 +497,11 @@ func (s *Sync) commit(req *request) (err error) {
 	if req.code {
 		s.membatch.codes[req.hash] = req.data
 		delete(s.codeReqs, req.hash)
-		s.fetches[len(req.path)]--
+		s.fetches[s.depth(req.path)]--
 	} else {
 		s.membatch.nodes[req.hash] = req.data
 		delete(s.nodeReqs, req.hash)
-		s.fetches[len(req.path)]--
+		s.fetches[s.depth(req.path)]--
 	}
 	// Check all parents for completion
 	for _, parent := range req.parents {
@@ -465,3 +514,90 @@ func (s *Sync) commit(req *request) (err error) {
 	}
 	return nil
 }
+
+// depth returns the depth of a trie node with the given path, used to prioritize
+// and throttle the retrievals.
+func (s *Sync) depth(path []byte) int {
+	if s.zktrie {
+		// Every element of a zktrie path is a single bit, count them by nibbles
+		// to keep the depth of storage nodes below 128
+		return len(path) / 4
+	}
+	return len(path)
+}
+
+// readNode retrieves the trie node with the given hash from the database.
+func (s *Sync) readNode(hash common.Hash) []byte {
+	if s.zktrie {
+		blob, _ := s.database.Get(ZktrieNodeKey(hash))
+		return blob
+	}
+	return rawdb.ReadTrieNode(s.database, hash)
+}
+
+// zkChildren retrieves all the missing children of a zktrie node for future
+// retrieval scheduling. Unlike in the Merkle Patricia trie, leaves are separate
+// nodes, so the leaf callback is invoked when the leaf itself is processed.
+func (s *Sync) zkChildren(req *request, blob []byte) ([]*request, error) {
+	node, err := zktrie.NewNodeFromBytes(blob)
+	if err != nil {
+		return nil, err
+	}
+	switch node.Type {
+	case zktrie.NodeTypeEmpty_New:
+		return nil, nil
+
+	case zktrie.NodeTypeLeaf_New:
+		// Notify any external watcher of a new key/value node. The path of a leaf
+		// is extended to all the bits of its key, so that the storage trie nodes
+		// of an account are prefixed by the path key of the account.
+		if req.callback != nil {
+			var (
+				key    = node.NodeKey.Bytes()
+				prefix []byte
+				paths  [][]byte
+			)
+			if len(req.path) >= 8*common.HashLength {
+				prefix = req.path[:8*common.HashLength]
+				paths = append(paths, ZktriePathKey(zkPathBitsKey(prefix)))
+			}
+			paths = append(paths, key)
+			path := append(common.CopyBytes(prefix), zkPathKeyBits(ZktriePathKey(key))...)
+			if err := req.callback(paths, path, node.Data(), req.hash); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+
+	case zktrie.NodeTypeBranch_0, zktrie.NodeTypeBranch_1, zktrie.NodeTypeBranch_2, zktrie.NodeTypeBranch_3:
+		var requests []*request
+		for i, child := range []*zkt.Hash{node.ChildL, node.ChildR} {
+			if *child == zkt.HashZero {
+				continue
+			}
+			// Try to resolve the node from the local database
+			hash := common.BytesToHash(child.Bytes())
+			if s.membatch.hasNode(hash) {
+				continue
+			}
+			if s.bloom == nil || s.bloom.Contains(hash[:]) {
+				if blob := s.readNode(hash); len(blob) > 0 {
+					continue
+				}
+				// False positive, bump fault meter
+				bloomFaultMeter.Mark(1)
+			}
+			// Locally unknown node, schedule for retrieval
+			requests = append(requests, &request{
+				path:     append(common.CopyBytes(req.path), byte(i)),
+				hash:     hash,
+				parents:  []*request{req},
+				callback: req.callback,
+			})
+		}
+		return requests, nil
+
+	default:
+		return nil, fmt.Errorf("invalid zktrie node type %d", node.Type)
+	}
+}
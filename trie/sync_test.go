@@ -476,3 +476,60 @@ func TestSyncOrdering(t *testing.T) {
 		}
 	}
 }
+
+// Tests that a zktrie can be synced by retrieving its nodes by path, and that the
+// leaf callback is invoked for all the leaves.
+func TestZktrieSync(t *testing.T) {
+	srcTrie, keys, _ := makeZkRangeTrie(200)
+
+	diskdb := memorydb.New()
+	leaves := make(map[string]bool)
+	sched := NewZktrieSync(srcTrie.Hash(), diskdb, func(paths [][]byte, path []byte, leaf []byte, parent common.Hash) error {
+		if len(paths) != 1 || len(path) != 8*common.HashLength {
+			t.Errorf("invalid leaf paths: %d keys, %d bits", len(paths), len(path))
+		}
+		leaves[string(ZktriePathKey(paths[0]))] = true
+		return nil
+	}, NewSyncBloom(1, diskdb))
+
+	nodes, paths, _ := sched.Missing(0)
+	for len(nodes) > 0 {
+		for i, hash := range nodes {
+			data, _, err := srcTrie.TryGetNode(paths[i][0])
+			if err != nil || data == nil {
+				t.Fatalf("failed to retrieve node %x by path: %v", hash, err)
+			}
+			if err := sched.Process(SyncResult{hash, data}); err != nil {
+				t.Fatalf("failed to process result %v", err)
+			}
+		}
+		batch := diskdb.NewBatch()
+		if err := sched.Commit(batch); err != nil {
+			t.Fatalf("failed to commit data: %v", err)
+		}
+		batch.Write()
+
+		nodes, paths, _ = sched.Missing(0)
+	}
+	if len(leaves) != len(keys) {
+		t.Fatalf("leaf count mismatch: have %d, want %d", len(leaves), len(keys))
+	}
+	for _, key := range keys {
+		if !leaves[string(key)] {
+			t.Fatalf("leaf %x not reported", key)
+		}
+	}
+	// Cross check that the two tries are in sync
+	dstTrie, err := NewZkTrie(srcTrie.Hash(), NewZktrieDatabase(diskdb))
+	if err != nil {
+		t.Fatal(err)
+	}
+	it := NewIterator(dstTrie.NodeIterator(nil))
+	var count int
+	for it.Next() {
+		count++
+	}
+	if it.Err != nil || count != len(keys) {
+		t.Fatalf("synced trie mismatch: %d leaves, err %v", count, it.Err)
+	}
+}
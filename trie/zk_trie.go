@@ -144,10 +144,9 @@ func (t *ZkTrie) Copy() *ZkTrie {
 }
 
 // NodeIterator returns an iterator that returns nodes of the underlying trie. Iteration
-// starts at the key after the given start key.
+// starts at the leaf with the given node key, or the one following it in iteration order.
 func (t *ZkTrie) NodeIterator(start []byte) NodeIterator {
-	/// FIXME
-	panic("not implemented")
+	return newZkNodeIterator(t, start)
 }
 
 // hashKey returns the hash of key as an ephemeral buffer.
@@ -200,6 +199,57 @@ func (t *ZkTrie) Prove(key []byte, fromLevel uint, proofDb ethdb.KeyValueWriter)
 	return proofDb.Put(magicHash, zktrie.ProofMagicBytes())
 }
 
+// ProveNodeKey constructs a merkle proof for the leaf with the given big-endian
+// node key, like Prove but without the key preimage. The nodes are written to the
+// proof database under their hashes, in the form they are persisted in. Unlike
+// Prove, no magic entry is added, so the proof can be used as a list of nodes, as
+// needed by VerifyZktrieRangeProof.
+func (t *ZkTrie) ProveNodeKey(nodeKey []byte, proofDb ethdb.KeyValueWriter) error {
+	return t.ZkTrie.Tree().Prove(zkt.NewHashFromBytes(nodeKey), 0, func(n *zktrie.Node) error {
+		nodeHash, err := n.NodeHash()
+		if err != nil {
+			return err
+		}
+		return proofDb.Put(nodeHash[:], n.CanonicalValue())
+	})
+}
+
+// TryGetNode attempts to retrieve a trie node by its compact encoded bit path, as
+// used by the trie sync. It returns nil if there is no node at the path.
+func (t *ZkTrie) TryGetNode(path []byte) ([]byte, int, error) {
+	bits, err := zkCompactToPath(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	hash, err := t.ZkTrie.Tree().Root()
+	if err != nil {
+		return nil, 0, err
+	}
+	var resolved int
+	for depth := 0; ; depth++ {
+		if *hash == zkt.HashZero {
+			return nil, resolved, nil
+		}
+		node, err := t.ZkTrie.Tree().GetNode(hash)
+		if err != nil {
+			return nil, resolved, &MissingNodeError{NodeHash: common.BytesToHash(hash.Bytes()), Path: common.CopyBytes(bits[:depth])}
+		}
+		resolved++
+
+		if depth == len(bits) {
+			return node.CanonicalValue(), resolved, nil
+		}
+		if node.IsTerminal() {
+			return nil, resolved, nil
+		}
+		if bits[depth] == 0 {
+			hash = node.ChildL
+		} else {
+			hash = node.ChildR
+		}
+	}
+}
+
 // VerifyProof checks merkle proofs. The given proof must contain the value for
 // key in a trie with the given root hash. VerifyProof returns an error if the
 // proof contains invalid trie nodes or the wrong value.
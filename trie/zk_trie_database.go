@@ -6,6 +6,7 @@ import (
 	"github.com/syndtr/goleveldb/leveldb"
 
 	zktrie "github.com/scroll-tech/zktrie/trie"
+	zkt "github.com/scroll-tech/zktrie/types"
 
 	"github.com/scroll-tech/go-ethereum/common"
 	"github.com/scroll-tech/go-ethereum/ethdb"
@@ -35,6 +36,12 @@ func NewZktrieDatabaseFromTriedb(db *Database) *ZktrieDatabase {
 	return &ZktrieDatabase{db: db, prefix: []byte{}}
 }
 
+// ZktrieNodeKey returns the key under which the zktrie node with the given hash,
+// as reported by the node iterator, is persisted in the disk database.
+func ZktrieNodeKey(hash common.Hash) []byte {
+	return bitReverse(zkt.NewHashFromBytes(hash.Bytes())[:])
+}
+
 // Put saves a key:value into the Storage
 func (l *ZktrieDatabase) Put(k, v []byte) error {
 	k = bitReverse(k)
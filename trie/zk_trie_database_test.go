@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/scroll-tech/go-ethereum/common"
+	"github.com/scroll-tech/go-ethereum/ethdb/memorydb"
 )
 
 // grep from `feat/snap`
@@ -67,3 +68,24 @@ func TestBitDoubleReverse(t *testing.T) {
 	}
 
 }
+
+func TestZktrieNodeKey(t *testing.T) {
+	diskdb := memorydb.New()
+	triedb := NewDatabaseWithConfig(diskdb, &Config{Zktrie: true})
+	trie, _ := NewZkTrie(common.Hash{}, NewZktrieDatabaseFromTriedb(triedb))
+	for i := byte(0); i < 16; i++ {
+		trie.Update(common.Hash{i}.Bytes(), common.Hash{i + 1}.Bytes())
+	}
+	root, _, _ := trie.Commit(nil)
+	triedb.Commit(root, false, nil)
+
+	it := trie.NodeIterator(nil)
+	for it.Next(true) {
+		if ok, _ := diskdb.Has(ZktrieNodeKey(it.Hash())); !ok {
+			t.Fatalf("node %x not found under its key", it.Hash())
+		}
+	}
+	if err := it.Error(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,342 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"bytes"
+
+	zktrie "github.com/scroll-tech/zktrie/trie"
+	zkt "github.com/scroll-tech/zktrie/types"
+
+	"github.com/scroll-tech/go-ethereum/common"
+	"github.com/scroll-tech/go-ethereum/ethdb"
+)
+
+// zkTrieMaxLevels is the maximum depth of a zktrie, leaf paths are padded to it.
+const zkTrieMaxLevels = zktrie.NodeKeyValidBytes * 8
+
+// zkNodeIteratorState represents the iteration state at one particular node of the
+// zktrie, which can be resumed at a later invocation.
+type zkNodeIteratorState struct {
+	hash    *zkt.Hash    // Hash of the node being iterated
+	node    *zktrie.Node // Trie node being iterated, nil until resolved
+	parent  common.Hash  // Hash of the parent node (empty if current is the root)
+	index   int          // Child to be processed next
+	pathlen int          // Length of the path to this node
+}
+
+// zkNodeIterator is a NodeIterator over the binary nodes of a zktrie.
+//
+// Every element of the path is a single bit of the node key, 0 for the left and 1 for
+// the right child. A leaf node is stored at the shallowest depth at which its key is
+// unique, so its path is padded with the remaining bits of its node key followed by the
+// terminator. This makes the path of a leaf identical in all tries containing its key,
+// and the iteration order is the order of the bit-reversed node keys.
+type zkNodeIterator struct {
+	trie  *ZkTrie                // Trie being iterated
+	stack []*zkNodeIteratorState // Hierarchy of trie nodes persisting the iteration state
+	path  []byte                 // Path to the current node
+	err   error                  // Failure set in case of an internal error in the iterator
+
+	resolver ethdb.KeyValueStore // Optional intermediate resolver above the disk layer
+}
+
+func newZkNodeIterator(trie *ZkTrie, start []byte) NodeIterator {
+	it := &zkNodeIterator{trie: trie}
+	if trie.Hash() == (common.Hash{}) {
+		it.err = errIteratorEnd
+		return it
+	}
+	it.err = it.seek(start)
+	return it
+}
+
+func (it *zkNodeIterator) AddResolver(resolver ethdb.KeyValueStore) {
+	it.resolver = resolver
+}
+
+func (it *zkNodeIterator) Hash() common.Hash {
+	if len(it.stack) == 0 {
+		return common.Hash{}
+	}
+	return common.BytesToHash(it.stack[len(it.stack)-1].hash.Bytes())
+}
+
+func (it *zkNodeIterator) Parent() common.Hash {
+	if len(it.stack) == 0 {
+		return common.Hash{}
+	}
+	return it.stack[len(it.stack)-1].parent
+}
+
+func (it *zkNodeIterator) Leaf() bool {
+	return hasTerm(it.path)
+}
+
+// LeafKey returns the node key of the leaf, that is the Poseidon hash of the key it
+// was inserted with. The preimage can be retrieved with ZkTrie.GetKey.
+func (it *zkNodeIterator) LeafKey() []byte {
+	if len(it.stack) > 0 && it.stack[len(it.stack)-1].node.Type == zktrie.NodeTypeLeaf_New {
+		return it.stack[len(it.stack)-1].node.NodeKey.Bytes()
+	}
+	panic("not at leaf")
+}
+
+// LeafBlob returns the value fields of the leaf, as returned by ZkTrie.TryGet.
+func (it *zkNodeIterator) LeafBlob() []byte {
+	if len(it.stack) > 0 && it.stack[len(it.stack)-1].node.Type == zktrie.NodeTypeLeaf_New {
+		return it.stack[len(it.stack)-1].node.Data()
+	}
+	panic("not at leaf")
+}
+
+// LeafProof returns the encoded nodes from the root down to the leaf. The key preimage
+// of the leaf is included if it is known to the database, like in ZkTrie.Prove.
+func (it *zkNodeIterator) LeafProof() [][]byte {
+	if len(it.stack) > 0 && it.stack[len(it.stack)-1].node.Type == zktrie.NodeTypeLeaf_New {
+		proofs := make([][]byte, 0, len(it.stack))
+		for _, item := range it.stack[:len(it.stack)-1] {
+			proofs = append(proofs, item.node.Value())
+		}
+		leaf := it.stack[len(it.stack)-1].node.Copy()
+		if preimage := it.trie.GetKey(leaf.NodeKey.Bytes()); len(preimage) > 0 {
+			leaf.KeyPreimage = new(zkt.Byte32)
+			copy(leaf.KeyPreimage[:], preimage)
+		}
+		return append(proofs, leaf.Value())
+	}
+	panic("not at leaf")
+}
+
+func (it *zkNodeIterator) Path() []byte {
+	return it.path
+}
+
+func (it *zkNodeIterator) Error() error {
+	if it.err == errIteratorEnd {
+		return nil
+	}
+	if seek, ok := it.err.(seekError); ok {
+		return seek.err
+	}
+	return it.err
+}
+
+// Next moves the iterator to the next node, returning whether there are any
+// further nodes. In case of an internal error this method returns false and
+// sets the Error field to the encountered failure. If `descend` is false,
+// skips iterating over any subnodes of the current node.
+func (it *zkNodeIterator) Next(descend bool) bool {
+	if it.err == errIteratorEnd {
+		return false
+	}
+	if seek, ok := it.err.(seekError); ok {
+		if it.err = it.seek(seek.key); it.err != nil {
+			return false
+		}
+	}
+	// Otherwise step forward with the iterator and report any errors.
+	state, parentIndex, path, err := it.peek(descend)
+	it.err = err
+	if it.err != nil {
+		return false
+	}
+	it.push(state, parentIndex, path)
+	return true
+}
+
+func (it *zkNodeIterator) seek(start []byte) error {
+	// The path we're looking for is the bit path of the node key without terminator.
+	var key []byte
+	if len(start) > 0 {
+		key = zkNodeKeyPath(nil, zkt.NewHashFromBytes(start), 0)
+		key = key[:len(key)-1]
+	}
+	// Move forward until we're just before the closest match to key.
+	for {
+		state, parentIndex, path, err := it.peekSeek(key)
+		if err == errIteratorEnd {
+			return errIteratorEnd
+		} else if err != nil {
+			return seekError{start, err}
+		} else if bytes.Compare(path, key) >= 0 {
+			return nil
+		}
+		it.push(state, parentIndex, path)
+	}
+}
+
+// init initializes the the iterator.
+func (it *zkNodeIterator) init() (*zkNodeIteratorState, []byte, error) {
+	root, err := it.trie.ZkTrie.Tree().Root()
+	if err != nil {
+		return nil, nil, err
+	}
+	state := &zkNodeIteratorState{hash: root, index: -1}
+	path, err := state.resolve(it, nil)
+	return state, path, err
+}
+
+// peek creates the next state of the iterator.
+func (it *zkNodeIterator) peek(descend bool) (*zkNodeIteratorState, *int, []byte, error) {
+	// Initialize the iterator if we've just started.
+	if len(it.stack) == 0 {
+		state, path, err := it.init()
+		return state, nil, path, err
+	}
+	if !descend {
+		// If we're skipping children, pop the current node first
+		it.pop()
+	}
+
+	// Continue iteration to the next child
+	for len(it.stack) > 0 {
+		parent := it.stack[len(it.stack)-1]
+		state, path, ok := it.nextChild(parent, nil)
+		if ok {
+			path, err := state.resolve(it, path)
+			if err != nil {
+				return parent, &parent.index, path, err
+			}
+			return state, &parent.index, path, nil
+		}
+		// No more child nodes, move back up.
+		it.pop()
+	}
+	return nil, nil, nil, errIteratorEnd
+}
+
+// peekSeek is like peek, but it also tries to skip resolving nodes by skipping
+// over the siblings that do not lead towards the desired seek position.
+func (it *zkNodeIterator) peekSeek(seekKey []byte) (*zkNodeIteratorState, *int, []byte, error) {
+	// Initialize the iterator if we've just started.
+	if len(it.stack) == 0 {
+		state, path, err := it.init()
+		return state, nil, path, err
+	}
+	if !bytes.HasPrefix(seekKey, it.path) {
+		// If we're skipping children, pop the current node first
+		it.pop()
+	}
+
+	// Continue iteration to the next child
+	for len(it.stack) > 0 {
+		parent := it.stack[len(it.stack)-1]
+		state, path, ok := it.nextChild(parent, seekKey)
+		if ok {
+			path, err := state.resolve(it, path)
+			if err != nil {
+				return parent, &parent.index, path, err
+			}
+			return state, &parent.index, path, nil
+		}
+		// No more child nodes, move back up.
+		it.pop()
+	}
+	return nil, nil, nil, errIteratorEnd
+}
+
+// nextChild returns the next non-empty child of a branch node. If a seek key is given,
+// a left child is skipped when the key is in the right subtree.
+func (it *zkNodeIterator) nextChild(parent *zkNodeIteratorState, seekKey []byte) (*zkNodeIteratorState, []byte, bool) {
+	if parent.node.IsTerminal() {
+		return parent, it.path, false
+	}
+	depth := len(it.path)
+	for index := parent.index + 1; index < 2; index++ {
+		child := parent.node.ChildL
+		if index == 1 {
+			child = parent.node.ChildR
+		} else if len(seekKey) > depth && bytes.HasPrefix(seekKey, it.path) && seekKey[depth] == 1 {
+			continue
+		}
+		if *child == zkt.HashZero {
+			continue
+		}
+		state := &zkNodeIteratorState{
+			hash:    child,
+			parent:  common.BytesToHash(parent.hash.Bytes()),
+			index:   -1,
+			pathlen: depth,
+		}
+		path := make([]byte, depth+1, zkTrieMaxLevels+1)
+		copy(path, it.path)
+		path[depth] = byte(index)
+		parent.index = index - 1
+		return state, path, true
+	}
+	return parent, it.path, false
+}
+
+// resolve loads the node of the state, and extends the path of a leaf to the full
+// path of its node key.
+func (st *zkNodeIteratorState) resolve(it *zkNodeIterator, path []byte) ([]byte, error) {
+	if st.node == nil {
+		node, err := it.resolveHash(st.hash, path)
+		if err != nil {
+			return path, err
+		}
+		st.node = node
+	}
+	if st.node.Type == zktrie.NodeTypeLeaf_New {
+		return zkNodeKeyPath(path, st.node.NodeKey, len(path)), nil
+	}
+	return path, nil
+}
+
+func (it *zkNodeIterator) resolveHash(hash *zkt.Hash, path []byte) (*zktrie.Node, error) {
+	if it.resolver != nil {
+		if blob, err := it.resolver.Get(hash[:]); err == nil && len(blob) > 0 {
+			if resolved, err := zktrie.NewNodeFromBytes(blob); err == nil {
+				return resolved, nil
+			}
+		}
+	}
+	node, err := it.trie.ZkTrie.Tree().GetNode(hash)
+	if err != nil {
+		return nil, &MissingNodeError{NodeHash: common.BytesToHash(hash.Bytes()), Path: common.CopyBytes(path)}
+	}
+	return node, nil
+}
+
+func (it *zkNodeIterator) push(state *zkNodeIteratorState, parentIndex *int, path []byte) {
+	it.path = path
+	it.stack = append(it.stack, state)
+	if parentIndex != nil {
+		*parentIndex++
+	}
+}
+
+func (it *zkNodeIterator) pop() {
+	last := it.stack[len(it.stack)-1]
+	it.path = it.path[:last.pathlen]
+	it.stack[len(it.stack)-1] = nil
+	it.stack = it.stack[:len(it.stack)-1]
+}
+
+// zkNodeKeyPath appends the bits of a node key starting at depth to path, followed
+// by the terminator.
+func zkNodeKeyPath(path []byte, nodeKey *zkt.Hash, depth int) []byte {
+	for i := depth; i < zkTrieMaxLevels; i++ {
+		var bit byte
+		if zkt.TestBit(nodeKey[:], uint(i)) {
+			bit = 1
+		}
+		path = append(path, bit)
+	}
+	return append(path, 16)
+}
@@ -0,0 +1,488 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+
+	zktrie "github.com/scroll-tech/zktrie/trie"
+	zkt "github.com/scroll-tech/zktrie/types"
+
+	"github.com/scroll-tech/go-ethereum/common"
+	"github.com/scroll-tech/go-ethereum/ethdb/memorydb"
+)
+
+// zkTestKey pads a test key to the key length accepted by the zktrie.
+func zkTestKey(k string) []byte {
+	return common.LeftPadBytes([]byte(k), 32)
+}
+
+// zkTestValue pads a test value to the value length stored by the zktrie.
+func zkTestValue(v string) []byte {
+	return common.LeftPadBytes([]byte(v), 32)
+}
+
+// zkTestNodeKeyPath returns the iteration path of the leaf of a key.
+func zkTestNodeKeyPath(key []byte) []byte {
+	k, err := zkt.ToSecureKey(key)
+	if err != nil {
+		panic(err)
+	}
+	return zkNodeKeyPath(nil, zkt.NewHashFromBigInt(k), 0)
+}
+
+// zkTestNodeKey returns the node key of a key, as returned by LeafKey.
+func zkTestNodeKey(key []byte) []byte {
+	k, err := zkt.ToSecureKey(key)
+	if err != nil {
+		panic(err)
+	}
+	return zkt.NewHashFromBigInt(k).Bytes()
+}
+
+// sortZkTestData sorts test data in the order it is iterated.
+func sortZkTestData(data []kvs) []kvs {
+	sorted := append([]kvs{}, data...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return bytes.Compare(zkTestNodeKeyPath(zkTestKey(sorted[i].k)), zkTestNodeKeyPath(zkTestKey(sorted[j].k))) < 0
+	})
+	return sorted
+}
+
+func newZkTestTrie(data []kvs) *ZkTrie {
+	trie := newEmptyZkTrie()
+	for _, val := range data {
+		trie.Update(zkTestKey(val.k), zkTestValue(val.v))
+	}
+	return trie
+}
+
+func checkZkIteratorOrder(want []kvs, tr *ZkTrie, it *Iterator) error {
+	for it.Next() {
+		if len(want) == 0 {
+			return fmt.Errorf("didn't expect any more values, got key %x", it.Key)
+		}
+		if key := tr.GetKey(it.Key); !bytes.Equal(key, zkTestKey(want[0].k)) {
+			return fmt.Errorf("wrong key: got %q, want %q", bytes.TrimLeft(key, "\x00"), want[0].k)
+		}
+		want = want[1:]
+	}
+	if len(want) > 0 {
+		return fmt.Errorf("iterator ended early, want key %q", want[0])
+	}
+	return nil
+}
+
+func TestZkTrieEmptyIterator(t *testing.T) {
+	trie := newEmptyZkTrie()
+	iter := trie.NodeIterator(nil)
+
+	seen := make(map[string]struct{})
+	for iter.Next(true) {
+		seen[string(iter.Path())] = struct{}{}
+	}
+	if len(seen) != 0 {
+		t.Fatal("Unexpected trie node iterated")
+	}
+}
+
+func TestZkTrieIterator(t *testing.T) {
+	trie := newEmptyZkTrie()
+	vals := []struct{ k, v string }{
+		{"do", "verb"},
+		{"ether", "wookiedoo"},
+		{"horse", "stallion"},
+		{"shaman", "horse"},
+		{"doge", "coin"},
+		{"dog", "puppy"},
+		{"somethingveryoddindeedthis is", "myothernodedata"},
+	}
+	all := make(map[string]string)
+	for _, val := range vals {
+		all[string(zkTestKey(val.k))] = string(zkTestValue(val.v))
+		trie.Update(zkTestKey(val.k), zkTestValue(val.v))
+	}
+	trie.Commit(nil)
+
+	found := make(map[string]string)
+	it := NewIterator(trie.NodeIterator(nil))
+	for it.Next() {
+		found[string(trie.GetKey(it.Key))] = string(it.Value)
+	}
+
+	for k, v := range all {
+		if found[k] != v {
+			t.Errorf("iterator value mismatch for %x: got %x want %x", k, found[k], v)
+		}
+	}
+	if len(found) != len(all) {
+		t.Errorf("iterator count mismatch: got %d values, want %d", len(found), len(all))
+	}
+}
+
+func TestZkTrieIteratorLargeData(t *testing.T) {
+	trie := newEmptyZkTrie()
+	vals := make(map[string]*kv)
+
+	for i := byte(0); i < 255; i++ {
+		value := &kv{common.LeftPadBytes([]byte{i}, 32), common.LeftPadBytes([]byte{i}, 32), false}
+		value2 := &kv{common.LeftPadBytes([]byte{10, i}, 32), common.LeftPadBytes([]byte{i}, 32), false}
+		trie.Update(value.k, value.v)
+		trie.Update(value2.k, value2.v)
+		vals[string(value.k)] = value
+		vals[string(value2.k)] = value2
+	}
+
+	it := NewIterator(trie.NodeIterator(nil))
+	for it.Next() {
+		value := vals[string(trie.GetKey(it.Key))]
+		if !bytes.Equal(it.Value, value.v) {
+			t.Errorf("iterator value mismatch for %x: got %x want %x", value.k, it.Value, value.v)
+		}
+		value.t = true
+	}
+
+	var untouched []*kv
+	for _, value := range vals {
+		if !value.t {
+			untouched = append(untouched, value)
+		}
+	}
+
+	if len(untouched) > 0 {
+		t.Errorf("Missed %d nodes", len(untouched))
+		for _, value := range untouched {
+			t.Error(value)
+		}
+	}
+}
+
+// Tests that the node iterator indeed walks over the entire trie.
+func TestZkTrieNodeIteratorCoverage(t *testing.T) {
+	// Create some arbitrary test trie to iterate
+	db, trie, _ := makeTestZkTrie()
+
+	// Gather all the node hashes found by the iterator
+	hashes := make(map[common.Hash]struct{})
+	for it := trie.NodeIterator(nil); it.Next(true); {
+		hashes[it.Hash()] = struct{}{}
+	}
+	// Cross check the hashes and the trie itself
+	for hash := range hashes {
+		if _, err := db.Get(zkt.NewHashFromBytes(hash.Bytes())[:]); err != nil {
+			t.Errorf("failed to retrieve reported node %x: %v", hash, err)
+		}
+	}
+	root, _ := trie.Tree().Root()
+	var nodes int
+	err := trie.Tree().Walk(root, func(n *zktrie.Node) {
+		if n.Type == zktrie.NodeTypeEmpty_New {
+			return
+		}
+		nodes++
+		hash, _ := n.NodeHash()
+		if _, ok := hashes[common.BytesToHash(hash.Bytes())]; !ok {
+			t.Errorf("trie node not reported %x", hash.Bytes())
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nodes != len(hashes) {
+		t.Errorf("node count mismatch: got %d, want %d", len(hashes), nodes)
+	}
+}
+
+func TestZkTrieIteratorSeek(t *testing.T) {
+	trie := newZkTestTrie(testdata1)
+	sorted := sortZkTestData(testdata1)
+
+	// Seek to every key.
+	for i, val := range sorted {
+		it := NewIterator(trie.NodeIterator(zkTestNodeKey(zkTestKey(val.k))))
+		if err := checkZkIteratorOrder(sorted[i:], trie, it); err != nil {
+			t.Fatalf("seek to %q: %v", val.k, err)
+		}
+	}
+
+	// Seek to non-existent keys.
+	for _, missing := range []string{"barc", "aardvark", "jars", "z"} {
+		path := zkTestNodeKeyPath(zkTestKey(missing))
+		want := sorted
+		for len(want) > 0 && bytes.Compare(zkTestNodeKeyPath(zkTestKey(want[0].k)), path) < 0 {
+			want = want[1:]
+		}
+		it := NewIterator(trie.NodeIterator(zkTestNodeKey(zkTestKey(missing))))
+		if err := checkZkIteratorOrder(want, trie, it); err != nil {
+			t.Fatalf("seek to %q: %v", missing, err)
+		}
+	}
+}
+
+func TestZkTrieDifferenceIterator(t *testing.T) {
+	triea := newZkTestTrie(testdata1)
+	triea.Commit(nil)
+	trieb := newZkTestTrie(testdata2)
+	trieb.Commit(nil)
+
+	found := make(map[string]string)
+	di, _ := NewDifferenceIterator(triea.NodeIterator(nil), trieb.NodeIterator(nil))
+	it := NewIterator(di)
+	for it.Next() {
+		found[string(trieb.GetKey(it.Key))] = string(it.Value)
+	}
+
+	all := []struct{ k, v string }{
+		{"aardvark", "c"},
+		{"barb", "bd"},
+		{"bars", "be"},
+		{"jars", "d"},
+	}
+	for _, item := range all {
+		if found[string(zkTestKey(item.k))] != string(zkTestValue(item.v)) {
+			t.Errorf("iterator value mismatch for %s: got %x want %x", item.k, found[string(zkTestKey(item.k))], zkTestValue(item.v))
+		}
+	}
+	if len(found) != len(all) {
+		t.Errorf("iterator count mismatch: got %d values, want %d", len(found), len(all))
+	}
+}
+
+func TestZkTrieUnionIterator(t *testing.T) {
+	triea := newZkTestTrie(testdata1)
+	triea.Commit(nil)
+	trieb := newZkTestTrie(testdata2)
+	trieb.Commit(nil)
+
+	di, _ := NewUnionIterator([]NodeIterator{triea.NodeIterator(nil), trieb.NodeIterator(nil)})
+	it := NewIterator(di)
+
+	// Entries with the same key and different values are both reported, ordered by
+	// their node hash.
+	all := sortZkTestData([]kvs{
+		{"aardvark", "c"},
+		{"barb", "ba"},
+		{"barb", "bd"},
+		{"bard", "bc"},
+		{"bars", "bb"},
+		{"bars", "be"},
+		{"bar", "b"},
+		{"fab", "z"},
+		{"food", "ab"},
+		{"foos", "aa"},
+		{"foo", "a"},
+		{"jars", "d"},
+	})
+	want := make(map[string]bool)
+	for _, kv := range all {
+		want[string(zkTestKey(kv.k))+string(zkTestValue(kv.v))] = true
+	}
+	for i, kv := range all {
+		if !it.Next() {
+			t.Fatalf("Iterator ends prematurely at element %d", i)
+		}
+		key := triea.GetKey(it.Key)
+		if key == nil {
+			key = trieb.GetKey(it.Key)
+		}
+		if !bytes.Equal(key, zkTestKey(kv.k)) {
+			t.Errorf("iterator key mismatch for element %d: got %x want %s", i, key, kv.k)
+		}
+		if !want[string(key)+string(it.Value)] {
+			t.Errorf("iterator value mismatch for element %d: got %x", i, it.Value)
+		}
+		delete(want, string(key)+string(it.Value))
+	}
+	if it.Next() {
+		t.Errorf("Iterator returned extra values.")
+	}
+}
+
+func TestZkTrieIteratorNoDups(t *testing.T) {
+	tr := newZkTestTrie(testdata1)
+	checkIteratorNoDups(t, tr.NodeIterator(nil), nil)
+}
+
+func TestZkTrieIteratorLeafProof(t *testing.T) {
+	tr := newZkTestTrie(testdata1)
+	root := tr.Hash()
+
+	var leaves int
+	for it := tr.NodeIterator(nil); it.Next(true); {
+		if !it.Leaf() {
+			continue
+		}
+		leaves++
+		proofDb := memorydb.New()
+		for _, blob := range it.LeafProof() {
+			n, err := zktrie.NewNodeFromBytes(blob)
+			if err != nil {
+				t.Fatalf("invalid proof node: %v", err)
+			}
+			hash, _ := n.NodeHash()
+			proofDb.Put(hash[:], blob)
+		}
+		key := tr.GetKey(it.LeafKey())
+		value, err := VerifyProofSMT(root, key, proofDb)
+		if err != nil {
+			t.Fatalf("failed to verify proof of %x: %v", key, err)
+		}
+		if !bytes.Equal(value, it.LeafBlob()) {
+			t.Fatalf("proof value mismatch for %x: got %x want %x", key, value, it.LeafBlob())
+		}
+	}
+	if leaves != len(testdata1) {
+		t.Fatalf("leaf count mismatch: got %d, want %d", leaves, len(testdata1))
+	}
+}
+
+// This test checks that zkNodeIterator.Next can be retried after inserting missing trie nodes.
+func TestZkTrieIteratorContinueAfterErrorDisk(t *testing.T) {
+	testZkTrieIteratorContinueAfterError(t, false)
+}
+func TestZkTrieIteratorContinueAfterErrorMemonly(t *testing.T) {
+	testZkTrieIteratorContinueAfterError(t, true)
+}
+
+func testZkTrieIteratorContinueAfterError(t *testing.T, memonly bool) {
+	diskdb := memorydb.New()
+	triedb := NewZktrieDatabaseFromTriedb(NewDatabaseWithConfig(diskdb, &Config{Preimages: true}))
+
+	tr, _ := NewZkTrie(common.Hash{}, triedb)
+	for _, val := range testdata1 {
+		tr.Update(zkTestKey(val.k), zkTestValue(val.v))
+	}
+	root, _, _ := tr.Commit(nil)
+	if !memonly {
+		triedb.db.Commit(root, false, nil)
+	}
+	wantNodeCount := checkIteratorNoDups(t, tr.NodeIterator(nil), nil)
+
+	var nodeHashes []common.Hash
+	for it := tr.NodeIterator(nil); it.Next(true); {
+		if it.Hash() != root {
+			nodeHashes = append(nodeHashes, it.Hash())
+		}
+	}
+	for i := 0; i < 20; i++ {
+		// Create trie that will load all nodes from DB.
+		tr, _ := NewZkTrie(root, triedb)
+
+		// Remove a random node from the database. It can't be the root node
+		// because that one is already loaded.
+		rkey := nodeHashes[rand.Intn(len(nodeHashes))]
+		dbKey := bitReverse(zkt.NewHashFromBytes(rkey.Bytes())[:])
+		var rval []byte
+		if memonly {
+			rval, _ = triedb.db.rawDirties.Get(dbKey)
+			delete(triedb.db.rawDirties, sha256.Sum256(dbKey))
+		} else {
+			rval, _ = diskdb.Get(dbKey)
+			diskdb.Delete(dbKey)
+		}
+		// Iterate until the error is hit.
+		seen := make(map[string]bool)
+		it := tr.NodeIterator(nil)
+		checkIteratorNoDups(t, it, seen)
+		missing, ok := it.Error().(*MissingNodeError)
+		if !ok || missing.NodeHash != rkey {
+			t.Fatal("didn't hit missing node, got", it.Error())
+		}
+
+		// Add the node back and continue iteration.
+		if memonly {
+			triedb.db.rawDirties.Put(dbKey, rval)
+		} else {
+			diskdb.Put(dbKey, rval)
+		}
+		checkIteratorNoDups(t, it, seen)
+		if it.Error() != nil {
+			t.Fatal("unexpected error", it.Error())
+		}
+		if len(seen) != wantNodeCount {
+			t.Fatal("wrong node iteration count, got", len(seen), "want", wantNodeCount)
+		}
+	}
+}
+
+// Similar to the test above, this one checks that failure to create zkNodeIterator at a
+// certain key behaves correctly when Next is called. The expectation is that Next
+// should retry seeking before returning true for the first time.
+func TestZkTrieIteratorContinueAfterSeekErrorDisk(t *testing.T) {
+	testZkTrieIteratorContinueAfterSeekError(t, false)
+}
+func TestZkTrieIteratorContinueAfterSeekErrorMemonly(t *testing.T) {
+	testZkTrieIteratorContinueAfterSeekError(t, true)
+}
+
+func testZkTrieIteratorContinueAfterSeekError(t *testing.T, memonly bool) {
+	// Commit test trie to db, then remove the leaf of "bars".
+	diskdb := memorydb.New()
+	triedb := NewZktrieDatabaseFromTriedb(NewDatabaseWithConfig(diskdb, &Config{Preimages: true}))
+
+	ctr, _ := NewZkTrie(common.Hash{}, triedb)
+	for _, val := range testdata1 {
+		ctr.Update(zkTestKey(val.k), zkTestValue(val.v))
+	}
+	root, _, _ := ctr.Commit(nil)
+	if !memonly {
+		triedb.db.Commit(root, false, nil)
+	}
+	leaf, err := ctr.Tree().GetLeafNode(zkt.NewHashFromBytes(zkTestNodeKey(zkTestKey("bars"))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	leafHash, _ := leaf.NodeHash()
+	dbKey := bitReverse(leafHash[:])
+	var leafBlob []byte
+	if memonly {
+		leafBlob, _ = triedb.db.rawDirties.Get(dbKey)
+		delete(triedb.db.rawDirties, sha256.Sum256(dbKey))
+	} else {
+		leafBlob, _ = diskdb.Get(dbKey)
+		diskdb.Delete(dbKey)
+	}
+	// Create a new iterator that seeks to "bars". Seeking can't proceed because
+	// the node is missing.
+	tr, _ := NewZkTrie(root, triedb)
+	it := tr.NodeIterator(zkTestNodeKey(zkTestKey("bars")))
+	missing, ok := it.Error().(*MissingNodeError)
+	if !ok {
+		t.Fatal("want MissingNodeError, got", it.Error())
+	} else if missing.NodeHash != common.BytesToHash(leafHash.Bytes()) {
+		t.Fatal("wrong node missing")
+	}
+	// Reinsert the missing node.
+	if memonly {
+		triedb.db.rawDirties.Put(dbKey, leafBlob)
+	} else {
+		diskdb.Put(dbKey, leafBlob)
+	}
+	// Check that iteration produces the right set of values.
+	sorted := sortZkTestData(testdata1)
+	for len(sorted) > 0 && sorted[0].k != "bars" {
+		sorted = sorted[1:]
+	}
+	if err := checkZkIteratorOrder(sorted, tr, NewIterator(it)); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,483 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+
+	zktrie "github.com/scroll-tech/zktrie/trie"
+	zkt "github.com/scroll-tech/zktrie/types"
+
+	"github.com/scroll-tech/go-ethereum/common"
+	"github.com/scroll-tech/go-ethereum/ethdb"
+)
+
+// The leaves of a zktrie are placed by the bits of their node keys, starting with
+// the least significant one. Ranges of a zktrie are therefore expressed in path
+// keys, which are the node keys with all their bits reversed: the big-endian
+// order of the path keys is the iteration order of the trie, and every subtree
+// covers a contiguous range of them.
+
+// ZktriePathKey converts the big-endian node key of a zktrie leaf into its path
+// key. The conversion is its own inverse, so it also converts a path key back into
+// the node key.
+func ZktriePathKey(key []byte) []byte {
+	return bitReverse(key)
+}
+
+// zkPathBit returns the bit of a path key at the given depth of the trie.
+func zkPathBit(key []byte, depth int) byte {
+	return key[depth/8] >> (7 - depth%8) & 1
+}
+
+// zkPathKeyBits expands a path key into the bits of its path, one per byte.
+func zkPathKeyBits(key []byte) []byte {
+	bits := make([]byte, 8*len(key))
+	for i := range bits {
+		bits[i] = zkPathBit(key, i)
+	}
+	return bits
+}
+
+// zkPathBitsKey packs the bits of a path, one per byte, into a path key.
+func zkPathBitsKey(bits []byte) []byte {
+	key := make([]byte, (len(bits)+7)/8)
+	for i, bit := range bits {
+		key[i/8] |= bit << (7 - i%8)
+	}
+	return key
+}
+
+// zkPathToCompact converts the bits of a path, one per byte, into the compact form
+// sent over the network. The first byte is the number of padding bits at the end
+// of the packed path.
+func zkPathToCompact(bits []byte) []byte {
+	return append([]byte{byte((8 - len(bits)%8) % 8)}, zkPathBitsKey(bits)...)
+}
+
+// zkCompactToPath converts a compact path into its bits, one per byte.
+func zkCompactToPath(compact []byte) ([]byte, error) {
+	if len(compact) == 0 || compact[0] > 7 || (len(compact) == 1 && compact[0] != 0) {
+		return nil, fmt.Errorf("invalid compact zktrie path %x", compact)
+	}
+	bits := zkPathKeyBits(compact[1:])
+	return bits[:len(bits)-int(compact[0])], nil
+}
+
+// zkBranchType returns the type of a branch node with the given kinds of children.
+func zkBranchType(leftTerminal, rightTerminal bool) zktrie.NodeType {
+	switch {
+	case leftTerminal && rightTerminal:
+		return zktrie.NodeTypeBranch_0
+	case leftTerminal:
+		return zktrie.NodeTypeBranch_1
+	case rightTerminal:
+		return zktrie.NodeTypeBranch_2
+	default:
+		return zktrie.NodeTypeBranch_3
+	}
+}
+
+// zkRangeLeaf is a leaf delivered as part of a zktrie range.
+type zkRangeLeaf struct {
+	index int          // Position of the leaf in the range
+	key   []byte       // Path key of the leaf
+	node  *zktrie.Node // Leaf node reconstructed from the value
+}
+
+// newZkRangeLeaves reconstructs the leaf nodes of a range from their path keys and
+// values. Every value is made up of the 32 byte fields of the leaf, which all share
+// the same compression flag.
+func newZkRangeLeaves(keys [][]byte, values [][]byte, flag uint32) ([]*zkRangeLeaf, error) {
+	if len(keys) != len(values) {
+		return nil, fmt.Errorf("inconsistent proof data, keys: %d, values: %d", len(keys), len(values))
+	}
+	leaves := make([]*zkRangeLeaf, len(keys))
+	for i, key := range keys {
+		if len(key) != common.HashLength {
+			return nil, fmt.Errorf("invalid key length %d", len(key))
+		}
+		if i > 0 && bytes.Compare(keys[i-1], key) >= 0 {
+			return nil, errors.New("range is not monotonically increasing")
+		}
+		value := values[i]
+		if len(value) == 0 || len(value)%32 != 0 {
+			return nil, fmt.Errorf("invalid value length %d", len(value))
+		}
+		fields := make([]zkt.Byte32, len(value)/32)
+		for j := range fields {
+			copy(fields[j][:], value[j*32:])
+		}
+		leaves[i] = &zkRangeLeaf{
+			index: i,
+			key:   key,
+			node:  zktrie.NewLeafNode(zkt.NewHashFromBytes(ZktriePathKey(key)), flag, fields),
+		}
+	}
+	return leaves, nil
+}
+
+// splitZkLeaves splits the ordered leaves of a subtree into the ones of its left
+// and right children.
+func splitZkLeaves(leaves []*zkRangeLeaf, depth int) ([]*zkRangeLeaf, []*zkRangeLeaf) {
+	split := sort.Search(len(leaves), func(i int) bool {
+		return zkPathBit(leaves[i].key, depth) == 1
+	})
+	return leaves[:split], leaves[split:]
+}
+
+// zkRange is a range of path keys, with both of its ends included.
+type zkRange struct {
+	first []byte
+	last  []byte
+}
+
+// newZkRange creates the range of path keys starting at origin and ending at last,
+// empty ends stand for the smallest and the largest path keys respectively.
+func newZkRange(origin, last []byte) (*zkRange, error) {
+	r := &zkRange{first: make([]byte, common.HashLength), last: bytes.Repeat([]byte{0xff}, common.HashLength)}
+	if len(origin) > 0 {
+		if len(origin) != common.HashLength {
+			return nil, fmt.Errorf("invalid origin length %d", len(origin))
+		}
+		r.first = origin
+	}
+	if len(last) > 0 {
+		if len(last) != common.HashLength {
+			return nil, fmt.Errorf("invalid last key length %d", len(last))
+		}
+		r.last = last
+	}
+	return r, nil
+}
+
+// zkRangeRelation tells the relation of a subtree to a range of path keys.
+type zkRangeRelation int
+
+const (
+	zkRangeStraddling zkRangeRelation = iota // Subtree partially within the range
+	zkRangeInside                            // Subtree entirely within the range
+	zkRangeBefore                            // Subtree entirely before the range
+	zkRangeAfter                             // Subtree entirely after the range
+)
+
+// relation returns the relation of the subtree with the given path prefix, the
+// first depth bits of prefix, to the range.
+func (r *zkRange) relation(prefix []byte, depth int) zkRangeRelation {
+	lo, hi := make([]byte, common.HashLength), make([]byte, common.HashLength)
+	for i := 0; i < 8*common.HashLength; i++ {
+		bit := byte(1)
+		if i < depth {
+			bit = zkPathBit(prefix, i)
+			lo[i/8] |= bit << (7 - i%8)
+		}
+		hi[i/8] |= bit << (7 - i%8)
+	}
+	switch {
+	case bytes.Compare(hi, r.first) < 0:
+		return zkRangeBefore
+	case bytes.Compare(lo, r.last) > 0:
+		return zkRangeAfter
+	case bytes.Compare(lo, r.first) >= 0 && bytes.Compare(hi, r.last) <= 0:
+		return zkRangeInside
+	default:
+		return zkRangeStraddling
+	}
+}
+
+// contains reports whether the path key is within the range.
+func (r *zkRange) contains(key []byte) bool {
+	return bytes.Compare(key, r.first) >= 0 && bytes.Compare(key, r.last) <= 0
+}
+
+// zkChildPrefix returns the path prefix of a child of the subtree with the given
+// prefix and depth.
+func zkChildPrefix(prefix []byte, depth int, bit byte) []byte {
+	child := common.CopyBytes(prefix)
+	child[depth/8] &^= 1 << (7 - depth%8)
+	child[depth/8] |= bit << (7 - depth%8)
+	return child
+}
+
+// zkRangeBuilder reconstructs the subtrees of a zktrie from all of their leaves,
+// optionally writing the nodes of the subtrees into a database.
+type zkRangeBuilder struct {
+	db   ethdb.KeyValueWriter // Database to write the nodes into, nil to only hash
+	skip func(int) bool       // Leaves whose ancestors must not be written, if set
+}
+
+// build reconstructs the subtree at the given depth containing exactly the given
+// leaves. It reports whether any of the leaves is to be skipped.
+func (b *zkRangeBuilder) build(depth int, leaves []*zkRangeLeaf) (*zktrie.Node, bool, error) {
+	var (
+		node    *zktrie.Node
+		skipped bool
+	)
+	switch len(leaves) {
+	case 0:
+		return zktrie.NewEmptyNode(), false, nil
+
+	case 1:
+		node = leaves[0].node
+		skipped = b.skip != nil && b.skip(leaves[0].index)
+
+	default:
+		if depth >= zkTrieMaxLevels {
+			return nil, false, errors.New("zktrie leaf keys collide")
+		}
+		l, r := splitZkLeaves(leaves, depth)
+		left, lskipped, err := b.build(depth+1, l)
+		if err != nil {
+			return nil, false, err
+		}
+		right, rskipped, err := b.build(depth+1, r)
+		if err != nil {
+			return nil, false, err
+		}
+		lhash, err := left.NodeHash()
+		if err != nil {
+			return nil, false, err
+		}
+		rhash, err := right.NodeHash()
+		if err != nil {
+			return nil, false, err
+		}
+		node = zktrie.NewParentNode(zkBranchType(left.IsTerminal(), right.IsTerminal()), lhash, rhash)
+		skipped = lskipped || rskipped
+	}
+	hash, err := node.NodeHash()
+	if err != nil {
+		return nil, false, err
+	}
+	if b.db != nil && !skipped {
+		if err := b.db.Put(bitReverse(hash[:]), node.CanonicalValue()); err != nil {
+			return nil, false, err
+		}
+	}
+	return node, skipped, nil
+}
+
+// commit writes all the subtrees within the range which are below the subtree with
+// the given prefix and depth.
+func (b *zkRangeBuilder) commit(r *zkRange, prefix []byte, depth int, leaves []*zkRangeLeaf) error {
+	switch r.relation(prefix, depth) {
+	case zkRangeInside:
+		_, _, err := b.build(depth, leaves)
+		return err
+
+	case zkRangeStraddling:
+		l, rr := splitZkLeaves(leaves, depth)
+		if err := b.commit(r, zkChildPrefix(prefix, depth, 0), depth+1, l); err != nil {
+			return err
+		}
+		return b.commit(r, zkChildPrefix(prefix, depth, 1), depth+1, rr)
+	}
+	return nil
+}
+
+// zkRangeVerifier reconstructs the root of a zktrie from a range of its leaves and
+// the proof nodes of the range boundaries.
+type zkRangeVerifier struct {
+	rng   *zkRange
+	proof map[zkt.Hash]*zktrie.Node
+	cont  bool // Whether there are leaves after the range
+}
+
+// verify reconstructs the node with the given hash, which straddles the range,
+// from the proof nodes and the leaves of the range.
+func (v *zkRangeVerifier) verify(prefix []byte, depth int, hash *zkt.Hash, leaves []*zkRangeLeaf) (*zktrie.Node, error) {
+	if *hash == zkt.HashZero {
+		if len(leaves) > 0 {
+			return nil, errors.New("more leaves than the trie")
+		}
+		return zktrie.NewEmptyNode(), nil
+	}
+	node, ok := v.proof[*hash]
+	if !ok {
+		return nil, fmt.Errorf("missing proof node %x", hash.Bytes())
+	}
+	switch node.Type {
+	case zktrie.NodeTypeLeaf_New:
+		// The only leaf of the subtree, if it's within the range it must be the
+		// delivered one, otherwise none may be delivered.
+		key := ZktriePathKey(node.NodeKey.Bytes())
+		for i := 0; i < depth; i++ {
+			if zkPathBit(key, i) != zkPathBit(prefix, i) {
+				return nil, fmt.Errorf("misplaced proof leaf %x", key)
+			}
+		}
+		if v.rng.contains(key) {
+			if len(leaves) != 1 {
+				return nil, fmt.Errorf("leaf count mismatch, have %d, want 1", len(leaves))
+			}
+			return leaves[0].node, nil
+		}
+		if len(leaves) > 0 {
+			return nil, errors.New("more leaves than the trie")
+		}
+		if bytes.Compare(key, v.rng.last) > 0 {
+			v.cont = true
+		}
+		return node, nil
+
+	case zktrie.NodeTypeBranch_0, zktrie.NodeTypeBranch_1, zktrie.NodeTypeBranch_2, zktrie.NodeTypeBranch_3:
+		var (
+			children  = [2]*zkt.Hash{node.ChildL, node.ChildR}
+			terminals = [2]bool{
+				node.Type == zktrie.NodeTypeBranch_0 || node.Type == zktrie.NodeTypeBranch_1,
+				node.Type == zktrie.NodeTypeBranch_0 || node.Type == zktrie.NodeTypeBranch_2,
+			}
+			subsets [2][]*zkRangeLeaf
+		)
+		subsets[0], subsets[1] = splitZkLeaves(leaves, depth)
+		for i := range children {
+			childPrefix := zkChildPrefix(prefix, depth, byte(i))
+			switch rel := v.rng.relation(childPrefix, depth+1); rel {
+			case zkRangeBefore, zkRangeAfter:
+				// Subtree out of the range, take it from the proof as is
+				if len(subsets[i]) > 0 {
+					return nil, errors.New("leaf outside of the range")
+				}
+				if rel == zkRangeAfter && *children[i] != zkt.HashZero {
+					v.cont = true
+				}
+
+			case zkRangeInside:
+				child, _, err := (&zkRangeBuilder{}).build(depth+1, subsets[i])
+				if err != nil {
+					return nil, err
+				}
+				if children[i], err = child.NodeHash(); err != nil {
+					return nil, err
+				}
+				terminals[i] = child.IsTerminal()
+
+			default:
+				child, err := v.verify(childPrefix, depth+1, children[i], subsets[i])
+				if err != nil {
+					return nil, err
+				}
+				if children[i], err = child.NodeHash(); err != nil {
+					return nil, err
+				}
+				terminals[i] = child.IsTerminal()
+			}
+		}
+		return zktrie.NewParentNode(zkBranchType(terminals[0], terminals[1]), children[0], children[1]), nil
+
+	default:
+		return nil, fmt.Errorf("invalid proof node type %d", node.Type)
+	}
+}
+
+// VerifyZktrieRangeProof checks whether the given leaves are all the leaves of the
+// zktrie with the given root, in the range starting at origin and ending at the last
+// key. If no keys are given, it checks that there are no leaves after origin.
+// The keys are the path keys of the leaves, see ZktriePathKey, and the values are
+// made up of the 32 byte fields of the leaves, which share the compression flag.
+//
+// The proof has to contain the nodes proving origin and the last key, as written by
+// ZkTrie.ProveNodeKey. If it's nil, the leaves must be all the leaves of the trie.
+//
+// The returned boolean tells whether there are more leaves after the last key.
+func VerifyZktrieRangeProof(rootHash common.Hash, origin []byte, keys [][]byte, values [][]byte, flag uint32, proof [][]byte) (bool, error) {
+	leaves, err := newZkRangeLeaves(keys, values, flag)
+	if err != nil {
+		return false, err
+	}
+	root := zkt.NewHashFromBytes(rootHash.Bytes())
+
+	// Special case, there is no proof, the leaves must make up the entire trie
+	if proof == nil {
+		node, _, err := (&zkRangeBuilder{}).build(0, leaves)
+		if err != nil {
+			return false, err
+		}
+		hash, err := node.NodeHash()
+		if err != nil {
+			return false, err
+		}
+		if *hash != *root {
+			return false, fmt.Errorf("invalid proof, want hash %x, got %x", root.Bytes(), hash.Bytes())
+		}
+		return false, nil
+	}
+	var last []byte
+	if len(keys) > 0 {
+		last = keys[len(keys)-1]
+	}
+	rng, err := newZkRange(origin, last)
+	if err != nil {
+		return false, err
+	}
+	if len(keys) > 0 && !rng.contains(keys[0]) {
+		return false, errors.New("range is not monotonically increasing")
+	}
+	v := &zkRangeVerifier{rng: rng, proof: make(map[zkt.Hash]*zktrie.Node)}
+	for _, blob := range proof {
+		node, err := zktrie.NewNodeFromBytes(blob)
+		if err != nil {
+			return false, err
+		}
+		hash, err := node.NodeHash()
+		if err != nil {
+			return false, err
+		}
+		v.proof[*hash] = node
+	}
+	var node *zktrie.Node
+	if rng.relation(nil, 0) == zkRangeInside {
+		node, _, err = (&zkRangeBuilder{}).build(0, leaves)
+	} else {
+		node, err = v.verify(make([]byte, common.HashLength), 0, root, leaves)
+	}
+	if err != nil {
+		return false, err
+	}
+	hash, err := node.NodeHash()
+	if err != nil {
+		return false, err
+	}
+	if *hash != *root {
+		return false, fmt.Errorf("invalid proof, want hash %x, got %x", root.Bytes(), hash.Bytes())
+	}
+	return v.cont, nil
+}
+
+// CommitZktrieRange writes the nodes of all subtrees of a zktrie that lie entirely
+// within the range from origin to last into the database, reconstructing them from
+// the leaves of the range. Empty ends stand for the smallest and the largest path
+// keys respectively. The keys and values have the same format as the ones of
+// VerifyZktrieRangeProof and must be all the leaves within the range.
+//
+// If skip is given, the subtrees containing a leaf for which it returns true are
+// not written, so that they can still be fetched by the trie sync.
+func CommitZktrieRange(origin, last []byte, keys [][]byte, values [][]byte, flag uint32, skip func(int) bool, db ethdb.KeyValueWriter) error {
+	leaves, err := newZkRangeLeaves(keys, values, flag)
+	if err != nil {
+		return err
+	}
+	rng, err := newZkRange(origin, last)
+	if err != nil {
+		return err
+	}
+	b := &zkRangeBuilder{db: db, skip: skip}
+	return b.commit(rng, make([]byte, common.HashLength), 0, leaves)
+}
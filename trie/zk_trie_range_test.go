@@ -0,0 +1,277 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"bytes"
+	"encoding/binary"
+	mrand "math/rand"
+	"sort"
+	"testing"
+
+	zktrie "github.com/scroll-tech/zktrie/trie"
+
+	"github.com/scroll-tech/go-ethereum/common"
+	"github.com/scroll-tech/go-ethereum/ethdb/memorydb"
+)
+
+// makeZkRangeTrie creates a zktrie of n storage slots and returns it along with the
+// path keys and values of its leaves in iteration order.
+func makeZkRangeTrie(n int) (*ZkTrie, [][]byte, [][]byte) {
+	tr, _ := NewZkTrie(common.Hash{}, NewZktrieDatabase(memorydb.New()))
+	type entry struct{ key, value []byte }
+	entries := make([]entry, 0, n)
+	for i := 0; i < n; i++ {
+		key, value := make([]byte, 32), make([]byte, 32)
+		binary.BigEndian.PutUint64(key[24:], uint64(i))
+		binary.BigEndian.PutUint64(value[24:], uint64(i+1))
+		tr.Update(key, value)
+
+		nodeKey := zkTestNodeKey(key)
+		entries = append(entries, entry{ZktriePathKey(nodeKey), value})
+	}
+	tr.Commit(nil)
+
+	sort.Slice(entries, func(i, j int) bool { return bytes.Compare(entries[i].key, entries[j].key) < 0 })
+	keys, values := make([][]byte, n), make([][]byte, n)
+	for i, e := range entries {
+		keys[i], values[i] = e.key, e.value
+	}
+	return tr, keys, values
+}
+
+// proveZkRange creates the proof of a range starting at origin and ending at last.
+func proveZkRange(t *testing.T, tr *ZkTrie, origin, last []byte) [][]byte {
+	proof := memorydb.New()
+	if err := tr.ProveNodeKey(ZktriePathKey(origin), proof); err != nil {
+		t.Fatal(err)
+	}
+	if last != nil {
+		if err := tr.ProveNodeKey(ZktriePathKey(last), proof); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var nodes [][]byte
+	it := proof.NewIterator(nil, nil)
+	for it.Next() {
+		nodes = append(nodes, common.CopyBytes(it.Value()))
+	}
+	it.Release()
+	return nodes
+}
+
+// Tests that the path keys are ordered like the leaves are iterated.
+func TestZktriePathKey(t *testing.T) {
+	tr, keys, _ := makeZkRangeTrie(100)
+
+	it := NewIterator(tr.NodeIterator(nil))
+	for i := 0; it.Next(); i++ {
+		if want := ZktriePathKey(keys[i]); !bytes.Equal(it.Key, want) {
+			t.Fatalf("leaf %d: node key mismatch, have %x, want %x", i, it.Key, want)
+		}
+	}
+	for _, bits := range [][]byte{nil, {1}, {0, 1, 1, 0, 1, 0, 1, 1}, {1, 0, 1, 1, 0, 0, 1, 1, 1}} {
+		have, err := zkCompactToPath(zkPathToCompact(bits))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(have, bits) && len(bits)+len(have) > 0 {
+			t.Fatalf("path %v: compact conversion mismatch, have %v", bits, have)
+		}
+	}
+}
+
+// Tests that ranges of a zktrie are proven, both complete ones and partial ones
+// starting at a key which is not in the trie.
+func TestZktrieRangeProof(t *testing.T) {
+	tr, keys, values := makeZkRangeTrie(500)
+	root := tr.Hash()
+
+	for i := 0; i < 500; i++ {
+		start, end := mrand.Intn(len(keys)), mrand.Intn(len(keys))
+		if start > end {
+			start, end = end, start
+		}
+		origin := keys[start]
+		if i%2 == 0 && start > 0 {
+			// Start in the gap before the first key
+			origin = common.CopyBytes(keys[start-1])
+			origin[31]++
+			if bytes.Equal(origin, keys[start]) {
+				continue
+			}
+		}
+		proof := proveZkRange(t, tr, origin, keys[end])
+		cont, err := VerifyZktrieRangeProof(root, origin, keys[start:end+1], values[start:end+1], 1, proof)
+		if err != nil {
+			t.Fatalf("range %d-%d: failed to verify: %v", start, end, err)
+		}
+		if want := end != len(keys)-1; cont != want {
+			t.Fatalf("range %d-%d: continuation mismatch, have %v, want %v", start, end, cont, want)
+		}
+	}
+	// The entire trie without proof
+	if cont, err := VerifyZktrieRangeProof(root, nil, keys, values, 1, nil); err != nil || cont {
+		t.Fatalf("failed to verify entire trie: cont %v, err %v", cont, err)
+	}
+	// The entire trie with proof
+	proof := proveZkRange(t, tr, make([]byte, 32), keys[len(keys)-1])
+	if cont, err := VerifyZktrieRangeProof(root, nil, keys, values, 1, proof); err != nil || cont {
+		t.Fatalf("failed to verify entire trie with proof: cont %v, err %v", cont, err)
+	}
+}
+
+// Tests that an empty range after the last leaf is proven, and that an empty
+// range before other leaves is rejected.
+func TestZktrieEmptyRangeProof(t *testing.T) {
+	tr, keys, _ := makeZkRangeTrie(100)
+	root := tr.Hash()
+
+	origin := common.CopyBytes(keys[len(keys)-1])
+	origin[31]++
+	if cont, err := VerifyZktrieRangeProof(root, origin, nil, nil, 1, proveZkRange(t, tr, origin, nil)); err != nil || cont {
+		t.Fatalf("failed to verify empty range: cont %v, err %v", cont, err)
+	}
+	origin = common.CopyBytes(keys[50])
+	origin[31]--
+	if _, err := VerifyZktrieRangeProof(root, origin, nil, nil, 1, proveZkRange(t, tr, origin, nil)); err == nil {
+		t.Fatal("empty range with leaves verified")
+	}
+	// Empty trie
+	empty, _ := NewZkTrie(common.Hash{}, NewZktrieDatabase(memorydb.New()))
+	if cont, err := VerifyZktrieRangeProof(common.Hash{}, nil, nil, nil, 1, proveZkRange(t, empty, make([]byte, 32), nil)); err != nil || cont {
+		t.Fatalf("failed to verify empty trie: cont %v, err %v", cont, err)
+	}
+}
+
+// Tests that tampered ranges are rejected.
+func TestZktrieBadRangeProof(t *testing.T) {
+	tr, keys, values := makeZkRangeTrie(500)
+	root := tr.Hash()
+
+	for i := 0; i < 200; i++ {
+		start, end := mrand.Intn(len(keys)-3), 0
+		end = start + 2 + mrand.Intn(len(keys)-start-2)
+
+		var (
+			rkeys   = append([][]byte{}, keys[start:end+1]...)
+			rvalues = append([][]byte{}, values[start:end+1]...)
+			proof   = proveZkRange(t, tr, keys[start], keys[end])
+		)
+		switch i % 4 {
+		case 0:
+			// Modified value
+			index := mrand.Intn(len(rvalues))
+			rvalues[index] = common.CopyBytes(rvalues[index])
+			rvalues[index][0]++
+		case 1:
+			// Missing leaf in the middle
+			index := 1 + mrand.Intn(len(rkeys)-2)
+			rkeys = append(rkeys[:index:index], rkeys[index+1:]...)
+			rvalues = append(rvalues[:index:index], rvalues[index+1:]...)
+		case 2:
+			// Missing proof node
+			proof = proof[1:]
+		case 3:
+			// Extra leaf in the middle
+			index := 1 + mrand.Intn(len(rkeys)-1)
+			key := common.CopyBytes(rkeys[index-1])
+			key[31]++
+			if bytes.Equal(key, rkeys[index]) {
+				continue
+			}
+			rkeys = append(rkeys[:index:index], append([][]byte{key}, rkeys[index:]...)...)
+			rvalues = append(rvalues[:index:index], append([][]byte{rvalues[index]}, rvalues[index:]...)...)
+		}
+		if _, err := VerifyZktrieRangeProof(root, keys[start], rkeys, rvalues, 1, proof); err == nil {
+			t.Fatalf("case %d, range %d-%d: tampered range verified", i%4, start, end)
+		}
+	}
+}
+
+// Tests that the subtrees within consecutive ranges are committed, except the ones
+// containing skipped leaves.
+func TestZktrieCommitRange(t *testing.T) {
+	tr, keys, values := makeZkRangeTrie(500)
+
+	var (
+		db      = memorydb.New()
+		skipped = make(map[string]bool)
+		bounds  = []int{0, 60, 61, 200, 350, 499}
+	)
+	for i := 1; i < len(bounds); i++ {
+		var (
+			start, end = bounds[i-1], bounds[i]
+			origin     = keys[start]
+		)
+		if i == 1 {
+			origin = nil
+		}
+		err := CommitZktrieRange(origin, keys[end], keys[start:end+1], values[start:end+1], 1, func(i int) bool {
+			return (start+i)%97 == 0
+		}, db)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := range keys {
+		if i%97 == 0 {
+			skipped[string(keys[i])] = true
+		}
+	}
+	// All written nodes must be in the trie, none of them above skipped leaves
+	it := tr.NodeIterator(nil)
+	var written int
+	for it.Next(true) {
+		blob, _ := db.Get(ZktrieNodeKey(it.Hash()))
+		if blob == nil {
+			continue
+		}
+		written++
+		node, err := zktrie.NewNodeFromBytes(blob)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hash, _ := node.NodeHash()
+		if common.BytesToHash(hash.Bytes()) != it.Hash() {
+			t.Fatalf("node %x: hash mismatch", it.Hash())
+		}
+		// Check that there are no skipped leaves below the node
+		sub := NewIterator(newZkSubtreeIterator(t, tr, it.Hash()))
+		for sub.Next() {
+			if skipped[string(ZktriePathKey(sub.Key))] {
+				t.Fatalf("node %x above skipped leaf %x written", it.Hash(), sub.Key)
+			}
+		}
+	}
+	if written != db.Len() {
+		t.Fatalf("written node count mismatch, have %d in trie, %d in database", written, db.Len())
+	}
+	if written == 0 {
+		t.Fatal("no nodes written")
+	}
+}
+
+// newZkSubtreeIterator returns an iterator over the subtree of a committed zktrie
+// rooted at the given node.
+func newZkSubtreeIterator(t *testing.T, tr *ZkTrie, hash common.Hash) NodeIterator {
+	sub, err := NewZkTrie(hash, tr.db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sub.NodeIterator(nil)
+}
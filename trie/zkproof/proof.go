@@ -0,0 +1,195 @@
+package zkproof
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+
+	zktrie "github.com/scroll-tech/zktrie/trie"
+
+	"github.com/scroll-tech/go-ethereum/common"
+	"github.com/scroll-tech/go-ethereum/common/hexutil"
+	"github.com/scroll-tech/go-ethereum/core/types"
+	"github.com/scroll-tech/go-ethereum/crypto/codehash"
+	"github.com/scroll-tech/go-ethereum/ethdb/memorydb"
+	"github.com/scroll-tech/go-ethereum/trie"
+)
+
+// NewSMTPath decodes a zktrie proof, as the list of node blobs from the root down
+// to the leaf (optionally followed by the magic bytes), into the SMT path it proves.
+func NewSMTPath(proof [][]byte) (*SMTPath, error) {
+	var (
+		path       = new(SMTPath)
+		keyPath    = big.NewInt(0)
+		keyCounter = big.NewInt(1)
+		lastNode   *zktrie.Node
+	)
+	for i, blob := range proof {
+		n, err := decodeNode(blob)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proof node %d: %v", i, err)
+		}
+		if n == nil {
+			// The magic bytes terminate the proof
+			break
+		}
+		nodeHash, err := n.NodeHash()
+		if err != nil {
+			return nil, err
+		}
+		if lastNode == nil {
+			path.Root = nodeHash[:]
+		} else {
+			switch {
+			case bytes.Equal(nodeHash[:], lastNode.ChildL[:]):
+				path.Path = append(path.Path, SMTPathNode{Value: nodeHash[:], Sibling: lastNode.ChildR[:]})
+			case bytes.Equal(nodeHash[:], lastNode.ChildR[:]):
+				path.Path = append(path.Path, SMTPathNode{Value: nodeHash[:], Sibling: lastNode.ChildL[:]})
+				keyPath.Add(keyPath, keyCounter)
+			default:
+				return nil, fmt.Errorf("proof node %d is not a child of its parent", i)
+			}
+			keyCounter.Mul(keyCounter, big.NewInt(2))
+		}
+		switch n.Type {
+		case zktrie.NodeTypeBranch_0, zktrie.NodeTypeBranch_1, zktrie.NodeTypeBranch_2, zktrie.NodeTypeBranch_3:
+			lastNode = n
+			continue
+		case zktrie.NodeTypeLeaf_New:
+			valueHash, err := n.ValueHash()
+			if err != nil {
+				return nil, err
+			}
+			path.Leaf = &SMTPathNode{Value: valueHash[:], Sibling: n.NodeKey[:]}
+		}
+		path.KeyPathPart = (*hexutil.Big)(keyPath)
+		return path, nil
+	}
+	return nil, errors.New("proof ends before a terminal node")
+}
+
+// VerifyAccountProof checks the proof of an account against the state root and
+// returns the proven account, or nil if the proof shows that it does not exist.
+func VerifyAccountProof(root common.Hash, address common.Address, proof [][]byte) (*types.StateAccount, error) {
+	data, err := verifyProof(root, address.Bytes(), proof)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	return types.UnmarshalStateAccount(data)
+}
+
+// VerifyStorageProof checks the proof of a storage slot against the storage root
+// of its account and returns the proven value, which is zero for missing slots.
+func VerifyStorageProof(root common.Hash, key common.Hash, proof [][]byte) (common.Hash, error) {
+	data, err := verifyProof(root, key.Bytes(), proof)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return common.BytesToHash(data), nil
+}
+
+// AccountClaim is the state of an account and some of its storage slots, along with
+// the proofs of these values, as returned by eth_getProof.
+type AccountClaim struct {
+	Address          common.Address
+	AccountProof     [][]byte
+	Balance          *big.Int
+	Nonce            uint64
+	CodeSize         uint64
+	KeccakCodeHash   common.Hash
+	PoseidonCodeHash common.Hash
+	StorageHash      common.Hash
+	StorageProof     []StorageClaim
+}
+
+// StorageClaim is the value of a storage slot along with its proof.
+type StorageClaim struct {
+	Key   common.Hash
+	Value *big.Int
+	Proof [][]byte
+}
+
+// VerifyAccountClaim checks the account and storage proofs of the claim against the
+// state root, and that the proven values match the claimed ones.
+func VerifyAccountClaim(root common.Hash, claim *AccountClaim) error {
+	account, err := VerifyAccountProof(root, claim.Address, claim.AccountProof)
+	if err != nil {
+		return fmt.Errorf("account proof verification failed: %v", err)
+	}
+	if account == nil {
+		// The account does not exist, nor do its fields
+		account = &types.StateAccount{
+			Balance:          new(big.Int),
+			KeccakCodeHash:   codehash.EmptyKeccakCodeHash.Bytes(),
+			PoseidonCodeHash: codehash.EmptyPoseidonCodeHash.Bytes(),
+		}
+	}
+	switch {
+	case claim.Balance == nil || account.Balance.Cmp(claim.Balance) != 0:
+		return fmt.Errorf("balance mismatch: have %v, proven %v", claim.Balance, account.Balance)
+	case account.Nonce != claim.Nonce:
+		return fmt.Errorf("nonce mismatch: have %d, proven %d", claim.Nonce, account.Nonce)
+	case account.CodeSize != claim.CodeSize:
+		return fmt.Errorf("code size mismatch: have %d, proven %d", claim.CodeSize, account.CodeSize)
+	case common.BytesToHash(account.KeccakCodeHash) != claim.KeccakCodeHash:
+		return fmt.Errorf("keccak code hash mismatch: have %x, proven %x", claim.KeccakCodeHash, account.KeccakCodeHash)
+	case common.BytesToHash(account.PoseidonCodeHash) != claim.PoseidonCodeHash:
+		return fmt.Errorf("poseidon code hash mismatch: have %x, proven %x", claim.PoseidonCodeHash, account.PoseidonCodeHash)
+	case account.Root != claim.StorageHash:
+		return fmt.Errorf("storage hash mismatch: have %x, proven %x", claim.StorageHash, account.Root)
+	}
+	for _, slot := range claim.StorageProof {
+		var value common.Hash
+		if account.Root != (common.Hash{}) {
+			if value, err = VerifyStorageProof(account.Root, slot.Key, slot.Proof); err != nil {
+				return fmt.Errorf("storage proof verification of %x failed: %v", slot.Key, err)
+			}
+		}
+		if slot.Value == nil || value.Big().Cmp(slot.Value) != 0 {
+			return fmt.Errorf("storage value mismatch of %x: have %v, proven %x", slot.Key, slot.Value, value)
+		}
+	}
+	return nil
+}
+
+// verifyProof indexes the proof nodes by their hash and verifies the proof of the
+// key preimage against the root.
+func verifyProof(root common.Hash, key []byte, proof [][]byte) ([]byte, error) {
+	proofDb := memorydb.New()
+	for i, blob := range proof {
+		n, err := decodeNode(blob)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proof node %d: %v", i, err)
+		}
+		if n == nil {
+			continue
+		}
+		nodeHash, err := n.NodeHash()
+		if err != nil {
+			return nil, err
+		}
+		proofDb.Put(nodeHash[:], blob)
+	}
+	return trie.VerifyProofSMT(root, key, proofDb)
+}
+
+// decodeNode decodes a proof node, returning nil for the magic bytes. Unlike the
+// zktrie decoder, it rejects the deprecated node types and leaves without value,
+// which would make hashing or reading the node panic.
+func decodeNode(blob []byte) (*zktrie.Node, error) {
+	n, err := zktrie.DecodeSMTProof(blob)
+	if err != nil || n == nil {
+		return nil, err
+	}
+	switch n.Type {
+	case zktrie.NodeTypeBranch_0, zktrie.NodeTypeBranch_1, zktrie.NodeTypeBranch_2, zktrie.NodeTypeBranch_3, zktrie.NodeTypeEmpty_New:
+	case zktrie.NodeTypeLeaf_New:
+		if len(n.ValuePreimage) == 0 {
+			return nil, errors.New("leaf without value")
+		}
+	default:
+		return nil, fmt.Errorf("unexpected node type %d", n.Type)
+	}
+	return n, nil
+}
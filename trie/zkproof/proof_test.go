@@ -0,0 +1,238 @@
+package zkproof
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	zkt "github.com/scroll-tech/zktrie/types"
+
+	"github.com/scroll-tech/go-ethereum/common"
+	"github.com/scroll-tech/go-ethereum/core/rawdb"
+	"github.com/scroll-tech/go-ethereum/core/state"
+	"github.com/scroll-tech/go-ethereum/crypto/codehash"
+	"github.com/scroll-tech/go-ethereum/trie"
+)
+
+// makeZkState creates a zktrie state with a few accounts, the first of them having
+// some storage, and returns it reopened at its root.
+func makeZkState(t *testing.T) (*state.StateDB, common.Hash) {
+	db := state.NewDatabaseWithConfig(rawdb.NewMemoryDatabase(), &trie.Config{Zktrie: true})
+	statedb, _ := state.New(common.Hash{}, db, nil)
+	for i := 1; i <= 50; i++ {
+		addr := common.BigToAddress(big.NewInt(int64(i)))
+		statedb.SetBalance(addr, big.NewInt(int64(i*100)))
+		statedb.SetNonce(addr, uint64(i))
+	}
+	contract := common.BigToAddress(big.NewInt(1))
+	statedb.SetCode(contract, []byte{0x60, 0x00})
+	for i := 1; i <= 20; i++ {
+		statedb.SetState(contract, common.BigToHash(big.NewInt(int64(i))), common.BigToHash(big.NewInt(int64(i*7))))
+	}
+	root, err := statedb.Commit(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.TrieDB().Commit(root, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	statedb, err = state.New(root, db, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return statedb, root
+}
+
+// Tests that account and storage proofs of a zktrie state are verified, both for
+// existing and for missing entries.
+func TestVerifyProof(t *testing.T) {
+	statedb, root := makeZkState(t)
+
+	for i := 1; i <= 51; i++ {
+		addr := common.BigToAddress(big.NewInt(int64(i)))
+		proof, err := statedb.GetProof(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		account, err := VerifyAccountProof(root, addr, proof)
+		if err != nil {
+			t.Fatalf("account %d: failed to verify: %v", i, err)
+		}
+		if i == 51 {
+			if account != nil {
+				t.Fatalf("missing account proven: %v", account)
+			}
+			continue
+		}
+		if account == nil || account.Nonce != uint64(i) || account.Balance.Cmp(statedb.GetBalance(addr)) != 0 {
+			t.Fatalf("account %d: mismatch: %v", i, account)
+		}
+	}
+	contract := common.BigToAddress(big.NewInt(1))
+	storageRoot := statedb.StorageTrie(contract).Hash()
+	for i := 1; i <= 21; i++ {
+		key := common.BigToHash(big.NewInt(int64(i)))
+		proof, err := statedb.GetStorageProof(contract, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		value, err := VerifyStorageProof(storageRoot, key, proof)
+		if err != nil {
+			t.Fatalf("slot %d: failed to verify: %v", i, err)
+		}
+		if want := statedb.GetState(contract, key); value != want {
+			t.Fatalf("slot %d: value mismatch, have %x, want %x", i, value, want)
+		}
+	}
+}
+
+// Tests that tampered proofs are rejected.
+func TestVerifyBadProof(t *testing.T) {
+	statedb, root := makeZkState(t)
+
+	addr := common.BigToAddress(big.NewInt(7))
+	proof, err := statedb.GetProof(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Missing intermediate node
+	if _, err := VerifyAccountProof(root, addr, append(proof[:1:1], proof[2:]...)); err == nil {
+		t.Fatal("proof with missing node verified")
+	}
+	// Modified leaf
+	bad := make([][]byte, len(proof))
+	copy(bad, proof)
+	leaf := common.CopyBytes(bad[len(bad)-2])
+	leaf[len(leaf)-40]++
+	bad[len(bad)-2] = leaf
+	if _, err := VerifyAccountProof(root, addr, bad); err == nil {
+		t.Fatal("proof with modified leaf verified")
+	}
+	// Wrong root
+	if _, err := VerifyAccountProof(common.Hash{1}, addr, proof); err == nil {
+		t.Fatal("proof verified against wrong root")
+	}
+	// Deprecated node type
+	if _, err := VerifyAccountProof(root, addr, [][]byte{{0}}); err == nil {
+		t.Fatal("deprecated node verified")
+	}
+}
+
+// Tests that claimed account and storage values are verified against their proofs,
+// and that values differing from the proven ones are rejected.
+func TestVerifyAccountClaim(t *testing.T) {
+	statedb, root := makeZkState(t)
+
+	// makeClaim assembles the claim of the current state of an account.
+	makeClaim := func(addr common.Address, keys ...common.Hash) *AccountClaim {
+		proof, err := statedb.GetProof(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		claim := &AccountClaim{
+			Address:          addr,
+			AccountProof:     proof,
+			Balance:          statedb.GetBalance(addr),
+			Nonce:            statedb.GetNonce(addr),
+			CodeSize:         uint64(statedb.GetCodeSize(addr)),
+			KeccakCodeHash:   statedb.GetKeccakCodeHash(addr),
+			PoseidonCodeHash: statedb.GetPoseidonCodeHash(addr),
+		}
+		if storageTrie := statedb.StorageTrie(addr); storageTrie != nil {
+			claim.StorageHash = storageTrie.Hash()
+		} else {
+			claim.KeccakCodeHash, claim.PoseidonCodeHash = codehash.EmptyKeccakCodeHash, codehash.EmptyPoseidonCodeHash
+		}
+		for _, key := range keys {
+			proof, err := statedb.GetStorageProof(addr, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			claim.StorageProof = append(claim.StorageProof, StorageClaim{
+				Key:   key,
+				Value: statedb.GetState(addr, key).Big(),
+				Proof: proof,
+			})
+		}
+		return claim
+	}
+	var (
+		contract = common.BigToAddress(big.NewInt(1))
+		account  = common.BigToAddress(big.NewInt(2))
+		missing  = common.BigToAddress(big.NewInt(51))
+	)
+	if err := VerifyAccountClaim(root, makeClaim(contract, common.BigToHash(big.NewInt(1)), common.BigToHash(big.NewInt(21)))); err != nil {
+		t.Fatalf("failed to verify contract: %v", err)
+	}
+	if err := VerifyAccountClaim(root, makeClaim(account)); err != nil {
+		t.Fatalf("failed to verify account: %v", err)
+	}
+	if err := VerifyAccountClaim(root, makeClaim(missing)); err != nil {
+		t.Fatalf("failed to verify missing account: %v", err)
+	}
+
+	// Claims differing from the proven values
+	bad := makeClaim(account)
+	bad.Nonce++
+	if err := VerifyAccountClaim(root, bad); err == nil {
+		t.Fatal("wrong nonce verified")
+	}
+	bad = makeClaim(missing)
+	bad.Balance = common.Big1
+	if err := VerifyAccountClaim(root, bad); err == nil {
+		t.Fatal("balance of missing account verified")
+	}
+	bad = makeClaim(contract)
+	bad.StorageHash = common.Hash{}
+	if err := VerifyAccountClaim(root, bad); err == nil {
+		t.Fatal("wrong storage hash verified")
+	}
+	bad = makeClaim(contract, common.BigToHash(big.NewInt(1)))
+	bad.StorageProof[0].Value = common.Big0
+	if err := VerifyAccountClaim(root, bad); err == nil {
+		t.Fatal("wrong storage value verified")
+	}
+	bad = makeClaim(contract, common.BigToHash(big.NewInt(1)))
+	bad.StorageProof[0].Proof = bad.StorageProof[0].Proof[1:]
+	if err := VerifyAccountClaim(root, bad); err == nil {
+		t.Fatal("incomplete storage proof verified")
+	}
+}
+
+// Tests that proofs are decoded into the path from the root to the leaf.
+func TestNewSMTPath(t *testing.T) {
+	statedb, root := makeZkState(t)
+
+	for i := 1; i <= 51; i++ {
+		addr := common.BigToAddress(big.NewInt(int64(i)))
+		proof, err := statedb.GetProof(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		path, err := NewSMTPath(proof)
+		if err != nil {
+			t.Fatalf("account %d: failed to decode: %v", i, err)
+		}
+		if want := zkt.NewHashFromBytes(root.Bytes()); !bytes.Equal(path.Root, want[:]) {
+			t.Fatalf("account %d: root mismatch, have %x, want %x", i, path.Root, want[:])
+		}
+		key, _ := zkt.ToSecureKey(addr.Bytes())
+		nodeKey := zkt.NewHashFromBigInt(key)
+		if i == 51 {
+			if path.Leaf != nil && bytes.Equal(path.Leaf.Sibling, nodeKey[:]) {
+				t.Fatal("missing account has a leaf")
+			}
+			continue
+		}
+		if path.Leaf == nil || !bytes.Equal(path.Leaf.Sibling, nodeKey[:]) {
+			t.Fatalf("account %d: leaf mismatch: %v", i, path.Leaf)
+		}
+		mask := new(big.Int).Lsh(big.NewInt(1), uint(len(path.Path)))
+		if want := new(big.Int).Mod(key, mask); path.KeyPathPart.ToInt().Cmp(want) != 0 {
+			t.Fatalf("account %d: path part mismatch, have %v, want %v", i, path.KeyPathPart, want)
+		}
+	}
+	if _, err := NewSMTPath(nil); err == nil {
+		t.Fatal("empty proof decoded")
+	}
+}